@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"time"
@@ -30,7 +31,11 @@ type MongoDBConfig struct {
 	// Advanced options
 	ReplicaSet string // Optional replica set name
 	TLS        bool
-	TLSCAFile  string // Path to CA certificate file
+	TLSCAFile  string      // Path to CA certificate file
+	TLSConfig  *tls.Config // Overrides the default TLS settings when TLS is on
+
+	// Dialer opens the network connections, e.g. through an SSH tunnel
+	Dialer options.ContextDialer
 
 	// Connection settings
 	Timeout         int // Connection timeout in seconds
@@ -143,9 +148,11 @@ func (c *MongoDBConnector) Connect() error {
 	clientOpts.SetServerSelectionTimeout(time.Duration(c.config.Timeout) * time.Second)
 
 	// TLS configuration
-	if c.config.TLS && c.config.TLSCAFile != "" {
-		// Note: Advanced TLS config with CA file would go here
-		// For now, basic TLS is handled in connection string
+	if c.config.TLS && c.config.TLSConfig != nil {
+		clientOpts.SetTLSConfig(c.config.TLSConfig)
+	}
+	if c.config.Dialer != nil {
+		clientOpts.SetDialer(c.config.Dialer)
 	}
 
 	// Create context with timeout
@@ -3,8 +3,10 @@ package handlers
 import (
 	"insight-engine-backend/database"
 	"insight-engine-backend/models"
+	"insight-engine-backend/services"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 // GetAlerts returns a list of alerts
@@ -46,6 +48,21 @@ func CreateAlert(c *fiber.Ctx) error {
 		})
 	}
 
+	// Reject alerts the scheduler could never evaluate
+	if err := services.ValidateAlert(alert); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid alert",
+			"error":   err.Error(),
+		})
+	}
+
+	alert.ID = uuid.New().String()
+	alert.UserId = c.Locals("userID").(string)
+	alert.LastRunAt = nil
+	alert.LastStatus = nil
+	alert.TriggeredAt = nil
+
 	result := database.DB.Create(&alert)
 	if result.Error != nil {
 		return c.Status(500).JSON(fiber.Map{
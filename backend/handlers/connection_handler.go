@@ -125,6 +125,15 @@ func (h *ConnectionHandler) CreateConnection(c *fiber.Ctx) error {
 		conn.Password = &encryptedPassword
 	}
 
+	// Encrypt TLS keys and SSH credentials
+	if err := services.EncryptConnectionSecrets(h.encryptionService, conn, nil); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to encrypt connection secrets",
+			"error":   err.Error(),
+		})
+	}
+
 	result := database.DB.Create(&conn)
 	if result.Error != nil {
 		return c.Status(500).JSON(fiber.Map{
@@ -178,6 +187,15 @@ func (h *ConnectionHandler) UpdateConnection(c *fiber.Ctx) error {
 		updates.Password = &encryptedPassword
 	}
 
+	// Encrypt TLS keys and SSH credentials, keeping stored ones that were left out
+	if err := services.EncryptConnectionSecrets(h.encryptionService, updates, &existing); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to encrypt connection secrets",
+			"error":   err.Error(),
+		})
+	}
+
 	// Apply updates
 	if err := database.DB.Model(&existing).Updates(updates).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
@@ -187,6 +205,9 @@ func (h *ConnectionHandler) UpdateConnection(c *fiber.Ctx) error {
 		})
 	}
 
+	// Reconnect with the new settings on next use
+	h.queryExecutor.InvalidateConnection(connID)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    existing.ToDTO(),
@@ -214,6 +235,8 @@ func (h *ConnectionHandler) DeleteConnection(c *fiber.Ctx) error {
 		})
 	}
 
+	h.queryExecutor.InvalidateConnection(connID)
+
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "Connection deleted",
@@ -233,22 +256,9 @@ func (h *ConnectionHandler) TestConnection(c *fiber.Ctx) error {
 		})
 	}
 
-	// Decrypt password for connection test
-	if h.encryptionService != nil && conn.Password != nil && *conn.Password != "" {
-		decryptedPassword, err := h.encryptionService.Decrypt(*conn.Password)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Failed to decrypt password",
-				"error":   err.Error(),
-			})
-		}
-		conn.Password = &decryptedPassword
-	}
-
 	// Test connection using query executor
 	ctx := c.Context()
-	_, err := h.queryExecutor.Execute(ctx, &conn, "SELECT 1", nil, nil)
+	err := h.queryExecutor.TestConnection(ctx, &conn)
 
 	if err != nil {
 		return c.JSON(fiber.Map{
@@ -277,19 +287,6 @@ func (h *ConnectionHandler) GetConnectionSchema(c *fiber.Ctx) error {
 		})
 	}
 
-	// Decrypt password for schema discovery
-	if h.encryptionService != nil && conn.Password != nil && *conn.Password != "" {
-		decryptedPassword, err := h.encryptionService.Decrypt(*conn.Password)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Failed to decrypt password",
-				"error":   err.Error(),
-			})
-		}
-		conn.Password = &decryptedPassword
-	}
-
 	// Discover schema
 	ctx := c.Context()
 	schema, err := h.schemaDiscovery.DiscoverSchema(ctx, &conn)
@@ -307,3 +304,13 @@ func (h *ConnectionHandler) GetConnectionSchema(c *fiber.Ctx) error {
 		"data":    schema,
 	})
 }
+
+// GetPoolStats returns live statistics of the pooled database connections (admin only)
+func (h *ConnectionHandler) GetPoolStats(c *fiber.Ctx) error {
+	stats := h.queryExecutor.PoolStats()
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    stats,
+		"count":   len(stats),
+	})
+}
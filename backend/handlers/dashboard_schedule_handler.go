@@ -3,8 +3,10 @@ package handlers
 import (
 	"insight-engine-backend/database"
 	"insight-engine-backend/models"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 // CreateSchedule creates a report schedule for a dashboard
@@ -40,19 +42,14 @@ func CreateSchedule(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Frequency != "DAILY" && req.Frequency != "WEEKLY" && req.Frequency != "MONTHLY" {
+	if msg := validateScheduleFields(req.Frequency, req.Format); msg != "" {
 		return c.Status(400).JSON(fiber.Map{
-			"error": "Frequency must be DAILY, WEEKLY, or MONTHLY",
-		})
-	}
-
-	if req.Format != "PDF" && req.Format != "PNG" {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Format must be PDF or PNG",
+			"error": msg,
 		})
 	}
 
 	schedule := models.ReportSchedule{
+		ID:          uuid.New().String(),
 		DashboardID: dashboardID,
 		Frequency:   req.Frequency,
 		Email:       req.Email,
@@ -69,3 +66,204 @@ func CreateSchedule(c *fiber.Ctx) error {
 
 	return c.JSON(schedule)
 }
+
+// GetSchedules lists the report schedules of a dashboard
+func GetSchedules(c *fiber.Ctx) error {
+	dashboardID := c.Params("id")
+	userID, _ := c.Locals("userId").(string)
+
+	// Verify dashboard ownership
+	var dashboard models.Dashboard
+	if err := database.DB.Where("id = ? AND user_id = ?", dashboardID, userID).First(&dashboard).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Dashboard not found",
+		})
+	}
+
+	var schedules []models.ReportSchedule
+	if err := database.DB.Where("dashboard_id = ?", dashboardID).Order("created_at DESC").Find(&schedules).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch schedules: " + err.Error(),
+		})
+	}
+
+	return c.JSON(schedules)
+}
+
+// UpdateSchedule updates the frequency, recipients, format or active state of a report schedule
+func UpdateSchedule(c *fiber.Ctx) error {
+	schedule, err := findOwnedSchedule(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Schedule not found",
+		})
+	}
+
+	type UpdateScheduleRequest struct {
+		Frequency *string `json:"frequency"`
+		Email     *string `json:"email"`
+		Format    *string `json:"format"`
+		IsActive  *bool   `json:"isActive"`
+	}
+
+	req := new(UpdateScheduleRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid input: " + err.Error(),
+		})
+	}
+
+	// Update allowed fields (explicit mapping to prevent mass assignment)
+	frequencyChanged := false
+	if req.Frequency != nil && *req.Frequency != schedule.Frequency {
+		schedule.Frequency = *req.Frequency
+		frequencyChanged = true
+	}
+	if req.Email != nil {
+		if strings.TrimSpace(*req.Email) == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Email cannot be empty",
+			})
+		}
+		schedule.Email = *req.Email
+	}
+	if req.Format != nil {
+		schedule.Format = *req.Format
+	}
+	if msg := validateScheduleFields(schedule.Frequency, schedule.Format); msg != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	resumed := req.IsActive != nil && *req.IsActive && !schedule.IsActive
+	if req.IsActive != nil {
+		schedule.IsActive = *req.IsActive
+	}
+
+	// A new frequency or a resumed schedule starts from the next regular run
+	if frequencyChanged || resumed {
+		schedule.NextRunAt = models.CalculateNextRun(schedule.Frequency)
+		schedule.Attempts = 0
+	}
+
+	if err := database.DB.Save(schedule).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update schedule: " + err.Error(),
+		})
+	}
+
+	return c.JSON(schedule)
+}
+
+// PauseSchedule stops delivering a report schedule until it is resumed
+func PauseSchedule(c *fiber.Ctx) error {
+	return setScheduleActive(c, false)
+}
+
+// ResumeSchedule resumes a paused report schedule from its next regular run
+func ResumeSchedule(c *fiber.Ctx) error {
+	return setScheduleActive(c, true)
+}
+
+// DeleteSchedule deletes a report schedule and its delivery history
+func DeleteSchedule(c *fiber.Ctx) error {
+	schedule, err := findOwnedSchedule(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Schedule not found",
+		})
+	}
+
+	if err := database.DB.Where("schedule_id = ?", schedule.ID).Delete(&models.ReportDelivery{}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete delivery history: " + err.Error(),
+		})
+	}
+	if err := database.DB.Delete(schedule).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete schedule: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": "Schedule deleted successfully"})
+}
+
+// GetScheduleDeliveries returns the delivery history of a report schedule, newest first
+func GetScheduleDeliveries(c *fiber.Ctx) error {
+	schedule, err := findOwnedSchedule(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Schedule not found",
+		})
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	var deliveries []models.ReportDelivery
+	if err := database.DB.Where("schedule_id = ?", schedule.ID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch deliveries: " + err.Error(),
+		})
+	}
+
+	return c.JSON(deliveries)
+}
+
+// setScheduleActive pauses or resumes a report schedule
+func setScheduleActive(c *fiber.Ctx, active bool) error {
+	schedule, err := findOwnedSchedule(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Schedule not found",
+		})
+	}
+
+	if schedule.IsActive != active {
+		schedule.IsActive = active
+		if active {
+			schedule.NextRunAt = models.CalculateNextRun(schedule.Frequency)
+			schedule.Attempts = 0
+		}
+		if err := database.DB.Save(schedule).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to update schedule: " + err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(schedule)
+}
+
+// findOwnedSchedule loads the :scheduleId schedule of the :id dashboard owned by the current user
+func findOwnedSchedule(c *fiber.Ctx) (*models.ReportSchedule, error) {
+	userID, _ := c.Locals("userId").(string)
+
+	var dashboard models.Dashboard
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&dashboard).Error; err != nil {
+		return nil, err
+	}
+
+	var schedule models.ReportSchedule
+	if err := database.DB.Where("id = ? AND dashboard_id = ?", c.Params("scheduleId"), dashboard.ID).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// validateScheduleFields returns an error message for an invalid frequency or format
+func validateScheduleFields(frequency, format string) string {
+	if frequency != "DAILY" && frequency != "WEEKLY" && frequency != "MONTHLY" {
+		return "Frequency must be DAILY, WEEKLY, or MONTHLY"
+	}
+	if format != "PDF" && format != "PNG" {
+		return "Format must be PDF or PNG"
+	}
+	return ""
+}
@@ -171,6 +171,12 @@ func RunDataflow(c *fiber.Ctx) error {
 		return c.Status(403).JSON(fiber.Map{"error": "Access denied"})
 	}
 
+	var stepCount int64
+	database.DB.Model(&models.DataflowStep{}).Where(&models.DataflowStep{DataflowID: dataflowID}).Count(&stepCount)
+	if stepCount == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Dataflow has no steps"})
+	}
+
 	// Create dataflow run record
 	run := models.DataflowRun{
 		ID:         uuid.New().String(),
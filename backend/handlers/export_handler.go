@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"insight-engine-backend/database"
 	"insight-engine-backend/services"
 	"os"
@@ -66,6 +67,12 @@ func (h *ExportHandler) CreateExport(c *fiber.Ctx) error {
 	// Create export job
 	job, err := h.exportService.CreateExportJob(c.Context(), dashID, userID, &options)
 	if err != nil {
+		if errors.Is(err, services.ErrExportTooLarge) {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"error":   err.Error(),
+			})
+		}
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"error":   "Failed to create export job: " + err.Error(),
@@ -240,7 +247,7 @@ func RegisterExportRoutes(app *fiber.App, exportService *services.ExportService)
  *    ```go
  *    exportDir := "./exports"
  *    baseURL := os.Getenv("BASE_URL") // e.g., "http://localhost:8080"
- *    exportService := services.NewExportService(database.DB, exportDir, baseURL)
+ *    exportService := services.NewExportService(database.DB, queryExecutor, exportDir, baseURL)
  *    handlers.RegisterExportRoutes(app, exportService)
  *    ```
  *
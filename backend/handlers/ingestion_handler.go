@@ -1,27 +1,47 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"insight-engine-backend/database"
 	"insight-engine-backend/models"
+	"insight-engine-backend/services"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// IngestionHandler handles loading external data into workspace tables
+type IngestionHandler struct {
+	service *services.IngestionService
+}
+
+// NewIngestionHandler creates a new ingestion handler
+func NewIngestionHandler(service *services.IngestionService) *IngestionHandler {
+	return &IngestionHandler{service: service}
+}
+
+// ingestInput is the request of both ingestion endpoints. It is sent as JSON, or as
+// multipart/form-data with a "file" part and sourceConfig as a JSON string.
+type ingestInput struct {
+	WorkspaceID  string                 `json:"workspaceId"`
+	SourceType   string                 `json:"sourceType"` // CSV, EXCEL, JSON, API, DATABASE
+	SourceConfig map[string]interface{} `json:"sourceConfig"`
+	TargetTable  string                 `json:"targetTable"`
+	Mode         string                 `json:"mode"`  // OVERWRITE, APPEND
+	Limit        int                    `json:"limit"` // Number of rows to preview (default 10, at most 1000)
+}
+
 // IngestData handles data ingestion from various sources
-func IngestData(c *fiber.Ctx) error {
+func (h *IngestionHandler) IngestData(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(string)
 
-	var input struct {
-		WorkspaceID  string                 `json:"workspaceId"`
-		SourceType   string                 `json:"sourceType"` // CSV, JSON, API, DATABASE
-		SourceConfig map[string]interface{} `json:"sourceConfig"`
-		TargetTable  string                 `json:"targetTable"`
-		Mode         string                 `json:"mode"` // OVERWRITE, APPEND
-	}
-
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	input, req, cleanup, err := parseIngestRequest(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
+	defer cleanup()
 
 	// Verify workspace access (ADMIN, OWNER, EDITOR only)
 	var membership models.WorkspaceMember
@@ -33,30 +53,33 @@ func IngestData(c *fiber.Ctx) error {
 		return c.Status(403).JSON(fiber.Map{"error": "Insufficient permissions"})
 	}
 
-	// Data ingestion feature not yet implemented
-	// This endpoint is reserved for future CSV/JSON/API/Database ingestion capabilities
-	// For now, return 501 Not Implemented per HTTP standards
-	return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
-		"error":   "Feature not implemented",
-		"message": "Data ingestion is planned for a future release. Currently supports direct database connections via Connection Manager.",
-		"status":  501,
-	})
+	if input.TargetTable == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "targetTable is required"})
+	}
+
+	req.UserID = userID
+	result, err := h.service.Ingest(c.Context(), req)
+	if err != nil {
+		services.LogError("ingest_failed", "Data ingestion failed", map[string]interface{}{
+			"workspace_id": input.WorkspaceID,
+			"source_type":  input.SourceType,
+			"error":        err,
+		})
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(result)
 }
 
 // PreviewIngest previews data before ingestion
-func PreviewIngest(c *fiber.Ctx) error {
+func (h *IngestionHandler) PreviewIngest(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(string)
 
-	var input struct {
-		WorkspaceID  string                 `json:"workspaceId"`
-		SourceType   string                 `json:"sourceType"` // CSV, JSON, API, DATABASE
-		SourceConfig map[string]interface{} `json:"sourceConfig"`
-		Limit        int                    `json:"limit"` // Number of rows to preview
-	}
-
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	input, req, cleanup, err := parseIngestRequest(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
+	defer cleanup()
 
 	// Verify workspace access
 	var membership models.WorkspaceMember
@@ -64,15 +87,66 @@ func PreviewIngest(c *fiber.Ctx) error {
 		return c.Status(403).JSON(fiber.Map{"error": "Access denied to workspace"})
 	}
 
-	if input.Limit == 0 {
-		input.Limit = 10
+	req.UserID = userID
+	preview, err := h.service.Preview(c.Context(), req, input.Limit)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(preview)
+}
+
+// parseIngestRequest reads a JSON or multipart ingestion request.
+// The returned cleanup closes the uploaded file, if any.
+func parseIngestRequest(c *fiber.Ctx) (*ingestInput, *services.IngestionRequest, func(), error) {
+	var input ingestInput
+	cleanup := func() {}
+
+	req := &services.IngestionRequest{}
+
+	if strings.HasPrefix(string(c.Request().Header.ContentType()), fiber.MIMEMultipartForm) {
+		input.WorkspaceID = c.FormValue("workspaceId")
+		input.SourceType = c.FormValue("sourceType")
+		input.TargetTable = c.FormValue("targetTable")
+		input.Mode = c.FormValue("mode")
+		if limit := c.FormValue("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil {
+				return nil, nil, cleanup, fmt.Errorf("limit must be a number")
+			}
+			input.Limit = n
+		}
+		if raw := c.FormValue("sourceConfig"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &input.SourceConfig); err != nil {
+				return nil, nil, cleanup, fmt.Errorf("sourceConfig must be a JSON object")
+			}
+		}
+
+		if fileHeader, err := c.FormFile("file"); err == nil {
+			file, err := fileHeader.Open()
+			if err != nil {
+				return nil, nil, cleanup, fmt.Errorf("failed to read uploaded file")
+			}
+			cleanup = func() { file.Close() }
+			req.File = file
+			req.FileName = fileHeader.Filename
+		}
+	} else if err := c.BodyParser(&input); err != nil {
+		return nil, nil, cleanup, fmt.Errorf("Invalid request body")
+	}
+
+	if input.WorkspaceID == "" {
+		return nil, nil, cleanup, fmt.Errorf("workspaceId is required")
+	}
+	if input.SourceConfig == nil {
+		input.SourceConfig = map[string]interface{}{}
 	}
 
-	// Data ingestion preview not yet implemented
-	// This endpoint is reserved for future data preview capabilities
-	return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
-		"error":   "Feature not implemented",
-		"message": "Data ingestion preview is planned for a future release.",
-		"status":  501,
-	})
+	req.WorkspaceID = input.WorkspaceID
+	req.SourceType = input.SourceType
+	req.SourceConfig = input.SourceConfig
+	req.TargetTable = input.TargetTable
+	req.Mode = input.Mode
+
+	return &input, req, cleanup, nil
 }
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"insight-engine-backend/models"
 	"insight-engine-backend/services"
 
@@ -141,7 +142,11 @@ func (h *ModelingHandler) UpdateModelDefinition(c *fiber.Ctx) error {
 	}
 
 	// Update model
-	if err := h.service.UpdateModelDefinition(existing); err != nil {
+	if err := h.service.UpdateModelDefinition(existing); errors.Is(err, services.ErrDefinitionMigrated) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	} else if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -313,7 +318,11 @@ func (h *ModelingHandler) UpdateMetricDefinition(c *fiber.Ctx) error {
 	}
 
 	// Update metric
-	if err := h.service.UpdateMetricDefinition(existing); err != nil {
+	if err := h.service.UpdateMetricDefinition(existing); errors.Is(err, services.ErrDefinitionMigrated) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	} else if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -325,7 +334,11 @@ func (h *ModelingHandler) UpdateMetricDefinition(c *fiber.Ctx) error {
 func (h *ModelingHandler) DeleteMetricDefinition(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	if err := h.service.DeleteMetricDefinition(id); err != nil {
+	if err := h.service.DeleteMetricDefinition(id); errors.Is(err, services.ErrMetricInUse) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	} else if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete metric definition",
 		})
@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"insight-engine-backend/database"
 	"insight-engine-backend/models"
 	"insight-engine-backend/services"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // GetPipelines returns all pipelines for a workspace (with optional pagination)
@@ -81,6 +84,15 @@ func GetPipeline(c *fiber.Ctx) error {
 		return c.Status(403).JSON(fiber.Map{"error": "Access denied"})
 	}
 
+	// Summarise the latest run's quality checks; the full history is at /pipelines/:id/quality
+	var latest models.QualityCheckResult
+	if err := database.DB.Where("pipeline_id = ?", pipelineID).Order("created_at DESC").First(&latest).Error; err == nil {
+		if err := database.DB.Where("execution_id = ?", latest.ExecutionID).Order("created_at ASC").
+			Find(&pipeline.LatestQualityResults).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
 	return c.JSON(pipeline)
 }
 
@@ -98,7 +110,7 @@ func CreatePipeline(c *fiber.Ctx) error {
 		DestinationConfig   map[string]interface{} `json:"destinationConfig"`
 		Mode                string                 `json:"mode"`
 		TransformationSteps []interface{}          `json:"transformationSteps"`
-		QualityRules        []interface{}          `json:"qualityRules"`
+		QualityRules        []qualityRuleInput     `json:"qualityRules"`
 		ScheduleCron        *string                `json:"scheduleCron"`
 	}
 
@@ -142,8 +154,14 @@ func CreatePipeline(c *fiber.Ctx) error {
 		transformationStepsStr = &str
 	}
 
+	pipelineID := uuid.New().String()
+	qualityRules, err := buildQualityRules(pipelineID, input.QualityRules)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	pipeline := models.Pipeline{
-		ID:                  uuid.New().String(),
+		ID:                  pipelineID,
 		Name:                input.Name,
 		Description:         input.Description,
 		WorkspaceID:         input.WorkspaceID,
@@ -155,10 +173,12 @@ func CreatePipeline(c *fiber.Ctx) error {
 		TransformationSteps: transformationStepsStr,
 		ScheduleCron:        input.ScheduleCron,
 		IsActive:            true,
+		QualityRules:        qualityRules,
 		CreatedAt:           time.Now(),
 		UpdatedAt:           time.Now(),
 	}
 
+	// Creates the quality rules in the same transaction
 	if err := database.DB.Create(&pipeline).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -195,6 +215,7 @@ func UpdatePipeline(c *fiber.Ctx) error {
 		DestinationConfig   map[string]interface{} `json:"destinationConfig"`
 		Mode                *string                `json:"mode"`
 		TransformationSteps []interface{}          `json:"transformationSteps"`
+		QualityRules        []qualityRuleInput     `json:"qualityRules"`
 		ScheduleCron        *string                `json:"scheduleCron"`
 		IsActive            *bool                  `json:"isActive"`
 	}
@@ -240,12 +261,38 @@ func UpdatePipeline(c *fiber.Ctx) error {
 	}
 	updates["updated_at"] = time.Now()
 
-	if err := database.DB.Model(&pipeline).Updates(updates).Error; err != nil {
+	var qualityRules []models.QualityRule
+	if input.QualityRules != nil {
+		rules, err := buildQualityRules(pipelineID, input.QualityRules)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		qualityRules = rules
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&pipeline).Updates(updates).Error; err != nil {
+			return err
+		}
+		if input.QualityRules == nil {
+			return nil
+		}
+
+		// The submitted list replaces the pipeline's rules
+		if err := tx.Where(&models.QualityRule{PipelineID: pipelineID}).Delete(&models.QualityRule{}).Error; err != nil {
+			return err
+		}
+		if len(qualityRules) == 0 {
+			return nil
+		}
+		return tx.Create(&qualityRules).Error
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	// Reload to get updated data
-	database.DB.First(&pipeline, "id = ?", pipelineID)
+	database.DB.Preload("QualityRules").First(&pipeline, "id = ?", pipelineID)
 
 	return c.JSON(pipeline)
 }
@@ -319,6 +366,92 @@ func RunPipeline(c *fiber.Ctx) error {
 	return c.Status(201).JSON(execution)
 }
 
+// GetPipelineQuality returns the per-rule quality check results of a pipeline's runs, oldest first
+func GetPipelineQuality(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	pipelineID := c.Params("id")
+
+	var pipeline models.Pipeline
+	if err := database.DB.Preload("QualityRules").First(&pipeline, "id = ?", pipelineID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Pipeline not found"})
+	}
+
+	// Verify workspace access
+	var membership models.WorkspaceMember
+	if err := database.DB.Where("workspace_id = ? AND user_id = ?", pipeline.WorkspaceID, userID).First(&membership).Error; err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Access denied"})
+	}
+
+	query := database.DB.Where("pipeline_id = ?", pipelineID)
+	if ruleID := c.Query("ruleId"); ruleID != "" {
+		query = query.Where("rule_id = ?", ruleID)
+	}
+	if since := c.Query("since"); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "since must be an RFC3339 timestamp"})
+		}
+		query = query.Where("created_at >= ?", sinceTime)
+	}
+
+	// Most recent results, returned in chronological order for charting
+	limit := c.QueryInt("limit", 500)
+	if limit < 1 {
+		limit = 1
+	} else if limit > 1000 {
+		limit = 1000
+	}
+	var results []models.QualityCheckResult
+	if err := query.Order("created_at DESC").Limit(limit).Find(&results).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+		results[i], results[j] = results[j], results[i]
+	}
+
+	return c.JSON(fiber.Map{
+		"rules":   pipeline.QualityRules,
+		"results": results,
+	})
+}
+
+// qualityRuleInput is a quality rule as submitted with a pipeline
+type qualityRuleInput struct {
+	Column      string  `json:"column"`
+	RuleType    string  `json:"ruleType"`
+	Value       *string `json:"value"`
+	Severity    string  `json:"severity"`
+	Description *string `json:"description"`
+}
+
+// buildQualityRules validates submitted quality rules and converts them to models
+func buildQualityRules(pipelineID string, inputs []qualityRuleInput) ([]models.QualityRule, error) {
+	rules := make([]models.QualityRule, 0, len(inputs))
+	for _, input := range inputs {
+		severity := strings.ToUpper(input.Severity)
+		if severity == "" {
+			severity = services.QualitySeverityWarn
+		}
+
+		rule := models.QualityRule{
+			ID:          uuid.New().String(),
+			PipelineID:  pipelineID,
+			Column:      input.Column,
+			RuleType:    strings.ToUpper(input.RuleType),
+			Value:       input.Value,
+			Severity:    severity,
+			Description: input.Description,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		if err := services.ValidateQualityRule(rule); err != nil {
+			return nil, fmt.Errorf("invalid quality rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
 // GetPipelineStats returns pipeline statistics for a workspace
 func GetPipelineStats(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(string)
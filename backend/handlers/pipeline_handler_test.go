@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"insight-engine-backend/database"
+	"insight-engine-backend/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// TestGetPipeline_LatestQualityResults tests that a pipeline is returned with the quality
+// results of its latest checked run only
+func TestGetPipeline_LatestQualityResults(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.WorkspaceMember{}, &models.Pipeline{}, &models.QualityRule{}, &models.QualityCheckResult{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+
+	db.Create(&models.WorkspaceMember{ID: "m1", WorkspaceID: "ws-1", UserID: "user-1", Role: models.RoleViewer})
+	db.Create(&models.Pipeline{ID: "p1", Name: "orders", WorkspaceID: "ws-1", SourceType: "CSV", SourceConfig: "{}"})
+	older := time.Now().Add(-time.Hour)
+	db.Create(&models.QualityCheckResult{ID: "r1", PipelineID: "p1", ExecutionID: "run-1", RuleID: "rule-1", Column: "id", RuleType: "NOT_NULL", Severity: "FAIL", CreatedAt: older})
+	db.Create(&models.QualityCheckResult{ID: "r2", PipelineID: "p1", ExecutionID: "run-2", RuleID: "rule-1", Column: "id", RuleType: "NOT_NULL", Severity: "FAIL", Passed: true, CreatedAt: time.Now()})
+	db.Create(&models.QualityCheckResult{ID: "r3", PipelineID: "p1", ExecutionID: "run-2", RuleID: "rule-2", Column: "email", RuleType: "UNIQUE", Severity: "WARN", RowsFailed: 2, CreatedAt: time.Now()})
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", "user-1")
+		return c.Next()
+	})
+	app.Get("/pipelines/:id", GetPipeline)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/pipelines/p1", nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	var pipeline models.Pipeline
+	if err := json.NewDecoder(resp.Body).Decode(&pipeline); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(pipeline.LatestQualityResults) != 2 {
+		t.Fatalf("Expected the 2 results of the latest run, got %+v", pipeline.LatestQualityResults)
+	}
+	for _, result := range pipeline.LatestQualityResults {
+		if result.ExecutionID != "run-2" {
+			t.Errorf("Expected results of run-2, got %s", result.ExecutionID)
+		}
+	}
+}
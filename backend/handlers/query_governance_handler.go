@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"insight-engine-backend/models"
+	"insight-engine-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// QueryGovernanceHandler manages governance policies and the queued and running queries
+type QueryGovernanceHandler struct {
+	governor *services.QueryGovernor
+}
+
+// NewQueryGovernanceHandler creates a new query governance handler
+func NewQueryGovernanceHandler(governor *services.QueryGovernor) *QueryGovernanceHandler {
+	return &QueryGovernanceHandler{
+		governor: governor,
+	}
+}
+
+// ListPolicies returns all governance policies
+// GET /api/admin/query-policies
+func (h *QueryGovernanceHandler) ListPolicies(c *fiber.Ctx) error {
+	policies, err := h.governor.ListPolicies()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch query policies",
+			"error":   err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    policies,
+	})
+}
+
+// CreatePolicy creates a governance policy
+// POST /api/admin/query-policies
+func (h *QueryGovernanceHandler) CreatePolicy(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	policy := new(models.QueryGovernancePolicy)
+	policy.Enabled = true
+	if err := c.BodyParser(policy); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"error":   err.Error(),
+		})
+	}
+	policy.ID = ""
+	policy.CreatedBy = userID
+
+	if err := h.governor.SavePolicy(policy); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	services.LogInfo("query_policy_create", "Query governance policy created", map[string]interface{}{"policy_id": policy.ID, "policy_name": policy.Name})
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"data":    policy,
+	})
+}
+
+// UpdatePolicy replaces the limits of a governance policy
+// PUT /api/admin/query-policies/:id
+func (h *QueryGovernanceHandler) UpdatePolicy(c *fiber.Ctx) error {
+	existing, err := h.governor.GetPolicy(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Query policy not found",
+		})
+	}
+
+	policy := *existing
+	if err := c.BodyParser(&policy); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"error":   err.Error(),
+		})
+	}
+	policy.ID = existing.ID
+	policy.CreatedBy = existing.CreatedBy
+	policy.CreatedAt = existing.CreatedAt
+
+	if err := h.governor.SavePolicy(&policy); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	services.LogInfo("query_policy_update", "Query governance policy updated", map[string]interface{}{"policy_id": policy.ID, "policy_name": policy.Name})
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    policy,
+	})
+}
+
+// DeletePolicy deletes a governance policy
+// DELETE /api/admin/query-policies/:id
+func (h *QueryGovernanceHandler) DeletePolicy(c *fiber.Ctx) error {
+	if err := h.governor.DeletePolicy(c.Params("id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Query policy not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to delete query policy",
+			"error":   err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Query policy deleted",
+	})
+}
+
+// ListActiveQueries returns the caller's queued and running queries; admins see everyone's
+// GET /api/queries/active
+func (h *QueryGovernanceHandler) ListActiveQueries(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	queries := h.governor.Queries(userID, h.governor.IsAdmin(userID))
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    queries,
+		"count":   len(queries),
+	})
+}
+
+// CancelQuery cancels a queued or running query of the caller; admins may cancel any
+// DELETE /api/queries/active/:id
+func (h *QueryGovernanceHandler) CancelQuery(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	if err := h.governor.Cancel(c.Params("id"), userID, h.governor.IsAdmin(userID)); err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Query not found",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Query cancelled",
+	})
+}
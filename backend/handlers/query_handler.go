@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"insight-engine-backend/database"
 	"insight-engine-backend/models"
 	"insight-engine-backend/services"
@@ -198,16 +202,69 @@ func (h *QueryHandler) RunQuery(c *fiber.Ctx) error {
 
 	// Parse request body for limit/offset
 	type RunParams struct {
-		Limit  *int `json:"limit"`
-		Offset *int `json:"offset"`
+		Limit       *int   `json:"limit"`
+		Offset      *int   `json:"offset"`
+		ExecutionID string `json:"executionId"`
 	}
 	params := new(RunParams)
 	_ = c.BodyParser(params)
 
+	executionID, err := queryExecutionID(c, params.ExecutionID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid execution ID",
+			"error":   err.Error(),
+		})
+	}
+
 	// Execute query
-	ctx := context.Background()
+	ctx := services.WithQueryExecutionID(services.WithQueryUser(context.Background(), userID), executionID)
+	ctx = services.WithQuerySource(ctx, services.QuerySourceSaved, query.ID)
 	result, err := h.queryExecutor.Execute(ctx, query.Connection, query.SQL, params.Limit, params.Offset)
 
+	if err != nil {
+		return c.Status(queryErrorStatus(err)).JSON(fiber.Map{
+			"status":      "error",
+			"message":     "Query execution failed",
+			"error":       err.Error(),
+			"executionId": executionID,
+		})
+	}
+	result.ExecutionID = executionID
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// ExportQuery streams a saved query's full result as a download
+// GET /api/queries/:id/export?format=csv|xlsx|parquet|ndjson
+func (h *QueryHandler) ExportQuery(c *fiber.Ctx) error {
+	queryID := c.Params("id")
+	userID, _ := c.Locals("userId").(string)
+
+	format, err := services.ParseResultFormat(c.Query("format"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	var query models.SavedQuery
+	if err := database.DB.Where("id = ? AND user_id = ?", queryID, userID).
+		Preload("Connection").
+		First(&query).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Query not found",
+		})
+	}
+
+	ctx := services.WithQuerySource(services.WithQueryUser(context.Background(), userID), services.QuerySourceExport, query.ID)
+	stream, err := h.queryExecutor.Stream(ctx, query.Connection, query.SQL)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
@@ -216,10 +273,151 @@ func (h *QueryHandler) RunQuery(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    result,
+	return streamResultExport(c, stream, format, services.ResultFileName(query.Name, format))
+}
+
+// streamResultExport writes an open result set to the response in format as an attachment.
+// Rows are encoded as they are read, so the result is never held in memory.
+func streamResultExport(c *fiber.Ctx, stream *services.QueryStream, format services.ResultFormat, filename string) error {
+	c.Set("Content-Type", format.ContentType())
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Set("Cache-Control", "no-store")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer stream.Close()
+		rows, err := services.ExportQueryStream(stream, format, w)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			// Headers are already sent, so the client sees a truncated file
+			services.LogError("query_export_failed", "Query result export failed", map[string]interface{}{
+				"format": format,
+				"rows":   rows,
+				"error":  err,
+			})
+			return
+		}
+		services.LogInfo("query_exported", "Query result exported", map[string]interface{}{"format": format, "rows": rows})
 	})
+	return nil
+}
+
+// streamQueryRequest selects the page of a streamed query and the rows per chunk
+type streamQueryRequest struct {
+	services.QueryPage
+	SQL          string `json:"sql"`
+	ConnectionID string `json:"connectionId"`
+	ChunkSize    int    `json:"chunkSize"`
+	ExecutionID  string `json:"executionId"`
+	Source       string `json:"source"` // "ai" for generated SQL; ad-hoc otherwise
+}
+
+// StreamQuery executes a saved query and streams its rows as chunked JSON lines
+// POST /api/queries/:id/stream
+func (h *QueryHandler) StreamQuery(c *fiber.Ctx) error {
+	queryID := c.Params("id")
+	userID, _ := c.Locals("userId").(string)
+
+	var query models.SavedQuery
+	if err := database.DB.Where("id = ? AND user_id = ?", queryID, userID).
+		Preload("Connection").
+		First(&query).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Query not found",
+		})
+	}
+
+	req := new(streamQueryRequest)
+	_ = c.BodyParser(req)
+
+	return h.streamQuery(c, query.Connection, query.SQL, req, query.ID)
+}
+
+// StreamAdHocQuery executes a query without saving it and streams its rows as chunked JSON lines
+// POST /api/queries/execute/stream
+func (h *QueryHandler) StreamAdHocQuery(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	req := new(streamQueryRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"error":   err.Error(),
+		})
+	}
+	if req.SQL == "" || req.ConnectionID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "SQL and ConnectionID are required",
+		})
+	}
+
+	var conn models.Connection
+	if err := database.DB.Where("id = ? AND user_id = ?", req.ConnectionID, userID).First(&conn).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Connection not found",
+		})
+	}
+
+	return h.streamQuery(c, &conn, req.SQL, req, "")
+}
+
+// streamQuery opens the query and writes one JSON object per line: the columns, chunks of
+// rows as they are read, and a final "done" (with the next keyset cursor) or "error" line
+func (h *QueryHandler) streamQuery(c *fiber.Ctx, conn *models.Connection, sql string, req *streamQueryRequest, queryID string) error {
+	userID, _ := c.Locals("userId").(string)
+	executionID, err := queryExecutionID(c, req.ExecutionID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid execution ID",
+			"error":   err.Error(),
+		})
+	}
+
+	source := queryRequestSource(req.Source)
+	if queryID != "" {
+		source = services.QuerySourceSaved
+	}
+	ctx := services.WithQueryExecutionID(services.WithQueryUser(context.Background(), userID), executionID)
+	ctx = services.WithQuerySource(ctx, source, queryID)
+	stream, err := h.queryExecutor.StreamPage(ctx, conn, sql, req.QueryPage)
+	if err != nil {
+		return c.Status(queryErrorStatus(err)).JSON(fiber.Map{
+			"status":      "error",
+			"message":     "Query execution failed",
+			"error":       err.Error(),
+			"executionId": executionID,
+		})
+	}
+
+	c.Set("Content-Type", "application/x-ndjson")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("X-Execution-ID", executionID)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer stream.Close()
+		encoder := json.NewEncoder(w)
+		err := services.PumpQueryStream(stream, req.ChunkSize, func(event *services.QueryStreamEvent) error {
+			event.ExecutionID = executionID
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+			return w.Flush()
+		})
+		if err != nil {
+			services.LogWarn("query_stream_failed", "Streaming query ended early", map[string]interface{}{
+				"connection_id": conn.ID,
+				"rows":          stream.RowsRead(),
+				"error":         err,
+			})
+		}
+	})
+	return nil
 }
 
 // ExecuteAdHocQuery executes a query without saving it
@@ -252,20 +450,75 @@ func (h *QueryHandler) ExecuteAdHocQuery(c *fiber.Ctx) error {
 		})
 	}
 
+	executionID, err := queryExecutionID(c, req.ExecutionID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid execution ID",
+			"error":   err.Error(),
+		})
+	}
+
 	// Execute query
-	ctx := context.Background()
+	ctx := services.WithQueryExecutionID(services.WithQueryUser(context.Background(), userID), executionID)
+	ctx = services.WithQuerySource(ctx, queryRequestSource(req.Source), "")
 	result, err := h.queryExecutor.Execute(ctx, &conn, req.SQL, nil, nil)
 
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Query execution failed",
-			"error":   err.Error(),
+		return c.Status(queryErrorStatus(err)).JSON(fiber.Map{
+			"status":      "error",
+			"message":     "Query execution failed",
+			"error":       err.Error(),
+			"executionId": executionID,
 		})
 	}
+	result.ExecutionID = executionID
 
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    result,
 	})
 }
+
+// queryErrorStatus maps a query error to an HTTP status: 429 when a governance policy
+// turned the query away, 500 otherwise
+func queryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrQueryRejected):
+		return fiber.StatusTooManyRequests
+	case errors.Is(err, services.ErrExecutionIDInUse):
+		return fiber.StatusConflict
+	}
+	return fiber.StatusInternalServerError
+}
+
+// queryExecutionID returns the execution ID the client chose for a query, from the request
+// body or the X-Execution-ID header, or a new one. Clients that choose their own ID can
+// cancel the query through /queries/active while the request is still in flight.
+func queryExecutionID(c *fiber.Ctx, requested string) (string, error) {
+	if requested == "" {
+		requested = c.Get("X-Execution-ID")
+	}
+	return parseExecutionID(requested)
+}
+
+// queryRequestSource returns the history source of SQL sent by the client, which marks
+// AI-generated SQL as such
+func queryRequestSource(requested string) string {
+	if requested == services.QuerySourceAI {
+		return services.QuerySourceAI
+	}
+	return services.QuerySourceAdHoc
+}
+
+// parseExecutionID validates a client-chosen execution ID, generating one when it is empty
+func parseExecutionID(requested string) (string, error) {
+	if requested == "" {
+		return uuid.New().String(), nil
+	}
+	id, err := uuid.Parse(requested)
+	if err != nil {
+		return "", fmt.Errorf("execution ID must be a UUID: %w", err)
+	}
+	return id.String(), nil
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"insight-engine-backend/database"
+	"insight-engine-backend/models"
+	"insight-engine-backend/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetQueryHistory searches the caller's past query runs; admins may search every user's
+// GET /api/queries/history?userId=&connectionId=&status=&source=&minDuration=&maxDuration=&q=&from=&to=&limit=&offset=
+func (h *QueryHandler) GetQueryHistory(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	filter := services.QueryHistoryFilter{
+		UserID:        userID,
+		ConnectionID:  c.Query("connectionId"),
+		Status:        c.Query("status"),
+		Source:        c.Query("source"),
+		MinDurationMs: int64(c.QueryInt("minDuration")),
+		MaxDurationMs: int64(c.QueryInt("maxDuration")),
+		Search:        c.Query("q"),
+		Limit:         c.QueryInt("limit", 50),
+		Offset:        c.QueryInt("offset"),
+	}
+	if isAdminUser(userID) {
+		// Admins see everyone's queries unless they ask for one user's
+		filter.UserID = c.Query("userId")
+	}
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := c.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Invalid " + param + " time, expected RFC 3339",
+					"error":   err.Error(),
+				})
+			}
+			*target = &t
+		}
+	}
+
+	entries, total, err := h.queryExecutor.History().Search(filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not fetch query history",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    entries,
+		"total":   total,
+	})
+}
+
+// RerunHistoryQuery runs the SQL of a past query run again on the same connection
+// POST /api/queries/history/:id/rerun
+func (h *QueryHandler) RerunHistoryQuery(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	entry, err := h.queryExecutor.History().Get(c.Params("id"))
+	if err != nil || (entry.UserID != userID && !isAdminUser(userID)) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Query history entry not found",
+		})
+	}
+
+	// Re-runs go through the caller's own connections, even for admins
+	var conn models.Connection
+	if err := database.DB.Where("id = ? AND user_id = ?", entry.ConnectionID, userID).First(&conn).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Connection not found",
+		})
+	}
+
+	params := new(struct {
+		Limit       *int   `json:"limit"`
+		Offset      *int   `json:"offset"`
+		ExecutionID string `json:"executionId"`
+	})
+	_ = c.BodyParser(params)
+
+	executionID, err := queryExecutionID(c, params.ExecutionID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid execution ID",
+			"error":   err.Error(),
+		})
+	}
+
+	queryID := ""
+	if entry.QueryID != nil {
+		queryID = *entry.QueryID
+	}
+	ctx := services.WithQueryExecutionID(services.WithQueryUser(context.Background(), userID), executionID)
+	ctx = services.WithQuerySource(ctx, entry.Source, queryID)
+	result, err := h.queryExecutor.Execute(ctx, &conn, entry.SQL, params.Limit, params.Offset)
+	if err != nil {
+		return c.Status(queryErrorStatus(err)).JSON(fiber.Map{
+			"status":      "error",
+			"message":     "Query execution failed",
+			"error":       err.Error(),
+			"executionId": executionID,
+		})
+	}
+	result.ExecutionID = executionID
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// isAdminUser reports whether a user has the admin role
+func isAdminUser(userID string) bool {
+	var user models.User
+	if err := database.DB.Select("id, role").Where("id = ?", userID).First(&user).Error; err != nil {
+		return false
+	}
+	return user.Role == "admin"
+}
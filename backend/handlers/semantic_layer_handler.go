@@ -1,34 +1,48 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"insight-engine-backend/database"
 	"insight-engine-backend/models"
 	"insight-engine-backend/services"
+	"sort"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
 type SemanticLayerHandler struct {
-	service *services.SemanticLayerService
+	service         *services.SemanticLayerService
+	queryExecutor   *services.QueryExecutor
+	schemaDiscovery *services.SchemaDiscovery
 }
 
-func NewSemanticLayerHandler(service *services.SemanticLayerService) *SemanticLayerHandler {
-	return &SemanticLayerHandler{service: service}
+func NewSemanticLayerHandler(service *services.SemanticLayerService, queryExecutor *services.QueryExecutor, schemaDiscovery *services.SchemaDiscovery) *SemanticLayerHandler {
+	return &SemanticLayerHandler{service: service, queryExecutor: queryExecutor, schemaDiscovery: schemaDiscovery}
 }
 
 // ListSemanticModels godoc
 // @Summary List semantic models
-// @Description Get all semantic models for the user's workspace
+// @Description Get all semantic models of a workspace the user is a member of
 // @Tags semantic-layer
 // @Accept json
 // @Produce json
+// @Param workspaceId query string true "Workspace ID"
 // @Success 200 {array} models.SemanticModel
+// @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/v1/semantic/models [get]
 func (h *SemanticLayerHandler) ListSemanticModels(c *fiber.Ctx) error {
-	// Get workspace ID from context (set by auth middleware)
-	workspaceID := c.Locals("workspaceID").(string)
+	workspaceID := c.Query("workspaceId")
+	if status, message := workspaceAccess(c, workspaceID); status != 0 {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
 
 	models, err := h.service.ListModelsByWorkspace(workspaceID)
 	if err != nil {
@@ -50,12 +64,11 @@ func (h *SemanticLayerHandler) ListSemanticModels(c *fiber.Ctx) error {
 // @Success 201 {object} models.SemanticModel
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/v1/semantic/models [post]
 func (h *SemanticLayerHandler) CreateSemanticModel(c *fiber.Ctx) error {
-	// Get user and workspace from context
-	userID := c.Locals("userID").(string)
-	workspaceID := c.Locals("workspaceID").(string)
+	userID, _ := c.Locals("userID").(string)
 
 	var req CreateSemanticModelRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -63,6 +76,11 @@ func (h *SemanticLayerHandler) CreateSemanticModel(c *fiber.Ctx) error {
 			"error": "Invalid request body",
 		})
 	}
+	if status, message := workspaceAccess(c, req.WorkspaceID); status != 0 {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
 
 	// Validate request
 	if req.Name == "" {
@@ -80,6 +98,11 @@ func (h *SemanticLayerHandler) CreateSemanticModel(c *fiber.Ctx) error {
 			"error": "Table name is required",
 		})
 	}
+	if !h.ownsDataSource(c, &models.SemanticModel{DataSourceID: req.DataSourceID}) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Data source not found",
+		})
+	}
 
 	// Build model
 	model := &models.SemanticModel{
@@ -88,23 +111,13 @@ func (h *SemanticLayerHandler) CreateSemanticModel(c *fiber.Ctx) error {
 		Description:  req.Description,
 		DataSourceID: req.DataSourceID,
 		Table:        req.TableName,
-		WorkspaceID:  workspaceID,
+		SourceQuery:  req.SourceQuery,
+		WorkspaceID:  req.WorkspaceID,
 		CreatedBy:    userID,
 	}
 
 	// Add dimensions
-	for _, dimReq := range req.Dimensions {
-		dimension := models.SemanticDimension{
-			ID:          uuid.New().String(),
-			ModelID:     model.ID,
-			Name:        dimReq.Name,
-			ColumnName:  dimReq.ColumnName,
-			DataType:    dimReq.DataType,
-			Description: dimReq.Description,
-			IsHidden:    dimReq.IsHidden,
-		}
-		model.Dimensions = append(model.Dimensions, dimension)
-	}
+	model.Dimensions = buildDimensions(model.ID, req.Dimensions)
 
 	// Add metrics
 	for _, metricReq := range req.Metrics {
@@ -115,10 +128,20 @@ func (h *SemanticLayerHandler) CreateSemanticModel(c *fiber.Ctx) error {
 			Formula:     metricReq.Formula,
 			Description: metricReq.Description,
 			Format:      metricReq.Format,
+			DataType:    metricReq.DataType,
 		}
 		model.Metrics = append(model.Metrics, metric)
 	}
 
+	// Metrics may only use the table's columns, when the data source can tell us what they are
+	if columns := h.tableColumns(c, model); columns != nil {
+		if err := h.service.ValidateModelMetrics(model, columns); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
 	// Create model
 	if err := h.service.CreateModel(model); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -129,6 +152,198 @@ func (h *SemanticLayerHandler) CreateSemanticModel(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(model)
 }
 
+// GetSemanticModel godoc
+// @Summary Get semantic model
+// @Description Get a semantic model with its dimensions and metrics
+// @Tags semantic-layer
+// @Produce json
+// @Param id path string true "Model ID"
+// @Success 200 {object} models.SemanticModel
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/semantic/models/{id} [get]
+func (h *SemanticLayerHandler) GetSemanticModel(c *fiber.Ctx) error {
+	model, err := h.service.GetModelByID(c.Params("id"))
+	if err != nil || !h.ownsDataSource(c, model) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	return c.JSON(model)
+}
+
+// UpdateSemanticModel godoc
+// @Summary Update semantic model
+// @Description Update a semantic model's name, description and source, and replace its dimensions if given
+// @Tags semantic-layer
+// @Accept json
+// @Produce json
+// @Param id path string true "Model ID"
+// @Param model body UpdateSemanticModelRequest true "Model changes"
+// @Success 200 {object} models.SemanticModel
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/semantic/models/{id} [put]
+func (h *SemanticLayerHandler) UpdateSemanticModel(c *fiber.Ctx) error {
+	model, err := h.service.GetModelByID(c.Params("id"))
+	if err != nil || !h.ownsDataSource(c, model) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	var req UpdateSemanticModelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Update fields
+	if req.Name != "" {
+		model.Name = req.Name
+	}
+	if req.Description != "" {
+		model.Description = req.Description
+	}
+	if req.TableName != "" {
+		model.Table = req.TableName
+	}
+	if req.SourceQuery != nil {
+		model.SourceQuery = *req.SourceQuery
+	}
+	var dimensions []models.SemanticDimension
+	if req.Dimensions != nil {
+		dimensions = buildDimensions(model.ID, req.Dimensions)
+	}
+
+	if err := h.service.UpdateModel(model, dimensions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(model)
+}
+
+// DeleteSemanticModel godoc
+// @Summary Delete semantic model
+// @Description Delete a semantic model with its dimensions, metrics and relationships
+// @Tags semantic-layer
+// @Produce json
+// @Param id path string true "Model ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/semantic/models/{id} [delete]
+func (h *SemanticLayerHandler) DeleteSemanticModel(c *fiber.Ctx) error {
+	model, err := h.service.GetModelByID(c.Params("id"))
+	if err != nil || !h.ownsDataSource(c, model) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	if err := h.service.DeleteModel(model.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete semantic model",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Semantic model deleted successfully",
+	})
+}
+
+// MigrateModelDefinitions godoc
+// @Summary Migrate model definitions
+// @Description Convert the workspace's modeling API model and metric definitions into semantic models on a data source
+// @Tags semantic-layer
+// @Accept json
+// @Produce json
+// @Param migration body MigrateModelDefinitionsRequest true "Data source and definitions to migrate"
+// @Success 200 {object} services.ModelMigrationResult
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/semantic/models/migrate [post]
+func (h *SemanticLayerHandler) MigrateModelDefinitions(c *fiber.Ctx) error {
+	var req MigrateModelDefinitionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if status, message := workspaceAccess(c, req.WorkspaceID); status != 0 {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+	if req.DataSourceID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Data source ID is required",
+		})
+	}
+	if !h.ownsDataSource(c, &models.SemanticModel{DataSourceID: req.DataSourceID}) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Data source not found",
+		})
+	}
+
+	result, err := h.service.MigrateModelDefinitions(req.WorkspaceID, req.DataSourceID, req.DefinitionIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// buildDimensions creates the dimensions of a model from a request
+func buildDimensions(modelID string, requests []CreateDimensionRequest) []models.SemanticDimension {
+	dimensions := make([]models.SemanticDimension, 0, len(requests))
+	for _, dimReq := range requests {
+		dimensions = append(dimensions, models.SemanticDimension{
+			ID:          uuid.New().String(),
+			ModelID:     modelID,
+			Name:        dimReq.Name,
+			ColumnName:  dimReq.ColumnName,
+			DataType:    dimReq.DataType,
+			Description: dimReq.Description,
+			IsHidden:    dimReq.IsHidden,
+		})
+	}
+	return dimensions
+}
+
+// tableColumns returns the columns of a model's table, or nil if they cannot be discovered
+func (h *SemanticLayerHandler) tableColumns(c *fiber.Ctx, model *models.SemanticModel) []string {
+	if model.SourceQuery != "" {
+		return nil
+	}
+	userID, _ := c.Locals("userID").(string)
+	var conn models.Connection
+	if err := database.DB.Where("id = ? AND user_id = ?", model.DataSourceID, userID).First(&conn).Error; err != nil {
+		return nil
+	}
+	tables, err := h.schemaDiscovery.DiscoverSchema(c.Context(), &conn)
+	if err != nil {
+		services.LogWarn("semantic_model_schema", "Could not discover columns to validate metrics", map[string]interface{}{"data_source_id": conn.ID, "error": err.Error()})
+		return nil
+	}
+	for _, table := range tables {
+		if strings.EqualFold(table.Name, model.Table) || strings.EqualFold(table.Schema+"."+table.Name, model.Table) {
+			columns := make([]string, 0, len(table.Columns))
+			for _, column := range table.Columns {
+				columns = append(columns, column.Name)
+			}
+			return columns
+		}
+	}
+	return nil
+}
+
 // ListSemanticMetrics godoc
 // @Summary List semantic metrics
 // @Description Get all metrics for a model or workspace
@@ -136,20 +351,34 @@ func (h *SemanticLayerHandler) CreateSemanticModel(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param modelId query string false "Model ID to filter by"
+// @Param workspaceId query string false "Workspace ID, required without modelId"
 // @Success 200 {array} models.SemanticMetric
+// @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/v1/semantic/metrics [get]
 func (h *SemanticLayerHandler) ListSemanticMetrics(c *fiber.Ctx) error {
-	workspaceID := c.Locals("workspaceID").(string)
 	modelID := c.Query("modelId")
 
 	var metrics []models.SemanticMetric
 	var err error
 
 	if modelID != "" {
+		model, modelErr := h.service.GetModelByID(modelID)
+		if modelErr != nil || !h.ownsDataSource(c, model) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Model not found",
+			})
+		}
 		metrics, err = h.service.ListMetricsByModel(modelID)
 	} else {
+		workspaceID := c.Query("workspaceId")
+		if status, message := workspaceAccess(c, workspaceID); status != 0 {
+			return c.Status(status).JSON(fiber.Map{
+				"error": message,
+			})
+		}
 		metrics, err = h.service.ListAllMetrics(workspaceID)
 	}
 
@@ -162,9 +391,331 @@ func (h *SemanticLayerHandler) ListSemanticMetrics(c *fiber.Ctx) error {
 	return c.JSON(metrics)
 }
 
+// CreateSemanticMetric godoc
+// @Summary Create semantic metric
+// @Description Add a metric to a semantic model; its formula may refer to the model's other metrics by name
+// @Tags semantic-layer
+// @Accept json
+// @Produce json
+// @Param metric body CreateSemanticMetricRequest true "Metric data"
+// @Success 201 {object} models.SemanticMetric
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/semantic/metrics [post]
+func (h *SemanticLayerHandler) CreateSemanticMetric(c *fiber.Ctx) error {
+	var req CreateSemanticMetricRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.Name == "" || req.Formula == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name and formula are required",
+		})
+	}
+
+	model, err := h.service.GetModelByID(req.ModelID)
+	if err != nil || !h.ownsDataSource(c, model) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	metric := &models.SemanticMetric{
+		ID:          uuid.New().String(),
+		ModelID:     model.ID,
+		Name:        req.Name,
+		Formula:     req.Formula,
+		Description: req.Description,
+		Format:      req.Format,
+		DataType:    req.DataType,
+	}
+	if err := h.service.CreateMetric(metric); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(metric)
+}
+
+// GetSemanticMetric godoc
+// @Summary Get semantic metric
+// @Tags semantic-layer
+// @Produce json
+// @Param id path string true "Metric ID"
+// @Success 200 {object} models.SemanticMetric
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/semantic/metrics/{id} [get]
+func (h *SemanticLayerHandler) GetSemanticMetric(c *fiber.Ctx) error {
+	metric, ok := h.ownedMetric(c)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Metric not found",
+		})
+	}
+
+	return c.JSON(metric)
+}
+
+// UpdateSemanticMetric godoc
+// @Summary Update semantic metric
+// @Description Update a metric; metrics that other metrics refer to cannot be renamed
+// @Tags semantic-layer
+// @Accept json
+// @Produce json
+// @Param id path string true "Metric ID"
+// @Param metric body UpdateSemanticMetricRequest true "Metric changes"
+// @Success 200 {object} models.SemanticMetric
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/semantic/metrics/{id} [put]
+func (h *SemanticLayerHandler) UpdateSemanticMetric(c *fiber.Ctx) error {
+	metric, ok := h.ownedMetric(c)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Metric not found",
+		})
+	}
+
+	var req UpdateSemanticMetricRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Update fields
+	if req.Name != "" {
+		metric.Name = req.Name
+	}
+	if req.Formula != "" {
+		metric.Formula = req.Formula
+	}
+	if req.Description != "" {
+		metric.Description = req.Description
+	}
+	if req.Format != "" {
+		metric.Format = req.Format
+	}
+	if req.DataType != "" {
+		metric.DataType = req.DataType
+	}
+
+	if err := h.service.UpdateMetric(metric); errors.Is(err, services.ErrMetricInUse) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(metric)
+}
+
+// DeleteSemanticMetric godoc
+// @Summary Delete semantic metric
+// @Description Delete a metric that no other metric refers to
+// @Tags semantic-layer
+// @Produce json
+// @Param id path string true "Metric ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/semantic/metrics/{id} [delete]
+func (h *SemanticLayerHandler) DeleteSemanticMetric(c *fiber.Ctx) error {
+	metric, ok := h.ownedMetric(c)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Metric not found",
+		})
+	}
+
+	if err := h.service.DeleteMetric(metric.ID); errors.Is(err, services.ErrMetricInUse) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete metric",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Metric deleted successfully",
+	})
+}
+
+// ownedMetric loads the metric of the request's id parameter, if its model's data source is
+// one of the caller's connections
+func (h *SemanticLayerHandler) ownedMetric(c *fiber.Ctx) (*models.SemanticMetric, bool) {
+	metric, err := h.service.GetMetricByID(c.Params("id"))
+	if err != nil {
+		return nil, false
+	}
+	model, err := h.service.GetModelByID(metric.ModelID)
+	if err != nil || !h.ownsDataSource(c, model) {
+		return nil, false
+	}
+	return metric, true
+}
+
+// CreateSemanticRelationship godoc
+// @Summary Create semantic relationship
+// @Description Define how two semantic models join, so queries can combine their fields
+// @Tags semantic-layer
+// @Accept json
+// @Produce json
+// @Param relationship body CreateRelationshipRequest true "Relationship data"
+// @Success 201 {object} models.SemanticRelationship
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/semantic/relationships [post]
+func (h *SemanticLayerHandler) CreateSemanticRelationship(c *fiber.Ctx) error {
+	var req CreateRelationshipRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// The models' data source must be one of the caller's connections
+	model, err := h.service.GetModelByID(req.FromModelID)
+	if err != nil || !h.ownsDataSource(c, model) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	relationship := &models.SemanticRelationship{
+		ID:               uuid.New().String(),
+		FromModelID:      req.FromModelID,
+		ToModelID:        req.ToModelID,
+		FromColumn:       req.FromColumn,
+		ToColumn:         req.ToColumn,
+		RelationshipType: req.RelationshipType,
+	}
+	if err := h.service.CreateRelationship(relationship); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(relationship)
+}
+
+// ListSemanticRelationships godoc
+// @Summary List semantic relationships
+// @Description Get the relationships of a semantic model
+// @Tags semantic-layer
+// @Produce json
+// @Param id path string true "Model ID"
+// @Success 200 {array} models.SemanticRelationship
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/semantic/models/{id}/relationships [get]
+func (h *SemanticLayerHandler) ListSemanticRelationships(c *fiber.Ctx) error {
+	model, err := h.service.GetModelByID(c.Params("id"))
+	if err != nil || !h.ownsDataSource(c, model) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	relationships, err := h.service.ListRelationships(model.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve relationships",
+		})
+	}
+
+	return c.JSON(relationships)
+}
+
+// ListInvalidSemanticMetrics godoc
+// @Summary List invalid semantic metrics
+// @Description Get the metrics of a semantic model that cannot be queried, such as raw SQL formulas saved before formulas were parsed, so that they can be rewritten or deleted
+// @Tags semantic-layer
+// @Produce json
+// @Param id path string true "Model ID"
+// @Success 200 {array} services.InvalidMetric
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/semantic/models/{id}/invalid-metrics [get]
+func (h *SemanticLayerHandler) ListInvalidSemanticMetrics(c *fiber.Ctx) error {
+	model, err := h.service.GetModelByID(c.Params("id"))
+	if err != nil || !h.ownsDataSource(c, model) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	return c.JSON(h.service.InvalidMetrics(model))
+}
+
+// DeleteSemanticRelationship godoc
+// @Summary Delete semantic relationship
+// @Tags semantic-layer
+// @Produce json
+// @Param id path string true "Relationship ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/semantic/relationships/{id} [delete]
+func (h *SemanticLayerHandler) DeleteSemanticRelationship(c *fiber.Ctx) error {
+	var relationship models.SemanticRelationship
+	if err := database.DB.First(&relationship, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Relationship not found",
+		})
+	}
+	model, err := h.service.GetModelByID(relationship.FromModelID)
+	if err != nil || !h.ownsDataSource(c, model) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Relationship not found",
+		})
+	}
+
+	if err := h.service.DeleteRelationship(relationship.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete relationship",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Relationship deleted successfully",
+	})
+}
+
+// ownsDataSource reports whether a model's data source is one of the caller's connections
+// workspaceAccess returns the status and error of requests for a workspace the user is not a
+// member of, or 0 if they are one
+func workspaceAccess(c *fiber.Ctx, workspaceID string) (int, string) {
+	userID, _ := c.Locals("userID").(string)
+	if workspaceID == "" {
+		return fiber.StatusBadRequest, "Workspace ID is required"
+	}
+	if !isMember(workspaceID, userID) {
+		return fiber.StatusNotFound, "Workspace not found"
+	}
+	return 0, ""
+}
+
+func (h *SemanticLayerHandler) ownsDataSource(c *fiber.Ctx, model *models.SemanticModel) bool {
+	userID, _ := c.Locals("userID").(string)
+	var count int64
+	database.DB.Model(&models.Connection{}).Where("id = ? AND user_id = ?", model.DataSourceID, userID).Count(&count)
+	return count > 0
+}
+
 // ExecuteSemanticQuery godoc
 // @Summary Execute semantic query
-// @Description Execute a query using business terms (dimensions and metrics)
+// @Description Execute a query using business terms (dimensions and metrics), including fields of related models
 // @Tags semantic-layer
 // @Accept json
 // @Produce json
@@ -188,7 +739,7 @@ func (h *SemanticLayerHandler) ExecuteSemanticQuery(c *fiber.Ctx) error {
 			"error": "Model ID is required",
 		})
 	}
-	if len(req.Dimensions) == 0 && len(req.Metrics) == 0 {
+	if len(req.Dimensions) == 0 && len(req.Metrics) == 0 && req.TimeDimension == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "At least one dimension or metric is required",
 		})
@@ -207,24 +758,76 @@ func (h *SemanticLayerHandler) ExecuteSemanticQuery(c *fiber.Ctx) error {
 	if limit == 0 {
 		limit = 100
 	}
+	if limit < 0 || req.Offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Limit and offset must not be negative",
+		})
+	}
+
+	// Filters given as a map test for equality
+	filters := append([]services.SemanticFilter{}, req.Where...)
+	fields := make([]string, 0, len(req.Filters))
+	for field := range req.Filters {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		filters = append(filters, services.SemanticFilter{Field: field, Operator: "equals", Value: req.Filters[field]})
+	}
+
+	// Run against the model's data source, which must be one of the caller's connections
+	userID, _ := c.Locals("userID").(string)
+	var conn models.Connection
+	if err := database.DB.Where("id = ? AND user_id = ?", model.DataSourceID, userID).First(&conn).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Data source not found",
+		})
+	}
+
+	// Translate semantic query to SQL in the data source's dialect
+	sql, args, err := h.service.CompileSemanticQuery(model, services.SemanticQuery{
+		Dimensions:    req.Dimensions,
+		Metrics:       req.Metrics,
+		Filters:       filters,
+		TimeDimension: req.TimeDimension,
+		OrderBy:       req.OrderBy,
+		Limit:         limit,
+		Offset:        req.Offset,
+	}, services.DialectFor(conn.Type))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-	// Translate semantic query to SQL
-	sql, args, err := h.service.TranslateSemanticQuery(model, req.Dimensions, req.Metrics, req.Filters, limit)
+	executionID, err := parseExecutionID(req.ExecutionID)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
+	ctx := services.WithQueryExecutionID(services.WithQueryUser(context.Background(), userID), executionID)
+	ctx = services.WithQuerySource(ctx, services.QuerySourceSemantic, model.ID)
+	result, err := h.queryExecutor.ExecuteWithArgs(ctx, &conn, sql, nil, nil, args...)
+	if err != nil {
+		return c.Status(queryErrorStatus(err)).JSON(fiber.Map{
+			"error":       "Query execution failed: " + err.Error(),
+			"sql":         sql,
+			"executionId": executionID,
+		})
+	}
 
-	// Execute query
-	// Note: In production, this should execute against the actual data source
-	// For now, we'll return the generated SQL
 	response := SemanticQueryResponse{
-		SQL:        sql,
-		Args:       args,
-		Dimensions: req.Dimensions,
-		Metrics:    req.Metrics,
-		RowCount:   0, // Would be populated after execution
+		SQL:           sql,
+		Args:          args,
+		Dimensions:    req.Dimensions,
+		Metrics:       req.Metrics,
+		Columns:       result.Columns,
+		Rows:          result.Rows,
+		RowCount:      result.RowCount,
+		ExecutionTime: result.ExecutionTime,
+		Truncated:     result.Truncated,
+		ExecutionID:   executionID,
 	}
 
 	return c.JSON(response)
@@ -233,14 +836,32 @@ func (h *SemanticLayerHandler) ExecuteSemanticQuery(c *fiber.Ctx) error {
 // Request/Response types
 
 type CreateSemanticModelRequest struct {
+	WorkspaceID  string                   `json:"workspaceId"`
 	Name         string                   `json:"name"`
 	Description  string                   `json:"description"`
 	DataSourceID string                   `json:"dataSourceId"`
 	TableName    string                   `json:"tableName"`
+	SourceQuery  string                   `json:"sourceQuery"` // SELECT to read instead of the table; tableName then names its results
 	Dimensions   []CreateDimensionRequest `json:"dimensions"`
 	Metrics      []CreateMetricRequest    `json:"metrics"`
 }
 
+// UpdateSemanticModelRequest changes the fields that are given; dimensions, if given, replace
+// the model's dimensions
+type UpdateSemanticModelRequest struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	TableName   string                   `json:"tableName"`
+	SourceQuery *string                  `json:"sourceQuery"` // "" reads from the table again
+	Dimensions  []CreateDimensionRequest `json:"dimensions"`
+}
+
+type MigrateModelDefinitionsRequest struct {
+	WorkspaceID   string   `json:"workspaceId"`
+	DataSourceID  string   `json:"dataSourceId"`
+	DefinitionIDs []string `json:"definitionIds"` // All unmigrated definitions of the workspace if empty
+}
+
 type CreateDimensionRequest struct {
 	Name        string `json:"name"`
 	ColumnName  string `json:"columnName"`
@@ -250,24 +871,57 @@ type CreateDimensionRequest struct {
 }
 
 type CreateMetricRequest struct {
+	Name        string `json:"name"`
+	Formula     string `json:"formula"` // May refer to the model's other metrics by name
+	Description string `json:"description"`
+	Format      string `json:"format"`
+	DataType    string `json:"dataType"` // number, currency, percentage, count, decimal
+}
+
+type CreateSemanticMetricRequest struct {
+	ModelID string `json:"modelId"`
+	CreateMetricRequest
+}
+
+type UpdateSemanticMetricRequest struct {
 	Name        string `json:"name"`
 	Formula     string `json:"formula"`
 	Description string `json:"description"`
 	Format      string `json:"format"`
+	DataType    string `json:"dataType"`
+}
+
+type CreateRelationshipRequest struct {
+	FromModelID      string `json:"fromModelId"`
+	ToModelID        string `json:"toModelId"`
+	FromColumn       string `json:"fromColumn"`
+	ToColumn         string `json:"toColumn"`
+	RelationshipType string `json:"relationshipType"` // one_to_one, one_to_many, many_to_one, many_to_many
 }
 
+// SemanticQueryRequest names fields of the model or, as "<model>.<field>", of related models
 type SemanticQueryRequest struct {
-	ModelID    string                 `json:"modelId"`
-	Dimensions []string               `json:"dimensions"`
-	Metrics    []string               `json:"metrics"`
-	Filters    map[string]interface{} `json:"filters"`
-	Limit      int                    `json:"limit"`
+	ModelID       string                          `json:"modelId"`
+	Dimensions    []string                        `json:"dimensions"`
+	Metrics       []string                        `json:"metrics"`
+	Filters       map[string]interface{}          `json:"filters"` // Equality filters on dimensions
+	Where         []services.SemanticFilter       `json:"where"`   // Filters with operators, on dimensions or requested metrics
+	TimeDimension *services.SemanticTimeDimension `json:"timeDimension"`
+	OrderBy       []services.SemanticOrder        `json:"orderBy"`
+	Limit         int                             `json:"limit"`
+	Offset        int                             `json:"offset"`
+	ExecutionID   string                          `json:"executionId"`
 }
 
 type SemanticQueryResponse struct {
-	SQL        string        `json:"sql"`
-	Args       []interface{} `json:"args"`
-	Dimensions []string      `json:"dimensions"`
-	Metrics    []string      `json:"metrics"`
-	RowCount   int           `json:"rowCount"`
+	SQL           string          `json:"sql"`
+	Args          []interface{}   `json:"args"`
+	Dimensions    []string        `json:"dimensions"`
+	Metrics       []string        `json:"metrics"`
+	Columns       []string        `json:"columns"`
+	Rows          [][]interface{} `json:"rows"`
+	RowCount      int             `json:"rowCount"`
+	ExecutionTime int64           `json:"executionTime"` // milliseconds
+	Truncated     bool            `json:"truncated,omitempty"`
+	ExecutionID   string          `json:"executionId"`
 }
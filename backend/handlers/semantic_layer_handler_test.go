@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"insight-engine-backend/database"
+	"insight-engine-backend/models"
+	"insight-engine-backend/services"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// setupSemanticLayerApp creates a Fiber app of the semantic layer routes on an in-memory
+// database, where user-1 is a member of ws-1 and owns the sqlite connection conn-1 and user-2
+// owns conn-2. The user is taken from the X-User header.
+func setupSemanticLayerApp(t *testing.T) (*fiber.App, *gorm.DB) {
+	root := t.TempDir()
+	services.SetFileStorageDir(root)
+	t.Cleanup(func() { services.SetFileStorageDir("./data/files") })
+	if err := os.MkdirAll(filepath.Join(root, "user-1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	warehouse, err := sql.Open("sqlite", filepath.Join(root, "user-1", "warehouse.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := warehouse.Exec("CREATE TABLE orders (id INTEGER, status TEXT, amount INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	warehouse.Close()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.WorkspaceMember{}, &models.Connection{}, &models.SemanticModel{}, &models.SemanticDimension{},
+		&models.SemanticMetric{}, &models.SemanticRelationship{}, &models.ModelDefinition{}, &models.MetricDefinition{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+
+	db.Create(&models.WorkspaceMember{ID: "m1", WorkspaceID: "ws-1", UserID: "user-1", Role: models.RoleEditor})
+	db.Create(&models.Connection{ID: "conn-1", Name: "warehouse", Type: "sqlite", Database: "warehouse.db", UserID: "user-1"})
+	db.Create(&models.Connection{ID: "conn-2", Name: "warehouse", Type: "postgres", UserID: "user-2"})
+
+	queryExecutor := services.NewQueryExecutor()
+	t.Cleanup(func() { queryExecutor.Close() })
+	handler := NewSemanticLayerHandler(services.NewSemanticLayerService(db), queryExecutor, services.NewSchemaDiscovery(queryExecutor))
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", c.Get("X-User"))
+		return c.Next()
+	})
+	app.Get("/semantic/models", handler.ListSemanticModels)
+	app.Post("/semantic/models", handler.CreateSemanticModel)
+	app.Post("/semantic/models/migrate", handler.MigrateModelDefinitions)
+	app.Get("/semantic/metrics", handler.ListSemanticMetrics)
+	return app, db
+}
+
+// semanticLayerRequest sends a request as userID and decodes the JSON response into result
+func semanticLayerRequest(t *testing.T, app *fiber.App, method, path, userID string, body interface{}, result interface{}) int {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", userID)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if result != nil {
+		json.NewDecoder(resp.Body).Decode(result)
+	}
+	return resp.StatusCode
+}
+
+// TestSemanticModels_CreateAndList tests creating and listing the semantic models and metrics
+// of a workspace the caller is a member of
+func TestSemanticModels_CreateAndList(t *testing.T) {
+	app, _ := setupSemanticLayerApp(t)
+
+	create := map[string]interface{}{
+		"workspaceId": "ws-1", "name": "orders", "dataSourceId": "conn-1", "tableName": "orders",
+		"metrics": []map[string]interface{}{{"name": "revenue", "formula": "SUM(amount)"}},
+	}
+	var model models.SemanticModel
+	if status := semanticLayerRequest(t, app, http.MethodPost, "/semantic/models", "user-1", create, &model); status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", status)
+	}
+	if model.WorkspaceID != "ws-1" || len(model.Metrics) != 1 {
+		t.Errorf("Unexpected model %+v", model)
+	}
+
+	// Metrics are checked against the table's columns
+	invalid := map[string]interface{}{
+		"workspaceId": "ws-1", "name": "refunds", "dataSourceId": "conn-1", "tableName": "orders",
+		"metrics": []map[string]interface{}{{"name": "refunded", "formula": "SUM(refund)"}},
+	}
+	if status := semanticLayerRequest(t, app, http.MethodPost, "/semantic/models", "user-1", invalid, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a metric on a missing column, got %d", status)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		userID string
+		body   map[string]interface{}
+		status int
+	}{
+		{"no workspace", "user-1", map[string]interface{}{"name": "a", "dataSourceId": "conn-1", "tableName": "orders"}, http.StatusBadRequest},
+		{"not a member", "user-2", map[string]interface{}{"workspaceId": "ws-1", "name": "a", "dataSourceId": "conn-2", "tableName": "orders"}, http.StatusNotFound},
+		{"another user's data source", "user-1", map[string]interface{}{"workspaceId": "ws-1", "name": "a", "dataSourceId": "conn-2", "tableName": "orders"}, http.StatusNotFound},
+	} {
+		if status := semanticLayerRequest(t, app, http.MethodPost, "/semantic/models", tc.userID, tc.body, nil); status != tc.status {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.status, status)
+		}
+	}
+
+	var listed []models.SemanticModel
+	if status := semanticLayerRequest(t, app, http.MethodGet, "/semantic/models?workspaceId=ws-1", "user-1", nil, &listed); status != http.StatusOK || len(listed) != 1 {
+		t.Errorf("Expected the workspace's model, got %d: %+v", status, listed)
+	}
+	if status := semanticLayerRequest(t, app, http.MethodGet, "/semantic/models", "user-1", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 listing models without a workspace, got %d", status)
+	}
+	if status := semanticLayerRequest(t, app, http.MethodGet, "/semantic/models?workspaceId=ws-1", "user-2", nil, nil); status != http.StatusNotFound {
+		t.Errorf("Expected 404 listing models of another workspace, got %d", status)
+	}
+
+	var metrics []models.SemanticMetric
+	if status := semanticLayerRequest(t, app, http.MethodGet, "/semantic/metrics?workspaceId=ws-1", "user-1", nil, &metrics); status != http.StatusOK || len(metrics) != 1 {
+		t.Errorf("Expected the workspace's metric, got %d: %+v", status, metrics)
+	}
+	if status := semanticLayerRequest(t, app, http.MethodGet, "/semantic/metrics?modelId="+model.ID, "user-1", nil, &metrics); status != http.StatusOK || len(metrics) != 1 {
+		t.Errorf("Expected the model's metric, got %d: %+v", status, metrics)
+	}
+	if status := semanticLayerRequest(t, app, http.MethodGet, "/semantic/metrics?modelId="+model.ID, "user-2", nil, nil); status != http.StatusNotFound {
+		t.Errorf("Expected 404 listing metrics of another user's model, got %d", status)
+	}
+	if status := semanticLayerRequest(t, app, http.MethodGet, "/semantic/metrics?workspaceId=ws-1", "user-2", nil, nil); status != http.StatusNotFound {
+		t.Errorf("Expected 404 listing metrics of another workspace, got %d", status)
+	}
+}
+
+// TestMigrateModelDefinitions tests migrating the model definitions of a workspace the caller
+// is a member of
+func TestMigrateModelDefinitions(t *testing.T) {
+	app, db := setupSemanticLayerApp(t)
+	db.Create(&models.ModelDefinition{ID: "def-orders", Name: "orders", Type: "table", SourceTable: "orders", WorkspaceID: "ws-1", CreatedBy: "user-1"})
+
+	migrate := func(userID string, body map[string]interface{}) (int, map[string]interface{}) {
+		var result map[string]interface{}
+		status := semanticLayerRequest(t, app, http.MethodPost, "/semantic/models/migrate", userID, body, &result)
+		return status, result
+	}
+
+	if status, _ := migrate("user-1", map[string]interface{}{"dataSourceId": "conn-1"}); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a workspace, got %d", status)
+	}
+	if status, _ := migrate("user-2", map[string]interface{}{"workspaceId": "ws-1", "dataSourceId": "conn-2"}); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for a workspace the caller is not a member of, got %d", status)
+	}
+	if status, _ := migrate("user-1", map[string]interface{}{"workspaceId": "ws-1", "dataSourceId": "conn-2"}); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for another user's data source, got %d", status)
+	}
+
+	status, result := migrate("user-1", map[string]interface{}{"workspaceId": "ws-1", "dataSourceId": "conn-1"})
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %v", status, result)
+	}
+	migrated, _ := result["models"].([]interface{})
+	if len(migrated) != 1 {
+		t.Fatalf("Expected the workspace's model definition to be migrated, got %v", result)
+	}
+	var model models.SemanticModel
+	if err := db.First(&model, "workspace_id = ? AND name = ?", "ws-1", "orders").Error; err != nil || model.DataSourceID != "conn-1" {
+		t.Errorf("Expected a semantic model on conn-1, got %+v (%v)", model, err)
+	}
+}
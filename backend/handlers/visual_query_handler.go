@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"insight-engine-backend/models"
@@ -359,21 +360,87 @@ func (h *VisualQueryHandler) PreviewVisualQuery(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Failed to generate SQL: %v", err)})
 	}
 
+	// The client may pick the execution ID so it can cancel a slow preview
+	var body struct {
+		ExecutionID string `json:"executionId"`
+	}
+	_ = c.BodyParser(&body)
+	executionID, err := queryExecutionID(c, body.ExecutionID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	// Execute query using query builder's ExecuteQuery method
-	result, err := h.queryBuilder.ExecuteQuery(c.Context(), &config, &conn, h.queryExecutor, userIDStr, id, workspaceID, userRole)
+	ctx := services.WithQueryExecutionID(services.WithQueryUser(c.Context(), userIDStr), executionID)
+	ctx = services.WithQuerySource(ctx, services.QuerySourceVisual, id)
+	result, err := h.queryBuilder.ExecuteQuery(ctx, &config, &conn, h.queryExecutor, userIDStr, id, workspaceID, userRole)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to execute query: %v", err)})
+		return c.Status(queryErrorStatus(err)).JSON(fiber.Map{
+			"error":       fmt.Sprintf("Failed to execute query: %v", err),
+			"executionId": executionID,
+		})
 	}
+	result.ExecutionID = executionID
 
 	// Store params for debugging
 	_ = params
 
 	return c.JSON(fiber.Map{
-		"sql":     generatedSQL,
-		"results": result,
+		"sql":         generatedSQL,
+		"results":     result,
+		"executionId": executionID,
 	})
 }
 
+// ExportVisualQuery streams a visual query's full result as a download
+// GET /api/visual-queries/:id/export?format=csv|xlsx|parquet|ndjson
+func (h *VisualQueryHandler) ExportVisualQuery(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+	if userID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	format, err := services.ParseResultFormat(c.Query("format"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	id := c.Params("id")
+
+	var visualQuery models.VisualQuery
+	if err := h.db.Where("id = ? AND user_id = ?", id, userID).First(&visualQuery).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Visual query not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch visual query"})
+	}
+
+	var conn models.Connection
+	if err := h.db.Where("id = ?", visualQuery.ConnectionID).First(&conn).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch connection"})
+	}
+
+	var config models.VisualQueryConfig
+	if err := json.Unmarshal(visualQuery.Config, &config); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse config"})
+	}
+
+	// Unlike the preview, the export keeps the query's own limit
+	userIDStr, workspaceID, userRole := h.getUserContext(c)
+	generatedSQL, params, err := h.queryBuilder.BuildSQL(c.Context(), &config, &conn, userIDStr, workspaceID, userRole)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Failed to generate SQL: %v", err)})
+	}
+
+	ctx := services.WithQuerySource(services.WithQueryUser(context.Background(), userIDStr), services.QuerySourceExport, id)
+	stream, err := h.queryExecutor.Stream(ctx, &conn, generatedSQL, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to execute query: %v", err)})
+	}
+
+	return streamResultExport(c, stream, format, services.ResultFileName(visualQuery.Name, format))
+}
+
 // GetVisualQueries retrieves user's visual queries with pagination
 // GET /api/visual-queries
 func (h *VisualQueryHandler) GetVisualQueries(c *fiber.Ctx) error {
@@ -38,7 +38,7 @@ func setupTestHandler(t *testing.T) (*VisualQueryHandler, *gorm.DB) {
 	queryExecutor := services.NewQueryExecutor()
 	schemaDiscovery := services.NewSchemaDiscovery(queryExecutor)
 	queryValidator := services.NewQueryValidator([]string{})
-	queryBuilder := services.NewQueryBuilder(queryValidator, schemaDiscovery, nil)
+	queryBuilder := services.NewQueryBuilder(queryValidator, schemaDiscovery, nil, nil)
 
 	handler := NewVisualQueryHandler(db, queryBuilder, queryExecutor, schemaDiscovery, nil)
 
@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"insight-engine-backend/database"
+	"insight-engine-backend/models"
 	"insight-engine-backend/services"
 	"time"
 
@@ -10,16 +14,33 @@ import (
 
 // WebSocketHandler handles WebSocket connections
 type WebSocketHandler struct {
-	wsHub *services.WebSocketHub
+	wsHub         *services.WebSocketHub
+	queryExecutor *services.QueryExecutor
 }
 
 // NewWebSocketHandler creates a new WebSocket handler
-func NewWebSocketHandler(wsHub *services.WebSocketHub) *WebSocketHandler {
+func NewWebSocketHandler(wsHub *services.WebSocketHub, queryExecutor *services.QueryExecutor) *WebSocketHandler {
 	return &WebSocketHandler{
-		wsHub: wsHub,
+		wsHub:         wsHub,
+		queryExecutor: queryExecutor,
 	}
 }
 
+// wsQueryRequest asks for a query's rows to be streamed over the socket, or for a running
+// query to be cancelled. To execute, either QueryID (a saved query) or ConnectionID and SQL
+// must be set; to cancel, ExecutionID names the query.
+type wsQueryRequest struct {
+	services.QueryPage
+	Type         string `json:"type"` // query.execute or query.cancel
+	RequestID    string `json:"requestId"`
+	ExecutionID  string `json:"executionId"`
+	Source       string `json:"source"` // "ai" for generated SQL
+	QueryID      string `json:"queryId"`
+	ConnectionID string `json:"connectionId"`
+	SQL          string `json:"sql"`
+	ChunkSize    int    `json:"chunkSize"`
+}
+
 // HandleConnection handles WebSocket connection upgrade and communication
 func (h *WebSocketHandler) HandleConnection(c *websocket.Conn) {
 	// Get user ID from locals (set by auth middleware)
@@ -47,13 +68,17 @@ func (h *WebSocketHandler) HandleConnection(c *websocket.Conn) {
 	// Register client
 	h.wsHub.Register(client)
 
+	// Streams started by this connection stop when it closes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Start goroutines for reading and writing
 	go h.writePump(client)
-	h.readPump(client)
+	h.readPump(ctx, client)
 }
 
 // readPump reads messages from the WebSocket connection
-func (h *WebSocketHandler) readPump(client *services.WebSocketClient) {
+func (h *WebSocketHandler) readPump(ctx context.Context, client *services.WebSocketClient) {
 	defer func() {
 		h.wsHub.Unregister(client)
 		client.Conn.Close()
@@ -77,7 +102,91 @@ func (h *WebSocketHandler) readPump(client *services.WebSocketClient) {
 
 		// Handle incoming messages (ping/pong, etc)
 		services.LogDebug("websocket_message_received", "Message received from client", map[string]interface{}{"user_id": client.UserID, "message_length": len(message)})
+
+		var req wsQueryRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			continue
+		}
+		switch req.Type {
+		case "query.execute":
+			go h.streamQuery(ctx, client, &req)
+		case "query.cancel":
+			go h.cancelQuery(ctx, client, &req)
+		}
+	}
+}
+
+// streamQuery runs a query for the client and sends its rows as they arrive, as
+// query.columns, query.rows (one per chunk) and finally query.done or query.error messages
+func (h *WebSocketHandler) streamQuery(ctx context.Context, client *services.WebSocketClient, req *wsQueryRequest) {
+	executionID, err := parseExecutionID(req.ExecutionID)
+	send := func(event *services.QueryStreamEvent) error {
+		event.RequestID = req.RequestID
+		event.ExecutionID = executionID
+		return h.wsHub.SendToClient(ctx, client, "query."+event.Type, event)
+	}
+	fail := func(message string) {
+		send(&services.QueryStreamEvent{Type: "error", Error: message})
+	}
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+
+	sql := req.SQL
+	source := queryRequestSource(req.Source)
+	var conn models.Connection
+	if req.QueryID != "" {
+		var query models.SavedQuery
+		if err := database.DB.Where("id = ? AND user_id = ?", req.QueryID, client.UserID).First(&query).Error; err != nil {
+			fail("Query not found")
+			return
+		}
+		sql = query.SQL
+		req.ConnectionID = query.ConnectionID
+		source = services.QuerySourceSaved
+	}
+	if sql == "" || req.ConnectionID == "" {
+		fail("SQL and ConnectionID are required")
+		return
+	}
+	if err := database.DB.Where("id = ? AND user_id = ?", req.ConnectionID, client.UserID).First(&conn).Error; err != nil {
+		fail("Connection not found")
+		return
+	}
+
+	queryCtx := services.WithQueryExecutionID(services.WithQueryUser(ctx, client.UserID), executionID)
+	queryCtx = services.WithQuerySource(queryCtx, source, req.QueryID)
+	stream, err := h.queryExecutor.StreamPage(queryCtx, &conn, sql, req.QueryPage)
+	if err != nil {
+		fail("Query execution failed: " + err.Error())
+		return
+	}
+	defer stream.Close()
+
+	if err := services.PumpQueryStream(stream, req.ChunkSize, send); err != nil {
+		services.LogWarn("websocket_query_stream_failed", "WebSocket query stream ended early", map[string]interface{}{
+			"user_id":    client.UserID,
+			"request_id": req.RequestID,
+			"rows":       stream.RowsRead(),
+			"error":      err,
+		})
+	}
+}
+
+// cancelQuery cancels one of the client's running queries and answers with query.cancelled,
+// or query.error when the query is not running
+func (h *WebSocketHandler) cancelQuery(ctx context.Context, client *services.WebSocketClient, req *wsQueryRequest) {
+	event := &services.QueryStreamEvent{Type: "cancelled", RequestID: req.RequestID, ExecutionID: req.ExecutionID}
+	governor := h.queryExecutor.Governor()
+	err := services.ErrQueryNotFound
+	if governor != nil {
+		err = governor.Cancel(req.ExecutionID, client.UserID, governor.IsAdmin(client.UserID))
+	}
+	if err != nil {
+		event.Type, event.Error = "error", err.Error()
 	}
+	h.wsHub.SendToClient(ctx, client, "query."+event.Type, event)
 }
 
 // writePump writes messages to the WebSocket connection
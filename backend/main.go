@@ -46,8 +46,7 @@ func main() {
 
 	// 2.8. Initialize Semantic Layer Service and Handler
 	semanticLayerService := services.NewSemanticLayerService(database.DB)
-	semanticLayerHandler := handlers.NewSemanticLayerHandler(semanticLayerService)
-	services.LogInfo("semantic_layer_init", "Semantic layer handler initialized successfully", nil)
+	services.LogInfo("semantic_layer_init", "Semantic layer service initialized successfully", nil)
 
 	// 2.9. Initialize Modeling Service and Handler
 	modelingService := services.NewModelingService(database.DB)
@@ -85,39 +84,25 @@ func main() {
 	schedulerHandler := handlers.NewSchedulerHandler(schedulerService)
 	services.LogInfo("scheduler_init", "Scheduler service initialized successfully", nil)
 
-	// 2.15. Initialize WebSocket Handler
-	wsHandler := handlers.NewWebSocketHandler(wsHub)
-	services.LogInfo("ws_handler_init", "WebSocket handler initialized successfully", nil)
-
 	// 2.16. Initialize Audit Service (Comprehensive logging for compliance)
 	auditService := services.NewAuditService(database.DB)
 	auditHandler := handlers.NewAuditHandler(auditService)
 	services.LogInfo("audit_init", "Audit service initialized (async logging with 5 workers)", nil)
 
-	// 3. Initialize Job Queue (5 workers)
-	services.InitJobQueue(5)
-	services.LogInfo("job_queue_init", "Job queue initialized with 5 workers", nil)
-
-	// Setup graceful shutdown
-	go func() {
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-		<-sigChan
-		services.LogInfo("graceful_shutdown", "Shutting down gracefully", nil)
-		cronService.Stop()
-		schedulerService.Stop()
-		auditService.Stop() // Flush pending audit logs
-		services.ShutdownJobQueue()
-		os.Exit(0)
-	}()
-
 	// 4. Initialize Fiber App
+	// File ingestion accepts uploads up to 100 MB; every other route keeps the default limit
+	const ingestBodyLimit = 100 * 1024 * 1024
 	app := fiber.New(fiber.Config{
-		AppName: "InsightEngine Backend (Go)",
+		AppName:   "InsightEngine Backend (Go)",
+		BodyLimit: ingestBodyLimit,
 	})
 
 	// 4. Middleware
 	app.Use(logger.New())
+	app.Use(middleware.BodyLimit(middleware.DefaultBodyLimit, map[string]int{
+		"/api/ingest":         ingestBodyLimit,
+		"/api/ingest/preview": ingestBodyLimit,
+	}))
 
 	// Hardened CORS Middleware (whitelist-based, environment-driven)
 	corsConfig := middleware.LoadCORSConfigFromEnv()
@@ -178,7 +163,21 @@ func main() {
 	// ---------------------------------------------------------
 
 	// 1. Core Services
+	// Uploaded files served by sqlite and file connections
+	if storageDir := os.Getenv("FILE_STORAGE_DIR"); storageDir != "" {
+		services.SetFileStorageDir(storageDir)
+	}
 	queryExecutor := services.NewQueryExecutor()
+	// Concurrency, runtime and row limits per connection and role
+	queryGovernor := services.NewQueryGovernor(database.DB)
+	queryExecutor.SetGovernor(queryGovernor)
+	// Every query run is recorded for /queries/history
+	queryExecutor.SetHistory(services.NewQueryHistory(database.DB))
+
+	// WebSocket handler (streams query results, so it needs the executor)
+	wsHandler := handlers.NewWebSocketHandler(wsHub, queryExecutor)
+	services.LogInfo("ws_handler_init", "WebSocket handler initialized successfully", nil)
+
 	schemaDiscovery := services.NewSchemaDiscovery(queryExecutor)
 	queryValidator := services.NewQueryValidator([]string{})
 
@@ -217,6 +216,8 @@ func main() {
 	visualQueryHandler := handlers.NewVisualQueryHandler(database.DB, queryBuilder, queryExecutor, schemaDiscovery, queryCache)
 	connectionHandler := handlers.NewConnectionHandler(queryExecutor, schemaDiscovery)
 	queryHandler := handlers.NewQueryHandler(queryExecutor)
+	semanticLayerHandler := handlers.NewSemanticLayerHandler(semanticLayerService, queryExecutor, schemaDiscovery)
+	queryGovernanceHandler := handlers.NewQueryGovernanceHandler(queryGovernor)
 	queryAnalyzerHandler := handlers.NewQueryAnalyzerHandler(database.DB, queryExecutor)
 	materializedViewService := services.NewMaterializedViewService(database.DB, queryExecutor)
 	materializedViewHandler := handlers.NewMaterializedViewHandler(database.DB, materializedViewService)
@@ -225,6 +226,55 @@ func main() {
 
 	services.LogInfo("services_init", "Core services initialized", map[string]interface{}{"services": []string{"RLS", "Engine", "Query", "MaterializedViews", "GeoJSON"}})
 
+	// 5. Initialize Job Queue (5 workers) backed by the pipeline executor and dataflow runner
+	pipelineExecutor := services.NewPipelineExecutor(database.DB, queryExecutor)
+	tempTableService := services.NewTempTableService(database.DB)
+	dataflowRunner := services.NewDataflowRunner(database.DB, queryExecutor, tempTableService, materializedViewService)
+	services.InitJobQueue(5, pipelineExecutor, dataflowRunner)
+	services.LogInfo("job_queue_init", "Job queue initialized with 5 workers", nil)
+
+	ingestionService := services.NewIngestionService(database.DB, pipelineExecutor)
+	ingestionHandler := handlers.NewIngestionHandler(ingestionService)
+
+	// Evaluate alerts on their schedules, notifying by email, webhook and in-app notification
+	alertService := services.NewAlertService(database.DB, queryExecutor, emailService, notificationService)
+	if err := alertService.Start(); err != nil {
+		services.LogError("alert_scheduler_init", "Failed to start alert scheduler", map[string]interface{}{"error": err})
+	}
+
+	// Deliver scheduled dashboard reports by email
+	exportDir := os.Getenv("EXPORT_DIR")
+	if exportDir == "" {
+		exportDir = "./exports"
+	}
+	var reportDeliveryService *services.ReportDeliveryService
+	exportService, err := services.NewExportService(database.DB, queryExecutor, exportDir, os.Getenv("BASE_URL"))
+	if err != nil {
+		services.LogError("export_service_init", "Failed to initialize export service", map[string]interface{}{"error": err})
+	} else {
+		reportDeliveryService = services.NewReportDeliveryService(database.DB, exportService, emailService)
+		if err := reportDeliveryService.Start(); err != nil {
+			services.LogError("report_delivery_init", "Failed to start report delivery worker", map[string]interface{}{"error": err})
+		}
+	}
+
+	// Setup graceful shutdown
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		<-sigChan
+		services.LogInfo("graceful_shutdown", "Shutting down gracefully", nil)
+		cronService.Stop()
+		schedulerService.Stop()
+		alertService.Stop()
+		if reportDeliveryService != nil {
+			reportDeliveryService.Stop()
+		}
+		auditService.Stop() // Flush pending audit logs
+		services.ShutdownJobQueue()
+		os.Exit(0)
+	}()
+
 	// Health Check Endpoints (Public)
 	// Basic health check
 	api.Get("/health", func(c *fiber.Ctx) error {
@@ -275,13 +325,21 @@ func main() {
 
 	// Query Routes (Protected)
 	// Query Routes (Protected)
+	// Queued, running and past queries (registered before /queries/:id)
+	api.Get("/queries/active", middleware.AuthMiddleware, queryGovernanceHandler.ListActiveQueries)
+	api.Delete("/queries/active/:id", middleware.AuthMiddleware, queryGovernanceHandler.CancelQuery)
+	api.Get("/queries/history", middleware.AuthMiddleware, queryHandler.GetQueryHistory)
+	api.Post("/queries/history/:id/rerun", middleware.AuthMiddleware, queryHandler.RerunHistoryQuery)
 	api.Get("/queries", middleware.AuthMiddleware, queryHandler.GetQueries)
 	api.Post("/queries", middleware.AuthMiddleware, queryHandler.CreateQuery)
 	api.Get("/queries/:id", middleware.AuthMiddleware, queryHandler.GetQuery)
 	api.Put("/queries/:id", middleware.AuthMiddleware, queryHandler.UpdateQuery)
 	api.Delete("/queries/:id", middleware.AuthMiddleware, queryHandler.DeleteQuery)
 	api.Post("/queries/:id/run", middleware.AuthMiddleware, queryHandler.RunQuery)
+	api.Get("/queries/:id/export", middleware.AuthMiddleware, queryHandler.ExportQuery)
 	api.Post("/queries/execute", middleware.AuthMiddleware, queryHandler.ExecuteAdHocQuery)
+	api.Post("/queries/execute/stream", middleware.AuthMiddleware, queryHandler.StreamAdHocQuery)
+	api.Post("/queries/:id/stream", middleware.AuthMiddleware, queryHandler.StreamQuery)
 
 	// Query Analyzer Routes (Protected) - Phase 2.5 Query Optimization (TASK-075)
 	api.Post("/query/analyze", middleware.AuthMiddleware, queryAnalyzerHandler.AnalyzeQueryPlan)
@@ -309,6 +367,13 @@ func main() {
 	api.Delete("/connections/:id", middleware.AuthMiddleware, connectionHandler.DeleteConnection)
 	api.Post("/connections/:id/test", middleware.AuthMiddleware, connectionHandler.TestConnection)
 	api.Get("/connections/:id/schema", middleware.AuthMiddleware, connectionHandler.GetConnectionSchema)
+	// Admin-only live pool statistics
+	api.Get("/admin/connection-pools", middleware.AuthMiddleware, middleware.AdminMiddleware, connectionHandler.GetPoolStats)
+	// Query governance policies (Admin Only)
+	api.Get("/admin/query-policies", middleware.AuthMiddleware, middleware.AdminMiddleware, queryGovernanceHandler.ListPolicies)
+	api.Post("/admin/query-policies", middleware.AuthMiddleware, middleware.AdminMiddleware, queryGovernanceHandler.CreatePolicy)
+	api.Put("/admin/query-policies/:id", middleware.AuthMiddleware, middleware.AdminMiddleware, queryGovernanceHandler.UpdatePolicy)
+	api.Delete("/admin/query-policies/:id", middleware.AuthMiddleware, middleware.AdminMiddleware, queryGovernanceHandler.DeletePolicy)
 
 	// Engine Routes (Protected) - Advanced Analytics
 	// Engine Routes (Protected) - Advanced Analytics
@@ -331,7 +396,13 @@ func main() {
 	api.Delete("/dashboards/:id/cards", middleware.AuthMiddleware, handlers.RemoveCard)
 
 	// Dashboard Schedule Routes (Protected)
+	api.Get("/dashboards/:id/schedule", middleware.AuthMiddleware, handlers.GetSchedules)
 	api.Post("/dashboards/:id/schedule", middleware.AuthMiddleware, handlers.CreateSchedule)
+	api.Put("/dashboards/:id/schedule/:scheduleId", middleware.AuthMiddleware, handlers.UpdateSchedule)
+	api.Post("/dashboards/:id/schedule/:scheduleId/pause", middleware.AuthMiddleware, handlers.PauseSchedule)
+	api.Post("/dashboards/:id/schedule/:scheduleId/resume", middleware.AuthMiddleware, handlers.ResumeSchedule)
+	api.Delete("/dashboards/:id/schedule/:scheduleId", middleware.AuthMiddleware, handlers.DeleteSchedule)
+	api.Get("/dashboards/:id/schedule/:scheduleId/deliveries", middleware.AuthMiddleware, handlers.GetScheduleDeliveries)
 
 	// Pipeline Routes (Protected) - Batch 2
 	api.Get("/pipelines", middleware.AuthMiddleware, handlers.GetPipelines)
@@ -341,6 +412,7 @@ func main() {
 	api.Put("/pipelines/:id", middleware.AuthMiddleware, handlers.UpdatePipeline)
 	api.Delete("/pipelines/:id", middleware.AuthMiddleware, handlers.DeletePipeline)
 	api.Post("/pipelines/:id/run", middleware.AuthMiddleware, handlers.RunPipeline)
+	api.Get("/pipelines/:id/quality", middleware.AuthMiddleware, handlers.GetPipelineQuality)
 
 	// Dataflow Routes (Protected) - Batch 2
 	api.Get("/dataflows", middleware.AuthMiddleware, handlers.GetDataflows)
@@ -350,8 +422,8 @@ func main() {
 	api.Post("/dataflows/:id/run", middleware.AuthMiddleware, handlers.RunDataflow)
 
 	// Ingestion Routes (Protected) - Batch 2
-	api.Post("/ingest", middleware.AuthMiddleware, handlers.IngestData)
-	api.Post("/ingest/preview", middleware.AuthMiddleware, handlers.PreviewIngest)
+	api.Post("/ingest", middleware.AuthMiddleware, ingestionHandler.IngestData)
+	api.Post("/ingest/preview", middleware.AuthMiddleware, ingestionHandler.PreviewIngest)
 
 	// Collection Routes (Protected) - Batch 3
 	api.Get("/collections", middleware.AuthMiddleware, handlers.GetCollections)
@@ -475,10 +547,23 @@ func main() {
 	// Semantic Layer Routes (Protected) - Business-friendly data layer
 	api.Get("/semantic/models", middleware.AuthMiddleware, semanticLayerHandler.ListSemanticModels)
 	api.Post("/semantic/models", middleware.AuthMiddleware, semanticLayerHandler.CreateSemanticModel)
+	api.Post("/semantic/models/migrate", middleware.AuthMiddleware, semanticLayerHandler.MigrateModelDefinitions)
+	api.Get("/semantic/models/:id", middleware.AuthMiddleware, semanticLayerHandler.GetSemanticModel)
+	api.Put("/semantic/models/:id", middleware.AuthMiddleware, semanticLayerHandler.UpdateSemanticModel)
+	api.Delete("/semantic/models/:id", middleware.AuthMiddleware, semanticLayerHandler.DeleteSemanticModel)
+	api.Get("/semantic/models/:id/relationships", middleware.AuthMiddleware, semanticLayerHandler.ListSemanticRelationships)
+	api.Get("/semantic/models/:id/invalid-metrics", middleware.AuthMiddleware, semanticLayerHandler.ListInvalidSemanticMetrics)
+	api.Post("/semantic/relationships", middleware.AuthMiddleware, semanticLayerHandler.CreateSemanticRelationship)
+	api.Delete("/semantic/relationships/:id", middleware.AuthMiddleware, semanticLayerHandler.DeleteSemanticRelationship)
 	api.Get("/semantic/metrics", middleware.AuthMiddleware, semanticLayerHandler.ListSemanticMetrics)
+	api.Post("/semantic/metrics", middleware.AuthMiddleware, semanticLayerHandler.CreateSemanticMetric)
+	api.Get("/semantic/metrics/:id", middleware.AuthMiddleware, semanticLayerHandler.GetSemanticMetric)
+	api.Put("/semantic/metrics/:id", middleware.AuthMiddleware, semanticLayerHandler.UpdateSemanticMetric)
+	api.Delete("/semantic/metrics/:id", middleware.AuthMiddleware, semanticLayerHandler.DeleteSemanticMetric)
 	api.Post("/semantic/query", middleware.AuthMiddleware, semanticLayerHandler.ExecuteSemanticQuery)
 
-	// Modeling API Routes (Protected) - Metric definitions for governance
+	// Modeling API Routes (Protected) - Deprecated: superseded by the semantic layer, which
+	// POST /semantic/models/migrate converts these definitions into. Migrated definitions are read-only.
 	api.Get("/modeling/definitions", middleware.AuthMiddleware, modelingHandler.ListModelDefinitions)
 	api.Post("/modeling/definitions", middleware.AuthMiddleware, modelingHandler.CreateModelDefinition)
 	api.Get("/modeling/definitions/:id", middleware.AuthMiddleware, modelingHandler.GetModelDefinition)
@@ -522,6 +607,7 @@ func main() {
 	api.Delete("/visual-queries/:id", middleware.AuthMiddleware, visualQueryHandler.DeleteVisualQuery)
 	api.Post("/visual-queries/generate-sql", middleware.AuthMiddleware, visualQueryHandler.GenerateSQL)
 	api.Post("/visual-queries/:id/preview", middleware.AuthMiddleware, visualQueryHandler.PreviewVisualQuery)
+	api.Get("/visual-queries/:id/export", middleware.AuthMiddleware, visualQueryHandler.ExportVisualQuery)
 	api.Get("/visual-queries/cache/stats", middleware.AuthMiddleware, visualQueryHandler.GetCacheStats)
 	api.Post("/visual-queries/join-suggestions", middleware.AuthMiddleware, visualQueryHandler.GetJoinSuggestions)
 
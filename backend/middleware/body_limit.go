@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// DefaultBodyLimit is the request body limit of routes without one of their own (Fiber's default)
+const DefaultBodyLimit = 4 * 1024 * 1024
+
+// BodyLimit rejects requests whose body is larger than limit. The paths in pathLimits, such
+// as upload routes, get their own limit instead. The server's BodyLimit must allow the
+// largest of these limits, since the server rejects larger bodies before any handler runs.
+func BodyLimit(limit int, pathLimits map[string]int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		max := limit
+		if pathLimit, ok := pathLimits[c.Path()]; ok {
+			max = pathLimit
+		}
+
+		size := c.Request().Header.ContentLength()
+		if size < 0 {
+			// Chunked bodies have no declared length
+			size = len(c.Body())
+		}
+		if size > max {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": "Request body too large",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,17 @@
+-- Migration: Add Alert Evaluation
+-- Description: Tracks the current breach of each alert for notification de-duplication and records every evaluation
+-- Date: 2026-10-16
+ALTER TABLE "Alert"
+ADD COLUMN IF NOT EXISTS triggered_at TIMESTAMP;
+CREATE TABLE IF NOT EXISTS "AlertHistory" (
+    id TEXT PRIMARY KEY,
+    alert_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    -- OK, TRIGGERED, ERROR
+    value DOUBLE PRECISION,
+    message TEXT,
+    notified BOOLEAN NOT NULL DEFAULT false,
+    executed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_alert_history_alert ON "AlertHistory"(alert_id, executed_at DESC);
+COMMENT ON COLUMN "Alert".triggered_at IS 'Start of the current breach; NULL while the alert is OK. Notifications are only sent when a breach begins';
@@ -0,0 +1,24 @@
+-- Migration: Add Quality Check Results
+-- Description: Stores per-rule pass/fail counts of every pipeline execution so data quality can be charted over time
+-- Date: 2026-10-16
+CREATE TABLE IF NOT EXISTS "QualityCheckResult" (
+    id VARCHAR(36) PRIMARY KEY,
+    pipeline_id VARCHAR(36) NOT NULL,
+    execution_id VARCHAR(36) NOT NULL,
+    rule_id VARCHAR(36) NOT NULL,
+    "column" TEXT NOT NULL,
+    rule_type VARCHAR(20) NOT NULL,
+    -- NOT_NULL, UNIQUE, RANGE, REGEX
+    severity VARCHAR(10) NOT NULL,
+    -- WARN, FAIL
+    rows_checked INTEGER NOT NULL DEFAULT 0,
+    rows_passed INTEGER NOT NULL DEFAULT 0,
+    rows_failed INTEGER NOT NULL DEFAULT 0,
+    passed BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_quality_check_results_pipeline ON "QualityCheckResult"(pipeline_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_quality_check_results_execution ON "QualityCheckResult"(execution_id);
+CREATE INDEX IF NOT EXISTS idx_quality_check_results_rule ON "QualityCheckResult"(rule_id, created_at DESC);
+COMMENT ON TABLE "QualityCheckResult" IS 'Per-rule data quality outcome of each pipeline execution';
+COMMENT ON COLUMN "QualityCheckResult".rows_failed IS 'Rows that broke the rule; FAIL-severity violations are moved to <table>_quarantine';
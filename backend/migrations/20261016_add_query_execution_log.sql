@@ -0,0 +1,27 @@
+-- Migration: Add Query Execution Log
+-- Description: History of every query run (ad-hoc, saved, visual, semantic, AI-generated) for search and re-runs
+-- Date: 2026-10-16
+CREATE TABLE IF NOT EXISTS "QueryExecutionLog" (
+    id VARCHAR(36) PRIMARY KEY,
+    query_id TEXT,
+    sql TEXT NOT NULL,
+    connection_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    source VARCHAR(20) NOT NULL DEFAULT 'adhoc',
+    -- adhoc, saved, visual, semantic, ai, export, system
+    execution_id TEXT,
+    status VARCHAR(20) NOT NULL,
+    -- success, error, timeout, cancelled, rejected
+    row_count INTEGER NOT NULL DEFAULT 0,
+    execution_time BIGINT NOT NULL DEFAULT 0,
+    error_message TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+ALTER TABLE "QueryExecutionLog" ADD COLUMN IF NOT EXISTS source VARCHAR(20) NOT NULL DEFAULT 'adhoc';
+ALTER TABLE "QueryExecutionLog" ADD COLUMN IF NOT EXISTS execution_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_query_execution_log_user_created ON "QueryExecutionLog"(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_query_execution_log_connection_created ON "QueryExecutionLog"(connection_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_query_execution_log_status ON "QueryExecutionLog"(status);
+CREATE INDEX IF NOT EXISTS idx_query_execution_log_execution_time ON "QueryExecutionLog"(execution_time DESC);
+COMMENT ON TABLE "QueryExecutionLog" IS 'One row per query run through the query executor; searched by /api/queries/history';
+COMMENT ON COLUMN "QueryExecutionLog".execution_time IS 'Milliseconds from start (including queueing) to the last row read';
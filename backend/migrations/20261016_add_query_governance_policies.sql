@@ -0,0 +1,26 @@
+-- Migration: Add Query Governance Policies
+-- Description: Per-connection and per-role limits on concurrent queries, runtime, rows returned and queueing
+-- Date: 2026-10-16
+CREATE TABLE IF NOT EXISTS "QueryGovernancePolicy" (
+    id VARCHAR(36) PRIMARY KEY,
+    name TEXT NOT NULL,
+    connection_id VARCHAR(36),
+    -- NULL applies to every connection
+    role TEXT,
+    -- NULL applies to every role
+    max_concurrent_queries INTEGER NOT NULL DEFAULT 0,
+    max_concurrent_per_user INTEGER NOT NULL DEFAULT 0,
+    max_runtime_seconds INTEGER NOT NULL DEFAULT 0,
+    max_rows INTEGER NOT NULL DEFAULT 0,
+    queue_mode VARCHAR(10) NOT NULL DEFAULT 'queue',
+    -- queue, reject
+    max_queue_length INTEGER NOT NULL DEFAULT 0,
+    queue_timeout_seconds INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_by TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_query_governance_policies_connection ON "QueryGovernancePolicy"(connection_id);
+COMMENT ON TABLE "QueryGovernancePolicy" IS 'Query limits; the most specific enabled policy (connection and role, connection, role, global) applies';
+COMMENT ON COLUMN "QueryGovernancePolicy".max_concurrent_queries IS 'Running queries allowed on the connection across all users; 0 is unlimited';
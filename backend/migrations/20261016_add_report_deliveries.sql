@@ -0,0 +1,24 @@
+-- Migration: Add Report Deliveries
+-- Description: Tracks the delivery state of scheduled dashboard reports and records every delivery attempt
+-- Date: 2026-10-16
+ALTER TABLE "ReportSchedule"
+ADD COLUMN IF NOT EXISTS last_run_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS last_status VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_report_schedule_due ON "ReportSchedule"(next_run_at)
+WHERE is_active = true;
+CREATE TABLE IF NOT EXISTS "ReportDelivery" (
+    id VARCHAR(255) PRIMARY KEY,
+    schedule_id VARCHAR(255) NOT NULL,
+    dashboard_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    -- SENT, FAILED
+    attempt INTEGER NOT NULL,
+    recipients TEXT NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    file_size BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_report_delivery_schedule ON "ReportDelivery"(schedule_id, created_at DESC);
+COMMENT ON COLUMN "ReportSchedule".attempts IS 'Failed attempts of the current run; failed deliveries are retried with backoff before advancing next_run_at';
@@ -0,0 +1,17 @@
+-- Migration: Unify Metric Store
+-- Description: Lets semantic models read from a query and carry metric data types, so that model and
+-- metric definitions from the modeling API can be migrated into the semantic layer
+-- Date: 2026-10-16
+ALTER TABLE semantic_models
+ADD COLUMN IF NOT EXISTS source_query TEXT;
+ALTER TABLE semantic_metrics
+ADD COLUMN IF NOT EXISTS data_type VARCHAR(50);
+ALTER TABLE model_definitions
+ADD COLUMN IF NOT EXISTS semantic_model_id VARCHAR(255) REFERENCES semantic_models(id) ON DELETE
+SET NULL;
+ALTER TABLE metric_definitions
+ADD COLUMN IF NOT EXISTS semantic_metric_id VARCHAR(255) REFERENCES semantic_metrics(id) ON DELETE
+SET NULL;
+COMMENT ON COLUMN semantic_models.source_query IS 'SELECT the model reads from instead of a table; table_name then names its results';
+COMMENT ON COLUMN model_definitions.semantic_model_id IS 'Semantic model the definition was migrated to; the semantic layer owns it from then on';
+COMMENT ON COLUMN metric_definitions.semantic_metric_id IS 'Semantic metric the definition was migrated to; the semantic layer owns it from then on';
@@ -18,13 +18,30 @@ type Alert struct {
 	WebhookUrl     *string `gorm:"type:text"`
 	WebhookHeaders []byte  `gorm:"type:jsonb"` // Using []byte for raw JSON
 	LastRunAt      *time.Time
-	LastStatus     *string
-	UserId         string    `gorm:"type:text;not null"`
-	CreatedAt      time.Time `gorm:"autoCreateTime"`
-	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+	LastStatus     *string    // OK, TRIGGERED, ERROR
+	TriggeredAt    *time.Time // Start of the current breach; notifications are only sent when it begins
+	UserId         string     `gorm:"type:text;not null"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime"`
 }
 
 // TableName overrides the table name used by User to `Alert`
 func (Alert) TableName() string {
 	return "Alert" // Prisma default naming (Capitalized if not mapped)
 }
+
+// AlertHistory records the outcome of every alert evaluation
+type AlertHistory struct {
+	ID         string    `gorm:"primaryKey;type:text" json:"id"`
+	AlertID    string    `gorm:"type:text;not null;index" json:"alertId"`
+	Status     string    `gorm:"type:text;not null" json:"status"` // OK, TRIGGERED, ERROR
+	Value      *float64  `json:"value"`                            // The value compared against the threshold
+	Message    *string   `gorm:"type:text" json:"message"`
+	Notified   bool      `gorm:"default:false" json:"notified"` // Whether notifications were sent for this run
+	ExecutedAt time.Time `gorm:"autoCreateTime" json:"executedAt"`
+}
+
+// TableName overrides the table name used by AlertHistory to `AlertHistory`
+func (AlertHistory) TableName() string {
+	return "AlertHistory"
+}
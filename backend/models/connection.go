@@ -42,7 +42,14 @@ type ConnectionDTO struct {
 	UpdatedAt time.Time               `json:"updatedAt"`
 }
 
-// ToDTO converts Connection to DTO (strips password)
+// ConnectionSecretOptions lists the fields of the Options objects that are stored
+// encrypted, like Password, and never returned by the API
+var ConnectionSecretOptions = map[string][]string{
+	"tls": {"clientKey"},
+	"ssh": {"password", "privateKey", "passphrase"},
+}
+
+// ToDTO converts Connection to DTO (strips password and secret options)
 func (c *Connection) ToDTO() ConnectionDTO {
 	return ConnectionDTO{
 		ID:        c.ID,
@@ -52,10 +59,35 @@ func (c *Connection) ToDTO() ConnectionDTO {
 		Port:      c.Port,
 		Database:  c.Database,
 		Username:  c.Username,
-		Options:   c.Options,
+		Options:   c.publicOptions(),
 		IsActive:  c.IsActive,
 		UserID:    c.UserID,
 		CreatedAt: c.CreatedAt,
 		UpdatedAt: c.UpdatedAt,
 	}
 }
+
+// publicOptions returns a copy of Options without the secret fields
+func (c *Connection) publicOptions() *map[string]interface{} {
+	if c.Options == nil {
+		return nil
+	}
+	options := make(map[string]interface{}, len(*c.Options))
+	for key, value := range *c.Options {
+		options[key] = value
+		secrets, hasSecrets := ConnectionSecretOptions[key]
+		nested, isObject := value.(map[string]interface{})
+		if !hasSecrets || !isObject {
+			continue
+		}
+		public := make(map[string]interface{}, len(nested))
+		for field, v := range nested {
+			public[field] = v
+		}
+		for _, field := range secrets {
+			delete(public, field)
+		}
+		options[key] = public
+	}
+	return &options
+}
@@ -18,8 +18,10 @@ type ModelDefinition struct {
 	CreatedBy   string             `gorm:"not null" json:"createdBy"`
 	Metadata    datatypes.JSON     `json:"metadata,omitempty"`
 	Metrics     []MetricDefinition `gorm:"foreignKey:ModelID" json:"metrics,omitempty"`
-	CreatedAt   time.Time          `json:"createdAt"`
-	UpdatedAt   time.Time          `json:"updatedAt"`
+	// Set once the definition has been migrated to the semantic layer, which owns it from then on
+	SemanticModelID *string   `json:"semanticModelId,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
 }
 
 // TableName specifies the table name for GORM
@@ -40,8 +42,10 @@ type MetricDefinition struct {
 	WorkspaceID     string         `gorm:"uniqueIndex:idx_workspace_metric;not null" json:"workspaceId"`
 	CreatedBy       string         `gorm:"not null" json:"createdBy"`
 	Metadata        datatypes.JSON `json:"metadata,omitempty"`
-	CreatedAt       time.Time      `json:"createdAt"`
-	UpdatedAt       time.Time      `json:"updatedAt"`
+	// Set once the metric has been migrated to the semantic layer, which owns it from then on
+	SemanticMetricID *string   `json:"semanticMetricId,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
 }
 
 // TableName specifies the table name for GORM
@@ -35,6 +35,9 @@ type Pipeline struct {
 	Executions   []JobExecution `json:"executions,omitempty" gorm:"foreignKey:PipelineID"`
 	QualityRules []QualityRule  `json:"qualityRules,omitempty" gorm:"foreignKey:PipelineID"`
 
+	// Per-rule quality results of the most recent run that checked any, loaded on request
+	LatestQualityResults []QualityCheckResult `json:"latestQualityResults,omitempty" gorm:"-"`
+
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
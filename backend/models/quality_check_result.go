@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// QualityCheckResult stores the outcome of one quality rule for one pipeline execution
+type QualityCheckResult struct {
+	ID          string `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	PipelineID  string `json:"pipelineId" gorm:"not null;index"`
+	ExecutionID string `json:"executionId" gorm:"not null;index"`
+	RuleID      string `json:"ruleId" gorm:"not null;index"`
+
+	Column   string `json:"column" gorm:"not null"`
+	RuleType string `json:"ruleType" gorm:"not null"` // NOT_NULL, UNIQUE, RANGE, REGEX
+	Severity string `json:"severity" gorm:"not null"` // WARN, FAIL
+
+	RowsChecked int  `json:"rowsChecked" gorm:"default:0"`
+	RowsPassed  int  `json:"rowsPassed" gorm:"default:0"`
+	RowsFailed  int  `json:"rowsFailed" gorm:"default:0"`
+	Passed      bool `json:"passed"`
+
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+}
+
+// TableName specifies the table name for GORM
+func (QualityCheckResult) TableName() string {
+	return "QualityCheckResult"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Queue modes of a QueryGovernancePolicy
+const (
+	QueueModeQueue  = "queue"  // Wait for a free slot
+	QueueModeReject = "reject" // Fail immediately when the limit is reached
+)
+
+// QueryGovernancePolicy limits the queries run against a connection and/or by users of a
+// role. The most specific enabled policy applies: connection and role, then connection,
+// then role, then a global policy with neither set.
+type QueryGovernancePolicy struct {
+	ID           string  `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Name         string  `json:"name" gorm:"not null"`
+	ConnectionID *string `json:"connectionId" gorm:"index"` // nil applies to every connection
+	Role         *string `json:"role"`                      // nil applies to every role
+
+	MaxConcurrentQueries int    `json:"maxConcurrentQueries"` // Across all users of a connection; 0 is unlimited
+	MaxConcurrentPerUser int    `json:"maxConcurrentPerUser"` // Per user and connection; 0 is unlimited
+	MaxRuntimeSeconds    int    `json:"maxRuntimeSeconds"`    // 0 keeps the default timeout
+	MaxRows              int    `json:"maxRows"`              // 0 is unlimited
+	QueueMode            string `json:"queueMode" gorm:"default:'queue'"`
+	MaxQueueLength       int    `json:"maxQueueLength"`      // Queued queries per connection; 0 is unlimited
+	QueueTimeoutSeconds  int    `json:"queueTimeoutSeconds"` // 0 waits as long as the query may run
+
+	Enabled   bool      `json:"enabled" gorm:"default:true"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName specifies the table name for GORM
+func (QueryGovernancePolicy) TableName() string {
+	return "QueryGovernancePolicy"
+}
@@ -4,13 +4,38 @@ import (
 	"time"
 )
 
+// Logical column types of a query result, independent of the database dialect
+const (
+	ColumnTypeInteger   = "integer"
+	ColumnTypeDecimal   = "decimal"
+	ColumnTypeText      = "text"
+	ColumnTypeBoolean   = "boolean"
+	ColumnTypeDate      = "date"
+	ColumnTypeTimestamp = "timestamp"
+	ColumnTypeJSON      = "json"
+	ColumnTypeBinary    = "binary"
+)
+
+// ColumnMetadata describes a column of a query result
+type ColumnMetadata struct {
+	Name         string `json:"name"`
+	DatabaseType string `json:"databaseType"` // As reported by the driver, e.g. NUMERIC or VARCHAR
+	Type         string `json:"type"`         // Logical type, one of the ColumnType constants
+	Nullable     *bool  `json:"nullable,omitempty"`
+	Precision    *int64 `json:"precision,omitempty"`
+	Scale        *int64 `json:"scale,omitempty"`
+}
+
 // QueryResult represents the result of a query execution
 type QueryResult struct {
-	Columns       []string        `json:"columns"`
-	Rows          [][]interface{} `json:"rows"`
-	RowCount      int             `json:"rowCount"`
-	ExecutionTime int64           `json:"executionTime"` // milliseconds
-	Error         *string         `json:"error,omitempty"`
+	Columns       []string         `json:"columns"`
+	ColumnTypes   []ColumnMetadata `json:"columnTypes"` // Same order as Columns
+	Rows          [][]interface{}  `json:"rows"`
+	RowCount      int              `json:"rowCount"`
+	ExecutionTime int64            `json:"executionTime"`       // milliseconds
+	Truncated     bool             `json:"truncated,omitempty"` // Cut off at the governance policy's row limit
+	ExecutionID   string           `json:"executionId,omitempty"`
+	Error         *string          `json:"error,omitempty"`
 }
 
 // QueryExecutionRequest represents a request to execute a query
@@ -20,16 +45,29 @@ type QueryExecutionRequest struct {
 	Limit        *int                   `json:"limit"`
 	Offset       *int                   `json:"offset"`
 	Parameters   map[string]interface{} `json:"parameters"`
+	ExecutionID  string                 `json:"executionId"` // Optional; lets the client cancel the query while it runs
+	Source       string                 `json:"source"`      // "ai" for generated SQL; ad-hoc otherwise
 }
 
+// Statuses of a QueryExecutionLog
+const (
+	QueryStatusSuccess   = "success"
+	QueryStatusError     = "error"
+	QueryStatusTimeout   = "timeout"
+	QueryStatusCancelled = "cancelled"
+	QueryStatusRejected  = "rejected" // Turned away by a query governance policy
+)
+
 // QueryExecutionLog for audit trail
 type QueryExecutionLog struct {
 	ID            string    `gorm:"primaryKey;type:text" json:"id"`
-	QueryID       *string   `gorm:"type:text" json:"queryId"`
+	QueryID       *string   `gorm:"type:text" json:"queryId"` // Saved or visual query the SQL came from
 	SQL           string    `gorm:"type:text;not null" json:"sql"`
 	ConnectionID  string    `gorm:"type:text;not null" json:"connectionId"`
 	UserID        string    `gorm:"type:text;not null" json:"userId"`
-	Status        string    `gorm:"type:text;not null" json:"status"` // success, error, timeout
+	Source        string    `gorm:"type:text;not null;default:adhoc" json:"source"` // adhoc, saved, visual, semantic, ai, export, system
+	ExecutionID   *string   `gorm:"type:text" json:"executionId"`
+	Status        string    `gorm:"type:text;not null" json:"status"` // success, error, timeout, cancelled, rejected
 	RowCount      int       `gorm:"type:integer" json:"rowCount"`
 	ExecutionTime int64     `gorm:"type:bigint" json:"executionTime"` // milliseconds
 	ErrorMessage  *string   `gorm:"type:text" json:"errorMessage"`
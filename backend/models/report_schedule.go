@@ -6,15 +6,18 @@ import (
 
 // ReportSchedule represents a scheduled dashboard report
 type ReportSchedule struct {
-	ID          string    `gorm:"primaryKey;type:varchar(255)" json:"id"`
-	DashboardID string    `gorm:"type:varchar(255);not null;index" json:"dashboardId"`
-	Frequency   string    `gorm:"type:varchar(50);not null" json:"frequency"` // DAILY, WEEKLY, MONTHLY
-	Email       string    `gorm:"type:varchar(255);not null" json:"email"`
-	Format      string    `gorm:"type:varchar(10);not null" json:"format"` // PDF, PNG
-	IsActive    bool      `gorm:"default:true" json:"isActive"`
-	NextRunAt   time.Time `gorm:"not null" json:"nextRunAt"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"createdAt"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+	ID          string     `gorm:"primaryKey;type:varchar(255)" json:"id"`
+	DashboardID string     `gorm:"type:varchar(255);not null;index" json:"dashboardId"`
+	Frequency   string     `gorm:"type:varchar(50);not null" json:"frequency"` // DAILY, WEEKLY, MONTHLY
+	Email       string     `gorm:"type:varchar(255);not null" json:"email"`
+	Format      string     `gorm:"type:varchar(10);not null" json:"format"` // PDF, PNG
+	IsActive    bool       `gorm:"default:true" json:"isActive"`
+	NextRunAt   time.Time  `gorm:"not null" json:"nextRunAt"`
+	LastRunAt   *time.Time `json:"lastRunAt"`
+	LastStatus  *string    `gorm:"type:varchar(20)" json:"lastStatus"` // SENT, FAILED
+	Attempts    int        `gorm:"default:0" json:"attempts"`          // Failed attempts of the current run; reset once it is sent or given up
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
 
 	// Relationships
 	Dashboard *Dashboard `gorm:"foreignKey:DashboardID" json:"dashboard,omitempty"`
@@ -25,6 +28,25 @@ func (ReportSchedule) TableName() string {
 	return "ReportSchedule"
 }
 
+// ReportDelivery records one attempt to deliver a scheduled report
+type ReportDelivery struct {
+	ID          string    `gorm:"primaryKey;type:varchar(255)" json:"id"`
+	ScheduleID  string    `gorm:"type:varchar(255);not null;index" json:"scheduleId"`
+	DashboardID string    `gorm:"type:varchar(255);not null" json:"dashboardId"`
+	Status      string    `gorm:"type:varchar(20);not null" json:"status"` // SENT, FAILED
+	Attempt     int       `gorm:"not null" json:"attempt"`
+	Recipients  string    `gorm:"type:text;not null" json:"recipients"`
+	Format      string    `gorm:"type:varchar(10);not null" json:"format"`
+	FileSize    int64     `json:"fileSize"`
+	Error       *string   `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// TableName specifies the table name for ReportDelivery
+func (ReportDelivery) TableName() string {
+	return "ReportDelivery"
+}
+
 // CalculateNextRun calculates the next run time based on frequency
 func CalculateNextRun(frequency string) time.Time {
 	now := time.Now()
@@ -11,6 +11,7 @@ type SemanticModel struct {
 	Description  string              `json:"description"`
 	DataSourceID string              `gorm:"not null" json:"dataSourceId"`
 	Table        string              `gorm:"column:table_name;not null" json:"tableName"`
+	SourceQuery  string              `json:"sourceQuery,omitempty"` // SELECT read instead of Table, whose results Table then names
 	WorkspaceID  string              `gorm:"uniqueIndex:idx_workspace_model_name;not null" json:"workspaceId"`
 	CreatedBy    string              `gorm:"not null" json:"createdBy"`
 	Dimensions   []SemanticDimension `gorm:"foreignKey:ModelID;constraint:OnDelete:CASCADE" json:"dimensions,omitempty"`
@@ -39,7 +40,8 @@ type SemanticMetric struct {
 	Name        string    `gorm:"uniqueIndex:idx_model_metric_name;not null" json:"name"`
 	Formula     string    `gorm:"not null" json:"formula"` // e.g., "SUM(revenue)", "COUNT(*)", "AVG(price)"
 	Description string    `json:"description"`
-	Format      string    `json:"format"` // currency, percentage, number, etc.
+	Format      string    `json:"format"`             // currency, percentage, number, etc.
+	DataType    string    `json:"dataType,omitempty"` // number, currency, percentage, count, decimal
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
 }
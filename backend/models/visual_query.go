@@ -30,14 +30,17 @@ func (VisualQuery) TableName() string {
 
 // VisualQueryConfig represents the visual query configuration structure
 type VisualQueryConfig struct {
-	Tables       []TableSelection  `json:"tables"`
-	Joins        []JoinConfig      `json:"joins"`
-	Columns      []ColumnSelection `json:"columns"`
-	Filters      []FilterCondition `json:"filters"`
-	Aggregations []Aggregation     `json:"aggregations"`
-	GroupBy      []string          `json:"groupBy"`
-	OrderBy      []OrderByClause   `json:"orderBy"`
-	Limit        *int              `json:"limit"`
+	Tables            []TableSelection   `json:"tables"`
+	Joins             []JoinConfig       `json:"joins"`
+	Columns           []ColumnSelection  `json:"columns"`
+	DateBuckets       []DateBucket       `json:"dateBuckets,omitempty"`
+	CalculatedColumns []CalculatedColumn `json:"calculatedColumns,omitempty"`
+	Filters           []FilterCondition  `json:"filters"`
+	Aggregations      []Aggregation      `json:"aggregations"`
+	WindowColumns     []WindowColumn     `json:"windowColumns,omitempty"`
+	GroupBy           []string           `json:"groupBy"`
+	OrderBy           []OrderByClause    `json:"orderBy"`
+	Limit             *int               `json:"limit"`
 }
 
 // TableSelection represents a selected table in the query
@@ -63,12 +66,20 @@ type ColumnSelection struct {
 	Aggregation *string `json:"aggregation"` // SUM, AVG, COUNT, MIN, MAX
 }
 
-// FilterCondition represents a filter condition
+// FilterCondition represents a filter condition, or a nested group of conditions when Group is set
 type FilterCondition struct {
-	Column   string      `json:"column"`
-	Operator string      `json:"operator"` // =, !=, >, <, >=, <=, LIKE, IN, BETWEEN
-	Value    interface{} `json:"value"`
-	Logic    string      `json:"logic"` // AND, OR
+	Column   string       `json:"column"`
+	Operator string       `json:"operator"` // =, !=, >, <, >=, <=, LIKE, NOT LIKE, ILIKE, IN, NOT IN, BETWEEN, IS NULL, IS NOT NULL, RELATIVE_DATE
+	Value    interface{}  `json:"value"`    // For RELATIVE_DATE: today, yesterday, this_week, last_month, last_7_days, ...
+	Logic    string       `json:"logic"`    // AND, OR
+	Group    *FilterGroup `json:"group,omitempty"`
+}
+
+// FilterGroup combines conditions with a single logical operator and is parenthesised in the
+// generated SQL, so (a OR b) AND c is a group followed by a condition. Groups can be nested.
+type FilterGroup struct {
+	Logic   string            `json:"logic"` // AND, OR
+	Filters []FilterCondition `json:"filters"`
 }
 
 // Aggregation represents an aggregation function
@@ -78,6 +89,36 @@ type Aggregation struct {
 	Alias    string `json:"alias"`
 }
 
+// DateBucket truncates a date column to the start of its day, week, month, quarter or year.
+// Aggregated queries are grouped by the bucket.
+type DateBucket struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Grain  string `json:"grain"` // day, week, month, quarter, year
+	Alias  string `json:"alias"` // Defaults to <column>_<grain>
+}
+
+// CalculatedColumn is a user-defined expression over the query's columns, with the operators
+// and functions metric formulas allow, e.g. "orders.price * orders.quantity" or
+// "SUM(orders.amount) / COUNT(*)"
+type CalculatedColumn struct {
+	Expression string `json:"expression"`
+	Alias      string `json:"alias"`
+}
+
+// WindowColumn computes a window function over the rows of the query. Column, PartitionBy and
+// OrderBy name output columns of the query (a column, or an aggregation, bucket or calculated
+// column alias).
+type WindowColumn struct {
+	Function    string          `json:"function"` // RUNNING_TOTAL, RANK, DENSE_RANK, ROW_NUMBER, LAG, LEAD, PERCENT_OF_TOTAL, MOVING_AVERAGE
+	Column      string          `json:"column"`
+	Alias       string          `json:"alias"`
+	PartitionBy []string        `json:"partitionBy"`
+	OrderBy     []OrderByClause `json:"orderBy"`
+	Offset      int             `json:"offset"` // LAG/LEAD distance, default 1
+	Window      int             `json:"window"` // MOVING_AVERAGE rows including the current one, default 3
+}
+
 // OrderByClause represents an ORDER BY clause
 type OrderByClause struct {
 	Column    string `json:"column"`
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"insight-engine-backend/models"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// Alert statuses
+const (
+	AlertStatusOK        = "OK"
+	AlertStatusTriggered = "TRIGGERED"
+	AlertStatusError     = "ERROR"
+)
+
+// alertScheduleAliases maps the schedule presets offered by the UI to cron descriptors
+var alertScheduleAliases = map[string]string{
+	"hourly": "@hourly",
+	"daily":  "@daily",
+	"weekly": "@weekly",
+}
+
+// ParseAlertSchedule parses an alert schedule: "hourly", "daily", "weekly" or a cron expression
+func ParseAlertSchedule(schedule string) (cron.Schedule, error) {
+	spec := strings.TrimSpace(schedule)
+	if alias, ok := alertScheduleAliases[strings.ToLower(spec)]; ok {
+		spec = alias
+	}
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", schedule, err)
+	}
+	return sched, nil
+}
+
+// CompareThreshold reports whether value breaches the threshold under operator (>, <, =, >=, <=, !=)
+func CompareThreshold(value float64, operator string, threshold float64) (bool, error) {
+	switch strings.TrimSpace(operator) {
+	case ">":
+		return value > threshold, nil
+	case "<":
+		return value < threshold, nil
+	case "=", "==":
+		return value == threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	case "!=", "<>":
+		return value != threshold, nil
+	default:
+		return false, fmt.Errorf("unsupported operator %q", operator)
+	}
+}
+
+// ValidateAlert checks that an alert can be scheduled and evaluated
+func ValidateAlert(alert *models.Alert) error {
+	if alert.Column == "" {
+		return fmt.Errorf("column is required")
+	}
+	if _, err := CompareThreshold(0, alert.Operator, alert.Threshold); err != nil {
+		return err
+	}
+	if _, err := ParseAlertSchedule(alert.Schedule); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AlertService evaluates active alerts on their schedules and notifies when they trigger
+type AlertService struct {
+	db            *gorm.DB
+	queryExecutor *QueryExecutor
+	email         *EmailService
+	notifications *NotificationService
+	httpClient    *http.Client
+	cron          *cron.Cron
+
+	mu      sync.Mutex
+	running map[string]bool // Alerts currently being evaluated
+}
+
+// NewAlertService creates a new alert service
+func NewAlertService(db *gorm.DB, queryExecutor *QueryExecutor, email *EmailService, notifications *NotificationService) *AlertService {
+	return &AlertService{
+		db:            db,
+		queryExecutor: queryExecutor,
+		email:         email,
+		notifications: notifications,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		cron:          cron.New(),
+		running:       make(map[string]bool),
+	}
+}
+
+// Start checks every minute for alerts whose schedule is due
+func (s *AlertService) Start() error {
+	if _, err := s.cron.AddFunc("@every 1m", s.tick); err != nil {
+		return fmt.Errorf("failed to schedule alert evaluation: %w", err)
+	}
+	s.cron.Start()
+	LogInfo("alert_scheduler_start", "Alert scheduler started", nil)
+	return nil
+}
+
+// Stop stops the alert scheduler
+func (s *AlertService) Stop() {
+	s.cron.Stop()
+	LogInfo("alert_scheduler_stop", "Alert scheduler stopped", nil)
+}
+
+// tick evaluates every active alert that is due
+func (s *AlertService) tick() {
+	var alerts []models.Alert
+	if err := s.db.Where("is_active = ?", true).Find(&alerts).Error; err != nil {
+		LogError("alert_scheduler_load", "Failed to load active alerts", map[string]interface{}{"error": err})
+		return
+	}
+
+	now := time.Now()
+	for i := range alerts {
+		alert := alerts[i]
+		due, err := alertDue(&alert, now)
+		if err != nil {
+			LogWarn("alert_invalid_schedule", "Skipping alert with invalid schedule", map[string]interface{}{"alert_id": alert.ID, "error": err})
+			continue
+		}
+		if due && s.acquire(alert.ID) {
+			go func() {
+				defer s.release(alert.ID)
+				s.Evaluate(context.Background(), &alert)
+			}()
+		}
+	}
+}
+
+// alertDue reports whether the alert's next scheduled run after its last run has passed
+func alertDue(alert *models.Alert, now time.Time) (bool, error) {
+	sched, err := ParseAlertSchedule(alert.Schedule)
+	if err != nil {
+		return false, err
+	}
+	last := alert.CreatedAt
+	if alert.LastRunAt != nil {
+		last = *alert.LastRunAt
+	}
+	return !sched.Next(last).After(now), nil
+}
+
+// acquire marks an alert as running, returning false if a previous evaluation is still in progress
+func (s *AlertService) acquire(alertID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[alertID] {
+		return false
+	}
+	s.running[alertID] = true
+	return true
+}
+
+func (s *AlertService) release(alertID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, alertID)
+}
+
+// Evaluate runs the alert's saved query, records the outcome and notifies when a breach begins.
+// An alert that stays breached is not notified again until it has recovered.
+func (s *AlertService) Evaluate(ctx context.Context, alert *models.Alert) *models.AlertHistory {
+	now := time.Now()
+	history := &models.AlertHistory{
+		ID:         uuid.New().String(),
+		AlertID:    alert.ID,
+		ExecutedAt: now,
+	}
+	updates := map[string]interface{}{"last_run_at": now}
+
+	value, breached, err := s.check(ctx, alert)
+	history.Value = value
+
+	switch {
+	case err != nil:
+		history.Status = AlertStatusError
+		message := err.Error()
+		history.Message = &message
+		LogWarn("alert_evaluation_failed", "Alert evaluation failed", map[string]interface{}{"alert_id": alert.ID, "error": err})
+
+	case breached:
+		history.Status = AlertStatusTriggered
+		message := fmt.Sprintf("%s is %v (%s %v)", alert.Column, *value, alert.Operator, alert.Threshold)
+		history.Message = &message
+		if alert.TriggeredAt == nil {
+			// Breaches nobody could be told about are notified again on the next evaluation
+			history.Notified = s.notify(ctx, alert, *value, message)
+			if history.Notified {
+				updates["triggered_at"] = now
+			}
+		}
+
+	default:
+		history.Status = AlertStatusOK
+		updates["triggered_at"] = nil
+	}
+	updates["last_status"] = history.Status
+
+	if err := s.db.Model(&models.Alert{}).Where("id = ?", alert.ID).Updates(updates).Error; err != nil {
+		LogError("alert_status_update", "Failed to update alert status", map[string]interface{}{"alert_id": alert.ID, "error": err})
+	}
+	if err := s.db.Create(history).Error; err != nil {
+		LogError("alert_history_save", "Failed to save alert history", map[string]interface{}{"alert_id": alert.ID, "error": err})
+	}
+
+	LogInfo("alert_evaluated", "Alert evaluated", map[string]interface{}{
+		"alert_id": alert.ID,
+		"status":   history.Status,
+		"notified": history.Notified,
+	})
+	return history
+}
+
+// check runs the saved query and compares the alert column against the threshold.
+// The alert breaches when any returned row does; value is the first breaching value,
+// or the first row's value when none breach.
+func (s *AlertService) check(ctx context.Context, alert *models.Alert) (*float64, bool, error) {
+	var query models.SavedQuery
+	if err := s.db.Where("id = ?", alert.QueryId).First(&query).Error; err != nil {
+		return nil, false, fmt.Errorf("saved query not found")
+	}
+
+	// Only the alert owner's connections can be queried
+	var conn models.Connection
+	if err := s.db.Where("id = ? AND user_id = ?", query.ConnectionID, alert.UserId).First(&conn).Error; err != nil {
+		return nil, false, fmt.Errorf("connection not found")
+	}
+
+	queryCtx := WithQuerySource(WithQueryUser(ctx, alert.UserId), QuerySourceSystem, query.ID)
+	result, err := s.queryExecutor.Execute(queryCtx, &conn, query.SQL, nil, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("query failed: %w", err)
+	}
+
+	return evaluateAlertRows(alert, result)
+}
+
+// evaluateAlertRows compares the alert column of each result row against the threshold
+func evaluateAlertRows(alert *models.Alert, result *models.QueryResult) (*float64, bool, error) {
+	colIdx := -1
+	for i, col := range result.Columns {
+		if strings.EqualFold(col, alert.Column) {
+			colIdx = i
+			break
+		}
+	}
+	if colIdx < 0 {
+		return nil, false, fmt.Errorf("column %q not found in query result", alert.Column)
+	}
+	if len(result.Rows) == 0 {
+		return nil, false, nil
+	}
+
+	var first *float64
+	for _, row := range result.Rows {
+		if colIdx >= len(row) || row[colIdx] == nil {
+			continue
+		}
+		value, ok := toFloat(row[colIdx])
+		if !ok {
+			return nil, false, fmt.Errorf("column %q is not numeric: %v", alert.Column, row[colIdx])
+		}
+		breached, err := CompareThreshold(value, alert.Operator, alert.Threshold)
+		if err != nil {
+			return nil, false, err
+		}
+		if breached {
+			return &value, true, nil
+		}
+		if first == nil {
+			first = &value
+		}
+	}
+	return first, false, nil
+}
+
+// notify delivers a triggered alert by email, webhook and in-app notification.
+// It reports whether at least one channel succeeded; failures are logged.
+func (s *AlertService) notify(ctx context.Context, alert *models.Alert, value float64, message string) bool {
+	delivered := false
+	alertPath := "/alerts/" + alert.ID
+
+	if s.email != nil {
+		for _, recipient := range splitRecipients(alert.Email) {
+			if err := s.email.SendAlertEmail(recipient, alert.Name, message, alertPath); err != nil {
+				LogError("alert_email_failed", "Failed to send alert email", map[string]interface{}{"alert_id": alert.ID, "error": err})
+				continue
+			}
+			delivered = true
+		}
+	}
+
+	if alert.WebhookUrl != nil && *alert.WebhookUrl != "" {
+		if err := s.sendWebhook(ctx, alert, value, message); err != nil {
+			LogError("alert_webhook_failed", "Failed to deliver alert webhook", map[string]interface{}{"alert_id": alert.ID, "error": err})
+		} else {
+			delivered = true
+		}
+	}
+
+	if s.notifications != nil {
+		userID, err := uuid.Parse(alert.UserId)
+		if err != nil {
+			LogWarn("alert_notification_skipped", "Alert owner is not a valid user ID", map[string]interface{}{"alert_id": alert.ID})
+		} else if err := s.notifications.SendNotification(userID, "Alert triggered: "+alert.Name, message, "warning", alertPath, nil); err != nil {
+			LogError("alert_notification_failed", "Failed to send alert notification", map[string]interface{}{"alert_id": alert.ID, "error": err})
+		} else {
+			delivered = true
+		}
+	}
+
+	return delivered
+}
+
+// sendWebhook POSTs the alert outcome as JSON with the alert's custom headers
+func (s *AlertService) sendWebhook(ctx context.Context, alert *models.Alert, value float64, message string) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"alertId":   alert.ID,
+		"alertName": alert.Name,
+		"status":    AlertStatusTriggered,
+		"column":    alert.Column,
+		"operator":  alert.Operator,
+		"threshold": alert.Threshold,
+		"value":     value,
+		"message":   message,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *alert.WebhookUrl, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(alert.WebhookHeaders) > 0 {
+		var headers map[string]string
+		if err := json.Unmarshal(alert.WebhookHeaders, &headers); err != nil {
+			return fmt.Errorf("invalid webhook headers: %w", err)
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
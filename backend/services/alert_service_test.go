@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"insight-engine-backend/models"
+)
+
+// TestEvaluateAlertRows tests threshold comparison across result rows
+func TestEvaluateAlertRows(t *testing.T) {
+	alert := &models.Alert{Column: "Revenue", Operator: ">", Threshold: 100}
+	result := &models.QueryResult{
+		Columns: []string{"region", "revenue"},
+		Rows:    [][]interface{}{{"north", int64(80)}, {"south", nil}, {"east", "150.5"}},
+	}
+
+	value, breached, err := evaluateAlertRows(alert, result)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !breached || value == nil || *value != 150.5 {
+		t.Errorf("Expected breach at 150.5, got breached=%v value=%v", breached, value)
+	}
+
+	alert.Threshold = 200
+	value, breached, err = evaluateAlertRows(alert, result)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if breached || value == nil || *value != 80 {
+		t.Errorf("Expected no breach with first value 80, got breached=%v value=%v", breached, value)
+	}
+
+	alert.Column = "missing"
+	if _, _, err := evaluateAlertRows(alert, result); err == nil {
+		t.Error("Expected error for missing column")
+	}
+}
+
+// TestAlertDue tests schedule presets and cron expressions against the last run
+func TestAlertDue(t *testing.T) {
+	lastRun := time.Date(2026, 10, 16, 9, 30, 0, 0, time.Local)
+	alert := &models.Alert{Schedule: "hourly", LastRunAt: &lastRun}
+
+	if due, _ := alertDue(alert, lastRun.Add(20*time.Minute)); due {
+		t.Error("Expected hourly alert not to be due before the next hour")
+	}
+	if due, _ := alertDue(alert, lastRun.Add(30*time.Minute)); !due {
+		t.Error("Expected hourly alert to be due at the next hour")
+	}
+
+	alert.Schedule = "*/15 * * * *"
+	if due, _ := alertDue(alert, lastRun.Add(15*time.Minute)); !due {
+		t.Error("Expected cron alert to be due")
+	}
+
+	alert.Schedule = "sometimes"
+	if _, err := alertDue(alert, lastRun); err == nil {
+		t.Error("Expected error for invalid schedule")
+	}
+}
@@ -0,0 +1,770 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// rasterFont is a 5x7 pixel font for ASCII 32-126; each row's low 5 bits are the pixels, left to right
+var rasterFont = [95][7]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // ' '
+	{0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04}, // '!'
+	{0x0a, 0x0a, 0x0a, 0x00, 0x00, 0x00, 0x00}, // '"'
+	{0x0a, 0x0a, 0x1f, 0x0a, 0x1f, 0x0a, 0x0a}, // '#'
+	{0x04, 0x0f, 0x14, 0x0e, 0x05, 0x1e, 0x04}, // '$'
+	{0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03}, // '%'
+	{0x0c, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0d}, // '&'
+	{0x04, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00}, // '\''
+	{0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02}, // '('
+	{0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08}, // ')'
+	{0x00, 0x04, 0x15, 0x0e, 0x15, 0x04, 0x00}, // '*'
+	{0x00, 0x04, 0x04, 0x1f, 0x04, 0x04, 0x00}, // '+'
+	{0x00, 0x00, 0x00, 0x00, 0x0c, 0x04, 0x08}, // ','
+	{0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00}, // '-'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x0c}, // '.'
+	{0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00}, // '/'
+	{0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e}, // '0'
+	{0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e}, // '1'
+	{0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f}, // '2'
+	{0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e}, // '3'
+	{0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02}, // '4'
+	{0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e}, // '5'
+	{0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e}, // '6'
+	{0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08}, // '7'
+	{0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e}, // '8'
+	{0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c}, // '9'
+	{0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x0c, 0x00}, // ':'
+	{0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x04, 0x08}, // ';'
+	{0x02, 0x04, 0x08, 0x10, 0x08, 0x04, 0x02}, // '<'
+	{0x00, 0x00, 0x1f, 0x00, 0x1f, 0x00, 0x00}, // '='
+	{0x08, 0x04, 0x02, 0x01, 0x02, 0x04, 0x08}, // '>'
+	{0x0e, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04}, // '?'
+	{0x0e, 0x11, 0x01, 0x0d, 0x15, 0x15, 0x0e}, // '@'
+	{0x0e, 0x11, 0x11, 0x11, 0x1f, 0x11, 0x11}, // 'A'
+	{0x1e, 0x11, 0x11, 0x1e, 0x11, 0x11, 0x1e}, // 'B'
+	{0x0e, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0e}, // 'C'
+	{0x1c, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1c}, // 'D'
+	{0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x1f}, // 'E'
+	{0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x10}, // 'F'
+	{0x0e, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0f}, // 'G'
+	{0x11, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11}, // 'H'
+	{0x0e, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e}, // 'I'
+	{0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0c}, // 'J'
+	{0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11}, // 'K'
+	{0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1f}, // 'L'
+	{0x11, 0x1b, 0x15, 0x15, 0x11, 0x11, 0x11}, // 'M'
+	{0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11}, // 'N'
+	{0x0e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e}, // 'O'
+	{0x1e, 0x11, 0x11, 0x1e, 0x10, 0x10, 0x10}, // 'P'
+	{0x0e, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0d}, // 'Q'
+	{0x1e, 0x11, 0x11, 0x1e, 0x14, 0x12, 0x11}, // 'R'
+	{0x0f, 0x10, 0x10, 0x0e, 0x01, 0x01, 0x1e}, // 'S'
+	{0x1f, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04}, // 'T'
+	{0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e}, // 'U'
+	{0x11, 0x11, 0x11, 0x11, 0x11, 0x0a, 0x04}, // 'V'
+	{0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0a}, // 'W'
+	{0x11, 0x11, 0x0a, 0x04, 0x0a, 0x11, 0x11}, // 'X'
+	{0x11, 0x11, 0x11, 0x0a, 0x04, 0x04, 0x04}, // 'Y'
+	{0x1f, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1f}, // 'Z'
+	{0x0e, 0x08, 0x08, 0x08, 0x08, 0x08, 0x0e}, // '['
+	{0x00, 0x10, 0x08, 0x04, 0x02, 0x01, 0x00}, // '\\'
+	{0x0e, 0x02, 0x02, 0x02, 0x02, 0x02, 0x0e}, // ']'
+	{0x04, 0x0a, 0x11, 0x00, 0x00, 0x00, 0x00}, // '^'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1f}, // '_'
+	{0x08, 0x04, 0x02, 0x00, 0x00, 0x00, 0x00}, // '`'
+	{0x00, 0x00, 0x0e, 0x01, 0x0f, 0x11, 0x0f}, // 'a'
+	{0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x1e}, // 'b'
+	{0x00, 0x00, 0x0e, 0x10, 0x10, 0x11, 0x0e}, // 'c'
+	{0x01, 0x01, 0x0d, 0x13, 0x11, 0x11, 0x0f}, // 'd'
+	{0x00, 0x00, 0x0e, 0x11, 0x1f, 0x10, 0x0e}, // 'e'
+	{0x06, 0x09, 0x08, 0x1c, 0x08, 0x08, 0x08}, // 'f'
+	{0x00, 0x0f, 0x11, 0x11, 0x0f, 0x01, 0x0e}, // 'g'
+	{0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x11}, // 'h'
+	{0x04, 0x00, 0x0c, 0x04, 0x04, 0x04, 0x0e}, // 'i'
+	{0x02, 0x00, 0x06, 0x02, 0x02, 0x12, 0x0c}, // 'j'
+	{0x10, 0x10, 0x12, 0x14, 0x18, 0x14, 0x12}, // 'k'
+	{0x0c, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e}, // 'l'
+	{0x00, 0x00, 0x1a, 0x15, 0x15, 0x11, 0x11}, // 'm'
+	{0x00, 0x00, 0x16, 0x19, 0x11, 0x11, 0x11}, // 'n'
+	{0x00, 0x00, 0x0e, 0x11, 0x11, 0x11, 0x0e}, // 'o'
+	{0x00, 0x00, 0x1e, 0x11, 0x1e, 0x10, 0x10}, // 'p'
+	{0x00, 0x00, 0x0d, 0x13, 0x0f, 0x01, 0x01}, // 'q'
+	{0x00, 0x00, 0x16, 0x19, 0x10, 0x10, 0x10}, // 'r'
+	{0x00, 0x00, 0x0e, 0x10, 0x0e, 0x01, 0x1e}, // 's'
+	{0x08, 0x08, 0x1c, 0x08, 0x08, 0x09, 0x06}, // 't'
+	{0x00, 0x00, 0x11, 0x11, 0x11, 0x13, 0x0d}, // 'u'
+	{0x00, 0x00, 0x11, 0x11, 0x11, 0x0a, 0x04}, // 'v'
+	{0x00, 0x00, 0x11, 0x11, 0x15, 0x15, 0x0a}, // 'w'
+	{0x00, 0x00, 0x11, 0x0a, 0x04, 0x0a, 0x11}, // 'x'
+	{0x00, 0x00, 0x11, 0x11, 0x0f, 0x01, 0x0e}, // 'y'
+	{0x00, 0x00, 0x1f, 0x02, 0x04, 0x08, 0x1f}, // 'z'
+	{0x02, 0x04, 0x04, 0x08, 0x04, 0x04, 0x02}, // '{'
+	{0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04}, // '|'
+	{0x08, 0x04, 0x04, 0x02, 0x04, 0x04, 0x08}, // '}'
+	{0x00, 0x00, 0x08, 0x15, 0x02, 0x00, 0x00}, // '~'
+}
+
+const (
+	rasterGlyphWidth   = 5
+	rasterGlyphHeight  = 7
+	rasterGlyphAdvance = 6 // Glyph width plus one pixel of spacing
+)
+
+// chartPalette matches the default series colors of the dashboard charts
+var chartPalette = []color.RGBA{
+	{84, 112, 198, 255}, {145, 204, 117, 255}, {250, 200, 88, 255}, {238, 102, 102, 255}, {115, 192, 222, 255},
+	{59, 162, 114, 255}, {252, 132, 82, 255}, {154, 96, 180, 255}, {234, 124, 204, 255},
+}
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartText       = color.RGBA{17, 24, 39, 255}
+	chartMutedText  = color.RGBA{107, 114, 128, 255}
+	chartGrid       = color.RGBA{229, 231, 235, 255}
+	chartHeaderFill = color.RGBA{243, 244, 246, 255}
+	chartErrorText  = color.RGBA{185, 28, 28, 255}
+)
+
+// Chart kinds drawn by the renderer; every other visualization type is drawn as a table
+const (
+	ChartKindBar   = "bar"
+	ChartKindLine  = "line"
+	ChartKindPie   = "pie"
+	ChartKindTable = "table"
+)
+
+// rasterCanvas draws shapes and pixel-font text onto an RGBA image
+type rasterCanvas struct {
+	img *image.RGBA
+}
+
+func newRasterCanvas(width, height int, background color.RGBA) *rasterCanvas {
+	c := &rasterCanvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+	c.fillRect(0, 0, width, height, background)
+	return c
+}
+
+// fillRect fills the rectangle [x0,x1) x [y0,y1), clipped to the canvas
+func (c *rasterCanvas) fillRect(x0, y0, x1, y1 int, col color.RGBA) {
+	r := image.Rect(x0, y0, x1, y1).Intersect(c.img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c.img.SetRGBA(x, y, col)
+		}
+	}
+}
+
+// strokeRect draws a one-pixel rectangle outline
+func (c *rasterCanvas) strokeRect(x0, y0, x1, y1 int, col color.RGBA) {
+	c.fillRect(x0, y0, x1, y0+1, col)
+	c.fillRect(x0, y1-1, x1, y1, col)
+	c.fillRect(x0, y0, x0+1, y1, col)
+	c.fillRect(x1-1, y0, x1, y1, col)
+}
+
+// line draws a line of the given thickness using Bresenham's algorithm
+func (c *rasterCanvas) line(x0, y0, x1, y1, thickness int, col color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	half := thickness / 2
+	for err := dx + dy; ; {
+		c.fillRect(x0-half, y0-half, x0-half+thickness, y0-half+thickness, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// text draws text with its top-left corner at x, y, each font pixel scaled to scale x scale
+func (c *rasterCanvas) text(x, y, scale int, col color.RGBA, text string) {
+	for _, r := range text {
+		if r < 32 || r > 126 {
+			r = '?'
+		}
+		glyph := rasterFont[r-32]
+		for row := 0; row < rasterGlyphHeight; row++ {
+			for bit := 0; bit < rasterGlyphWidth; bit++ {
+				if glyph[row]&(1<<(rasterGlyphWidth-1-bit)) != 0 {
+					px, py := x+bit*scale, y+row*scale
+					c.fillRect(px, py, px+scale, py+scale, col)
+				}
+			}
+		}
+		x += rasterGlyphAdvance * scale
+	}
+}
+
+// rasterTextWidth returns the width in pixels of text drawn at scale
+func rasterTextWidth(text string, scale int) int {
+	n := len([]rune(text))
+	if n == 0 {
+		return 0
+	}
+	return (n*rasterGlyphAdvance - 1) * scale
+}
+
+// rasterTruncate shortens text with ".." so that it fits in width pixels
+func rasterTruncate(text string, scale, width int) string {
+	if rasterTextWidth(text, scale) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if candidate := string(runes) + ".."; rasterTextWidth(candidate, scale) <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// chartSpec is a card's visualization config resolved against its result columns
+type chartSpec struct {
+	Kind   string
+	XIndex int
+	YIndex []int
+	Colors []color.RGBA
+}
+
+// parseChartSpec resolves the chart type, axes and colors of a visualization config.
+// Missing axes default to the first text column and the numeric columns, like the dashboard does.
+func parseChartSpec(config string, columns []string, rows [][]interface{}) chartSpec {
+	var raw struct {
+		Type      string          `json:"type"`
+		ChartType string          `json:"chartType"`
+		XAxis     string          `json:"xAxis"`
+		YAxis     json.RawMessage `json:"yAxis"`
+		Colors    []string        `json:"colors"`
+	}
+	if config != "" {
+		_ = json.Unmarshal([]byte(config), &raw)
+	}
+
+	spec := chartSpec{Kind: chartKind(raw.ChartType), XIndex: -1, Colors: chartPalette}
+	if raw.ChartType == "" {
+		spec.Kind = chartKind(raw.Type)
+	}
+
+	if colors := parseChartColors(raw.Colors); len(colors) > 0 {
+		spec.Colors = colors
+	}
+
+	numeric := make([]bool, len(columns))
+	for i := range columns {
+		numeric[i] = columnIsNumeric(rows, i)
+	}
+
+	spec.XIndex = columnIndex(columns, raw.XAxis)
+	if spec.XIndex < 0 {
+		spec.XIndex = 0
+		for i := range columns {
+			if !numeric[i] {
+				spec.XIndex = i
+				break
+			}
+		}
+	}
+
+	var yAxes []string
+	if err := json.Unmarshal(raw.YAxis, &yAxes); err != nil {
+		var single string
+		if json.Unmarshal(raw.YAxis, &single) == nil && single != "" {
+			yAxes = []string{single}
+		}
+	}
+	for _, name := range yAxes {
+		if idx := columnIndex(columns, name); idx >= 0 && numeric[idx] {
+			spec.YIndex = append(spec.YIndex, idx)
+		}
+	}
+	if len(spec.YIndex) == 0 {
+		for i := range columns {
+			if i != spec.XIndex && numeric[i] && len(spec.YIndex) < 5 {
+				spec.YIndex = append(spec.YIndex, i)
+			}
+		}
+	}
+
+	if spec.Kind != ChartKindTable && len(spec.YIndex) == 0 {
+		spec.Kind = ChartKindTable
+	}
+	return spec
+}
+
+// chartKind maps a dashboard visualization type to the chart kind used to rasterise it
+func chartKind(visualizationType string) string {
+	switch strings.ToLower(visualizationType) {
+	case "bar", "column", "stacked-bar", "stacked_bar", "horizontal-bar", "histogram", "":
+		return ChartKindBar
+	case "line", "area", "spline", "stacked-area", "combo":
+		return ChartKindLine
+	case "pie", "donut", "doughnut":
+		return ChartKindPie
+	default:
+		return ChartKindTable
+	}
+}
+
+func parseChartColors(values []string) []color.RGBA {
+	var colors []color.RGBA
+	for _, value := range values {
+		hex := strings.TrimPrefix(strings.TrimSpace(value), "#")
+		if len(hex) != 6 {
+			continue
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			continue
+		}
+		colors = append(colors, color.RGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 255})
+	}
+	return colors
+}
+
+func columnIndex(columns []string, name string) int {
+	for i, col := range columns {
+		if name != "" && strings.EqualFold(col, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// columnIsNumeric reports whether the first non-null value of a column is a number
+func columnIsNumeric(rows [][]interface{}, index int) bool {
+	for _, row := range rows {
+		if index >= len(row) || row[index] == nil {
+			continue
+		}
+		switch row[index].(type) {
+		case string, []byte:
+			return false
+		}
+		_, ok := toFloat(row[index])
+		return ok
+	}
+	return false
+}
+
+// chartSeries extracts category labels and one series of values per y column
+func chartSeries(spec chartSpec, rows [][]interface{}, maxPoints int) ([]string, [][]float64) {
+	if len(rows) > maxPoints {
+		rows = rows[:maxPoints]
+	}
+	labels := make([]string, len(rows))
+	series := make([][]float64, len(spec.YIndex))
+	for s := range series {
+		series[s] = make([]float64, len(rows))
+	}
+	for r, row := range rows {
+		if spec.XIndex < len(row) {
+			labels[r] = formatExportValue(row[spec.XIndex])
+		}
+		for s, idx := range spec.YIndex {
+			series[s][r] = math.NaN()
+			if idx < len(row) && row[idx] != nil {
+				if v, ok := toFloat(row[idx]); ok {
+					series[s][r] = v
+				}
+			}
+		}
+	}
+	return labels, series
+}
+
+// RenderCardChart rasterises a card's results as a bar, line, pie or table image of width x height pixels.
+// scale is the size of one font pixel; titles are drawn one step larger.
+func RenderCardChart(title, visualizationConfig string, columns []string, rows [][]interface{}, errMessage string, width, height, scale int) *image.RGBA {
+	c := newRasterCanvas(width, height, chartBackground)
+	c.strokeRect(0, 0, width, height, chartGrid)
+
+	pad := 6 * scale
+	titleScale := scale + 1
+	c.text(pad, pad, titleScale, chartText, rasterTruncate(title, titleScale, width-2*pad))
+	area := image.Rect(pad, pad+rasterGlyphHeight*titleScale+pad, width-pad, height-pad)
+	if area.Dx() <= 0 || area.Dy() <= 0 {
+		return c.img
+	}
+
+	if errMessage != "" {
+		drawChartMessage(c, area, scale, chartErrorText, errMessage)
+		return c.img
+	}
+
+	spec := parseChartSpec(visualizationConfig, columns, rows)
+	switch {
+	case len(rows) == 0:
+		drawChartMessage(c, area, scale, chartMutedText, "No rows returned")
+	case spec.Kind == ChartKindBar || spec.Kind == ChartKindLine:
+		drawAxisChart(c, area, scale, spec, columns, rows)
+	case spec.Kind == ChartKindPie:
+		drawPieChart(c, area, scale, spec, rows)
+	default:
+		drawTableChart(c, area, scale, columns, rows)
+	}
+	return c.img
+}
+
+// RenderTextCard rasterises a text card, wrapping its content to the card width
+func RenderTextCard(title, text string, width, height, scale int) *image.RGBA {
+	c := newRasterCanvas(width, height, chartBackground)
+	c.strokeRect(0, 0, width, height, chartGrid)
+
+	pad := 6 * scale
+	titleScale := scale + 1
+	c.text(pad, pad, titleScale, chartText, rasterTruncate(title, titleScale, width-2*pad))
+	drawChartMessage(c, image.Rect(pad, pad+rasterGlyphHeight*titleScale+pad, width-pad, height-pad), scale, chartText, text)
+	return c.img
+}
+
+// drawChartMessage draws word-wrapped text inside area
+func drawChartMessage(c *rasterCanvas, area image.Rectangle, scale int, col color.RGBA, message string) {
+	lineHeight := (rasterGlyphHeight + 3) * scale
+	y := area.Min.Y
+	for _, paragraph := range strings.Split(message, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := strings.TrimSpace(line + " " + word)
+			if line != "" && rasterTextWidth(candidate, scale) > area.Dx() {
+				if y+lineHeight > area.Max.Y {
+					return
+				}
+				c.text(area.Min.X, y, scale, col, rasterTruncate(line, scale, area.Dx()))
+				y += lineHeight
+				line = word
+				continue
+			}
+			line = candidate
+		}
+		if y+lineHeight > area.Max.Y {
+			return
+		}
+		c.text(area.Min.X, y, scale, col, rasterTruncate(line, scale, area.Dx()))
+		y += lineHeight
+	}
+}
+
+// drawAxisChart draws grouped bars or lines with a value axis, category labels and a legend
+func drawAxisChart(c *rasterCanvas, area image.Rectangle, scale int, spec chartSpec, columns []string, rows [][]interface{}) {
+	maxPoints := 500
+	if spec.Kind == ChartKindBar {
+		maxPoints = 50
+	}
+	labels, series := chartSeries(spec, rows, maxPoints)
+	textHeight := rasterGlyphHeight * scale
+
+	// Legend for multiple series
+	top := area.Min.Y
+	if len(series) > 1 {
+		x := area.Min.X
+		for s, idx := range spec.YIndex {
+			name := columns[idx]
+			w := textHeight + 2*scale + rasterTextWidth(name, scale) + 4*scale
+			if x+w > area.Max.X {
+				break
+			}
+			c.fillRect(x, top, x+textHeight, top+textHeight, spec.Colors[s%len(spec.Colors)])
+			c.text(x+textHeight+2*scale, top, scale, chartMutedText, name)
+			x += w + 4*scale
+		}
+		top += textHeight + 4*scale
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, values := range series {
+		for _, v := range values {
+			if !math.IsNaN(v) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+	}
+	if math.IsInf(lo, 1) {
+		drawChartMessage(c, area, scale, chartMutedText, "No numeric values")
+		return
+	}
+	ticks := niceTicks(math.Min(lo, 0), math.Max(hi, 0), 5)
+	lo, hi = ticks[0], ticks[len(ticks)-1]
+
+	labelWidth := 0
+	for _, t := range ticks {
+		if w := rasterTextWidth(formatChartNumber(t), scale); w > labelWidth {
+			labelWidth = w
+		}
+	}
+	plot := image.Rect(area.Min.X+labelWidth+4*scale, top+textHeight/2, area.Max.X, area.Max.Y-textHeight-4*scale)
+	if plot.Dx() <= 0 || plot.Dy() <= 0 || len(labels) == 0 {
+		return
+	}
+	yFor := func(v float64) int {
+		return plot.Max.Y - int(math.Round((v-lo)/(hi-lo)*float64(plot.Dy())))
+	}
+
+	for _, t := range ticks {
+		y := yFor(t)
+		c.fillRect(plot.Min.X, y, plot.Max.X, y+1, chartGrid)
+		label := formatChartNumber(t)
+		c.text(plot.Min.X-4*scale-rasterTextWidth(label, scale), y-textHeight/2, scale, chartMutedText, label)
+	}
+
+	// Category labels, skipping some when they would overlap
+	slot := float64(plot.Dx()) / float64(len(labels))
+	maxLabel := 0
+	for _, label := range labels {
+		if w := rasterTextWidth(label, scale); w > maxLabel {
+			maxLabel = w
+		}
+	}
+	step := 1
+	if maxLabel > 0 {
+		step = int(math.Ceil(float64(min(maxLabel, 12*rasterGlyphAdvance*scale)+4*scale) / slot))
+		if step < 1 {
+			step = 1
+		}
+	}
+	for i := 0; i < len(labels); i += step {
+		label := rasterTruncate(labels[i], scale, int(slot*float64(step))-2*scale)
+		cx := plot.Min.X + int((float64(i)+0.5)*slot)
+		c.text(cx-rasterTextWidth(label, scale)/2, plot.Max.Y+3*scale, scale, chartMutedText, label)
+	}
+
+	zero := yFor(0)
+	if spec.Kind == ChartKindBar {
+		groupWidth := slot * 0.7
+		barWidth := groupWidth / float64(len(series))
+		for i := range labels {
+			x0 := float64(plot.Min.X) + float64(i)*slot + (slot-groupWidth)/2
+			for s, values := range series {
+				if math.IsNaN(values[i]) {
+					continue
+				}
+				bx := int(x0 + float64(s)*barWidth)
+				bw := max(1, int(barWidth)-max(0, scale/2))
+				y := yFor(values[i])
+				c.fillRect(bx, min(y, zero), bx+bw, max(y, zero), spec.Colors[s%len(spec.Colors)])
+			}
+		}
+	} else {
+		thickness := max(1, scale)
+		for s, values := range series {
+			col := spec.Colors[s%len(spec.Colors)]
+			prevX, prevY, havePrev := 0, 0, false
+			for i, v := range values {
+				if math.IsNaN(v) {
+					havePrev = false
+					continue
+				}
+				x, y := plot.Min.X+int((float64(i)+0.5)*slot), yFor(v)
+				if havePrev {
+					c.line(prevX, prevY, x, y, thickness, col)
+				} else if len(values) <= 60 {
+					c.fillRect(x-thickness, y-thickness, x+thickness+1, y+thickness+1, col)
+				}
+				prevX, prevY, havePrev = x, y, true
+			}
+		}
+	}
+	c.fillRect(plot.Min.X, zero, plot.Max.X, zero+1, chartMutedText)
+}
+
+// drawPieChart draws the first series as slices, grouping small trailing slices into "Other"
+func drawPieChart(c *rasterCanvas, area image.Rectangle, scale int, spec chartSpec, rows [][]interface{}) {
+	const maxSlices = 8
+
+	labels, series := chartSeries(spec, rows, 1000)
+	var names []string
+	var values []float64
+	other := 0.0
+	for i, v := range series[0] {
+		if math.IsNaN(v) || v <= 0 {
+			continue
+		}
+		if len(values) < maxSlices-1 {
+			names = append(names, labels[i])
+			values = append(values, v)
+		} else {
+			other += v
+		}
+	}
+	if other > 0 {
+		names = append(names, "Other")
+		values = append(values, other)
+	}
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	if total == 0 {
+		drawChartMessage(c, area, scale, chartMutedText, "No positive values")
+		return
+	}
+
+	// Pie on the left, legend on the right
+	legendWidth := min(area.Dx()/2, 24*rasterGlyphAdvance*scale)
+	radius := min(area.Dx()-legendWidth-4*scale, area.Dy()) / 2
+	if radius <= 0 {
+		return
+	}
+	cx, cy := area.Min.X+radius, area.Min.Y+area.Dy()/2
+
+	ends := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		ends[i] = sum / total * 2 * math.Pi
+	}
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if x*x+y*y > radius*radius {
+				continue
+			}
+			// Angle clockwise from 12 o'clock
+			angle := math.Atan2(float64(x), float64(-y))
+			if angle < 0 {
+				angle += 2 * math.Pi
+			}
+			slice := 0
+			for slice < len(ends)-1 && angle > ends[slice] {
+				slice++
+			}
+			c.img.SetRGBA(cx+x, cy+y, spec.Colors[slice%len(spec.Colors)])
+		}
+	}
+
+	textHeight := rasterGlyphHeight * scale
+	lineHeight := textHeight + 4*scale
+	x := area.Max.X - legendWidth
+	y := cy - len(values)*lineHeight/2
+	for i, name := range names {
+		label := fmt.Sprintf("%s %.0f%%", name, values[i]/total*100)
+		c.fillRect(x, y, x+textHeight, y+textHeight, spec.Colors[i%len(spec.Colors)])
+		c.text(x+textHeight+3*scale, y, scale, chartText, rasterTruncate(label, scale, legendWidth-textHeight-3*scale))
+		y += lineHeight
+	}
+}
+
+// drawTableChart draws as many rows and columns of the results as fit in area
+func drawTableChart(c *rasterCanvas, area image.Rectangle, scale int, columns []string, rows [][]interface{}) {
+	if len(columns) == 0 {
+		return
+	}
+	textHeight := rasterGlyphHeight * scale
+	rowHeight := textHeight + 6*scale
+	pad := 3 * scale
+
+	visible := min(len(columns), max(1, area.Dx()/(8*rasterGlyphAdvance*scale)))
+	colWidth := area.Dx() / visible
+
+	c.fillRect(area.Min.X, area.Min.Y, area.Max.X, area.Min.Y+rowHeight, chartHeaderFill)
+	for i := 0; i < visible; i++ {
+		c.text(area.Min.X+i*colWidth+pad, area.Min.Y+3*scale, scale, chartText, rasterTruncate(columns[i], scale, colWidth-2*pad))
+	}
+
+	y := area.Min.Y + rowHeight
+	for _, row := range rows {
+		if y+rowHeight > area.Max.Y {
+			break
+		}
+		c.fillRect(area.Min.X, y+rowHeight-1, area.Max.X, y+rowHeight, chartGrid)
+		for i := 0; i < visible && i < len(row); i++ {
+			c.text(area.Min.X+i*colWidth+pad, y+3*scale, scale, chartText, rasterTruncate(formatExportValue(row[i]), scale, colWidth-2*pad))
+		}
+		y += rowHeight
+	}
+}
+
+// drawWatermark blends large, translucent watermark text across the middle of img
+func drawWatermark(img *image.RGBA, text string) {
+	bounds := img.Bounds()
+	scale := max(2, bounds.Dx()/(len([]rune(text))*rasterGlyphAdvance*2))
+	scale = min(scale, bounds.Dy()/(rasterGlyphHeight*2))
+	if scale < 1 {
+		return
+	}
+
+	mask := image.NewAlpha(bounds)
+	x := bounds.Min.X + (bounds.Dx()-rasterTextWidth(text, scale))/2
+	y := bounds.Min.Y + (bounds.Dy()-rasterGlyphHeight*scale)/2
+	for _, r := range text {
+		if r < 32 || r > 126 {
+			r = '?'
+		}
+		glyph := rasterFont[r-32]
+		for row := 0; row < rasterGlyphHeight; row++ {
+			for bit := 0; bit < rasterGlyphWidth; bit++ {
+				if glyph[row]&(1<<(rasterGlyphWidth-1-bit)) != 0 {
+					px, py := x+bit*scale, y+row*scale
+					draw.Draw(mask, image.Rect(px, py, px+scale, py+scale), &image.Uniform{color.Alpha{40}}, image.Point{}, draw.Src)
+				}
+			}
+		}
+		x += rasterGlyphAdvance * scale
+	}
+	draw.DrawMask(img, bounds, &image.Uniform{color.RGBA{107, 114, 128, 255}}, image.Point{}, mask, bounds.Min, draw.Over)
+}
+
+// niceTicks returns about n evenly spaced round tick values covering [lo, hi]
+func niceTicks(lo, hi float64, n int) []float64 {
+	if hi <= lo {
+		hi = lo + 1
+	}
+	rough := (hi - lo) / float64(n)
+	magnitude := math.Pow(10, math.Floor(math.Log10(rough)))
+	step := magnitude
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if m*magnitude >= rough {
+			step = m * magnitude
+			break
+		}
+	}
+	var ticks []float64
+	for v := math.Floor(lo/step) * step; v <= hi+step/2; v += step {
+		ticks = append(ticks, math.Round(v/step)*step)
+		if v >= hi {
+			break
+		}
+	}
+	if len(ticks) < 2 {
+		ticks = append(ticks, ticks[0]+step)
+	}
+	return ticks
+}
+
+// formatChartNumber formats an axis value compactly, e.g. 1.5K or 2M
+func formatChartNumber(v float64) string {
+	switch a := math.Abs(v); {
+	case a >= 1e9:
+		return strconv.FormatFloat(v/1e9, 'f', -1, 64) + "B"
+	case a >= 1e6:
+		return strconv.FormatFloat(v/1e6, 'f', -1, 64) + "M"
+	case a >= 1e3:
+		return strconv.FormatFloat(v/1e3, 'f', -1, 64) + "K"
+	default:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
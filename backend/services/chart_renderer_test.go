@@ -0,0 +1,80 @@
+package services
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// TestParseChartSpec tests chart type and axis resolution
+func TestParseChartSpec(t *testing.T) {
+	columns := []string{"revenue", "region", "orders"}
+	rows := [][]interface{}{{100.5, "EU", int64(3)}, {80.0, "US", int64(5)}}
+
+	spec := parseChartSpec(`{"type":"bar"}`, columns, rows)
+	if spec.Kind != ChartKindBar || spec.XIndex != 1 || len(spec.YIndex) != 2 {
+		t.Errorf("Expected bar chart over region with two series, got %+v", spec)
+	}
+
+	spec = parseChartSpec(`{"chartType":"line","xAxis":"region","yAxis":"orders"}`, columns, rows)
+	if spec.Kind != ChartKindLine || spec.XIndex != 1 || len(spec.YIndex) != 1 || spec.YIndex[0] != 2 {
+		t.Errorf("Expected line chart of orders by region, got %+v", spec)
+	}
+
+	spec = parseChartSpec(`{"type":"pie"}`, []string{"region"}, [][]interface{}{{"EU"}})
+	if spec.Kind != ChartKindTable {
+		t.Errorf("Expected a table when there is nothing numeric to plot, got %s", spec.Kind)
+	}
+}
+
+// TestRenderCardChart tests that a chart draws series colors into the card
+func TestRenderCardChart(t *testing.T) {
+	columns := []string{"region", "revenue"}
+	rows := [][]interface{}{{"EU", 120.0}, {"US", 80.0}, {"APAC", 40.0}}
+
+	img := RenderCardChart("Revenue", `{"type":"bar","colors":["#ff0000"]}`, columns, rows, "", 400, 300, 1)
+	if img.Bounds().Dx() != 400 || img.Bounds().Dy() != 300 {
+		t.Fatalf("Expected a 400x300 image, got %v", img.Bounds())
+	}
+
+	red := 0
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			if img.RGBAAt(x, y) == (color.RGBA{255, 0, 0, 255}) {
+				red++
+			}
+		}
+	}
+	if red < 100 {
+		t.Errorf("Expected bars in the configured color, found %d pixels", red)
+	}
+}
+
+// TestComposeDashboardImage tests grid placement and PNG encoding of a dashboard
+func TestComposeDashboardImage(t *testing.T) {
+	snapshot := &dashboardSnapshot{Cards: []exportCard{
+		{Title: "Notes", Type: "text", Text: "Hello", Position: cardGridPosition{X: 0, Y: 0, W: 6, H: 4}},
+		{Title: "Revenue", Type: "visualization", Columns: []string{"region", "revenue"}, Rows: [][]interface{}{{"EU", 1.0}}, Position: cardGridPosition{X: 6, Y: 0, W: 6, H: 4}},
+		{Title: "Unplaced", Type: "visualization", Error: "query failed"},
+	}}
+	snapshot.Dashboard.Name = "Sales"
+
+	options := &ExportOptions{Format: ExportFormatPNG, Quality: QualityHigh, Resolution: 96}
+	img := composeDashboardImage(snapshot, options)
+	if img.Bounds().Dx() != dashboardImageWidth {
+		t.Errorf("Expected width %d, got %d", dashboardImageWidth, img.Bounds().Dx())
+	}
+	// Two rows of 4 grid units plus a full-width card of 8 below them
+	if minHeight := 12 * (dashboardGridRow + dashboardGridMargin); img.Bounds().Dy() < minHeight {
+		t.Errorf("Expected height of at least %d, got %d", minHeight, img.Bounds().Dy())
+	}
+
+	var buf bytes.Buffer
+	if err := encodeExportImage(&buf, img, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := png.Decode(&buf); err != nil {
+		t.Errorf("Expected a valid PNG: %v", err)
+	}
+}
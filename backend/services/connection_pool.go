@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"insight-engine-backend/models"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PoolSettings size the pool of a connection. Each can be set through Connection.Options:
+// "maxOpenConns", "maxIdleConns", "connMaxLifetimeSeconds" and "idleTimeoutSeconds".
+type PoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// IdleTimeout closes the whole pool after it has not been used for this long
+	IdleTimeout time.Duration
+}
+
+// DefaultPoolSettings apply to connections that do not override them
+var DefaultPoolSettings = PoolSettings{
+	MaxOpenConns:    25,
+	MaxIdleConns:    5,
+	ConnMaxLifetime: 5 * time.Minute,
+	IdleTimeout:     30 * time.Minute,
+}
+
+// poolSettings returns the pool settings of a connection
+func poolSettings(conn *models.Connection) PoolSettings {
+	settings := DefaultPoolSettings
+	if n := intOption(conn, "maxOpenConns"); n > 0 {
+		settings.MaxOpenConns = n
+	}
+	if n := intOption(conn, "maxIdleConns"); n > 0 {
+		settings.MaxIdleConns = n
+	}
+	if settings.MaxIdleConns > settings.MaxOpenConns {
+		settings.MaxIdleConns = settings.MaxOpenConns
+	}
+	if n := intOption(conn, "connMaxLifetimeSeconds"); n > 0 {
+		settings.ConnMaxLifetime = time.Duration(n) * time.Second
+	}
+	if n := intOption(conn, "idleTimeoutSeconds"); n > 0 {
+		settings.IdleTimeout = time.Duration(n) * time.Second
+	}
+	return settings
+}
+
+// apply configures a database/sql pool, filling unset values from the defaults
+func (s PoolSettings) apply(db *sql.DB) {
+	if s.MaxOpenConns <= 0 {
+		s = DefaultPoolSettings
+	}
+	db.SetMaxOpenConns(s.MaxOpenConns)
+	db.SetMaxIdleConns(s.MaxIdleConns)
+	db.SetConnMaxLifetime(s.ConnMaxLifetime)
+}
+
+// intOption returns an integer option of a connection, or 0
+func intOption(conn *models.Connection, key string) int {
+	n, err := strconv.Atoi(connectionOption(conn, key))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// ConnectionPool keeps one open Connector per connection. A connector is replaced when the
+// connection's settings or credentials change, closed after its idle timeout, and the least
+// recently used one is closed when MaxConnectors are open.
+type ConnectionPool struct {
+	mu      sync.Mutex
+	entries map[string]*poolEntry
+
+	// MaxConnectors bounds the number of open connectors across all connections
+	MaxConnectors int
+	// PingInterval is how long a connector is trusted before it is pinged again
+	PingInterval time.Duration
+
+	factory ConnectorFactory
+	stop    chan struct{}
+}
+
+type poolEntry struct {
+	connector   Connector
+	fingerprint string
+	settings    PoolSettings
+	name        string
+	connType    string
+	userID      string
+	createdAt   time.Time
+	lastUsed    time.Time
+	lastPing    time.Time
+	uses        int64
+}
+
+// PoolStats describes an open connector for the admin pool endpoint
+type PoolStats struct {
+	ConnectionID   string    `json:"connectionId"`
+	ConnectionName string    `json:"connectionName"`
+	Type           string    `json:"type"`
+	UserID         string    `json:"userId"`
+	CreatedAt      time.Time `json:"createdAt"`
+	LastUsedAt     time.Time `json:"lastUsedAt"`
+	Uses           int64     `json:"uses"`
+	IdleTimeout    string    `json:"idleTimeout"`
+
+	// database/sql pool counters; absent for native clients such as BigQuery
+	MaxOpenConnections *int    `json:"maxOpenConnections,omitempty"`
+	OpenConnections    *int    `json:"openConnections,omitempty"`
+	InUse              *int    `json:"inUse,omitempty"`
+	Idle               *int    `json:"idle,omitempty"`
+	WaitCount          *int64  `json:"waitCount,omitempty"`
+	WaitDuration       *string `json:"waitDuration,omitempty"`
+	MaxIdleClosed      *int64  `json:"maxIdleClosed,omitempty"`
+	MaxLifetimeClosed  *int64  `json:"maxLifetimeClosed,omitempty"`
+}
+
+// NewConnectionPool creates a pool that opens connectors with factory
+func NewConnectionPool(factory ConnectorFactory) *ConnectionPool {
+	return &ConnectionPool{
+		entries:       make(map[string]*poolEntry),
+		MaxConnectors: 100,
+		PingInterval:  30 * time.Second,
+		factory:       factory,
+	}
+}
+
+// Get returns the open connector of a connection, connecting on first use and reconnecting
+// when the connection changed or the cached connector no longer answers a ping
+func (p *ConnectionPool) Get(ctx context.Context, conn *models.Connection) (Connector, error) {
+	fingerprint := connectionFingerprint(conn)
+
+	p.mu.Lock()
+	entry, exists := p.entries[conn.ID]
+	if exists && entry.fingerprint != fingerprint {
+		// Settings or credentials changed since the connector was opened
+		p.removeLocked(conn.ID, entry)
+		exists = false
+	}
+	if exists && time.Since(entry.lastPing) < p.PingInterval && !pingsEveryUse(entry.connector) {
+		entry.touch()
+		p.mu.Unlock()
+		return entry.connector, nil
+	}
+	p.mu.Unlock()
+
+	if exists {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := entry.connector.Ping(pingCtx)
+		cancel()
+
+		p.mu.Lock()
+		if err == nil {
+			entry.lastPing = time.Now()
+			entry.touch()
+			p.mu.Unlock()
+			return entry.connector, nil
+		}
+		// Connection dead, remove it from the pool
+		LogWarn("connection_pool", "Pooled connection failed ping, reconnecting", map[string]interface{}{
+			"connection_id": conn.ID,
+			"error":         err,
+		})
+		p.removeLocked(conn.ID, entry)
+		p.mu.Unlock()
+	}
+
+	connector, err := p.factory(conn)
+	if err != nil {
+		return nil, err
+	}
+	if err := connector.Connect(ctx); err != nil {
+		connector.Close()
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if existing, exists := p.entries[conn.ID]; exists && existing.fingerprint == fingerprint {
+		// Another request connected first; keep its connector
+		connector.Close()
+		existing.touch()
+		return existing.connector, nil
+	} else if exists {
+		p.removeLocked(conn.ID, existing)
+	}
+
+	now := time.Now()
+	entry = &poolEntry{
+		connector:   connector,
+		fingerprint: fingerprint,
+		settings:    poolSettings(conn),
+		name:        conn.Name,
+		connType:    conn.Type,
+		userID:      conn.UserID,
+		createdAt:   now,
+		lastPing:    now,
+	}
+	entry.touch()
+	p.entries[conn.ID] = entry
+	p.evictOverflowLocked()
+	return connector, nil
+}
+
+// pingsEveryUse reports whether a connector asks to be pinged before every use; file
+// connectors do, because their ping is cheap and detects changed files
+func pingsEveryUse(connector Connector) bool {
+	local, ok := connector.(interface{ pingEveryUse() bool })
+	return ok && local.pingEveryUse()
+}
+
+func (e *poolEntry) touch() {
+	e.lastUsed = time.Now()
+	e.uses++
+}
+
+// Invalidate closes the connector of a connection, e.g. after it was updated or deleted
+func (p *ConnectionPool) Invalidate(connectionID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, exists := p.entries[connectionID]; exists {
+		p.removeLocked(connectionID, entry)
+	}
+}
+
+// EvictIdle closes the connectors that were not used within their idle timeout
+func (p *ConnectionPool) EvictIdle() int {
+	return p.evictIdle(time.Now())
+}
+
+func (p *ConnectionPool) evictIdle(now time.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	evicted := 0
+	for id, entry := range p.entries {
+		if now.Sub(entry.lastUsed) >= entry.settings.IdleTimeout {
+			p.removeLocked(id, entry)
+			evicted++
+		}
+	}
+	if evicted > 0 {
+		LogInfo("connection_pool", "Evicted idle connections", map[string]interface{}{
+			"evicted": evicted,
+			"open":    len(p.entries),
+		})
+	}
+	return evicted
+}
+
+// evictOverflowLocked closes the least recently used connectors beyond MaxConnectors
+func (p *ConnectionPool) evictOverflowLocked() {
+	for p.MaxConnectors > 0 && len(p.entries) > p.MaxConnectors {
+		var oldestID string
+		var oldest *poolEntry
+		for id, entry := range p.entries {
+			if oldest == nil || entry.lastUsed.Before(oldest.lastUsed) {
+				oldestID, oldest = id, entry
+			}
+		}
+		p.removeLocked(oldestID, oldest)
+	}
+}
+
+// removeLocked drops an entry and closes its connector; p.mu must be held. Closing a
+// database/sql pool waits for its running queries.
+func (p *ConnectionPool) removeLocked(id string, entry *poolEntry) {
+	if p.entries[id] == entry {
+		delete(p.entries, id)
+	}
+	go entry.connector.Close()
+}
+
+// StartEviction evicts idle connectors every interval until Close
+func (p *ConnectionPool) StartEviction(interval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	stop := p.stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.EvictIdle()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stats returns the open connectors, most recently used first
+func (p *ConnectionPool) Stats() []PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]PoolStats, 0, len(p.entries))
+	for id, entry := range p.entries {
+		s := PoolStats{
+			ConnectionID:   id,
+			ConnectionName: entry.name,
+			Type:           entry.connType,
+			UserID:         entry.userID,
+			CreatedAt:      entry.createdAt,
+			LastUsedAt:     entry.lastUsed,
+			Uses:           entry.uses,
+			IdleTimeout:    entry.settings.IdleTimeout.String(),
+		}
+		if pool, ok := entry.connector.(interface{ sqlDB() *sql.DB }); ok && pool.sqlDB() != nil {
+			db := pool.sqlDB().Stats()
+			waitDuration := db.WaitDuration.String()
+			s.MaxOpenConnections = &db.MaxOpenConnections
+			s.OpenConnections = &db.OpenConnections
+			s.InUse = &db.InUse
+			s.Idle = &db.Idle
+			s.WaitCount = &db.WaitCount
+			s.WaitDuration = &waitDuration
+			s.MaxIdleClosed = &db.MaxIdleClosed
+			s.MaxLifetimeClosed = &db.MaxLifetimeClosed
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].LastUsedAt.After(stats[j].LastUsedAt)
+	})
+	return stats
+}
+
+// Close stops eviction and closes every connector
+func (p *ConnectionPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+	var firstErr error
+	for id, entry := range p.entries {
+		if err := entry.connector.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(p.entries, id)
+	}
+	return firstErr
+}
+
+// connectionFingerprint identifies the settings a connector was opened with, so that a
+// pool is replaced when its connection's address, credentials or options change
+func connectionFingerprint(conn *models.Connection) string {
+	data, _ := json.Marshal(struct {
+		Type     string
+		Host     *string
+		Port     *int
+		Database string
+		Username *string
+		Password *string
+		Options  *map[string]interface{}
+	}{conn.Type, conn.Host, conn.Port, conn.Database, conn.Username, conn.Password, conn.Options})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"insight-engine-backend/models"
+	"testing"
+	"time"
+)
+
+// poolConnector counts connects and reports closes for pool tests
+type poolConnector struct {
+	memoryConnector
+	closed chan struct{}
+}
+
+func (c *poolConnector) Close() error {
+	close(c.closed)
+	return nil
+}
+
+// TestConnectionPool tests reuse, invalidation on changed credentials, and eviction
+func TestConnectionPool(t *testing.T) {
+	connects := 0
+	var opened []*poolConnector
+	pool := NewConnectionPool(func(conn *models.Connection) (Connector, error) {
+		connector := &poolConnector{memoryConnector: memoryConnector{connects: &connects}, closed: make(chan struct{})}
+		opened = append(opened, connector)
+		return connector, nil
+	})
+	defer pool.Close()
+	ctx := context.Background()
+
+	password := "secret"
+	options := map[string]interface{}{"maxOpenConns": 4, "idleTimeoutSeconds": "60"}
+	conn := &models.Connection{ID: "conn-1", Type: "memory", Password: &password, Options: &options}
+	first, _ := pool.Get(ctx, conn)
+	second, _ := pool.Get(ctx, conn)
+	if first != second || connects != 1 {
+		t.Fatalf("Expected the connector to be reused, got %d connects", connects)
+	}
+	if settings := poolSettings(conn); settings.MaxOpenConns != 4 || settings.MaxIdleConns != 4 || settings.IdleTimeout != time.Minute {
+		t.Errorf("Unexpected pool settings %+v", settings)
+	}
+
+	// Changed credentials replace the connector
+	changed := "rotated"
+	conn.Password = &changed
+	third, _ := pool.Get(ctx, conn)
+	if third == first || connects != 2 {
+		t.Fatalf("Expected a new connector after the password changed, got %d connects", connects)
+	}
+	waitClosed(t, opened[0])
+
+	stats := pool.Stats()
+	if len(stats) != 1 || stats[0].ConnectionID != "conn-1" || stats[0].Uses != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// Idle connectors are evicted after their idle timeout
+	if n := pool.evictIdle(time.Now().Add(30 * time.Second)); n != 0 {
+		t.Errorf("Expected nothing to be evicted yet, got %d", n)
+	}
+	if n := pool.evictIdle(time.Now().Add(2 * time.Minute)); n != 1 {
+		t.Errorf("Expected the idle connector to be evicted, got %d", n)
+	}
+	waitClosed(t, opened[1])
+
+	// The least recently used connector is closed beyond MaxConnectors
+	pool.MaxConnectors = 1
+	pool.Get(ctx, &models.Connection{ID: "conn-2", Type: "memory"})
+	pool.Get(ctx, &models.Connection{ID: "conn-3", Type: "memory"})
+	waitClosed(t, opened[2])
+	if stats := pool.Stats(); len(stats) != 1 || stats[0].ConnectionID != "conn-3" {
+		t.Errorf("Expected only conn-3 to stay open, got %+v", stats)
+	}
+
+	pool.Invalidate("conn-3")
+	waitClosed(t, opened[3])
+	if len(pool.Stats()) != 0 {
+		t.Error("Expected the pool to be empty after invalidation")
+	}
+}
+
+func waitClosed(t *testing.T, connector *poolConnector) {
+	t.Helper()
+	select {
+	case <-connector.closed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected connector to be closed")
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"insight-engine-backend/models"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// TLS modes, following libpq's sslmode names
+const (
+	TLSModeDisable    = "disable"
+	TLSModeRequire    = "require"     // Encrypt without verifying the server
+	TLSModeVerifyCA   = "verify-ca"   // Verify the server certificate chain
+	TLSModeVerifyFull = "verify-full" // Also verify the server host name
+)
+
+// ConnectionTLS is the Options["tls"] object of a connection
+type ConnectionTLS struct {
+	Mode       string `json:"mode"`
+	CACert     string `json:"caCert"`     // PEM CA bundle
+	ClientCert string `json:"clientCert"` // PEM client certificate
+	ClientKey  string `json:"clientKey"`  // PEM client key, stored encrypted
+	ServerName string `json:"serverName"` // Host name to verify instead of the connection's host
+}
+
+// ConnectionSSH is the Options["ssh"] object of a connection: a jump host through which the
+// database is reached. Authenticates with a password or a private key.
+type ConnectionSSH struct {
+	Host             string `json:"host"`
+	Port             int    `json:"port"`
+	User             string `json:"user"`
+	Password         string `json:"password"`   // Stored encrypted
+	PrivateKey       string `json:"privateKey"` // PEM, stored encrypted
+	Passphrase       string `json:"passphrase"` // Of the private key, stored encrypted
+	HostKey          string `json:"hostKey"`    // Jump host public key, authorized_keys format
+	SkipHostKeyCheck bool   `json:"skipHostKeyCheck"`
+}
+
+// connectionTLS returns the TLS settings of a connection, or nil when it has none
+func connectionTLS(conn *models.Connection) (*ConnectionTLS, error) {
+	var settings ConnectionTLS
+	found, err := decodeConnectionOption(conn, "tls", &settings)
+	if err != nil || !found {
+		return nil, err
+	}
+	switch settings.Mode {
+	case "":
+		settings.Mode = TLSModeVerifyFull
+	case TLSModeDisable, TLSModeRequire, TLSModeVerifyCA, TLSModeVerifyFull:
+	default:
+		return nil, fmt.Errorf("invalid tls mode: %s", settings.Mode)
+	}
+	return &settings, nil
+}
+
+// connectionSSH returns the SSH tunnel settings of a connection, or nil when it has none
+func connectionSSH(conn *models.Connection) (*ConnectionSSH, error) {
+	var settings ConnectionSSH
+	found, err := decodeConnectionOption(conn, "ssh", &settings)
+	if err != nil || !found {
+		return nil, err
+	}
+	if settings.Host == "" || settings.User == "" {
+		return nil, fmt.Errorf("ssh tunnel requires host and user")
+	}
+	if settings.Port == 0 {
+		settings.Port = 22
+	}
+	return &settings, nil
+}
+
+// decodeConnectionOption decodes an object option into target
+func decodeConnectionOption(conn *models.Connection, key string, target interface{}) (bool, error) {
+	if conn.Options == nil {
+		return false, nil
+	}
+	value, exists := (*conn.Options)[key]
+	if !exists || value == nil {
+		return false, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return false, fmt.Errorf("invalid %s options: %w", key, err)
+	}
+	return true, nil
+}
+
+// EncryptConnectionSecrets encrypts the secret fields of a connection's options in place.
+// When updating, secrets left out of the new options are kept from existing, which holds
+// them encrypted already.
+func EncryptConnectionSecrets(es *EncryptionService, conn *models.Connection, existing *models.Connection) error {
+	if conn.Options == nil {
+		return nil
+	}
+	for key, fields := range models.ConnectionSecretOptions {
+		object, ok := (*conn.Options)[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var previous map[string]interface{}
+		if existing != nil && existing.Options != nil {
+			previous, _ = (*existing.Options)[key].(map[string]interface{})
+		}
+
+		for _, field := range fields {
+			value, _ := object[field].(string)
+			if value == "" {
+				if kept, _ := previous[field].(string); kept != "" {
+					object[field] = kept
+				}
+				continue
+			}
+			if es == nil {
+				return fmt.Errorf("encryption is not configured; cannot store %s.%s", key, field)
+			}
+			encrypted, err := es.Encrypt(value)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt %s.%s: %w", key, field, err)
+			}
+			object[field] = encrypted
+		}
+	}
+	return nil
+}
+
+// decryptConnection returns a copy of a connection with its password and secret options
+// decrypted. Without an encryption service the connection is returned as stored.
+func decryptConnection(es *EncryptionService, conn *models.Connection) (*models.Connection, error) {
+	if es == nil {
+		return conn, nil
+	}
+	decrypted := *conn
+	if conn.Password != nil && *conn.Password != "" {
+		password, err := es.Decrypt(*conn.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt password: %w", err)
+		}
+		decrypted.Password = &password
+	}
+	if conn.Options == nil {
+		return &decrypted, nil
+	}
+
+	options := make(map[string]interface{}, len(*conn.Options))
+	for key, value := range *conn.Options {
+		options[key] = value
+		object, ok := value.(map[string]interface{})
+		fields, secret := models.ConnectionSecretOptions[key]
+		if !ok || !secret {
+			continue
+		}
+		copied := make(map[string]interface{}, len(object))
+		for field, v := range object {
+			copied[field] = v
+		}
+		for _, field := range fields {
+			if value, _ := copied[field].(string); value != "" {
+				plain, err := es.Decrypt(value)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decrypt %s.%s: %w", key, field, err)
+				}
+				copied[field] = plain
+			}
+		}
+		options[key] = copied
+	}
+	decrypted.Options = &options
+	return &decrypted, nil
+}
+
+// Config builds the Go TLS configuration for a server, or nil when TLS is disabled
+func (t *ConnectionTLS) Config(host string) (*tls.Config, error) {
+	if t == nil || t.Mode == TLSModeDisable {
+		return nil, nil
+	}
+	config := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if t.ServerName != "" {
+		config.ServerName = t.ServerName
+	}
+
+	if t.CACert != "" {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM([]byte(t.CACert)) {
+			return nil, fmt.Errorf("invalid tls CA bundle")
+		}
+	}
+	if t.ClientCert != "" || t.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(t.ClientCert), []byte(t.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	switch t.Mode {
+	case TLSModeRequire:
+		config.InsecureSkipVerify = true
+	case TLSModeVerifyCA:
+		// Verify the chain but not the host name
+		roots := config.RootCAs
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyCertificateChain(rawCerts, roots)
+		}
+	}
+	return config, nil
+}
+
+// verifyCertificateChain verifies a peer certificate chain against roots, ignoring host names
+func verifyCertificateChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("server presented no certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	return err
+}
+
+// sshTunnel reaches a database through a jump host. Drivers are given the tunnel as their
+// dialer, so every connection of a pool shares the one SSH session.
+type sshTunnel struct {
+	client *ssh.Client
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// sshDialTimeout bounds connecting and authenticating to the jump host
+const sshDialTimeout = 15 * time.Second
+
+// openSSHTunnel connects and authenticates to the jump host
+func openSSHTunnel(settings *ConnectionSSH) (*sshTunnel, error) {
+	config := &ssh.ClientConfig{User: settings.User, Timeout: sshDialTimeout}
+
+	if settings.PrivateKey != "" {
+		var signer ssh.Signer
+		var err error
+		if settings.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(settings.PrivateKey), []byte(settings.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(settings.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ssh private key: %w", err)
+		}
+		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+	}
+	if settings.Password != "" {
+		config.Auth = append(config.Auth, ssh.Password(settings.Password))
+	}
+	if len(config.Auth) == 0 {
+		return nil, fmt.Errorf("ssh tunnel requires a password or private key")
+	}
+
+	switch {
+	case settings.HostKey != "":
+		hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(settings.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ssh host key: %w", err)
+		}
+		config.HostKeyCallback = ssh.FixedHostKey(hostKey)
+	case settings.SkipHostKeyCheck:
+		config.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("ssh tunnel requires the jump host's hostKey")
+	}
+
+	client, err := ssh.Dial("tcp", net.JoinHostPort(settings.Host, strconv.Itoa(settings.Port)), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh host: %w", err)
+	}
+	return &sshTunnel{client: client}, nil
+}
+
+// Dial opens a connection to address through the jump host
+func (t *sshTunnel) Dial(network, address string) (net.Conn, error) {
+	return t.client.Dial(network, address)
+}
+
+// DialTimeout is Dial; the jump host connection is already established
+func (t *sshTunnel) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	return t.client.Dial(network, address)
+}
+
+// DialContext is Dial for drivers that pass a context
+func (t *sshTunnel) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return t.client.DialContext(ctx, network, address)
+}
+
+// Close disconnects from the jump host
+func (t *sshTunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	return t.client.Close()
+}
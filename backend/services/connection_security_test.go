@@ -0,0 +1,219 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"insight-engine-backend/models"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// TestConnectionSecrets tests that TLS and SSH secrets are encrypted, hidden and kept on update
+func TestConnectionSecrets(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEY", "0123456789abcdef0123456789abcdef")
+	es, err := NewEncryptionService()
+	if err != nil {
+		t.Fatalf("Failed to create encryption service: %v", err)
+	}
+
+	options := map[string]interface{}{
+		"tls": map[string]interface{}{"mode": "verify-ca", "clientKey": "client-key"},
+		"ssh": map[string]interface{}{"host": "bastion", "user": "deploy", "password": "hunter2"},
+	}
+	conn := &models.Connection{ID: "conn-1", Type: "postgres", Database: "sales", Options: &options}
+	if err := EncryptConnectionSecrets(es, conn, nil); err != nil {
+		t.Fatalf("Failed to encrypt secrets: %v", err)
+	}
+	stored := options["ssh"].(map[string]interface{})["password"]
+	if stored == "hunter2" {
+		t.Fatal("Expected the ssh password to be encrypted")
+	}
+
+	dto := conn.ToDTO()
+	if _, exists := (*dto.Options)["ssh"].(map[string]interface{})["password"]; exists {
+		t.Error("Expected the ssh password to be left out of the DTO")
+	}
+	if (*dto.Options)["ssh"].(map[string]interface{})["host"] != "bastion" {
+		t.Error("Expected non-secret ssh options in the DTO")
+	}
+
+	// An update that omits the password keeps the stored one
+	updated := map[string]interface{}{
+		"ssh": map[string]interface{}{"host": "bastion-2", "user": "deploy"},
+	}
+	update := &models.Connection{Options: &updated}
+	if err := EncryptConnectionSecrets(es, update, conn); err != nil {
+		t.Fatalf("Failed to encrypt update: %v", err)
+	}
+	if updated["ssh"].(map[string]interface{})["password"] != stored {
+		t.Error("Expected the stored ssh password to be kept")
+	}
+
+	decrypted, err := decryptConnection(es, conn)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	sshSettings, err := connectionSSH(decrypted)
+	if err != nil || sshSettings.Password != "hunter2" || sshSettings.Port != 22 {
+		t.Errorf("Expected decrypted ssh settings, got %+v (%v)", sshSettings, err)
+	}
+	tlsSettings, err := connectionTLS(decrypted)
+	if err != nil || tlsSettings.ClientKey != "client-key" {
+		t.Errorf("Expected decrypted tls settings, got %+v (%v)", tlsSettings, err)
+	}
+	if options["tls"].(map[string]interface{})["clientKey"] == "client-key" {
+		t.Error("Expected decryption to leave the stored connection encrypted")
+	}
+}
+
+// TestPostgresTLSParams tests the sslmode parameters and value quoting of postgres DSNs
+func TestPostgresTLSParams(t *testing.T) {
+	if got := postgresTLSParams(nil); got != " sslmode=disable" {
+		t.Errorf("Expected TLS to stay disabled by default, got %q", got)
+	}
+	got := postgresTLSParams(&ConnectionTLS{Mode: TLSModeVerifyFull, CACert: "-----BEGIN CERTIFICATE-----\nMII"})
+	expected := " sslmode=verify-full sslinline=true sslrootcert='-----BEGIN CERTIFICATE-----\nMII'"
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if got := postgresDSNValue(`it's a \ test`); got != `'it\'s a \\ test'` {
+		t.Errorf("Expected quoted value, got %q", got)
+	}
+	if _, err := connectionTLS(&models.Connection{Options: &map[string]interface{}{"tls": map[string]interface{}{"mode": "sometimes"}}}); err == nil {
+		t.Error("Expected an error for an invalid tls mode")
+	}
+}
+
+// TestSSHTunnel tests dialling through an in-process SSH server with password auth
+func TestSSHTunnel(t *testing.T) {
+	// Echo server standing in for the database
+	database, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	go func() {
+		for {
+			conn, err := database.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	_, hostKey, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jumpHost, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jumpHost.Close()
+	go serveTestSSH(jumpHost, signer)
+
+	port := jumpHost.Addr().(*net.TCPAddr).Port
+	settings := &ConnectionSSH{
+		Host:     "127.0.0.1",
+		Port:     port,
+		User:     "deploy",
+		Password: "wrong",
+		HostKey:  string(ssh.MarshalAuthorizedKey(signer.PublicKey())),
+	}
+	if _, err := openSSHTunnel(settings); err == nil {
+		t.Fatal("Expected a wrong password to be rejected")
+	}
+
+	settings.Password = "hunter2"
+	tunnel, err := openSSHTunnel(settings)
+	if err != nil {
+		t.Fatalf("Failed to open tunnel: %v", err)
+	}
+	defer tunnel.Close()
+
+	conn, err := tunnel.Dial("tcp", database.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial through tunnel: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("ping"))
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "ping" {
+		t.Errorf("Expected echo through the tunnel, got %q (%v)", reply, err)
+	}
+
+	// A jump host presenting another key is refused
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherSigner, _ := ssh.NewSignerFromKey(otherKey)
+	settings.HostKey = string(ssh.MarshalAuthorizedKey(otherSigner.PublicKey()))
+	if _, err := openSSHTunnel(settings); err == nil || !strings.Contains(err.Error(), "ssh") {
+		t.Errorf("Expected a host key mismatch, got %v", err)
+	}
+}
+
+// serveTestSSH accepts password "hunter2" and forwards direct-tcpip channels
+func serveTestSSH(listener net.Listener, signer ssh.Signer) {
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) == "hunter2" {
+				return nil, nil
+			}
+			return nil, io.ErrUnexpectedEOF
+		},
+	}
+	config.AddHostKey(signer)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			_, channels, requests, err := ssh.NewServerConn(conn, config)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			go ssh.DiscardRequests(requests)
+			for newChannel := range channels {
+				if newChannel.ChannelType() != "direct-tcpip" {
+					newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+					continue
+				}
+				// Payload: host string, port uint32, origin host string, origin port uint32
+				payload := newChannel.ExtraData()
+				hostLen := binary.BigEndian.Uint32(payload)
+				host := string(payload[4 : 4+hostLen])
+				port := binary.BigEndian.Uint32(payload[4+hostLen:])
+				target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+				if err != nil {
+					newChannel.Reject(ssh.ConnectionFailed, err.Error())
+					continue
+				}
+				channel, channelRequests, err := newChannel.Accept()
+				if err != nil {
+					target.Close()
+					continue
+				}
+				go ssh.DiscardRequests(channelRequests)
+				go func() {
+					defer channel.Close()
+					defer target.Close()
+					go io.Copy(target, channel)
+					io.Copy(channel, target)
+				}()
+			}
+		}()
+	}
+}
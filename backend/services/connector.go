@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"insight-engine-backend/models"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Connector is a data source behind a models.Connection. Every source - whether reached
+// through database/sql or a native client such as BigQuery or MongoDB - exposes the same
+// operations, so handlers and services do not need to know which kind they talk to.
+type Connector interface {
+	// Connect opens the client or pool and verifies that the source is reachable
+	Connect(ctx context.Context) error
+	Ping(ctx context.Context) error
+	Close() error
+
+	// Dialect is the query language used for limiting and quoting
+	Dialect() SQLDialect
+
+	ListSchemas(ctx context.Context) ([]string, error)
+	// ListTables lists the tables of a schema; an empty schema means the connection's default
+	ListTables(ctx context.Context, schema string) ([]TableInfo, error)
+	ListColumns(ctx context.Context, schema, table string) ([]ColumnInfo, error)
+
+	// Stream runs a query and returns a cursor over its rows. Execute collects the same
+	// cursor into a QueryResult.
+	Stream(ctx context.Context, query string, args ...interface{}) (RowIterator, error)
+}
+
+// RowIterator is an open result set of a Connector
+type RowIterator interface {
+	Columns() []ResultColumn
+	// Next reads the next row into dest, returning io.EOF after the last row
+	Next(dest []interface{}) error
+	Close() error
+}
+
+// ConnectorFactory creates an unconnected Connector for a connection
+type ConnectorFactory func(conn *models.Connection) (Connector, error)
+
+var connectorRegistry = struct {
+	sync.RWMutex
+	factories map[string]ConnectorFactory
+}{factories: make(map[string]ConnectorFactory)}
+
+// RegisterConnector makes a connector available for connections of the given type,
+// replacing any connector registered for it before
+func RegisterConnector(connectionType string, factory ConnectorFactory) {
+	connectorRegistry.Lock()
+	defer connectorRegistry.Unlock()
+	connectorRegistry.factories[strings.ToLower(connectionType)] = factory
+}
+
+// ConnectorTypes returns the registered connection types
+func ConnectorTypes() []string {
+	connectorRegistry.RLock()
+	defer connectorRegistry.RUnlock()
+	types := make([]string, 0, len(connectorRegistry.factories))
+	for connectionType := range connectorRegistry.factories {
+		types = append(types, connectionType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewConnector creates the registered connector for a connection's type
+func NewConnector(conn *models.Connection) (Connector, error) {
+	connectorRegistry.RLock()
+	factory, exists := connectorRegistry.factories[strings.ToLower(conn.Type)]
+	connectorRegistry.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unsupported database type: %s", conn.Type)
+	}
+	return factory(conn)
+}
+
+func init() {
+	for _, connectionType := range []string{"postgres", "mysql", "sqlserver", "mssql", "oracle", "snowflake", "sqlite", "sqlite3"} {
+		RegisterConnector(connectionType, newSQLConnector)
+	}
+	RegisterConnector("file", newFileConnector)
+	RegisterConnector("bigquery", newBigQueryConnector)
+	RegisterConnector("mongodb", newMongoDBConnector)
+	RegisterConnector("mongo", newMongoDBConnector)
+}
+
+// Connector returns the pooled connector of a connection, connecting on first use
+func (qe *QueryExecutor) Connector(ctx context.Context, conn *models.Connection) (Connector, error) {
+	return qe.pool.Get(ctx, conn)
+}
+
+// TestConnection connects to a connection with a fresh connector, bypassing the pool, so
+// that changed settings are tested rather than the pooled connection
+func (qe *QueryExecutor) TestConnection(ctx context.Context, conn *models.Connection) error {
+	connector, err := qe.newConnector(conn)
+	if err != nil {
+		return err
+	}
+	defer connector.Close()
+	if err := connector.Connect(ctx); err != nil {
+		return err
+	}
+	return connector.Ping(ctx)
+}
+
+// newConnector creates the connector of a stored connection, decrypting its secrets
+func (qe *QueryExecutor) newConnector(conn *models.Connection) (Connector, error) {
+	decrypted, err := decryptConnection(qe.encryption, conn)
+	if err != nil {
+		return nil, err
+	}
+	return NewConnector(decrypted)
+}
+
+// ListTables lists the tables of a schema of any connection
+func (qe *QueryExecutor) ListTables(ctx context.Context, conn *models.Connection, schema string) ([]TableInfo, error) {
+	connector, err := qe.Connector(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.ListTables(ctx, schema)
+}
+
+// ListSchemas lists the schemas (datasets, databases) of any connection
+func (qe *QueryExecutor) ListSchemas(ctx context.Context, conn *models.Connection) ([]string, error) {
+	connector, err := qe.Connector(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.ListSchemas(ctx)
+}
+
+// ListColumns lists the columns of a table of any connection
+func (qe *QueryExecutor) ListColumns(ctx context.Context, conn *models.Connection, schema, table string) ([]ColumnInfo, error) {
+	connector, err := qe.Connector(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.ListColumns(ctx, schema, table)
+}
+
+// Dataset runs a query on any connection and collects every row keyed by column name
+func (qe *QueryExecutor) Dataset(ctx context.Context, conn *models.Connection, query string) (*PipelineDataset, error) {
+	stream, err := qe.Stream(ctx, conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("source query failed: %w", err)
+	}
+	defer stream.Close()
+
+	columns := make([]string, len(stream.Columns))
+	for i, column := range stream.Columns {
+		columns[i] = column.Name
+	}
+	ds := &PipelineDataset{Columns: columns}
+	for stream.Next() {
+		row := make(map[string]interface{}, len(columns))
+		for i, v := range stream.Values() {
+			row[columns[i]] = v
+		}
+		ds.Rows = append(ds.Rows, row)
+	}
+	return ds, stream.Err()
+}
+
+// TableQuery returns the query that reads every row of a table in the given dialect
+func TableQuery(dialect SQLDialect, table string) string {
+	if dialect == DialectMongoDB {
+		collection, _ := json.Marshal(table)
+		return fmt.Sprintf(`{"collection": %s}`, collection)
+	}
+	return "SELECT * FROM " + dialect.QuoteIdent(table)
+}
+
+// connectionOption returns a string option of a connection, or ""
+func connectionOption(conn *models.Connection, key string) string {
+	if conn.Options == nil {
+		return ""
+	}
+	switch value := (*conn.Options)[key].(type) {
+	case string:
+		return value
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
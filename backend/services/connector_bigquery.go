@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"insight-engine-backend/database"
+	"insight-engine-backend/models"
+	"io"
+	"math/big"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"google.golang.org/api/iterator"
+)
+
+// bigQueryConnector serves BigQuery connections. The project comes from Options["projectId"]
+// (or Host), the base64 service account JSON from Password, the default dataset from
+// Database and the location from Options["location"].
+type bigQueryConnector struct {
+	config    *database.BigQueryConfig
+	connector *database.BigQueryConnector
+}
+
+// newBigQueryConnector creates a connector for a BigQuery connection
+func newBigQueryConnector(conn *models.Connection) (Connector, error) {
+	config := &database.BigQueryConfig{
+		ProjectID:       connectionOption(conn, "projectId"),
+		CredentialsJSON: connectionOption(conn, "credentialsJson"),
+		DefaultDataset:  conn.Database,
+		Location:        connectionOption(conn, "location"),
+	}
+	if config.ProjectID == "" && conn.Host != nil {
+		config.ProjectID = *conn.Host
+	}
+	if conn.Password != nil && *conn.Password != "" {
+		config.CredentialsJSON = *conn.Password
+	}
+	if config.ProjectID == "" {
+		return nil, fmt.Errorf("bigquery connections require a project ID")
+	}
+	return &bigQueryConnector{
+		config:    config,
+		connector: database.NewBigQueryConnector(config),
+	}, nil
+}
+
+func (c *bigQueryConnector) Connect(ctx context.Context) error {
+	return c.connector.Connect()
+}
+
+func (c *bigQueryConnector) Ping(ctx context.Context) error {
+	return c.connector.Ping()
+}
+
+func (c *bigQueryConnector) Close() error {
+	return c.connector.Disconnect()
+}
+
+func (c *bigQueryConnector) Dialect() SQLDialect {
+	return DialectBigQuery
+}
+
+func (c *bigQueryConnector) ListSchemas(ctx context.Context) ([]string, error) {
+	return c.connector.GetDatasets()
+}
+
+func (c *bigQueryConnector) ListTables(ctx context.Context, schema string) ([]TableInfo, error) {
+	dataset, err := c.dataset(schema)
+	if err != nil {
+		return nil, err
+	}
+	found, err := c.connector.GetTables(dataset)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]TableInfo, len(found))
+	for i, table := range found {
+		tables[i] = TableInfo{Schema: table.Schema, Name: table.Name, Type: table.Type}
+	}
+	return tables, nil
+}
+
+func (c *bigQueryConnector) ListColumns(ctx context.Context, schema, table string) ([]ColumnInfo, error) {
+	dataset, err := c.dataset(schema)
+	if err != nil {
+		return nil, err
+	}
+	found, err := c.connector.GetColumns(dataset, table)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]ColumnInfo, len(found))
+	for i, column := range found {
+		columns[i] = ColumnInfo{Name: column.Name, Type: column.DataType, Nullable: column.Nullable}
+	}
+	return columns, nil
+}
+
+// dataset resolves an empty schema to the connection's default dataset
+func (c *bigQueryConnector) dataset(schema string) (string, error) {
+	if schema != "" {
+		return schema, nil
+	}
+	if c.config.DefaultDataset == "" {
+		return "", fmt.Errorf("no dataset selected")
+	}
+	return c.config.DefaultDataset, nil
+}
+
+// Stream runs a GoogleSQL query; args bind to its positional (?) parameters
+func (c *bigQueryConnector) Stream(ctx context.Context, query string, args ...interface{}) (RowIterator, error) {
+	client := c.connector.GetClient()
+	if client == nil {
+		return nil, fmt.Errorf("bigquery client not initialized")
+	}
+
+	q := client.Query(query)
+	q.Location = c.config.Location
+	if c.config.DefaultDataset != "" {
+		q.DefaultProjectID = c.config.ProjectID
+		q.DefaultDatasetID = c.config.DefaultDataset
+	}
+	for _, arg := range args {
+		q.Parameters = append(q.Parameters, bigquery.QueryParameter{Value: arg})
+	}
+
+	it, err := q.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The schema is only known once the first page has been fetched
+	rows := &bigQueryRows{it: it}
+	if err := it.Next(&rows.pending); err == iterator.Done {
+		rows.done = true
+	} else if err != nil {
+		return nil, err
+	}
+	rows.columns = make([]ResultColumn, len(it.Schema))
+	for i, field := range it.Schema {
+		column := ResultColumn{Name: field.Name, DatabaseType: string(field.Type), Kind: columnKind(string(field.Type), nil)}
+		if field.Repeated || field.Type == bigquery.RecordFieldType {
+			column.Kind = ColumnKindString
+		}
+		rows.columns[i] = column
+	}
+	return rows, nil
+}
+
+// bigQueryRows reads a BigQuery result, holding back the row read to learn the schema
+type bigQueryRows struct {
+	it      *bigquery.RowIterator
+	columns []ResultColumn
+	pending []bigquery.Value
+	done    bool
+}
+
+func (r *bigQueryRows) Columns() []ResultColumn {
+	return r.columns
+}
+
+func (r *bigQueryRows) Next(dest []interface{}) error {
+	if r.done {
+		return io.EOF
+	}
+	row := r.pending
+	if row == nil {
+		if err := r.it.Next(&row); err == iterator.Done {
+			r.done = true
+			return io.EOF
+		} else if err != nil {
+			return err
+		}
+	}
+	r.pending = nil
+
+	for i := range dest {
+		dest[i] = nil
+		if i < len(row) {
+			dest[i] = bigQueryValue(row[i])
+		}
+	}
+	return nil
+}
+
+func (r *bigQueryRows) Close() error {
+	r.done = true
+	return nil
+}
+
+// bigQueryValue converts a BigQuery value to the types used by other connectors
+func bigQueryValue(v bigquery.Value) interface{} {
+	switch value := v.(type) {
+	case *big.Rat:
+		if value == nil {
+			return nil
+		}
+		f, _ := value.Float64()
+		return f
+	case civil.Date:
+		return value.In(time.UTC)
+	case civil.DateTime:
+		return value.In(time.UTC)
+	case civil.Time:
+		return value.String()
+	case []bigquery.Value, map[string]bigquery.Value:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%v", value)
+		}
+		return string(data)
+	default:
+		return value
+	}
+}
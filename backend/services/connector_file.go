@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"insight-engine-backend/models"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// fileStorageDir is the root of the files served by sqlite and file connections. Each user's
+// files live in a subdirectory named after their user ID.
+var fileStorageDir = struct {
+	sync.RWMutex
+	path string
+}{path: "./data/files"}
+
+// SetFileStorageDir sets the root directory of uploaded files
+func SetFileStorageDir(dir string) {
+	fileStorageDir.Lock()
+	defer fileStorageDir.Unlock()
+	fileStorageDir.path = dir
+}
+
+// storagePath resolves a path within the storage directory of a connection's owner.
+// Paths cannot climb out of that directory.
+func storagePath(conn *models.Connection, path string) string {
+	fileStorageDir.RLock()
+	root := fileStorageDir.path
+	fileStorageDir.RUnlock()
+	return filepath.Join(root, filepath.Clean("/"+conn.UserID), filepath.Clean("/"+path))
+}
+
+// fileExtensions are the file types a file connection serves as tables
+var fileExtensions = map[string]bool{".csv": true, ".tsv": true, ".parquet": true}
+
+// fileStamp identifies the version of a file that was loaded
+type fileStamp struct {
+	size    int64
+	modTime int64
+}
+
+// fileConnector serves CSV and Parquet files as tables of a SQLite database. Database is a
+// file or a directory within the owner's storage directory; each file of a directory
+// becomes a table named after it. The database is rebuilt when the files change.
+type fileConnector struct {
+	*sqlConnector
+	root   string
+	dbPath string
+	loaded map[string]fileStamp
+}
+
+// newFileConnector creates a connector for a file connection
+func newFileConnector(conn *models.Connection) (Connector, error) {
+	return &fileConnector{root: storagePath(conn, conn.Database)}, nil
+}
+
+func (c *fileConnector) Connect(ctx context.Context) error {
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no CSV or Parquet files found in %s", filepath.Base(c.root))
+	}
+
+	tmp, err := os.CreateTemp("", "insight-files-*.db")
+	if err != nil {
+		return fmt.Errorf("failed to create file database: %w", err)
+	}
+	tmp.Close()
+	c.dbPath = tmp.Name()
+	c.sqlConnector = &sqlConnector{driver: sqliteDriverName, dsn: c.dbPath, dialect: DialectSQLite}
+	if err := c.sqlConnector.Connect(ctx); err != nil {
+		return err
+	}
+
+	c.loaded = make(map[string]fileStamp, len(files))
+	tables := make(map[string]bool, len(files))
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		table := fileTableName(path, tables)
+		if err := c.load(ctx, path, table); err != nil {
+			return fmt.Errorf("failed to load %s: %w", filepath.Base(path), err)
+		}
+		c.loaded[path] = fileStamp{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	}
+
+	LogInfo("file_connector", "Loaded files", map[string]interface{}{
+		"files": len(files),
+	})
+	return nil
+}
+
+func (c *fileConnector) Dialect() SQLDialect {
+	return DialectSQLite
+}
+
+// Ping fails when files were added, changed or removed since they were loaded, so that
+// the connection is rebuilt on its next use
+func (c *fileConnector) Ping(ctx context.Context) error {
+	if c.sqlConnector == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	if len(files) != len(c.loaded) {
+		return fmt.Errorf("source files changed")
+	}
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if stamp, exists := c.loaded[path]; !exists || stamp != (fileStamp{size: info.Size(), modTime: info.ModTime().UnixNano()}) {
+			return fmt.Errorf("source files changed")
+		}
+	}
+	return c.sqlConnector.Ping(ctx)
+}
+
+func (c *fileConnector) pingEveryUse() bool {
+	return true
+}
+
+func (c *fileConnector) Close() error {
+	var err error
+	if c.sqlConnector != nil {
+		err = c.sqlConnector.Close()
+	}
+	if c.dbPath != "" {
+		os.Remove(c.dbPath)
+	}
+	return err
+}
+
+// files lists the data files of the connection, sorted by path
+func (c *fileConnector) files() ([]string, error) {
+	info, err := os.Stat(c.root)
+	if err != nil {
+		return nil, fmt.Errorf("file not found: %s", filepath.Base(c.root))
+	}
+	if !info.IsDir() {
+		if !fileExtensions[strings.ToLower(filepath.Ext(c.root))] {
+			return nil, fmt.Errorf("unsupported file type: %s", filepath.Ext(c.root))
+		}
+		return []string{c.root}, nil
+	}
+
+	entries, err := os.ReadDir(c.root)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && fileExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			files = append(files, filepath.Join(c.root, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// load copies a file into a table of the SQLite database
+func (c *fileConnector) load(ctx context.Context, path, table string) error {
+	loader := NewTableLoader(c.db, "sqlite")
+	if strings.EqualFold(filepath.Ext(path), ".parquet") {
+		return loadParquetFile(ctx, loader, path, table)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	importer := NewCSVImporter()
+	options := importer.GetDefaultOptions()
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		options.Delimiter = '\t'
+	}
+	csvColumns, rows, err := importer.ReadCSVRows(ctx, f, options)
+	if err != nil {
+		return err
+	}
+	columns := make([]TempTableColumn, len(csvColumns))
+	for i, col := range csvColumns {
+		columns[i] = TempTableColumn{Name: col.Name, DataType: col.DetectedType, Nullable: true, Index: i}
+	}
+	_, err = loader.Load(ctx, table, columns, rows, LoadModeOverwrite)
+	return err
+}
+
+// loadParquetFile copies a Parquet file into a table one record batch at a time
+func loadParquetFile(ctx context.Context, loader *TableLoader, path, table string) error {
+	reader, err := file.OpenParquetFile(path, false)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	arrowReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{BatchSize: 10000}, memory.DefaultAllocator)
+	if err != nil {
+		return err
+	}
+	records, err := arrowReader.GetRecordReader(ctx, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer records.Release()
+
+	schema := records.Schema()
+	columns := make([]TempTableColumn, schema.NumFields())
+	for i, field := range schema.Fields() {
+		columns[i] = TempTableColumn{Name: field.Name, DataType: arrowDataType(field.Type), Nullable: true, Index: i}
+	}
+
+	mode := LoadModeOverwrite
+	for records.Next() {
+		record := records.Record()
+		rows := make([][]interface{}, record.NumRows())
+		for r := range rows {
+			rows[r] = make([]interface{}, len(columns))
+			for i := range columns {
+				rows[r][i] = arrowValue(record.Column(i), r)
+			}
+		}
+		if _, err := loader.Load(ctx, table, columns, rows, mode); err != nil {
+			return err
+		}
+		mode = LoadModeAppend
+	}
+	if err := records.Err(); err != nil && err != io.EOF {
+		return err
+	}
+	if mode == LoadModeOverwrite {
+		// No rows; still create the table
+		_, err = loader.Load(ctx, table, columns, nil, mode)
+	}
+	return err
+}
+
+// arrowDataType maps an Arrow type to an internal data type
+func arrowDataType(dataType arrow.DataType) string {
+	switch dataType.ID() {
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64,
+		arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64:
+		return "integer"
+	case arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64, arrow.DECIMAL128, arrow.DECIMAL256:
+		return "float"
+	case arrow.BOOL:
+		return "boolean"
+	case arrow.TIMESTAMP, arrow.DATE32, arrow.DATE64:
+		return "timestamp"
+	default:
+		return "text"
+	}
+}
+
+// arrowValue returns the i-th value of an Arrow array as a Go value
+func arrowValue(arr arrow.Array, i int) interface{} {
+	if arr.IsNull(i) {
+		return nil
+	}
+	switch a := arr.(type) {
+	case *array.Int8:
+		return int64(a.Value(i))
+	case *array.Int16:
+		return int64(a.Value(i))
+	case *array.Int32:
+		return int64(a.Value(i))
+	case *array.Int64:
+		return a.Value(i)
+	case *array.Uint8:
+		return int64(a.Value(i))
+	case *array.Uint16:
+		return int64(a.Value(i))
+	case *array.Uint32:
+		return int64(a.Value(i))
+	case *array.Uint64:
+		return int64(a.Value(i))
+	case *array.Float32:
+		return float64(a.Value(i))
+	case *array.Float64:
+		return a.Value(i)
+	case *array.Decimal128:
+		return a.Value(i).ToFloat64(a.DataType().(*arrow.Decimal128Type).Scale)
+	case *array.Boolean:
+		return a.Value(i)
+	case *array.String:
+		return a.Value(i)
+	case *array.LargeString:
+		return a.Value(i)
+	case *array.Timestamp:
+		return a.Value(i).ToTime(a.DataType().(*arrow.TimestampType).Unit).UTC()
+	case *array.Date32:
+		return a.Value(i).ToTime().UTC()
+	case *array.Date64:
+		return a.Value(i).ToTime().UTC()
+	default:
+		return arr.ValueStr(i)
+	}
+}
+
+// fileTableName derives a unique table name from a file name
+func fileTableName(path string, taken map[string]bool) string {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name := strings.Trim(strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(base)), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "t_" + name
+	}
+
+	unique := name
+	for n := 2; taken[unique]; n++ {
+		unique = fmt.Sprintf("%s_%d", name, n)
+	}
+	taken[unique] = true
+	return unique
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"insight-engine-backend/models"
+)
+
+// TestFileConnector tests querying CSV and Parquet files as SQLite tables
+func TestFileConnector(t *testing.T) {
+	root := t.TempDir()
+	SetFileStorageDir(root)
+	defer SetFileStorageDir("./data/files")
+
+	dir := filepath.Join(root, "user-1", "sales")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	csvData := "Region,Orders\nEU,3\nUS,5\n"
+	if err := os.WriteFile(filepath.Join(dir, "orders.csv"), []byte(csvData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2026 targets.parquet"), writeExportTestRows(t, ResultFormatParquet), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qe := NewQueryExecutor()
+	defer qe.Close()
+	conn := &models.Connection{ID: "files-1", Type: "file", Database: "sales", UserID: "user-1"}
+	ctx := context.Background()
+
+	if escaped := storagePath(conn, "../../etc/passwd"); escaped != filepath.Join(root, "user-1", "etc", "passwd") {
+		t.Errorf("Expected the path to stay in the user's directory, got %s", escaped)
+	}
+
+	tables, err := qe.ListTables(ctx, conn, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tables) != 2 || tables[0].Name != "orders" || tables[1].Name != "t_2026_targets" {
+		t.Fatalf("Unexpected tables: %+v", tables)
+	}
+
+	result, err := qe.Execute(ctx, conn, `SELECT o.region, o.orders + t.orders AS total, t.updated_at
+		FROM orders o JOIN t_2026_targets t ON t.region = o.region ORDER BY o.region`, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.RowCount != 1 || result.Rows[0][0] != "US" || result.Rows[0][1] != int64(10) {
+		t.Errorf("Unexpected result: %+v", result.Rows)
+	}
+
+	// Changed files are reloaded on the next query
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(filepath.Join(dir, "orders.csv"), []byte(csvData+"APAC,7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filepath.Join(dir, "orders.csv"), later, later)
+	result, err = qe.Execute(ctx, conn, "SELECT COUNT(*) FROM orders", nil, nil)
+	if err != nil || result.Rows[0][0] != int64(3) {
+		t.Errorf("Expected the reloaded file to have 3 rows, got %v (%v)", result, err)
+	}
+}
+
+// TestSQLiteConnector tests sqlite connections and their schema listing
+func TestSQLiteConnector(t *testing.T) {
+	root := t.TempDir()
+	SetFileStorageDir(root)
+	defer SetFileStorageDir("./data/files")
+
+	path := filepath.Join(root, "user-1", "app.db")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT NOT NULL, tier TEXT DEFAULT 'basic'); INSERT INTO customers (name) VALUES ('Ada'), ('Grace')"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	qe := NewQueryExecutor()
+	defer qe.Close()
+	conn := &models.Connection{ID: "sqlite-1", Type: "sqlite", Database: "app.db", UserID: "user-1"}
+	ctx := context.Background()
+
+	limit := 1
+	result, err := qe.Execute(ctx, conn, "SELECT name FROM customers ORDER BY id", &limit, nil)
+	if err != nil || result.RowCount != 1 || result.Rows[0][0] != "Ada" {
+		t.Fatalf("Unexpected result %+v (%v)", result, err)
+	}
+
+	columns, err := qe.ListColumns(ctx, conn, "", "customers")
+	if err != nil || len(columns) != 3 {
+		t.Fatalf("Unexpected columns %+v (%v)", columns, err)
+	}
+	if !columns[0].IsPrimaryKey || columns[1].Nullable || columns[2].DefaultValue == nil {
+		t.Errorf("Unexpected column details: %+v", columns)
+	}
+}
+
+// TestSQLiteConnector_Confined tests that SQL of sqlite connections cannot reach other files
+func TestSQLiteConnector_Confined(t *testing.T) {
+	root := t.TempDir()
+	SetFileStorageDir(filepath.Join(root, "files"))
+	defer SetFileStorageDir("./data/files")
+	os.MkdirAll(filepath.Join(root, "files", "user-1"), 0755)
+
+	qe := NewQueryExecutor()
+	defer qe.Close()
+	conn := &models.Connection{ID: "sqlite-1", Type: "sqlite", Database: "app.db", UserID: "user-1"}
+	ctx := context.Background()
+
+	escaped := filepath.Join(root, "escaped.db")
+	for _, statement := range []string{
+		"ATTACH DATABASE '" + escaped + "' AS x",
+		"SELECT 1; attach '" + escaped + "' AS x",
+		"VACUUM INTO '" + escaped + "'",
+		"SELECT load_extension('/tmp/ext.so')",
+	} {
+		if _, err := qe.Execute(ctx, conn, statement, nil, nil); err == nil {
+			t.Errorf("Expected %q to be rejected", statement)
+		}
+	}
+
+	// Statements that bypass the query executor go through the same driver
+	db, err := qe.getConnection(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "ATTACH DATABASE '"+escaped+"' AS x"); err == nil {
+		t.Error("Expected ATTACH to be rejected on the connection pool")
+	}
+	if _, err := os.Stat(escaped); !os.IsNotExist(err) {
+		t.Errorf("Expected no file outside the storage directory, got %v", err)
+	}
+
+	// Quoted identifiers and strings are not statements
+	if _, err := db.ExecContext(ctx, `CREATE TABLE notes ("vacuum" TEXT); INSERT INTO notes VALUES ('attach')`); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
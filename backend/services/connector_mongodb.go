@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"insight-engine-backend/database"
+	"insight-engine-backend/models"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoSampleSize is how many documents are read to infer the columns of a collection or
+// query result. Fields that first appear after the sample are not returned.
+const mongoSampleSize = 100
+
+// mongoDBConnector serves MongoDB connections. Documents are flattened into rows by
+// MongoDBTranslator, so nested fields become columns such as "address_city".
+type mongoDBConnector struct {
+	config     *database.MongoDBConfig
+	connector  *database.MongoDBConnector
+	translator *MongoDBTranslator
+	ssh        *ConnectionSSH
+	tunnel     *sshTunnel
+}
+
+// newMongoDBConnector creates a connector for a MongoDB connection. Options may carry a
+// full "uri", and "authSource", "replicaSet" and "tls" for connections built from parts.
+func newMongoDBConnector(conn *models.Connection) (Connector, error) {
+	config := &database.MongoDBConfig{
+		URI:        connectionOption(conn, "uri"),
+		Database:   conn.Database,
+		AuthSource: connectionOption(conn, "authSource"),
+		ReplicaSet: connectionOption(conn, "replicaSet"),
+	}
+	config.UseURI = config.URI != ""
+	pool := poolSettings(conn)
+	config.MaxPoolSize = pool.MaxOpenConns
+	config.MinPoolSize = pool.MaxIdleConns
+	if conn.Host != nil {
+		config.Host = *conn.Host
+	}
+	if conn.Port != nil {
+		config.Port = *conn.Port
+	}
+	if conn.Username != nil {
+		config.Username = *conn.Username
+	}
+	if conn.Password != nil {
+		config.Password = *conn.Password
+	}
+
+	// "tls" is either the legacy true/"true" flag or a TLS settings object
+	var tlsOption interface{}
+	if conn.Options != nil {
+		tlsOption = (*conn.Options)["tls"]
+	}
+	switch tlsOption.(type) {
+	case string, bool:
+		config.TLS = connectionOption(conn, "tls") == "true"
+	default:
+		tlsSettings, err := connectionTLS(conn)
+		if err != nil {
+			return nil, err
+		}
+		config.TLS = tlsSettings != nil && tlsSettings.Mode != TLSModeDisable
+		if config.TLSConfig, err = tlsSettings.Config(config.Host); err != nil {
+			return nil, err
+		}
+	}
+	sshSettings, err := connectionSSH(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	connector := database.NewMongoDBConnector(config)
+	return &mongoDBConnector{
+		config:     config,
+		connector:  connector,
+		translator: NewMongoDBTranslator(connector),
+		ssh:        sshSettings,
+	}, nil
+}
+
+func (c *mongoDBConnector) Connect(ctx context.Context) error {
+	if c.ssh != nil {
+		tunnel, err := openSSHTunnel(c.ssh)
+		if err != nil {
+			return err
+		}
+		c.tunnel = tunnel
+		c.config.Dialer = tunnel
+	}
+	return c.connector.Connect()
+}
+
+func (c *mongoDBConnector) Ping(ctx context.Context) error {
+	return c.connector.Ping()
+}
+
+func (c *mongoDBConnector) Close() error {
+	err := c.connector.Disconnect()
+	if c.tunnel != nil {
+		c.tunnel.Close()
+	}
+	return err
+}
+
+func (c *mongoDBConnector) Dialect() SQLDialect {
+	return DialectMongoDB
+}
+
+func (c *mongoDBConnector) ListSchemas(ctx context.Context) ([]string, error) {
+	return c.connector.ListDatabases()
+}
+
+func (c *mongoDBConnector) ListTables(ctx context.Context, schema string) ([]TableInfo, error) {
+	db, err := c.database(schema)
+	if err != nil {
+		return nil, err
+	}
+	names, err := db.ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	tables := make([]TableInfo, len(names))
+	for i, name := range names {
+		tables[i] = TableInfo{Schema: db.Name(), Name: name, Type: "COLLECTION"}
+	}
+	return tables, nil
+}
+
+func (c *mongoDBConnector) ListColumns(ctx context.Context, schema, table string) ([]ColumnInfo, error) {
+	db, err := c.database(schema)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := db.Collection(table).Find(ctx, bson.M{}, options.Find().SetLimit(mongoSampleSize))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sample []map[string]interface{}
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		sample = append(sample, c.translator.FlattenDocument(doc, ""))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	var columns []ColumnInfo
+	for _, column := range mongoColumns(sample) {
+		columns = append(columns, ColumnInfo{
+			Name:         column.Name,
+			Type:         column.DatabaseType,
+			Nullable:     column.Name != "_id",
+			IsPrimaryKey: column.Name == "_id",
+		})
+	}
+	return columns, nil
+}
+
+// database resolves an empty schema to the connection's database
+func (c *mongoDBConnector) database(schema string) (*mongo.Database, error) {
+	client := c.connector.GetClient()
+	if client == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if schema == "" {
+		schema = c.config.Database
+	}
+	if schema == "" {
+		return nil, fmt.Errorf("no database selected")
+	}
+	return client.Database(schema), nil
+}
+
+// mongoQuery is the query language of MongoDB connections: a find on a collection followed by
+// an optional aggregation pipeline, written as (extended) JSON, e.g.
+//
+//	{"collection": "orders", "filter": {"status": "paid"}, "sort": {"createdAt": -1}, "limit": 100}
+//	{"collection": "orders", "pipeline": [{"$group": {"_id": "$region", "total": {"$sum": "$amount"}}}]}
+type mongoQuery struct {
+	Collection string   `bson:"collection"`
+	Filter     bson.D   `bson:"filter"`
+	Sort       bson.D   `bson:"sort"`
+	Skip       int64    `bson:"skip"`
+	Limit      int64    `bson:"limit"`
+	Projection bson.D   `bson:"projection"`
+	Pipeline   []bson.D `bson:"pipeline"`
+	Database   string   `bson:"database"`
+}
+
+// stages returns the query as a single aggregation pipeline
+func (q *mongoQuery) stages() mongo.Pipeline {
+	var pipeline mongo.Pipeline
+	if len(q.Filter) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: q.Filter}})
+	}
+	if len(q.Sort) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: q.Sort}})
+	}
+	if q.Skip > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: q.Skip}})
+	}
+	if q.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: q.Limit}})
+	}
+	if len(q.Projection) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: q.Projection}})
+	}
+	return append(pipeline, q.Pipeline...)
+}
+
+// Stream runs a mongoQuery. MongoDB queries take no bind parameters.
+func (c *mongoDBConnector) Stream(ctx context.Context, query string, args ...interface{}) (RowIterator, error) {
+	if len(args) > 0 {
+		return nil, fmt.Errorf("mongodb queries do not take parameters")
+	}
+	var q mongoQuery
+	if err := bson.UnmarshalExtJSON([]byte(query), false, &q); err != nil {
+		return nil, fmt.Errorf("invalid mongodb query: %w", err)
+	}
+	if q.Collection == "" {
+		return nil, fmt.Errorf("invalid mongodb query: collection is required")
+	}
+	db, err := c.database(q.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.Collection(q.Collection).Aggregate(ctx, q.stages())
+	if err != nil {
+		return nil, err
+	}
+
+	rows := &mongoRows{ctx: ctx, cursor: cursor, translator: c.translator}
+	for len(rows.pending) < mongoSampleSize && cursor.Next(ctx) {
+		doc, err := rows.decode()
+		if err != nil {
+			cursor.Close(ctx)
+			return nil, err
+		}
+		rows.pending = append(rows.pending, doc)
+	}
+	if err := cursor.Err(); err != nil {
+		cursor.Close(ctx)
+		return nil, err
+	}
+	rows.columns = mongoColumns(rows.pending)
+	return rows, nil
+}
+
+// mongoRows reads flattened documents from a cursor, starting with the sampled ones
+type mongoRows struct {
+	ctx        context.Context
+	cursor     *mongo.Cursor
+	translator *MongoDBTranslator
+	columns    []ResultColumn
+	pending    []map[string]interface{}
+}
+
+func (r *mongoRows) Columns() []ResultColumn {
+	return r.columns
+}
+
+func (r *mongoRows) Next(dest []interface{}) error {
+	var doc map[string]interface{}
+	if len(r.pending) > 0 {
+		doc, r.pending = r.pending[0], r.pending[1:]
+	} else {
+		if !r.cursor.Next(r.ctx) {
+			if err := r.cursor.Err(); err != nil {
+				return err
+			}
+			return io.EOF
+		}
+		var err error
+		if doc, err = r.decode(); err != nil {
+			return err
+		}
+	}
+
+	for i, column := range r.columns {
+		dest[i] = mongoValue(doc[column.Name])
+	}
+	return nil
+}
+
+func (r *mongoRows) Close() error {
+	return r.cursor.Close(context.Background())
+}
+
+// decode flattens the cursor's current document
+func (r *mongoRows) decode() (map[string]interface{}, error) {
+	var doc bson.M
+	if err := r.cursor.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return r.translator.FlattenDocument(doc, ""), nil
+}
+
+// mongoColumns derives the columns of flattened documents: _id first, then by name
+func mongoColumns(docs []map[string]interface{}) []ResultColumn {
+	kinds := make(map[string]string)
+	for _, doc := range docs {
+		for name, value := range doc {
+			if kind, seen := kinds[name]; !seen || kind == "" {
+				kinds[name] = mongoKind(value)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "_id" || names[j] == "_id" {
+			return names[i] == "_id"
+		}
+		return names[i] < names[j]
+	})
+
+	columns := make([]ResultColumn, len(names))
+	for i, name := range names {
+		kind := kinds[name]
+		if kind == "" {
+			kind = ColumnKindString
+		}
+		columns[i] = ResultColumn{Name: name, DatabaseType: strings.ToUpper(kind), Kind: kind}
+	}
+	return columns
+}
+
+// mongoKind classifies a flattened value, or returns "" for null
+func mongoKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return ""
+	case int32, int64, int:
+		return ColumnKindInteger
+	case float64, primitive.Decimal128:
+		return ColumnKindFloat
+	case bool:
+		return ColumnKindBoolean
+	default:
+		return ColumnKindString
+	}
+}
+
+// mongoValue converts a flattened value to the types used by other connectors
+func mongoValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case primitive.D:
+		data, err := bson.MarshalExtJSON(v, false, false)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	default:
+		return value
+	}
+}
+
+// mongoLimit applies a limit and offset to a mongoQuery by appending $skip and $limit stages
+// to its pipeline, so that they apply to the result of any limits already in the query
+func mongoLimit(query string, limit, offset *int) string {
+	var spec map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(query), &spec); err != nil {
+		return query // Reported when the query runs
+	}
+	var pipeline []json.RawMessage
+	if raw, exists := spec["pipeline"]; exists {
+		if err := json.Unmarshal(raw, &pipeline); err != nil {
+			return query
+		}
+	}
+
+	if offset != nil && *offset > 0 {
+		pipeline = append(pipeline, json.RawMessage(fmt.Sprintf(`{"$skip": %d}`, *offset)))
+	}
+	if limit != nil {
+		if *limit > 0 {
+			pipeline = append(pipeline, json.RawMessage(fmt.Sprintf(`{"$limit": %d}`, *limit)))
+		} else {
+			// $limit must be positive
+			pipeline = append(pipeline, json.RawMessage(`{"$match": {"$expr": false}}`))
+		}
+	}
+
+	raw, err := json.Marshal(pipeline)
+	if err != nil {
+		return query
+	}
+	spec["pipeline"] = raw
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return query
+	}
+	return string(data)
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// sqliteDriverName is the driver of sqlite and file connections. Their SQL runs inside the
+// server's process, so the driver rejects the statements through which SQLite reaches other
+// files, which would escape the owner's storage directory.
+const sqliteDriverName = "sqlite_confined"
+
+// sqliteFileKeywords are the statements and functions of SQLite that open, create or load
+// files: ATTACH opens any database file, VACUUM INTO writes one and load_extension loads a
+// shared library
+var sqliteFileKeywords = []string{"ATTACH", "DETACH", "VACUUM", "LOAD_EXTENSION"}
+
+func init() {
+	// sql.Open does not connect; it only resolves the registered driver
+	db, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(err)
+	}
+	sql.Register(sqliteDriverName, &confinedSQLiteDriver{base: db.Driver()})
+	db.Close()
+}
+
+// checkSQLiteStatement rejects SQL that reaches files other than the connection's database
+func checkSQLiteStatement(query string) error {
+	keywords := queryKeywords(query, true)
+	for _, keyword := range sqliteFileKeywords {
+		if keywords[keyword] {
+			return fmt.Errorf("%s is not allowed on sqlite connections", strings.ToLower(keyword))
+		}
+	}
+	return nil
+}
+
+// confinedSQLiteDriver opens SQLite connections that check every statement they run
+type confinedSQLiteDriver struct {
+	base driver.Driver
+}
+
+func (d *confinedSQLiteDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &confinedSQLiteConn{Conn: conn}, nil
+}
+
+// confinedSQLiteConn checks statements before the SQLite connection prepares or runs them.
+// database/sql only uses the context-aware methods, which the SQLite driver implements.
+type confinedSQLiteConn struct {
+	driver.Conn
+}
+
+func (c *confinedSQLiteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *confinedSQLiteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := checkSQLiteStatement(query); err != nil {
+		return nil, err
+	}
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+}
+
+func (c *confinedSQLiteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := checkSQLiteStatement(query); err != nil {
+		return nil, err
+	}
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *confinedSQLiteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := checkSQLiteStatement(query); err != nil {
+		return nil, err
+	}
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *confinedSQLiteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *confinedSQLiteConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
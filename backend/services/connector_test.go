@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"insight-engine-backend/models"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// memoryConnector serves fixed rows for registry tests
+type memoryConnector struct {
+	connects *int
+	rows     [][]interface{}
+	columns  []ResultColumn // Defaults to id and name
+}
+
+func (c *memoryConnector) Connect(ctx context.Context) error {
+	*c.connects++
+	return nil
+}
+func (c *memoryConnector) Ping(ctx context.Context) error { return nil }
+func (c *memoryConnector) Close() error                   { return nil }
+func (c *memoryConnector) Dialect() SQLDialect            { return DialectPostgres }
+func (c *memoryConnector) ListSchemas(ctx context.Context) ([]string, error) {
+	return []string{"main"}, nil
+}
+func (c *memoryConnector) ListTables(ctx context.Context, schema string) ([]TableInfo, error) {
+	return []TableInfo{{Schema: "main", Name: "orders", Type: "BASE TABLE"}}, nil
+}
+func (c *memoryConnector) ListColumns(ctx context.Context, schema, table string) ([]ColumnInfo, error) {
+	return []ColumnInfo{{Name: "id", Type: "INTEGER"}}, nil
+}
+func (c *memoryConnector) Stream(ctx context.Context, query string, args ...interface{}) (RowIterator, error) {
+	return &memoryRows{rows: c.rows, columns: c.columns}, nil
+}
+
+type memoryRows struct {
+	rows    [][]interface{}
+	columns []ResultColumn
+}
+
+func (r *memoryRows) Columns() []ResultColumn {
+	if r.columns != nil {
+		return r.columns
+	}
+	return []ResultColumn{{Name: "id", Kind: ColumnKindInteger}, {Name: "name", Kind: ColumnKindString}}
+}
+
+func (r *memoryRows) Next(dest []interface{}) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func (r *memoryRows) Close() error { return nil }
+
+// TestConnectorRegistry tests that queries are routed to the connector registered for a type
+func TestConnectorRegistry(t *testing.T) {
+	connects := 0
+	RegisterConnector("memory", func(conn *models.Connection) (Connector, error) {
+		return &memoryConnector{connects: &connects, rows: [][]interface{}{{int64(1), []byte("a")}, {int64(2), "b"}}}, nil
+	})
+
+	qe := NewQueryExecutor()
+	conn := &models.Connection{ID: "conn-1", Type: "Memory"}
+
+	result, err := qe.Execute(context.Background(), conn, "SELECT * FROM orders", nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(result.Columns, []string{"id", "name"}) || result.RowCount != 2 || result.Rows[0][1] != "a" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	stream, err := qe.Stream(context.Background(), conn, "SELECT * FROM orders")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer stream.Close()
+	chunk, err := stream.NextChunk(10)
+	if err != nil || len(chunk) != 2 || chunk[1][0] != int64(2) {
+		t.Errorf("Unexpected chunk %v (%v)", chunk, err)
+	}
+	if connects != 1 {
+		t.Errorf("Expected the connector to be reused, got %d connects", connects)
+	}
+
+	tables, err := NewSchemaDiscovery(qe).DiscoverSchema(context.Background(), conn)
+	if err != nil || len(tables) != 1 || len(tables[0].Columns) != 1 {
+		t.Errorf("Unexpected schema %+v (%v)", tables, err)
+	}
+
+	if _, err := NewConnector(&models.Connection{Type: "dbase"}); err == nil {
+		t.Error("Expected an error for an unregistered type")
+	}
+}
+
+// TestTypedResults tests column metadata and that decimals keep their precision in JSON
+func TestTypedResults(t *testing.T) {
+	connects := 0
+	nullable := false
+	RegisterConnector("typed", func(conn *models.Connection) (Connector, error) {
+		return &memoryConnector{
+			connects: &connects,
+			columns: []ResultColumn{
+				{Name: "amount", DatabaseType: "NUMERIC", Kind: ColumnKindFloat, Nullable: &nullable},
+				{Name: "payload", DatabaseType: "JSONB", Kind: ColumnKindString},
+				{Name: "day", DatabaseType: "DATE", Kind: ColumnKindTimestamp},
+				{Name: "price", DatabaseType: "MONEY", Kind: ColumnKindFloat},
+			},
+			rows: [][]interface{}{{[]byte("12345678901234567890.120"), []byte(`{"a":1}`), "2026-10-16", []byte("$1.50")}},
+		}, nil
+	})
+
+	qe := NewQueryExecutor()
+	defer qe.Close()
+	result, err := qe.Execute(context.Background(), &models.Connection{ID: "conn-1", Type: "typed"}, "SELECT * FROM payments", nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var types []string
+	for _, column := range result.ColumnTypes {
+		types = append(types, column.Type)
+	}
+	expected := []string{models.ColumnTypeDecimal, models.ColumnTypeJSON, models.ColumnTypeDate, models.ColumnTypeDecimal}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("Expected types %v, got %v", expected, types)
+	}
+	if n := result.ColumnTypes[0].Nullable; n == nil || *n {
+		t.Errorf("Expected the nullability to be reported, got %v", n)
+	}
+
+	data, _ := json.Marshal(result.Rows)
+	if string(data) != `[[12345678901234567890.120,"{\"a\":1}","2026-10-16","$1.50"]]` {
+		t.Errorf("Unexpected JSON rows %s", data)
+	}
+}
+
+// TestMongoLimit tests that limits are appended to the query's pipeline
+func TestMongoLimit(t *testing.T) {
+	limit, offset := 10, 20
+	query := DialectMongoDB.LimitSQL(`{"collection": "orders", "limit": 100, "pipeline": [{"$sort": {"b": 1, "a": -1}}]}`, &limit, &offset)
+
+	var spec struct {
+		Collection string            `json:"collection"`
+		Limit      int               `json:"limit"`
+		Pipeline   []json.RawMessage `json:"pipeline"`
+	}
+	if err := json.Unmarshal([]byte(query), &spec); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stages := make([]string, len(spec.Pipeline))
+	for i, stage := range spec.Pipeline {
+		stages[i] = string(stage)
+	}
+	expected := []string{`{"$sort":{"b":1,"a":-1}}`, `{"$skip":20}`, `{"$limit":10}`}
+	if spec.Collection != "orders" || spec.Limit != 100 || !reflect.DeepEqual(stages, expected) {
+		t.Errorf("Unexpected query %s", query)
+	}
+}
+
+// TestMongoColumns tests column inference from flattened documents
+func TestMongoColumns(t *testing.T) {
+	columns := mongoColumns([]map[string]interface{}{
+		{"total": nil, "_id": "a1", "address_city": "Oslo"},
+		{"total": 12.5, "_id": "a2", "count": int32(3)},
+	})
+	expected := []ResultColumn{
+		{Name: "_id", DatabaseType: "STRING", Kind: ColumnKindString},
+		{Name: "address_city", DatabaseType: "STRING", Kind: ColumnKindString},
+		{Name: "count", DatabaseType: "INTEGER", Kind: ColumnKindInteger},
+		{Name: "total", DatabaseType: "FLOAT", Kind: ColumnKindFloat},
+	}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Expected %v, got %v", expected, columns)
+	}
+}
@@ -331,6 +331,12 @@ func (imp *CSVImporter) isBoolean(value string) bool {
 
 // isDate checks if a value is a date
 func (imp *CSVImporter) isDate(value string) bool {
+	_, ok := imp.parseDate(value)
+	return ok
+}
+
+// parseDate parses a value using the supported date layouts
+func (imp *CSVImporter) parseDate(value string) (time.Time, bool) {
 	dateFormats := []string{
 		"2006-01-02",
 		"2006-01-02 15:04:05",
@@ -344,13 +350,13 @@ func (imp *CSVImporter) isDate(value string) bool {
 	}
 
 	for _, format := range dateFormats {
-		_, err := time.Parse(format, value)
+		parsed, err := time.Parse(format, value)
 		if err == nil {
-			return true
+			return parsed, true
 		}
 	}
 
-	return false
+	return time.Time{}, false
 }
 
 // ImportCSV imports CSV file to temporary table
@@ -377,6 +383,132 @@ func (imp *CSVImporter) ImportCSV(
 	return result, nil
 }
 
+// ReadCSVRows reads every data row of a CSV stream and converts values to their detected column types
+func (imp *CSVImporter) ReadCSVRows(
+	ctx context.Context,
+	r io.Reader,
+	options *CSVImportOptions,
+) ([]CSVColumn, [][]interface{}, error) {
+	if options == nil {
+		options = imp.GetDefaultOptions()
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = options.Delimiter
+	reader.TrimLeadingSpace = options.TrimWhitespace
+	reader.FieldsPerRecord = -1
+
+	for i := 0; i < options.SkipRows; i++ {
+		if _, err := reader.Read(); err != nil {
+			return nil, nil, fmt.Errorf("failed to skip row %d: %w", i, err)
+		}
+	}
+
+	var headers []string
+	if options.HasHeader {
+		header, err := reader.Read()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		headers = imp.cleanHeaders(header)
+	}
+
+	var records [][]string
+	for options.MaxRows == 0 || len(records) < options.MaxRows {
+		if len(records)%1000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read row %d: %w", len(records)+1, err)
+		}
+		records = append(records, row)
+	}
+
+	if len(records) == 0 {
+		return nil, nil, errors.New("no data rows found in CSV")
+	}
+
+	if !options.HasHeader {
+		headers = make([]string, len(records[0]))
+		for i := range headers {
+			headers[i] = fmt.Sprintf("column_%d", i+1)
+		}
+	}
+
+	sample := records
+	if len(sample) > imp.sampleSize {
+		sample = sample[:imp.sampleSize]
+	}
+	columns := imp.detectColumnTypes(headers, sample, options)
+
+	rows := make([][]interface{}, len(records))
+	for i, record := range records {
+		rows[i] = imp.ConvertRecord(record, columns, options)
+	}
+
+	return columns, rows, nil
+}
+
+// ConvertRecord converts a raw string record to typed values using detected column types.
+// Values that do not parse as the detected type are kept as strings.
+func (imp *CSVImporter) ConvertRecord(record []string, columns []CSVColumn, options *CSVImportOptions) []interface{} {
+	values := make([]interface{}, len(columns))
+
+	for i, col := range columns {
+		if i >= len(record) {
+			continue
+		}
+
+		raw := record[i]
+		if options.TrimWhitespace {
+			raw = strings.TrimSpace(raw)
+		}
+
+		isNull := false
+		for _, nullVal := range options.NullValues {
+			if raw == nullVal {
+				isNull = true
+				break
+			}
+		}
+		if isNull {
+			continue
+		}
+
+		values[i] = raw
+		switch col.DetectedType {
+		case "integer":
+			if v, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				values[i] = v
+			}
+		case "float":
+			if v, err := strconv.ParseFloat(raw, 64); err == nil {
+				values[i] = v
+			}
+		case "boolean":
+			switch strings.ToLower(raw) {
+			case "true", "yes", "1", "t", "y":
+				values[i] = true
+			case "false", "no", "0", "f", "n":
+				values[i] = false
+			}
+		case "date":
+			if v, ok := imp.parseDate(raw); ok {
+				values[i] = v
+			}
+		}
+	}
+
+	return values
+}
+
 // ValidateCSVFile validates CSV file before import
 func (imp *CSVImporter) ValidateCSVFile(fileHeader *multipart.FileHeader) error {
 	// Check file size
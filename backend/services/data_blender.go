@@ -232,7 +232,8 @@ func (s *DataBlenderService) fetchAllSources(ctx context.Context, query *BlendQu
 		sql := s.buildSourceQuery(source)
 
 		// Execute query
-		queryResult, err := s.queryExecutor.Execute(ctx, &connection, sql, nil, nil)
+		queryCtx := WithQuerySource(WithQueryUser(ctx, query.UserID.String()), QuerySourceSystem, "")
+		queryResult, err := s.queryExecutor.Execute(queryCtx, &connection, sql, nil, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to query source %s: %w", source.Alias, err)
 		}
@@ -243,12 +244,16 @@ func (s *DataBlenderService) fetchAllSources(ctx context.Context, query *BlendQu
 				source.Alias, len(queryResult.Rows), s.maxRowsPerSource)
 		}
 
-		// Convert columns from []string to []QueryColumn
+		// Convert columns from []string to []QueryColumn, with the logical type of each
 		queryColumns := make([]QueryColumn, len(queryResult.Columns))
 		for i, colName := range queryResult.Columns {
+			dataType := models.ColumnTypeText
+			if i < len(queryResult.ColumnTypes) {
+				dataType = queryResult.ColumnTypes[i].Type
+			}
 			queryColumns[i] = QueryColumn{
 				Name:     colName,
-				DataType: "text", // Default type, could be enhanced
+				DataType: dataType,
 			}
 		}
 
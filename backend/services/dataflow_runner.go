@@ -0,0 +1,509 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"insight-engine-backend/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Dataflow step types
+const (
+	DataflowStepQuery       = "QUERY"
+	DataflowStepMaterialize = "MATERIALIZE"
+)
+
+// Dataflow step statuses reported in DataflowRun.Logs
+const (
+	DataflowStepPending   = "PENDING"
+	DataflowStepRunning   = "RUNNING"
+	DataflowStepCompleted = "COMPLETED"
+	DataflowStepFailed    = "FAILED"
+	DataflowStepSkipped   = "SKIPPED"
+)
+
+// MATERIALIZE targets
+const (
+	materializeTargetTempTable = "TEMP_TABLE"
+	materializeTargetView      = "MATERIALIZED_VIEW"
+)
+
+// DataflowStepConfig is the decoded DataflowStep.Config
+type DataflowStepConfig struct {
+	DependsOn []string `json:"dependsOn"` // Upstream step IDs or names
+
+	// QUERY
+	SQL          string `json:"sql"`
+	ConnectionID string `json:"connectionId"` // Empty = query upstream result sets
+
+	// MATERIALIZE
+	SourceStepID string `json:"sourceStepId"` // Defaults to the only upstream step
+	Target       string `json:"target"`       // TEMP_TABLE (default), MATERIALIZED_VIEW
+	TargetTable  string `json:"targetTable"`  // Display name of the output (default: step name)
+	Mode         string `json:"mode"`         // TEMP_TABLE: overwrite (default), append
+	RefreshMode  string `json:"refreshMode"`  // MATERIALIZED_VIEW: full (default), incremental
+}
+
+// DataflowStepLog is the status entry of one step stored in DataflowRun.Logs
+type DataflowStepLog struct {
+	StepID      string     `json:"stepId"`
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Status      string     `json:"status"` // PENDING, RUNNING, COMPLETED, FAILED, SKIPPED
+	DependsOn   []string   `json:"dependsOn"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	DurationMs  int64      `json:"durationMs"`
+	Rows        int        `json:"rows"`
+	Output      string     `json:"output,omitempty"` // Table or view written by MATERIALIZE
+	Message     string     `json:"message,omitempty"`
+}
+
+// dataflowNode is a step with its decoded config and resolved upstream steps
+type dataflowNode struct {
+	step   models.DataflowStep
+	config DataflowStepConfig
+	deps   []*dataflowNode
+}
+
+// DataflowRunner executes dataflow steps in dependency order
+type DataflowRunner struct {
+	db                *gorm.DB
+	queryExecutor     *QueryExecutor
+	tempTables        *TempTableService
+	materializedViews *MaterializedViewService
+}
+
+// NewDataflowRunner creates a new dataflow runner
+func NewDataflowRunner(
+	db *gorm.DB,
+	queryExecutor *QueryExecutor,
+	tempTables *TempTableService,
+	materializedViews *MaterializedViewService,
+) *DataflowRunner {
+	return &DataflowRunner{
+		db:                db,
+		queryExecutor:     queryExecutor,
+		tempTables:        tempTables,
+		materializedViews: materializedViews,
+	}
+}
+
+// Run executes the steps of a dataflow. Steps run by Order unless they declare upstream
+// steps, in which case they run as a dependency graph. onProgress receives the step logs
+// every time a step changes state; the first failure skips all remaining steps.
+func (r *DataflowRunner) Run(
+	ctx context.Context,
+	dataflow *models.Dataflow,
+	steps []models.DataflowStep,
+	onProgress func([]DataflowStepLog),
+) ([]DataflowStepLog, error) {
+	nodes, err := planDataflow(steps)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]DataflowStepLog, len(nodes))
+	for i, node := range nodes {
+		deps := make([]string, len(node.deps))
+		for j, dep := range node.deps {
+			deps[j] = dep.step.ID
+		}
+		logs[i] = DataflowStepLog{
+			StepID:    node.step.ID,
+			Name:      node.step.Name,
+			Type:      node.step.Type,
+			Status:    DataflowStepPending,
+			DependsOn: deps,
+		}
+	}
+	onProgress(logs)
+
+	run := &dataflowRun{
+		runner:   r,
+		dataflow: dataflow,
+		results:  make(map[string]*PipelineDataset),
+		loaded:   make(map[string]bool),
+	}
+	defer run.close()
+
+	for i, node := range nodes {
+		start := time.Now()
+		logs[i].Status = DataflowStepRunning
+		logs[i].StartedAt = &start
+		onProgress(logs)
+
+		ds, output, err := run.execute(ctx, node)
+
+		end := time.Now()
+		logs[i].CompletedAt = &end
+		logs[i].DurationMs = end.Sub(start).Milliseconds()
+
+		if err != nil {
+			logs[i].Status = DataflowStepFailed
+			logs[i].Message = err.Error()
+			for j := i + 1; j < len(logs); j++ {
+				logs[j].Status = DataflowStepSkipped
+				logs[j].Message = fmt.Sprintf("Skipped after step %q failed", node.step.Name)
+			}
+			onProgress(logs)
+			return logs, fmt.Errorf("step %q failed: %w", node.step.Name, err)
+		}
+
+		run.results[node.step.ID] = ds
+		logs[i].Status = DataflowStepCompleted
+		logs[i].Rows = len(ds.Rows)
+		logs[i].Output = output
+		onProgress(logs)
+	}
+
+	return logs, nil
+}
+
+// planDataflow decodes step configs and returns the steps in execution order.
+// When no step declares upstream steps, each step depends on the previous one by Order.
+func planDataflow(steps []models.DataflowStep) ([]*dataflowNode, error) {
+	sorted := make([]models.DataflowStep, len(steps))
+	copy(sorted, steps)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Order < sorted[j].Order })
+
+	nodes := make([]*dataflowNode, len(sorted))
+	byRef := make(map[string]*dataflowNode, len(sorted)*2)
+	declared := false
+	for i, step := range sorted {
+		node := &dataflowNode{step: step}
+		if step.Config != "" {
+			if err := json.Unmarshal([]byte(step.Config), &node.config); err != nil {
+				return nil, fmt.Errorf("invalid config for step %q: %w", step.Name, err)
+			}
+		}
+		if len(node.config.DependsOn) > 0 || node.config.SourceStepID != "" {
+			declared = true
+		}
+		nodes[i] = node
+		byRef[step.ID] = node
+		if _, taken := byRef[step.Name]; !taken {
+			byRef[step.Name] = node
+		}
+	}
+
+	for i, node := range nodes {
+		if !declared {
+			if i > 0 {
+				node.deps = []*dataflowNode{nodes[i-1]}
+			}
+			continue
+		}
+
+		refs := node.config.DependsOn
+		if node.config.SourceStepID != "" && !contains(refs, node.config.SourceStepID) {
+			refs = append(refs, node.config.SourceStepID)
+		}
+		for _, ref := range refs {
+			dep, ok := byRef[ref]
+			if !ok {
+				return nil, fmt.Errorf("step %q depends on unknown step %q", node.step.Name, ref)
+			}
+			if dep == node {
+				return nil, fmt.Errorf("step %q depends on itself", node.step.Name)
+			}
+			node.deps = append(node.deps, dep)
+		}
+	}
+
+	// Kahn's algorithm, preferring the lowest Order among ready steps
+	pending := make(map[*dataflowNode]int, len(nodes))
+	dependents := make(map[*dataflowNode][]*dataflowNode, len(nodes))
+	for _, node := range nodes {
+		pending[node] = len(node.deps)
+		for _, dep := range node.deps {
+			dependents[dep] = append(dependents[dep], node)
+		}
+	}
+
+	ordered := make([]*dataflowNode, 0, len(nodes))
+	done := make(map[*dataflowNode]bool, len(nodes))
+	for len(ordered) < len(nodes) {
+		var next *dataflowNode
+		for _, node := range nodes {
+			if !done[node] && pending[node] == 0 {
+				next = node
+				break
+			}
+		}
+		if next == nil {
+			var cycle []string
+			for _, node := range nodes {
+				if !done[node] {
+					cycle = append(cycle, node.step.Name)
+				}
+			}
+			return nil, fmt.Errorf("dataflow has a dependency cycle between steps: %s", strings.Join(cycle, ", "))
+		}
+
+		done[next] = true
+		ordered = append(ordered, next)
+		for _, dependent := range dependents[next] {
+			pending[dependent]--
+		}
+	}
+
+	return ordered, nil
+}
+
+// dataflowRun holds the state shared by the steps of one run
+type dataflowRun struct {
+	runner    *DataflowRunner
+	dataflow  *models.Dataflow
+	results   map[string]*PipelineDataset // Step ID -> result set
+	workspace *sql.DB                     // In-memory database holding upstream result sets
+	loaded    map[string]bool             // Step IDs loaded into the workspace
+}
+
+// execute runs one step and returns its result set and output location
+func (run *dataflowRun) execute(ctx context.Context, node *dataflowNode) (*PipelineDataset, string, error) {
+	switch strings.ToUpper(node.step.Type) {
+	case DataflowStepQuery:
+		ds, err := run.query(ctx, node)
+		return ds, "", err
+	case DataflowStepMaterialize:
+		return run.materialize(ctx, node)
+	default:
+		return nil, "", fmt.Errorf("unsupported step type %q", node.step.Type)
+	}
+}
+
+// query runs a QUERY step on its connection, or on the upstream result sets when no connection is set
+func (run *dataflowRun) query(ctx context.Context, node *dataflowNode) (*PipelineDataset, error) {
+	if strings.TrimSpace(node.config.SQL) == "" {
+		return nil, fmt.Errorf("QUERY step requires sql")
+	}
+
+	if node.config.ConnectionID != "" {
+		conn, err := run.connection(node.config.ConnectionID)
+		if err != nil {
+			return nil, err
+		}
+		return run.runner.queryExecutor.Dataset(ctx, conn, node.config.SQL)
+	}
+
+	if len(node.deps) == 0 {
+		return nil, fmt.Errorf("QUERY step without connectionId must depend on an upstream step")
+	}
+
+	workspace, err := run.workspaceDB()
+	if err != nil {
+		return nil, err
+	}
+
+	// Upstream result sets are queryable as tables named after their steps
+	loader := NewTableLoader(workspace, "sqlite")
+	for _, dep := range node.deps {
+		if run.loaded[dep.step.ID] {
+			continue
+		}
+		ds := run.results[dep.step.ID]
+		columns := InferColumnTypes(ds.Columns, ds.Rows)
+		if _, err := loader.Load(ctx, WorkspaceTableName(dep.step.Name), columns, ds.PositionalRows(), LoadModeOverwrite); err != nil {
+			return nil, fmt.Errorf("failed to stage result of step %q: %w", dep.step.Name, err)
+		}
+		run.loaded[dep.step.ID] = true
+	}
+
+	return queryDataset(ctx, workspace, node.config.SQL)
+}
+
+// materialize writes the result set of the source step to a temp table or materialized view
+func (run *dataflowRun) materialize(ctx context.Context, node *dataflowNode) (*PipelineDataset, string, error) {
+	source, err := materializeSource(node)
+	if err != nil {
+		return nil, "", err
+	}
+	ds := run.results[source.step.ID]
+
+	name := node.config.TargetTable
+	if name == "" {
+		name = node.step.Name
+	}
+
+	target := strings.ToUpper(node.config.Target)
+	if target == "" {
+		target = materializeTargetTempTable
+	}
+
+	switch target {
+	case materializeTargetTempTable:
+		output, err := run.materializeTempTable(ctx, name, strings.ToLower(node.config.Mode), ds)
+		return ds, output, err
+	case materializeTargetView:
+		output, err := run.materializeView(ctx, node, source, name)
+		return ds, output, err
+	default:
+		return nil, "", fmt.Errorf("unsupported MATERIALIZE target %q", node.config.Target)
+	}
+}
+
+// materializeTempTable replaces (overwrite) or extends (append) the dataflow's temp table
+func (run *dataflowRun) materializeTempTable(ctx context.Context, name string, mode string, ds *PipelineDataset) (string, error) {
+	userID, err := uuid.Parse(run.dataflow.UserID)
+	if err != nil {
+		return "", fmt.Errorf("invalid dataflow owner: %w", err)
+	}
+
+	tempTables := run.runner.tempTables
+	existing, err := tempTables.FindTempTables(ctx, userID, name, "dataflow")
+	if err != nil {
+		return "", err
+	}
+
+	columns := InferColumnTypes(ds.Columns, ds.Rows)
+	rows := ds.PositionalRows()
+
+	switch mode {
+	case "", "overwrite":
+		for _, table := range existing {
+			if err := tempTables.DropTempTable(ctx, table.ID, userID); err != nil {
+				return "", fmt.Errorf("failed to replace temp table %s: %w", table.TempTableName, err)
+			}
+		}
+	case "append":
+		if len(existing) > 0 {
+			if err := tempTables.AppendRows(ctx, &existing[0], columns, rows); err != nil {
+				return "", err
+			}
+			return existing[0].TempTableName, nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported MATERIALIZE mode %q", mode)
+	}
+
+	metadata, err := tempTables.CreateTempTable(ctx, userID, name, "dataflow", "", columns, rows)
+	if err != nil {
+		return "", err
+	}
+	return metadata.TempTableName, nil
+}
+
+// materializeView creates or refreshes a materialized view over the source step's query
+func (run *dataflowRun) materializeView(ctx context.Context, node *dataflowNode, source *dataflowNode, name string) (string, error) {
+	if strings.ToUpper(source.step.Type) != DataflowStepQuery || source.config.ConnectionID == "" {
+		return "", fmt.Errorf("MATERIALIZED_VIEW target requires a QUERY source step with a connectionId")
+	}
+
+	connectionID := node.config.ConnectionID
+	if connectionID == "" {
+		connectionID = source.config.ConnectionID
+	}
+	if connectionID != source.config.ConnectionID {
+		return "", fmt.Errorf("materialized view must live on the connection of its source step")
+	}
+	if _, err := run.connection(connectionID); err != nil {
+		return "", err
+	}
+
+	var mv models.MaterializedView
+	err := run.runner.db.Where("user_id = ? AND connection_id = ? AND name = ?", run.dataflow.UserID, connectionID, name).
+		First(&mv).Error
+	if err == gorm.ErrRecordNotFound {
+		refreshMode := strings.ToLower(node.config.RefreshMode)
+		if refreshMode == "" {
+			refreshMode = "full"
+		}
+		created, err := run.runner.materializedViews.CreateMaterializedView(
+			ctx, run.dataflow.UserID, connectionID, name, source.config.SQL, refreshMode, "")
+		if err != nil {
+			return "", err
+		}
+		return created.TargetTable, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if mv.SourceQuery != source.config.SQL {
+		if err := run.runner.db.Model(&mv).Update("source_query", source.config.SQL).Error; err != nil {
+			return "", fmt.Errorf("failed to update materialized view query: %w", err)
+		}
+	}
+	if err := run.runner.materializedViews.RefreshMaterializedView(ctx, mv.ID); err != nil {
+		return "", err
+	}
+	return mv.TargetTable, nil
+}
+
+// connection loads a connection owned by the dataflow's user
+func (run *dataflowRun) connection(id string) (*models.Connection, error) {
+	var conn models.Connection
+	if err := run.runner.db.Where("id = ? AND user_id = ?", id, run.dataflow.UserID).First(&conn).Error; err != nil {
+		return nil, fmt.Errorf("connection %s not found", id)
+	}
+	return &conn, nil
+}
+
+// workspaceDB lazily opens the run's in-memory database
+func (run *dataflowRun) workspaceDB() (*sql.DB, error) {
+	if run.workspace != nil {
+		return run.workspace, nil
+	}
+
+	// QUERY steps run the user's SQL here, so the database is as confined as sqlite connections
+	db, err := sql.Open(sqliteDriverName, ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataflow workspace: %w", err)
+	}
+
+	// Every connection to ":memory:" is a separate database
+	db.SetMaxOpenConns(1)
+	run.workspace = db
+	return db, nil
+}
+
+// close releases the run's in-memory database
+func (run *dataflowRun) close() {
+	if run.workspace != nil {
+		run.workspace.Close()
+	}
+}
+
+// materializeSource resolves the step whose result set a MATERIALIZE step writes
+func materializeSource(node *dataflowNode) (*dataflowNode, error) {
+	if ref := node.config.SourceStepID; ref != "" {
+		for _, dep := range node.deps {
+			if dep.step.ID == ref || dep.step.Name == ref {
+				return dep, nil
+			}
+		}
+		return nil, fmt.Errorf("source step %q not found", ref)
+	}
+
+	switch len(node.deps) {
+	case 0:
+		return nil, fmt.Errorf("MATERIALIZE step has no upstream step")
+	case 1:
+		return node.deps[0], nil
+	default:
+		return nil, fmt.Errorf("MATERIALIZE step has several upstream steps, set sourceStepId")
+	}
+}
+
+// WorkspaceTableName returns the table name under which a step's result set can be queried
+func WorkspaceTableName(stepName string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(strings.TrimSpace(stepName)))
+
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "step_" + name
+	}
+	return name
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"insight-engine-backend/models"
+)
+
+func stepNames(nodes []*dataflowNode) []string {
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node.step.Name
+	}
+	return names
+}
+
+// TestPlanDataflow_Sequential tests that steps without dependencies run by Order
+func TestPlanDataflow_Sequential(t *testing.T) {
+	nodes, err := planDataflow([]models.DataflowStep{
+		{ID: "s2", Name: "b", Type: DataflowStepMaterialize, Order: 2, Config: `{}`},
+		{ID: "s1", Name: "a", Type: DataflowStepQuery, Order: 1, Config: `{"sql":"SELECT 1"}`},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := strings.Join(stepNames(nodes), ","); got != "a,b" {
+		t.Errorf("Expected order a,b, got %s", got)
+	}
+	if len(nodes[1].deps) != 1 || nodes[1].deps[0].step.ID != "s1" {
+		t.Error("Expected b to depend on a")
+	}
+}
+
+// TestPlanDataflow_Graph tests dependency ordering and cycle detection
+func TestPlanDataflow_Graph(t *testing.T) {
+	nodes, err := planDataflow([]models.DataflowStep{
+		{ID: "s1", Name: "joined", Type: DataflowStepQuery, Order: 1, Config: `{"sql":"SELECT 1","dependsOn":["orders","customers"]}`},
+		{ID: "s2", Name: "orders", Type: DataflowStepQuery, Order: 2, Config: `{"sql":"SELECT 1","connectionId":"c1"}`},
+		{ID: "s3", Name: "customers", Type: DataflowStepQuery, Order: 3, Config: `{"sql":"SELECT 1","connectionId":"c1"}`},
+		{ID: "s4", Name: "save", Type: DataflowStepMaterialize, Order: 4, Config: `{"sourceStepId":"s1"}`},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := strings.Join(stepNames(nodes), ","); got != "orders,customers,joined,save" {
+		t.Errorf("Expected order orders,customers,joined,save, got %s", got)
+	}
+
+	_, err = planDataflow([]models.DataflowStep{
+		{ID: "s1", Name: "a", Type: DataflowStepQuery, Order: 1, Config: `{"dependsOn":["b"]}`},
+		{ID: "s2", Name: "b", Type: DataflowStepQuery, Order: 2, Config: `{"dependsOn":["a"]}`},
+	})
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected cycle error, got %v", err)
+	}
+}
+
+// TestDataflowRun_QueryUpstreamResults tests querying upstream result sets by step name
+func TestDataflowRun_QueryUpstreamResults(t *testing.T) {
+	nodes, err := planDataflow([]models.DataflowStep{
+		{ID: "s1", Name: "Raw Orders", Type: DataflowStepQuery, Order: 1, Config: `{"sql":"SELECT 1","connectionId":"c1"}`},
+		{ID: "s2", Name: "totals", Type: DataflowStepQuery, Order: 2,
+			Config: `{"sql":"SELECT customer, SUM(amount) AS total FROM raw_orders GROUP BY customer ORDER BY customer"}`},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	run := &dataflowRun{
+		dataflow: &models.Dataflow{ID: "df1"},
+		results: map[string]*PipelineDataset{
+			"s1": NewPipelineDataset([]string{"customer", "amount"}, [][]interface{}{
+				{"alice", int64(10)}, {"bob", int64(5)}, {"alice", int64(7)},
+			}),
+		},
+		loaded: make(map[string]bool),
+	}
+	defer run.close()
+
+	ds, _, err := run.execute(context.Background(), nodes[1])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(ds.Rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(ds.Rows))
+	}
+	if ds.Rows[0]["customer"] != "alice" || ds.Rows[0]["total"] != int64(17) {
+		t.Errorf("Unexpected first row: %v", ds.Rows[0])
+	}
+
+	// Upstream queries cannot reach files on the server
+	escape := &dataflowNode{step: models.DataflowStep{ID: "s3", Name: "escape", Type: DataflowStepQuery},
+		config: DataflowStepConfig{SQL: "ATTACH DATABASE 'escaped.db' AS x"}, deps: nodes[:1]}
+	if _, _, err := run.execute(context.Background(), escape); err == nil {
+		t.Error("Expected ATTACH to be rejected")
+	}
+}
@@ -2,10 +2,16 @@ package services
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/smtp"
+	"net/textproto"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,6 +29,13 @@ type EmailConfig struct {
 	AppBaseURL string // Frontend URL for links
 }
 
+// EmailAttachment is a file attached to an outgoing email
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
 // EmailService handles email sending operations
 type EmailService struct {
 	config EmailConfig
@@ -432,3 +445,189 @@ The InsightEngine Team`, userName, resetURL)
 func (s *EmailService) GetPasswordResetExpiry() time.Time {
 	return time.Now().Add(1 * time.Hour) // 1 hour expiration
 }
+
+// SendAlertEmail notifies a recipient that an alert has been triggered
+func (s *EmailService) SendAlertEmail(toEmail, alertName, message, alertPath string) error {
+	subject := fmt.Sprintf("Alert Triggered: %s - InsightEngine", alertName)
+	body := s.buildAlertEmail(alertName, message, s.config.AppBaseURL+alertPath)
+
+	return s.sendEmail(toEmail, subject, body)
+}
+
+// buildAlertEmail creates HTML email content for a triggered alert
+func (s *EmailService) buildAlertEmail(alertName, message, alertURL string) string {
+	emailTemplate := `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Alert Triggered</title>
+</head>
+<body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Arial, sans-serif; color: #333; max-width: 600px; margin: 0 auto; padding: 20px;">
+    <h2 style="color: #dc2626;">{{.AlertName}}</h2>
+    <p>{{.Message}}</p>
+    <p><a href="{{.AlertURL}}">View alert in InsightEngine</a></p>
+    <p style="color: #666; font-size: 12px;">You will not be notified again until the alert recovers and triggers again.</p>
+</body>
+</html>`
+
+	tmpl, err := template.New("alert").Parse(emailTemplate)
+	if err != nil {
+		return fmt.Sprintf("%s\n\n%s\n\n%s", alertName, message, alertURL)
+	}
+
+	data := struct {
+		AlertName string
+		Message   string
+		AlertURL  string
+	}{
+		AlertName: alertName,
+		Message:   message,
+		AlertURL:  alertURL,
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Sprintf("%s\n\n%s\n\n%s", alertName, message, alertURL)
+	}
+
+	return buf.String()
+}
+
+// SendReportEmail sends a scheduled dashboard report with the rendered file attached
+func (s *EmailService) SendReportEmail(recipients []string, dashboardName, dashboardPath string, attachment EmailAttachment) error {
+	subject := fmt.Sprintf("Scheduled Report: %s - InsightEngine", dashboardName)
+	body := s.buildReportEmail(dashboardName, s.config.AppBaseURL+dashboardPath)
+
+	return s.sendEmailWithAttachments(recipients, subject, body, []EmailAttachment{attachment})
+}
+
+// buildReportEmail creates HTML email content for a scheduled report
+func (s *EmailService) buildReportEmail(dashboardName, dashboardURL string) string {
+	emailTemplate := `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Scheduled Report</title>
+</head>
+<body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Arial, sans-serif; color: #333; max-width: 600px; margin: 0 auto; padding: 20px;">
+    <h2>{{.DashboardName}}</h2>
+    <p>Your scheduled report is attached.</p>
+    <p><a href="{{.DashboardURL}}">Open the dashboard in InsightEngine</a></p>
+</body>
+</html>`
+
+	tmpl, err := template.New("report").Parse(emailTemplate)
+	if err != nil {
+		return fmt.Sprintf("%s\n\nYour scheduled report is attached.\n\n%s", dashboardName, dashboardURL)
+	}
+
+	var buf bytes.Buffer
+	data := struct {
+		DashboardName string
+		DashboardURL  string
+	}{
+		DashboardName: dashboardName,
+		DashboardURL:  dashboardURL,
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Sprintf("%s\n\nYour scheduled report is attached.\n\n%s", dashboardName, dashboardURL)
+	}
+
+	return buf.String()
+}
+
+// sendEmailWithAttachments sends an email with attachments based on configured provider
+func (s *EmailService) sendEmailWithAttachments(recipients []string, subject, body string, attachments []EmailAttachment) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	switch s.config.Provider {
+	case "smtp":
+		from := fmt.Sprintf("%s <%s>", s.config.FromName, s.config.FromEmail)
+		message, err := buildMultipartEmail(from, recipients, subject, body, attachments)
+		if err != nil {
+			return err
+		}
+
+		auth := smtp.PlainAuth("", s.config.SMTPUser, s.config.SMTPPass, s.config.SMTPHost)
+		addr := fmt.Sprintf("%s:%s", s.config.SMTPHost, s.config.SMTPPort)
+		if err := smtp.SendMail(addr, auth, s.config.FromEmail, recipients, message); err != nil {
+			return fmt.Errorf("failed to send email via SMTP: %w", err)
+		}
+		return nil
+	default:
+		names := make([]string, len(attachments))
+		for i, attachment := range attachments {
+			names[i] = fmt.Sprintf("%s (%d bytes)", attachment.Filename, len(attachment.Data))
+		}
+		LogInfo("email_console_mode", "Email with attachments sent via console (dev mode)", map[string]interface{}{
+			"to":          strings.Join(recipients, ", "),
+			"from":        fmt.Sprintf("%s <%s>", s.config.FromName, s.config.FromEmail),
+			"subject":     subject,
+			"attachments": names,
+		})
+		return nil
+	}
+}
+
+// buildMultipartEmail builds a multipart/mixed MIME message with an HTML body and base64 attachments
+func buildMultipartEmail(from string, recipients []string, subject, body string, attachments []EmailAttachment) ([]byte, error) {
+	var message bytes.Buffer
+	writer := multipart.NewWriter(&message)
+
+	var header bytes.Buffer
+	header.WriteString(fmt.Sprintf("From: %s\r\n", from))
+	header.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(recipients, ", ")))
+	header.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject)))
+	header.WriteString("MIME-Version: 1.0\r\n")
+	header.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=%q\r\n\r\n", writer.Boundary()))
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// RFC 2045 limits encoded lines to 76 characters
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+		for len(encoded) > 76 {
+			if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+				return nil, err
+			}
+			encoded = encoded[76:]
+		}
+		if _, err := part.Write([]byte(encoded + "\r\n")); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return append(header.Bytes(), message.Bytes()...), nil
+}
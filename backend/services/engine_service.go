@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -53,15 +54,46 @@ func (es *EngineService) Aggregate(ctx context.Context, req AggregateRequest) (*
 	}, nil
 }
 
-// buildAggregationSQL constructs SQL for aggregation
-func (es *EngineService) buildAggregationSQL(req AggregateRequest) string {
-	// Simplified SQL builder
-	// Production version would use proper SQL AST manipulation
-	return fmt.Sprintf("SELECT %s FROM (%s) AS subquery GROUP BY %s",
-		"*", // Placeholder
-		req.SQL,
-		"*", // Placeholder
-	)
+// buildAggregationSQL constructs SQL for aggregation, aggregating the rows of req.SQL in the
+// given dialect
+func (es *EngineService) buildAggregationSQL(req AggregateRequest, dialect SQLDialect) (string, error) {
+	validFunctions := map[string]bool{"SUM": true, "AVG": true, "COUNT": true, "MIN": true, "MAX": true}
+
+	var selectParts, groupByParts []string
+	for _, column := range req.GroupBy {
+		quoted := dialect.QuoteIdent(column)
+		selectParts = append(selectParts, quoted)
+		groupByParts = append(groupByParts, quoted)
+	}
+	for _, agg := range req.Aggregations {
+		function := strings.ToUpper(agg.Function)
+		if !validFunctions[function] {
+			return "", fmt.Errorf("invalid aggregation function '%s'", agg.Function)
+		}
+		column := "*"
+		if agg.Column != "*" {
+			column = dialect.QuoteIdent(agg.Column)
+		} else if function != "COUNT" {
+			return "", fmt.Errorf("%s requires a column", function)
+		}
+		alias := agg.Alias
+		if alias == "" {
+			alias = strings.ToLower(function)
+			if agg.Column != "*" {
+				alias += "_" + agg.Column
+			}
+		}
+		selectParts = append(selectParts, fmt.Sprintf("%s(%s) AS %s", function, column, dialect.QuoteIdent(alias)))
+	}
+	if len(selectParts) == 0 {
+		return "", fmt.Errorf("at least one group by column or aggregation is required")
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM (\n%s\n)%s", strings.Join(selectParts, ", "), trimStatement(req.SQL), dialect.subqueryAlias("subquery"))
+	if len(groupByParts) > 0 {
+		sql += " GROUP BY " + strings.Join(groupByParts, ", ")
+	}
+	return sql, nil
 }
 
 // ForecastRequest represents a time-series forecast request
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"strings"
 	"time"
@@ -254,6 +255,93 @@ func (imp *ExcelImporter) ImportExcel(
 	return result, nil
 }
 
+// ReadExcelRows reads every data row of a sheet and converts values to their detected column types
+func (imp *ExcelImporter) ReadExcelRows(
+	ctx context.Context,
+	r io.Reader,
+	options *ExcelImportOptions,
+) ([]CSVColumn, [][]interface{}, error) {
+	if options == nil {
+		options = imp.GetDefaultOptions()
+	}
+
+	xlsxFile, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open Excel file: %w", err)
+	}
+	defer xlsxFile.Close()
+
+	sheetList := xlsxFile.GetSheetList()
+	if len(sheetList) == 0 {
+		return nil, nil, errors.New("Excel file has no sheets")
+	}
+
+	var targetSheet string
+	if options.SheetName != "" {
+		targetSheet = options.SheetName
+	} else if options.SheetIndex >= 0 && options.SheetIndex < len(sheetList) {
+		targetSheet = sheetList[options.SheetIndex]
+	} else {
+		targetSheet = xlsxFile.GetSheetName(xlsxFile.GetActiveSheetIndex())
+	}
+
+	allRows, err := xlsxFile.GetRows(targetSheet)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read sheet %s: %w", targetSheet, err)
+	}
+
+	if options.SkipRows > 0 && options.SkipRows < len(allRows) {
+		allRows = allRows[options.SkipRows:]
+	}
+	if len(allRows) == 0 {
+		return nil, nil, errors.New("no data rows found in sheet")
+	}
+
+	var headers []string
+	dataRows := allRows
+	if options.HasHeader {
+		headers = allRows[0]
+		dataRows = allRows[1:]
+	} else {
+		headers = make([]string, len(allRows[0]))
+		for i := range headers {
+			headers[i] = fmt.Sprintf("column_%d", i+1)
+		}
+	}
+	headers = imp.csvImporter.cleanHeaders(headers)
+
+	if options.MaxRows > 0 && len(dataRows) > options.MaxRows {
+		dataRows = dataRows[:options.MaxRows]
+	}
+	if len(dataRows) == 0 {
+		return nil, nil, errors.New("no data rows found in sheet")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	records := imp.normalizeRowLengths(dataRows, len(headers))
+
+	csvOptions := &CSVImportOptions{
+		DetectTypes:    options.DetectTypes,
+		TrimWhitespace: options.TrimWhitespace,
+		NullValues:     options.NullValues,
+	}
+	sample := records
+	if len(sample) > imp.sampleSize {
+		sample = sample[:imp.sampleSize]
+	}
+	columns := imp.csvImporter.detectColumnTypes(headers, sample, csvOptions)
+
+	rows := make([][]interface{}, len(records))
+	for i, record := range records {
+		rows[i] = imp.csvImporter.ConvertRecord(record, columns, csvOptions)
+	}
+
+	return columns, rows, nil
+}
+
 // ValidateExcelFile validates Excel file before import
 func (imp *ExcelImporter) ValidateExcelFile(fileHeader *multipart.FileHeader) error {
 	// Check file size
@@ -1,12 +1,24 @@
 package services
 
 import (
+	"compress/zlib"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"insight-engine-backend/models"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -106,15 +118,16 @@ func (ExportJob) TableName() string {
 
 // ExportService handles dashboard export operations
 type ExportService struct {
-	db         *gorm.DB
-	exportDir  string
-	baseURL    string
-	cleanupAge time.Duration
+	db            *gorm.DB
+	queryExecutor *QueryExecutor // Runs card queries for the rendered dashboard
+	exportDir     string
+	baseURL       string
+	cleanupAge    time.Duration
 }
 
 // NewExportService creates a new export service instance
 // Returns error if export directory cannot be created
-func NewExportService(db *gorm.DB, exportDir, baseURL string) (*ExportService, error) {
+func NewExportService(db *gorm.DB, queryExecutor *QueryExecutor, exportDir, baseURL string) (*ExportService, error) {
 	// Create export directory if it doesn't exist
 	if err := os.MkdirAll(exportDir, 0755); err != nil {
 		LogError("export_service_init", "Failed to create export directory", map[string]interface{}{
@@ -130,10 +143,11 @@ func NewExportService(db *gorm.DB, exportDir, baseURL string) (*ExportService, e
 	})
 
 	return &ExportService{
-		db:         db,
-		exportDir:  exportDir,
-		baseURL:    baseURL,
-		cleanupAge: 24 * time.Hour, // Clean up files older than 24 hours
+		db:            db,
+		queryExecutor: queryExecutor,
+		exportDir:     exportDir,
+		baseURL:       baseURL,
+		cleanupAge:    24 * time.Hour, // Clean up files older than 24 hours
 	}, nil
 }
 
@@ -143,6 +157,11 @@ func (s *ExportService) CreateExportJob(ctx context.Context, dashboardID, userID
 	if err := validateExportOptions(options); err != nil {
 		return nil, fmt.Errorf("invalid export options: %w", err)
 	}
+	if options.Format == ExportFormatPNG || options.Format == ExportFormatJPEG {
+		if err := s.checkImageSize(ctx, dashboardID, options); err != nil {
+			return nil, err
+		}
+	}
 
 	// Serialize options
 	optionsJSON, err := json.Marshal(options)
@@ -228,22 +247,7 @@ func (s *ExportService) processExportJob(ctx context.Context, exportID uuid.UUID
 	s.updateJobStatus(ctx, exportID, StatusProcessing, 50, nil)
 
 	// Generate file based on format
-	var filesize int64
-	var err error
-
-	switch options.Format {
-	case ExportFormatPDF:
-		filesize, err = s.generatePDF(ctx, &job, &options, filepath)
-	case ExportFormatPNG, ExportFormatJPEG:
-		filesize, err = s.generateImage(ctx, &job, &options, filepath)
-	case ExportFormatPPTX:
-		// PPTX generation would require additional library (e.g., github.com/unidoc/unipptx)
-		// For now, return error indicating feature not yet implemented
-		err = errors.New("PPTX export not yet implemented - use PDF or PNG instead")
-	default:
-		err = fmt.Errorf("unsupported export format: %s", options.Format)
-	}
-
+	filesize, err := s.render(ctx, job.DashboardID.String(), &options, filepath)
 	if err != nil {
 		s.updateJobStatus(ctx, exportID, StatusFailed, 0, fmt.Errorf("export generation failed: %w", err))
 		return
@@ -267,6 +271,43 @@ func (s *ExportService) processExportJob(ctx context.Context, exportID uuid.UUID
 	}
 }
 
+// render writes the export file for the requested format
+func (s *ExportService) render(ctx context.Context, dashboardID string, options *ExportOptions, outputPath string) (int64, error) {
+	switch options.Format {
+	case ExportFormatPDF:
+		return s.generatePDF(ctx, dashboardID, options, outputPath)
+	case ExportFormatPNG, ExportFormatJPEG:
+		return s.generateImage(ctx, dashboardID, options, outputPath)
+	case ExportFormatPPTX:
+		// PPTX generation would require additional library (e.g., github.com/unidoc/unipptx)
+		// For now, return error indicating feature not yet implemented
+		return 0, errors.New("PPTX export not yet implemented - use PDF or PNG instead")
+	default:
+		return 0, fmt.Errorf("unsupported export format: %s", options.Format)
+	}
+}
+
+// RenderDashboard renders a dashboard synchronously without creating an export job,
+// for callers such as scheduled report delivery that need the file contents directly
+func (s *ExportService) RenderDashboard(ctx context.Context, dashboardID string, options *ExportOptions) ([]byte, error) {
+	if err := validateExportOptions(options); err != nil {
+		return nil, fmt.Errorf("invalid export options: %w", err)
+	}
+
+	outputPath := filepath.Join(s.exportDir, fmt.Sprintf("%s.%s", uuid.New(), options.Format))
+	defer os.Remove(outputPath)
+
+	if _, err := s.render(ctx, dashboardID, options, outputPath); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rendered export: %w", err)
+	}
+	return data, nil
+}
+
 // updateJobStatus updates the status of an export job
 func (s *ExportService) updateJobStatus(ctx context.Context, exportID uuid.UUID, status ExportStatus, progress int, err error) error {
 	updates := map[string]interface{}{
@@ -428,108 +469,612 @@ func estimateExportTime(opts *ExportOptions) *int {
 	return &baseTime
 }
 
-// generatePDF generates a PDF export file
-// This is a basic implementation - in production, integrate with chromedp for HTML rendering
-func (s *ExportService) generatePDF(ctx context.Context, job *ExportJob, options *ExportOptions, outputPath string) (int64, error) {
-	// Create a simple HTML document with export metadata
-	// In production, this would render the actual dashboard using chromedp
-	html := s.generateExportHTML(job, options)
+// cardGridPosition is a card's place on the 12-column dashboard grid
+type cardGridPosition struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
 
-	// Write HTML to temp file for now (basic implementation)
-	// In production: use chromedp to render HTML to PDF
-	// Example: chromedp.Run(ctx, chromedp.Navigate(...), chromedp.WaitReady(...), chromedp.ActionFunc(func(ctx context.Context) error {...}))
+// exportCard is a dashboard card with the results of its query, as rendered into an export
+type exportCard struct {
+	ID                  string
+	Title               string
+	Type                string // visualization | text
+	Text                string
+	Position            cardGridPosition
+	VisualizationConfig string // The card's config, else its saved query's
+	Columns             []string
+	Rows                [][]interface{}
+	TotalRows           int
+	Error               string
+}
 
-	// For now, create a simple text file as placeholder
-	content := fmt.Sprintf("Export ID: %s\nDashboard ID: %s\nFormat: PDF\nGenerated: %s\n\n%s",
-		job.ID, job.DashboardID, time.Now().Format(time.RFC3339), html)
+// dashboardSnapshot is a dashboard and its cards in reading order
+type dashboardSnapshot struct {
+	Dashboard models.Dashboard
+	Cards     []exportCard
+}
 
-	if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
-		return 0, fmt.Errorf("failed to write PDF file: %w", err)
+// exportRowLimit returns how many rows of each card are rendered for a quality
+func exportRowLimit(quality ExportQuality) int {
+	switch quality {
+	case QualityLow:
+		return 25
+	case QualityMedium:
+		return 100
+	default:
+		return 500
 	}
+}
 
-	// Get file size
-	info, err := os.Stat(outputPath)
-	if err != nil {
-		return 0, fmt.Errorf("failed to stat PDF file: %w", err)
+// collectDashboard loads a dashboard and runs the query of each card.
+// Card failures are captured on the card so the rest of the dashboard still renders.
+func (s *ExportService) collectDashboard(ctx context.Context, dashboardID string, options *ExportOptions) (*dashboardSnapshot, error) {
+	var snapshot dashboardSnapshot
+	if err := s.db.WithContext(ctx).Where("id = ?", dashboardID).First(&snapshot.Dashboard).Error; err != nil {
+		return nil, fmt.Errorf("dashboard not found: %w", err)
 	}
 
-	return info.Size(), nil
+	var cards []models.DashboardCard
+	if err := s.db.WithContext(ctx).Where("dashboard_id = ?", dashboardID).Find(&cards).Error; err != nil {
+		return nil, fmt.Errorf("failed to load dashboard cards: %w", err)
+	}
+
+	rowLimit := exportRowLimit(options.Quality)
+	for _, card := range cards {
+		if len(options.CardIDs) > 0 && !contains(options.CardIDs, card.ID) {
+			continue
+		}
+		snapshot.Cards = append(snapshot.Cards, s.collectCard(ctx, &card, snapshot.Dashboard.UserID, rowLimit))
+	}
+
+	// Reading order: top to bottom, then left to right
+	sort.SliceStable(snapshot.Cards, func(i, j int) bool {
+		a, b := snapshot.Cards[i].Position, snapshot.Cards[j].Position
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+
+	return &snapshot, nil
 }
 
-// generateImage generates an image export (PNG/JPEG)
-func (s *ExportService) generateImage(ctx context.Context, job *ExportJob, options *ExportOptions, outputPath string) (int64, error) {
-	// In production, use chromedp to:
-	// 1. Navigate to dashboard URL
-	// 2. Wait for charts to render
-	// 3. Take screenshot with specified resolution
-	// 4. Save to outputPath
+// collectCard resolves a card's title, position and visualization config and runs its query
+func (s *ExportService) collectCard(ctx context.Context, card *models.DashboardCard, ownerID string, rowLimit int) exportCard {
+	item := exportCard{ID: card.ID, Title: "Untitled", Type: card.Type}
+	if card.Title != nil && *card.Title != "" {
+		item.Title = *card.Title
+	}
+	_ = json.Unmarshal([]byte(card.Position), &item.Position)
+	if card.VisualizationConfig != nil {
+		item.VisualizationConfig = *card.VisualizationConfig
+	}
 
-	// For now, create a placeholder file
-	content := fmt.Sprintf("Export ID: %s\nDashboard ID: %s\nFormat: %s\nGenerated: %s",
-		job.ID, job.DashboardID, options.Format, time.Now().Format(time.RFC3339))
+	switch {
+	case card.Type == "text":
+		if card.TextContent != nil {
+			item.Text = *card.TextContent
+		}
+	case card.QueryID == nil:
+		item.Error = "No query attached to this card"
+	default:
+		query, result, err := s.runCardQuery(ctx, *card.QueryID, ownerID)
+		if err != nil {
+			item.Error = err.Error()
+			break
+		}
+		if item.VisualizationConfig == "" && len(query.VisualizationConfig) > 0 {
+			item.VisualizationConfig = string(query.VisualizationConfig)
+		}
+		item.Columns = result.Columns
+		item.TotalRows = len(result.Rows)
+		item.Rows = result.Rows
+		if len(item.Rows) > rowLimit {
+			item.Rows = item.Rows[:rowLimit]
+		}
+	}
+	return item
+}
 
-	if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
-		return 0, fmt.Errorf("failed to write image file: %w", err)
+// runCardQuery executes a card's saved query on a connection owned by the dashboard owner
+func (s *ExportService) runCardQuery(ctx context.Context, queryID, ownerID string) (*models.SavedQuery, *models.QueryResult, error) {
+	if s.queryExecutor == nil {
+		return nil, nil, errors.New("query execution is not available")
+	}
+
+	var query models.SavedQuery
+	if err := s.db.WithContext(ctx).Where("id = ?", queryID).First(&query).Error; err != nil {
+		return nil, nil, errors.New("saved query not found")
+	}
+
+	var conn models.Connection
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", query.ConnectionID, ownerID).First(&conn).Error; err != nil {
+		return nil, nil, errors.New("connection not found")
 	}
 
-	info, err := os.Stat(outputPath)
+	queryCtx := WithQuerySource(WithQueryUser(ctx, ownerID), QuerySourceSystem, query.ID)
+	result, err := s.queryExecutor.Execute(queryCtx, &conn, query.SQL, nil, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to stat image file: %w", err)
+		return nil, nil, fmt.Errorf("query failed: %w", err)
 	}
+	return &query, result, nil
+}
 
-	return info.Size(), nil
+// exportPageSize returns the page width and height in points for the page size and orientation
+func exportPageSize(options *ExportOptions) (float64, float64, error) {
+	const pointsPerMM = 72 / 25.4
+
+	var width, height float64
+	switch options.PageSize {
+	case PageSizeA4, "":
+		width, height = 595.28, 841.89
+	case PageSizeLetter:
+		width, height = 612, 792
+	case PageSizeLegal:
+		width, height = 612, 1008
+	case PageSizeTabloid:
+		width, height = 792, 1224
+	case PageSizeCustom:
+		// Custom dimensions are in millimetres, like the presets offered in the export dialog
+		if options.CustomWidth == nil || options.CustomHeight == nil || *options.CustomWidth < 50 || *options.CustomHeight < 50 {
+			return 0, 0, errors.New("custom page size requires customWidth and customHeight of at least 50 mm")
+		}
+		width, height = float64(*options.CustomWidth)*pointsPerMM, float64(*options.CustomHeight)*pointsPerMM
+	default:
+		return 0, 0, fmt.Errorf("unsupported page size: %s", options.PageSize)
+	}
+
+	if (options.Orientation == OrientationLandscape) != (width > height) {
+		width, height = height, width
+	}
+	return width, height, nil
+}
+
+// formatExportValue formats a query result value for display
+func formatExportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// pdfLayout places export content on pages, starting new pages as they fill up
+type pdfLayout struct {
+	doc       *PDFDocument
+	margin    float64
+	y         float64
+	watermark string
+}
+
+// newPage starts a page, drawing the watermark first so content is painted over it
+func (l *pdfLayout) newPage() {
+	l.doc.AddPage()
+	l.y = l.margin
+	if l.watermark != "" {
+		size := math.Min(l.doc.Width(), l.doc.Height()) / 8
+		width := l.doc.TextWidth(l.watermark, size, true) * math.Sqrt2 / 2
+		l.doc.SetFillColor(235, 235, 235)
+		l.doc.RotatedText(l.doc.Width()/2-width/2, l.doc.Height()/2+width/2, size, 45, true, l.watermark)
+		l.doc.SetFillColor(0, 0, 0)
+	}
+}
+
+// ensure starts a new page unless height points still fit above the footer
+func (l *pdfLayout) ensure(height float64) bool {
+	if l.y+height > l.doc.Height()-l.margin-16 {
+		l.newPage()
+		return true
+	}
+	return false
+}
+
+func (l *pdfLayout) contentWidth() float64 {
+	return l.doc.Width() - 2*l.margin
+}
+
+// text draws a line of text and advances by the line height
+func (l *pdfLayout) text(size float64, bold bool, text string) {
+	l.ensure(size * 1.5)
+	l.y += size * 1.2
+	l.doc.Text(l.margin, l.y, size, bold, l.doc.Truncate(text, size, bold, l.contentWidth()))
+	l.y += size * 0.3
+}
+
+// table draws a card's results, repeating the header row on each new page
+func (l *pdfLayout) table(card *exportCard) {
+	const (
+		fontSize  = 8.0
+		rowHeight = 14.0
+		padding   = 3.0
+		minWidth  = 48.0
+	)
+
+	// Keep as many columns as fit at a readable width
+	columns := card.Columns
+	if maxColumns := int(l.contentWidth() / minWidth); len(columns) > maxColumns {
+		columns = columns[:maxColumns]
+	}
+	if len(columns) == 0 {
+		l.text(fontSize, false, "No columns returned")
+		return
+	}
+	colWidth := l.contentWidth() / float64(len(columns))
+
+	header := func() {
+		l.doc.SetFillColor(229, 231, 235)
+		l.doc.Rect(l.margin, l.y, l.contentWidth(), rowHeight, true)
+		l.doc.SetFillColor(17, 24, 39)
+		for i, col := range columns {
+			x := l.margin + float64(i)*colWidth
+			l.doc.Text(x+padding, l.y+rowHeight-4, fontSize, true, l.doc.Truncate(col, fontSize, true, colWidth-2*padding))
+		}
+		l.y += rowHeight
+	}
+
+	l.ensure(rowHeight * 3)
+	header()
+	for r, row := range card.Rows {
+		if l.ensure(rowHeight) {
+			header()
+		}
+		if r%2 == 1 {
+			l.doc.SetFillColor(249, 250, 251)
+			l.doc.Rect(l.margin, l.y, l.contentWidth(), rowHeight, true)
+		}
+		l.doc.SetFillColor(55, 65, 81)
+		for i := range columns {
+			var value interface{}
+			if i < len(row) {
+				value = row[i]
+			}
+			x := l.margin + float64(i)*colWidth
+			l.doc.Text(x+padding, l.y+rowHeight-4, fontSize, false, l.doc.Truncate(formatExportValue(value), fontSize, false, colWidth-2*padding))
+		}
+		l.y += rowHeight
+	}
+	l.doc.SetStrokeColor(209, 213, 219)
+	l.doc.Line(l.margin, l.y, l.margin+l.contentWidth(), l.y, 0.5)
+
+	var notes []string
+	if len(card.Rows) < card.TotalRows {
+		notes = append(notes, fmt.Sprintf("Showing %d of %d rows", len(card.Rows), card.TotalRows))
+	} else if len(card.Rows) == 0 {
+		notes = append(notes, "No rows returned")
+	}
+	if hidden := len(card.Columns) - len(columns); hidden > 0 {
+		notes = append(notes, fmt.Sprintf("%d more columns not shown", hidden))
+	}
+	if len(notes) > 0 {
+		l.doc.SetFillColor(107, 114, 128)
+		l.text(7, false, strings.Join(notes, " | "))
+	}
+	l.doc.SetFillColor(0, 0, 0)
 }
 
-// generateExportHTML generates HTML content for the export
-func (s *ExportService) generateExportHTML(job *ExportJob, options *ExportOptions) string {
-	title := "Dashboard Export"
-	if options.Title != nil {
+// generatePDF renders the dashboard's cards and their query results into a PDF file
+func (s *ExportService) generatePDF(ctx context.Context, dashboardID string, options *ExportOptions, outputPath string) (int64, error) {
+	width, height, err := exportPageSize(options)
+	if err != nil {
+		return 0, err
+	}
+
+	snapshot, err := s.collectDashboard(ctx, dashboardID, options)
+	if err != nil {
+		return 0, err
+	}
+
+	title := snapshot.Dashboard.Name
+	if options.Title != nil && *options.Title != "" {
 		title = *options.Title
 	}
 
-	subtitle := ""
-	if options.Subtitle != nil {
-		subtitle = fmt.Sprintf("<h2>%s</h2>", *options.Subtitle)
+	layout := &pdfLayout{doc: NewPDFDocument(width, height, title), margin: 36}
+	if options.Watermark != nil {
+		layout.watermark = *options.Watermark
+	}
+	layout.newPage()
+
+	// Header
+	layout.doc.SetFillColor(17, 24, 39)
+	layout.text(20, true, title)
+	if options.Subtitle != nil && *options.Subtitle != "" {
+		layout.doc.SetFillColor(75, 85, 99)
+		layout.text(13, false, *options.Subtitle)
+	}
+	layout.doc.SetFillColor(107, 114, 128)
+	if options.IncludeTimestamp {
+		layout.text(9, false, "Generated "+time.Now().Format("2006-01-02 15:04:05 MST"))
+	}
+	if options.IncludeFilters && snapshot.Dashboard.Filters != nil && *snapshot.Dashboard.Filters != "" {
+		layout.text(9, false, "Filters: "+*snapshot.Dashboard.Filters)
+	}
+	layout.y += 8
+
+	if len(snapshot.Cards) == 0 {
+		layout.text(10, false, "This dashboard has no cards")
+	}
+
+	for i := range snapshot.Cards {
+		card := &snapshot.Cards[i]
+
+		// Keep a card title together with the start of its content
+		layout.ensure(60)
+		layout.doc.SetFillColor(17, 24, 39)
+		layout.text(12, true, card.Title)
+		layout.y += 2
+
+		switch {
+		case card.Error != "":
+			layout.doc.SetFillColor(185, 28, 28)
+			layout.text(9, false, card.Error)
+		case card.Type == "text":
+			layout.doc.SetFillColor(55, 65, 81)
+			for _, line := range layout.doc.WrapText(card.Text, 10, false, layout.contentWidth()) {
+				layout.text(10, false, line)
+			}
+		default:
+			layout.table(card)
+		}
+		layout.y += 16
 	}
 
+	// Footers, now that the page count is known
 	footer := ""
 	if options.FooterText != nil {
 		footer = *options.FooterText
 	}
+	pages := layout.doc.PageCount()
+	for i := 0; i < pages; i++ {
+		layout.doc.SetPage(i)
+		layout.doc.SetFillColor(107, 114, 128)
+		baseline := height - layout.margin + 12
+		pageLabel := fmt.Sprintf("Page %d of %d", i+1, pages)
+		if footer != "" {
+			layout.doc.Text(layout.margin, baseline, 8, false, layout.doc.Truncate(footer, 8, false, layout.contentWidth()-80))
+		}
+		layout.doc.Text(width-layout.margin-layout.doc.TextWidth(pageLabel, 8, false), baseline, 8, false, pageLabel)
+	}
 
-	watermark := ""
-	if options.Watermark != nil {
-		watermark = fmt.Sprintf("<div style=\"opacity:0.1;position:fixed;top:50%%;left:50%%;transform:translate(-50%%,-50%%);font-size:72px;color:#ccc;\">%s</div>", *options.Watermark)
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create PDF file: %w", err)
+	}
+	defer file.Close()
+
+	size, err := layout.doc.WriteTo(file, zlib.DefaultCompression)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write PDF file: %w", err)
+	}
+	return size, nil
+}
+
+// Dashboard grid geometry, matching the dashboard layout at 96 DPI
+const (
+	dashboardGridColumns = 12
+	dashboardGridRow     = 30 // Pixels per grid row
+	dashboardGridMargin  = 10
+	dashboardImageWidth  = 1200
+	dashboardImageMaxPx  = 16000 // Longest side of a dashboard image
+
+	// dashboardImageMaxPixels bounds the memory of a dashboard image's canvas, at 4 bytes a pixel
+	dashboardImageMaxPixels = 40_000_000
+)
+
+// ErrExportTooLarge is returned for image exports with more than dashboardImageMaxPixels pixels
+var ErrExportTooLarge = errors.New("dashboard image would be too large")
+
+// generateImage rasterises the dashboard's cards at their grid positions into a PNG or JPEG file
+func (s *ExportService) generateImage(ctx context.Context, dashboardID string, options *ExportOptions, outputPath string) (int64, error) {
+	snapshot, err := s.collectDashboard(ctx, dashboardID, options)
+	if err != nil {
+		return 0, err
+	}
+
+	img := composeDashboardImage(snapshot, options)
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create image file: %w", err)
 	}
+	defer file.Close()
 
-	timestamp := ""
+	if err := encodeExportImage(file, img, options); err != nil {
+		return 0, fmt.Errorf("failed to write image file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat image file: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// renderExportCard rasterises one card of a snapshot
+func renderExportCard(card *exportCard, width, height, scale int) *image.RGBA {
+	if card.Type == "text" && card.Error == "" {
+		return RenderTextCard(card.Title, card.Text, width, height, scale)
+	}
+	return RenderCardChart(card.Title, card.VisualizationConfig, card.Columns, card.Rows, card.Error, width, height, scale)
+}
+
+// dashboardLayout is the geometry of a dashboard image
+type dashboardLayout struct {
+	scale, width, height       int
+	margin, rowHeight          int
+	lineHeight, header, footer int
+	colWidth                   float64
+	positions                  []cardGridPosition
+}
+
+// layoutDashboard places cards on the grid of a dashboard image with headerLines lines below
+// its title. The height is that of the whole grid, before it is limited.
+func layoutDashboard(positions []cardGridPosition, headerLines int, hasFooter bool, resolution int) dashboardLayout {
+	ratio := math.Min(math.Max(float64(resolution)/96, 0.75), float64(dashboardImageMaxPx)/dashboardImageWidth)
+	px := func(v float64) int { return int(math.Round(v * ratio)) }
+	layout := dashboardLayout{
+		scale:     max(1, int(math.Round(ratio))),
+		width:     px(dashboardImageWidth),
+		margin:    px(dashboardGridMargin),
+		rowHeight: px(dashboardGridRow),
+	}
+	layout.colWidth = float64(layout.width-layout.margin*(dashboardGridColumns+1)) / dashboardGridColumns
+
+	// Cards without a stored size get a full-width row below the others
+	layout.positions = make([]cardGridPosition, len(positions))
+	bottom := 0
+	for i, position := range positions {
+		if position.W > 0 && position.H > 0 {
+			layout.positions[i] = position
+			bottom = max(bottom, position.Y+position.H)
+		}
+	}
+	for i := range layout.positions {
+		if layout.positions[i].W <= 0 || layout.positions[i].H <= 0 {
+			layout.positions[i] = cardGridPosition{X: 0, Y: bottom, W: dashboardGridColumns, H: 8}
+			bottom += 8
+		}
+		layout.positions[i].X = min(max(layout.positions[i].X, 0), dashboardGridColumns-1)
+		layout.positions[i].W = min(layout.positions[i].W, dashboardGridColumns-layout.positions[i].X)
+	}
+
+	layout.lineHeight = (rasterGlyphHeight + 4) * layout.scale
+	layout.header = layout.margin + rasterGlyphHeight*(layout.scale+2) + headerLines*layout.lineHeight + layout.margin
+	if hasFooter {
+		layout.footer = layout.lineHeight + layout.margin
+	}
+	layout.height = layout.header + bottom*(layout.rowHeight+layout.margin) + layout.margin + layout.footer
+	return layout
+}
+
+// dashboardHeaderLines returns the lines below the title of a dashboard image
+func dashboardHeaderLines(dashboard *models.Dashboard, options *ExportOptions) []string {
+	var lines []string
+	if options.Subtitle != nil && *options.Subtitle != "" {
+		lines = append(lines, *options.Subtitle)
+	}
 	if options.IncludeTimestamp {
-		timestamp = fmt.Sprintf("<div>Generated: %s</div>", time.Now().Format("2006-01-02 15:04:05"))
-	}
-
-	return fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-	<meta charset="UTF-8">
-	<title>%s</title>
-	<style>
-		body { font-family: Arial, sans-serif; margin: 20px; }
-		h1 { color: #333; }
-		.footer { margin-top: 40px; padding-top: 20px; border-top: 1px solid #ccc; font-size: 12px; color: #666; }
-	</style>
-</head>
-<body>
-	%s
-	<h1>%s</h1>
-	%s
-	%s
-	<div class="content">
-		<p>Dashboard ID: %s</p>
-		<p>Export ID: %s</p>
-		<p>Format: %s | Orientation: %s | Quality: %s</p>
-	</div>
-	<div class="footer">%s</div>
-</body>
-</html>`, title, watermark, title, subtitle, timestamp, job.DashboardID, job.ID, options.Format, options.Orientation, options.Quality, footer)
+		lines = append(lines, "Generated "+time.Now().Format("2006-01-02 15:04:05 MST"))
+	}
+	if options.IncludeFilters && dashboard.Filters != nil && *dashboard.Filters != "" {
+		lines = append(lines, "Filters: "+*dashboard.Filters)
+	}
+	return lines
+}
+
+// checkImageSize returns ErrExportTooLarge if the image of a dashboard would have more
+// pixels than dashboardImageMaxPixels, which at high resolutions takes only a tall dashboard
+func (s *ExportService) checkImageSize(ctx context.Context, dashboardID uuid.UUID, options *ExportOptions) error {
+	var dashboard models.Dashboard
+	if err := s.db.WithContext(ctx).Where("id = ?", dashboardID.String()).First(&dashboard).Error; err != nil {
+		return fmt.Errorf("dashboard not found: %w", err)
+	}
+	var cards []models.DashboardCard
+	if err := s.db.WithContext(ctx).Select("id", "position").Where("dashboard_id = ?", dashboardID.String()).Find(&cards).Error; err != nil {
+		return fmt.Errorf("failed to load dashboard cards: %w", err)
+	}
+
+	var positions []cardGridPosition
+	for _, card := range cards {
+		if len(options.CardIDs) > 0 && !contains(options.CardIDs, card.ID) {
+			continue
+		}
+		var position cardGridPosition
+		_ = json.Unmarshal([]byte(card.Position), &position)
+		positions = append(positions, position)
+	}
+	hasFooter := options.FooterText != nil && *options.FooterText != ""
+	layout := layoutDashboard(positions, len(dashboardHeaderLines(&dashboard, options)), hasFooter, options.Resolution)
+	if layout.width*layout.height > dashboardImageMaxPixels {
+		return fmt.Errorf("%w: %dx%d pixels at %d DPI; lower the resolution or export fewer cards",
+			ErrExportTooLarge, layout.width, layout.height, options.Resolution)
+	}
+	return nil
+}
+
+// composeDashboardImage lays out every card at its grid position below a title header.
+// Options.Resolution scales the 1200px-wide, 96 DPI dashboard layout.
+func composeDashboardImage(snapshot *dashboardSnapshot, options *ExportOptions) *image.RGBA {
+	positions := make([]cardGridPosition, len(snapshot.Cards))
+	for i, card := range snapshot.Cards {
+		positions[i] = card.Position
+	}
+	title := snapshot.Dashboard.Name
+	if options.Title != nil && *options.Title != "" {
+		title = *options.Title
+	}
+	headerLines := dashboardHeaderLines(&snapshot.Dashboard, options)
+	hasFooter := options.FooterText != nil && *options.FooterText != ""
+	layout := layoutDashboard(positions, len(headerLines), hasFooter, options.Resolution)
+
+	scale, width, margin, lineHeight := layout.scale, layout.width, layout.margin, layout.lineHeight
+	colWidth, rowHeight, header, footer := layout.colWidth, layout.rowHeight, layout.header, layout.footer
+	positions = layout.positions
+	// Export requests over the limits are rejected, but the canvas never exceeds them
+	height := min(min(layout.height, dashboardImageMaxPx), dashboardImageMaxPixels/width)
+
+	canvas := newRasterCanvas(width, height, color.RGBA{249, 250, 251, 255})
+	canvas.text(margin, margin, scale+2, chartText, rasterTruncate(title, scale+2, width-2*margin))
+	y := margin + rasterGlyphHeight*(scale+2) + 4*scale
+	for _, line := range headerLines {
+		canvas.text(margin, y, scale, chartMutedText, rasterTruncate(line, scale, width-2*margin))
+		y += lineHeight
+	}
+
+	for i := range snapshot.Cards {
+		pos := positions[i]
+		x0 := margin + int(float64(pos.X)*(colWidth+float64(margin)))
+		y0 := header + pos.Y*(rowHeight+margin)
+		w := int(float64(pos.W)*colWidth) + (pos.W-1)*margin
+		h := pos.H*rowHeight + (pos.H-1)*margin
+		if y0 >= height-footer || w <= 0 || h <= 0 {
+			continue
+		}
+		cardImg := renderExportCard(&snapshot.Cards[i], w, h, scale)
+		draw.Draw(canvas.img, image.Rect(x0, y0, x0+w, y0+h), cardImg, image.Point{}, draw.Src)
+	}
+
+	if footer > 0 {
+		canvas.text(margin, height-footer, scale, chartMutedText, rasterTruncate(*options.FooterText, scale, width-2*margin))
+	}
+	if options.Watermark != nil && *options.Watermark != "" {
+		drawWatermark(canvas.img, *options.Watermark)
+	}
+
+	return canvas.img
+}
+
+// encodeExportImage writes img as PNG or JPEG; quality sets the JPEG quality and PNG compression
+func encodeExportImage(w io.Writer, img image.Image, options *ExportOptions) error {
+	if options.Format == ExportFormatJPEG {
+		quality := 92
+		switch options.Quality {
+		case QualityMedium:
+			quality = 80
+		case QualityLow:
+			quality = 60
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	}
+
+	encoder := png.Encoder{CompressionLevel: png.DefaultCompression}
+	if options.Quality == QualityLow {
+		encoder.CompressionLevel = png.BestCompression
+	}
+	return encoder.Encode(w, img)
 }
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"insight-engine-backend/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TestPDFDocument_XRef tests that every cross-reference entry points at its object
+func TestPDFDocument_XRef(t *testing.T) {
+	doc := NewPDFDocument(595.28, 841.89, "Report (Q3)")
+	doc.AddPage()
+	doc.Text(36, 60, 12, true, "Revenue by region")
+	doc.AddPage()
+	doc.Rect(36, 36, 100, 20, true)
+
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pdf := buf.Bytes()
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("Missing PDF header or trailer")
+	}
+	if !bytes.Contains(pdf, []byte("/Count 2")) {
+		t.Error("Expected 2 pages")
+	}
+	if !bytes.Contains(pdf, []byte(`(Report \(Q3\))`)) {
+		t.Error("Expected escaped title")
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf, -1)
+	if len(entries) == 0 {
+		t.Fatal("No xref entries")
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		want := strconv.Itoa(i+1) + " 0 obj"
+		if !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("xref entry %d does not point at %q", i+1, want)
+		}
+	}
+}
+
+// TestExportPageSize tests page presets, orientation and custom sizes
+func TestExportPageSize(t *testing.T) {
+	w, h, err := exportPageSize(&ExportOptions{PageSize: PageSizeLetter, Orientation: OrientationLandscape})
+	if err != nil || w != 792 || h != 612 {
+		t.Errorf("Expected landscape Letter 792x612, got %vx%v (%v)", w, h, err)
+	}
+
+	width, height := 100, 200
+	w, h, err = exportPageSize(&ExportOptions{PageSize: PageSizeCustom, Orientation: OrientationPortrait, CustomWidth: &width, CustomHeight: &height})
+	if err != nil || w < 283 || w > 284 || h < 566 || h > 567 {
+		t.Errorf("Expected custom 100x200mm page, got %vx%v (%v)", w, h, err)
+	}
+
+	if _, _, err := exportPageSize(&ExportOptions{PageSize: PageSizeCustom}); err == nil {
+		t.Error("Expected error for custom size without dimensions")
+	}
+}
+
+// TestGeneratePDF tests rendering a dashboard with text and query cards
+func TestGeneratePDF(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Dashboard{}, &models.DashboardCard{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	textTitle, text := "Notes", "Quarterly numbers are preliminary."
+	chartTitle, queryID := "Revenue", "missing-query"
+	db.Create(&models.Dashboard{ID: "d1", Name: "Sales Overview", CollectionID: "c1", UserID: "u1"})
+	db.Create(&models.DashboardCard{ID: "card2", DashboardID: "d1", Type: "visualization", Title: &chartTitle, QueryID: &queryID, Position: `{"x":0,"y":4}`})
+	db.Create(&models.DashboardCard{ID: "card1", DashboardID: "d1", Type: "text", Title: &textTitle, TextContent: &text, Position: `{"x":0,"y":0}`})
+
+	service := &ExportService{db: db, exportDir: t.TempDir()}
+	options := &ExportOptions{Format: ExportFormatPDF, Orientation: OrientationPortrait, PageSize: PageSizeA4, Quality: QualityHigh, Resolution: 150}
+
+	snapshot, err := service.collectDashboard(context.Background(), "d1", options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(snapshot.Cards) != 2 || snapshot.Cards[0].Title != "Notes" {
+		t.Fatalf("Expected cards in grid order, got %+v", snapshot.Cards)
+	}
+	if snapshot.Cards[1].Error == "" {
+		t.Error("Expected query card without executor to carry an error")
+	}
+
+	outputPath := filepath.Join(service.exportDir, "d1.pdf")
+	size, err := service.generatePDF(context.Background(), "d1", options, outputPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, _ := os.ReadFile(outputPath)
+	if int64(len(data)) != size || !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Errorf("Expected a PDF of %d bytes", size)
+	}
+}
+
+// TestPDFLayout_TablePagination tests that long tables continue on new pages
+func TestPDFLayout_TablePagination(t *testing.T) {
+	layout := &pdfLayout{doc: NewPDFDocument(595.28, 841.89, "t"), margin: 36}
+	layout.newPage()
+
+	card := &exportCard{Columns: []string{"id", "name"}}
+	for i := 0; i < 120; i++ {
+		card.Rows = append(card.Rows, []interface{}{int64(i), "row"})
+	}
+	card.TotalRows = len(card.Rows)
+	layout.table(card)
+
+	if layout.doc.PageCount() < 3 {
+		t.Errorf("Expected table to span at least 3 pages, got %d", layout.doc.PageCount())
+	}
+}
+
+// TestCheckImageSize tests rejecting image exports whose canvas would be too large
+func TestCheckImageSize(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Dashboard{}, &models.DashboardCard{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	dashboardID := uuid.New()
+	db.Create(&models.Dashboard{ID: dashboardID.String(), Name: "Tall", CollectionID: "c1", UserID: "u1"})
+	db.Create(&models.DashboardCard{ID: "card1", DashboardID: dashboardID.String(), Type: "text", Position: `{"x":0,"y":0,"w":12,"h":4}`})
+	db.Create(&models.DashboardCard{ID: "card2", DashboardID: dashboardID.String(), Type: "text", Position: `{"x":0,"y":4,"w":12,"h":200}`})
+
+	service := &ExportService{db: db, exportDir: t.TempDir()}
+	options := &ExportOptions{Format: ExportFormatPNG, Quality: QualityHigh, Resolution: 96}
+	if err := service.checkImageSize(context.Background(), dashboardID, options); err != nil {
+		t.Errorf("Unexpected error at 96 DPI: %v", err)
+	}
+	options.Resolution = 600
+	if err := service.checkImageSize(context.Background(), dashboardID, options); !errors.Is(err, ErrExportTooLarge) {
+		t.Errorf("Expected ErrExportTooLarge at 600 DPI, got %v", err)
+	}
+	options.CardIDs = []string{"card1"}
+	if err := service.checkImageSize(context.Background(), dashboardID, options); err != nil {
+		t.Errorf("Unexpected error exporting the short card: %v", err)
+	}
+
+	// The canvas stays within the limit whatever it is given
+	snapshot := &dashboardSnapshot{Cards: []exportCard{{Type: "text", Position: cardGridPosition{X: 0, Y: 0, W: 12, H: 400}}}}
+	img := composeDashboardImage(snapshot, &ExportOptions{Format: ExportFormatPNG, Quality: QualityHigh, Resolution: 600})
+	if pixels := img.Bounds().Dx() * img.Bounds().Dy(); pixels > dashboardImageMaxPixels {
+		t.Errorf("Expected at most %d pixels, got %d", dashboardImageMaxPixels, pixels)
+	}
+}
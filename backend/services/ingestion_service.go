@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"insight-engine-backend/models"
+	"io"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ingestion source types
+const (
+	IngestSourceCSV      = "CSV"
+	IngestSourceExcel    = "EXCEL"
+	IngestSourceJSON     = "JSON"
+	IngestSourceAPI      = "API"
+	IngestSourceDatabase = "DATABASE"
+)
+
+// IngestionRequest describes a source to preview or load into a workspace table
+type IngestionRequest struct {
+	WorkspaceID  string
+	UserID       string
+	SourceType   string                 // CSV, EXCEL, JSON, API, DATABASE
+	SourceConfig map[string]interface{} // PipelineSourceConfig fields, plus RESTConnectorConfig fields for API
+	TargetTable  string
+	Mode         string // OVERWRITE, APPEND (default)
+
+	File     io.Reader // Uploaded file for CSV, EXCEL and JSON sources (else sourceConfig.filePath)
+	FileName string
+}
+
+// IngestionColumn is a typed column of an ingestion source
+type IngestionColumn struct {
+	Name         string        `json:"name"`
+	Type         string        `json:"type"` // text, integer, float, boolean, date, timestamp, json
+	NullCount    int           `json:"nullCount"`
+	SampleValues []interface{} `json:"sampleValues"`
+}
+
+// IngestionPreview is the first rows of a source with their detected column types
+type IngestionPreview struct {
+	SourceType string            `json:"sourceType"`
+	Columns    []IngestionColumn `json:"columns"`
+	Rows       [][]interface{}   `json:"rows"`
+	SampleSize int               `json:"sampleSize"`
+	HasMore    bool              `json:"hasMore"` // The source has rows beyond the sample
+}
+
+// IngestionResult summarises a finished ingestion
+type IngestionResult struct {
+	TargetTable  string            `json:"targetTable"` // Schema-qualified table in the internal database
+	Mode         string            `json:"mode"`
+	RowsIngested int               `json:"rowsIngested"`
+	Columns      []IngestionColumn `json:"columns"`
+	DurationMs   int64             `json:"durationMs"`
+}
+
+// IngestionService loads files, APIs and external databases into workspace tables
+type IngestionService struct {
+	db        *gorm.DB
+	extractor *PipelineExecutor // Shares the pipeline source readers
+}
+
+// NewIngestionService creates a new ingestion service
+func NewIngestionService(db *gorm.DB, extractor *PipelineExecutor) *IngestionService {
+	return &IngestionService{
+		db:        db,
+		extractor: extractor,
+	}
+}
+
+// Preview reads the first limit rows of a source (1000 at most) and returns them with typed
+// columns. Only the sample is read from the source; column types are detected from it.
+func (s *IngestionService) Preview(ctx context.Context, req *IngestionRequest, limit int) (*IngestionPreview, error) {
+	if limit <= 0 {
+		limit = 10
+	} else if limit > 1000 {
+		limit = 1000
+	}
+
+	// One extra row tells whether the source has more
+	columns, rows, err := s.read(ctx, req, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+
+	return &IngestionPreview{
+		SourceType: strings.ToUpper(req.SourceType),
+		Columns:    describeColumns(columns, rows),
+		Rows:       rows,
+		SampleSize: len(rows),
+		HasMore:    hasMore,
+	}, nil
+}
+
+// Ingest reads a source and writes it into TargetTable inside the workspace schema
+func (s *IngestionService) Ingest(ctx context.Context, req *IngestionRequest) (*IngestionResult, error) {
+	startTime := time.Now()
+
+	if !validWorkspaceTableName.MatchString(req.TargetTable) {
+		return nil, fmt.Errorf("invalid target table name: %q", req.TargetTable)
+	}
+
+	mode := strings.ToUpper(req.Mode)
+	if mode == "" {
+		mode = LoadModeAppend
+	}
+	if mode != LoadModeAppend && mode != LoadModeOverwrite {
+		return nil, fmt.Errorf("invalid mode %q: must be OVERWRITE or APPEND", req.Mode)
+	}
+
+	columns, rows, err := s.read(ctx, req, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := s.db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to access internal database: %w", err)
+	}
+	loader := NewTableLoader(db, "postgres")
+	schema := WorkspaceSchema(req.WorkspaceID)
+	if err := loader.EnsureSchema(ctx, schema); err != nil {
+		return nil, err
+	}
+
+	table := schema + "." + req.TargetTable
+	loaded, err := loader.Load(ctx, table, columns, rows, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	LogInfo("ingest_complete", "Data ingested", map[string]interface{}{
+		"workspace_id": req.WorkspaceID,
+		"source_type":  req.SourceType,
+		"table":        table,
+		"mode":         mode,
+		"rows":         loaded,
+	})
+
+	return &IngestionResult{
+		TargetTable:  table,
+		Mode:         mode,
+		RowsIngested: loaded,
+		Columns:      describeColumns(columns, rows),
+		DurationMs:   time.Since(startTime).Milliseconds(),
+	}, nil
+}
+
+// read dispatches on the source type and returns typed columns and up to maxRows
+// positional rows (0 = all)
+func (s *IngestionService) read(ctx context.Context, req *IngestionRequest, maxRows int) ([]TempTableColumn, [][]interface{}, error) {
+	rawConfig, err := json.Marshal(req.SourceConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid source config: %w", err)
+	}
+	var cfg PipelineSourceConfig
+	if err := json.Unmarshal(rawConfig, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("invalid source config: %w", err)
+	}
+	cfg.MaxRows = maxRows
+
+	switch sourceType := strings.ToUpper(req.SourceType); sourceType {
+	case IngestSourceCSV, IngestSourceExcel, IngestSourceJSON:
+		return s.readFile(ctx, sourceType, req, &cfg)
+
+	case IngestSourceAPI, IngestSourceDatabase:
+		if sourceType == IngestSourceDatabase {
+			if cfg.ConnectionID == "" {
+				return nil, nil, fmt.Errorf("DATABASE source requires connectionId")
+			}
+			// Only the requesting user's own connections can be read
+			var conn models.Connection
+			if err := s.db.Where("id = ? AND user_id = ?", cfg.ConnectionID, req.UserID).First(&conn).Error; err != nil {
+				return nil, nil, fmt.Errorf("connection not found")
+			}
+		}
+
+		ds, err := s.extractor.Extract(ctx, req.WorkspaceID, sourceType, string(rawConfig), maxRows)
+		if err != nil {
+			return nil, nil, err
+		}
+		return InferColumnTypes(ds.Columns, ds.Rows), ds.PositionalRows(), nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported source type %q: must be CSV, EXCEL, JSON, API or DATABASE", req.SourceType)
+	}
+}
+
+// readFile reads an uploaded file, or a file already in the workspace's upload directory
+func (s *IngestionService) readFile(ctx context.Context, sourceType string, req *IngestionRequest, cfg *PipelineSourceConfig) ([]TempTableColumn, [][]interface{}, error) {
+	r := req.File
+	fileName := req.FileName
+	if r == nil {
+		file, err := s.extractor.openUpload(req.WorkspaceID, cfg.FilePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer file.Close()
+		r = file
+		fileName = cfg.FilePath
+	}
+
+	csvColumns, rows, err := s.extractor.ReadFile(ctx, FileFormat(cfg.Format, sourceType, fileName), r, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := make([]TempTableColumn, len(csvColumns))
+	for i, col := range csvColumns {
+		columns[i] = TempTableColumn{Name: col.Name, DataType: col.DetectedType, Nullable: true, Index: i}
+	}
+	return columns, rows, nil
+}
+
+// describeColumns adds null counts and sample values to column definitions
+func describeColumns(columns []TempTableColumn, rows [][]interface{}) []IngestionColumn {
+	const maxSamples = 5
+
+	result := make([]IngestionColumn, len(columns))
+	for i, col := range columns {
+		described := IngestionColumn{Name: col.Name, Type: col.DataType, SampleValues: []interface{}{}}
+		for _, row := range rows {
+			var value interface{}
+			if i < len(row) {
+				value = row[i]
+			}
+			if value == nil {
+				described.NullCount++
+				continue
+			}
+			if len(described.SampleValues) < maxSamples {
+				described.SampleValues = append(described.SampleValues, value)
+			}
+		}
+		result[i] = described
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"insight-engine-backend/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestIngestionPreview tests previewing uploaded files and database tables without reading
+// more than the sample
+func TestIngestionPreview(t *testing.T) {
+	root := t.TempDir()
+	SetFileStorageDir(root)
+	defer SetFileStorageDir("./data/files")
+	uploads := t.TempDir()
+	t.Setenv("UPLOAD_DIR", uploads)
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Connection{}, &models.WorkspaceMember{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	qe := NewQueryExecutor()
+	defer qe.Close()
+	service := NewIngestionService(db, NewPipelineExecutor(db, qe))
+	ctx := context.Background()
+
+	var csvData strings.Builder
+	csvData.WriteString("id,name,score\n")
+	for i := 10; i < 60; i++ {
+		csvData.WriteString(strconv.Itoa(i) + ",alice,2.5\n")
+	}
+	preview, err := service.Preview(ctx, &IngestionRequest{WorkspaceID: "ws-1", UserID: "user-1", SourceType: "csv",
+		File: strings.NewReader(csvData.String()), FileName: "scores.csv"}, 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if preview.SampleSize != 5 || len(preview.Rows) != 5 || !preview.HasMore {
+		t.Errorf("Expected 5 rows and more to come, got %d (hasMore %v)", preview.SampleSize, preview.HasMore)
+	}
+	if len(preview.Columns) != 3 || preview.Columns[0].Type != "integer" || preview.Columns[2].Type != "float" {
+		t.Errorf("Unexpected columns %+v", preview.Columns)
+	}
+
+	preview, err = service.Preview(ctx, &IngestionRequest{WorkspaceID: "ws-1", UserID: "user-1", SourceType: "json",
+		File: strings.NewReader(`[{"id": 1}, {"id": 2}]`), FileName: "ids.json"}, 10)
+	if err != nil || preview.SampleSize != 2 || preview.HasMore {
+		t.Errorf("Expected the whole file, got %+v (%v)", preview, err)
+	}
+
+	// Files already uploaded are read from the workspace's upload directory only
+	for path, content := range map[string]string{"ws-1/ids.csv": "id\n1\n2\n", "ws-2/secret.csv": "secret\nx\n"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(uploads, path)), 0755)
+		os.WriteFile(filepath.Join(uploads, path), []byte(content), 0644)
+	}
+	preview, err = service.Preview(ctx, &IngestionRequest{WorkspaceID: "ws-1", UserID: "user-1", SourceType: "csv",
+		SourceConfig: map[string]interface{}{"filePath": "ids.csv"}}, 10)
+	if err != nil || preview.SampleSize != 2 {
+		t.Errorf("Expected the workspace's file, got %+v (%v)", preview, err)
+	}
+	for _, path := range []string{"../ws-2/secret.csv", "/ws-2/secret.csv", "ws-2/secret.csv"} {
+		if _, err := service.Preview(ctx, &IngestionRequest{WorkspaceID: "ws-1", UserID: "user-1", SourceType: "csv",
+			SourceConfig: map[string]interface{}{"filePath": path}}, 10); err == nil {
+			t.Errorf("Expected %s of another workspace to be unreadable", path)
+		}
+	}
+
+	// Database previews only read the sample from the connection
+	if err := os.MkdirAll(filepath.Join(root, "user-1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	warehouse, err := sql.Open("sqlite", filepath.Join(root, "user-1", "warehouse.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer warehouse.Close()
+	if _, err := warehouse.Exec(`CREATE TABLE events (id INTEGER, kind TEXT);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 500)
+		INSERT INTO events SELECT i, 'click' FROM n`); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&models.WorkspaceMember{ID: "m1", WorkspaceID: "ws-1", UserID: "user-1", Role: models.RoleEditor})
+	db.Create(&models.Connection{ID: "wh", Name: "warehouse", Type: "sqlite", Database: "warehouse.db", UserID: "user-1"})
+
+	source := map[string]interface{}{"connectionId": "wh", "tableName": "events"}
+	preview, err = service.Preview(ctx, &IngestionRequest{WorkspaceID: "ws-1", UserID: "user-1", SourceType: "database", SourceConfig: source}, 20)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if preview.SampleSize != 20 || !preview.HasMore || preview.Rows[19][0] != int64(20) {
+		t.Errorf("Expected the first 20 events, got %d (hasMore %v)", preview.SampleSize, preview.HasMore)
+	}
+
+	// Other users' connections cannot be previewed
+	if _, err := service.Preview(ctx, &IngestionRequest{WorkspaceID: "ws-1", UserID: "user-2", SourceType: "database", SourceConfig: source}, 20); err == nil {
+		t.Error("Expected another user's connection to be rejected")
+	}
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"container/list"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"insight-engine-backend/database"
 	"insight-engine-backend/models"
@@ -35,16 +37,21 @@ type JobQueue struct {
 	workers    int
 	ctx        context.Context
 	cancel     context.CancelFunc
+
+	pipelineExecutor *PipelineExecutor
+	dataflowRunner   *DataflowRunner
 }
 
 // NewJobQueue creates a new job queue
-func NewJobQueue(maxWorkers int) *JobQueue {
+func NewJobQueue(maxWorkers int, pipelineExecutor *PipelineExecutor, dataflowRunner *DataflowRunner) *JobQueue {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &JobQueue{
-		queue:      list.New(),
-		maxWorkers: maxWorkers,
-		ctx:        ctx,
-		cancel:     cancel,
+		queue:            list.New(),
+		maxWorkers:       maxWorkers,
+		ctx:              ctx,
+		cancel:           cancel,
+		pipelineExecutor: pipelineExecutor,
+		dataflowRunner:   dataflowRunner,
 	}
 }
 
@@ -109,8 +116,8 @@ func (jq *JobQueue) worker(id int) {
 			if err := jq.processJob(job); err != nil {
 				LogError("job_process_failed", "Worker failed to process job", map[string]interface{}{"worker_id": id, "job_id": job.ID, "error": err})
 
-				// Retry logic
-				if job.Retries < 3 {
+				// Retry logic (broken quality rules fail the same way on every attempt)
+				if job.Retries < 3 && !errors.Is(err, ErrQualityCheckFailed) {
 					job.Retries++
 					LogInfo("job_retry", "Retrying job", map[string]interface{}{"job_id": job.ID, "attempt": job.Retries, "max_retries": 3})
 					time.Sleep(time.Duration(job.Retries) * 5 * time.Second) // Exponential backoff
@@ -130,46 +137,58 @@ func (jq *JobQueue) worker(id int) {
 func (jq *JobQueue) processJob(job *Job) error {
 	switch job.Type {
 	case JobTypePipeline:
-		return jq.processPipeline(job.EntityID)
+		return jq.processPipeline(job)
 	case JobTypeDataflow:
-		return jq.processDataflow(job.EntityID)
+		return jq.processDataflow(job)
 	default:
 		return fmt.Errorf("unknown job type: %s", job.Type)
 	}
 }
 
-// processPipeline executes a pipeline
-func (jq *JobQueue) processPipeline(pipelineID string) error {
-	// Find the execution record
+// processPipeline executes a pipeline run (job.ID is the JobExecution ID)
+func (jq *JobQueue) processPipeline(job *Job) error {
+	// Find the execution record (PROCESSING when this is a retry)
 	var execution models.JobExecution
-	if err := database.DB.Where("pipeline_id = ? AND status = ?", pipelineID, "PENDING").
-		Order("started_at DESC").
+	if err := database.DB.Where("id = ? AND status IN ?", job.ID, []string{"PENDING", "PROCESSING"}).
 		First(&execution).Error; err != nil {
 		return fmt.Errorf("execution not found: %w", err)
 	}
 
+	var pipeline models.Pipeline
+	if err := database.DB.First(&pipeline, "id = ?", job.EntityID).Error; err != nil {
+		return fmt.Errorf("pipeline not found: %w", err)
+	}
+
 	// Update status to PROCESSING
 	execution.Status = "PROCESSING"
 	database.DB.Save(&execution)
 
-	// NOTE: Actual pipeline execution logic is business-specific and depends on
-	// the pipeline configuration stored in the database. This would typically:
-	// 1. Load pipeline steps from database
-	// 2. Execute each step sequentially or in parallel
-	// 3. Handle step dependencies and conditional execution
-	// 4. Aggregate results and update execution metadata
-	//
-	// For now, this is a placeholder that simulates successful execution.
-	// Production implementation should integrate with PipelineExecutor service.
-	time.Sleep(2 * time.Second)
+	runLog := &PipelineRunLog{}
+	runLog.Addf("START", "Execution %s started (attempt %d)", execution.ID, job.Retries+1)
+
+	result, err := jq.pipelineExecutor.Run(jq.ctx, &pipeline, execution.ID, runLog)
+	if result != nil {
+		execution.RowsProcessed = result.RowsLoaded
+	}
+	if err != nil {
+		// Keep the partial logs; markJobFailed finalises the record once retries are exhausted
+		runLog.Addf("ERROR", "%v", err)
+		errorMsg := err.Error()
+		execution.Error = &errorMsg
+		execution.Logs = runLog.JSON()
+		database.DB.Save(&execution)
+		return err
+	}
 
 	// Update execution record
 	now := time.Now()
+	runLog.Addf("SUCCESS", "Job completed successfully")
 	execution.Status = "COMPLETED"
 	execution.CompletedAt = &now
 	durationMs := int(now.Sub(execution.StartedAt).Milliseconds())
 	execution.DurationMs = &durationMs
-	execution.RowsProcessed = 100 // Mock value
+	execution.Error = nil
+	execution.Logs = runLog.JSON()
 
 	if err := database.DB.Save(&execution).Error; err != nil {
 		return fmt.Errorf("failed to update execution: %w", err)
@@ -177,43 +196,55 @@ func (jq *JobQueue) processPipeline(pipelineID string) error {
 
 	// Update pipeline last run status
 	database.DB.Exec("UPDATE \"Pipeline\" SET last_run_at = ?, last_status = ? WHERE id = ?",
-		now, "SUCCESS", pipelineID)
+		now, "SUCCESS", pipeline.ID)
 
 	return nil
 }
 
-// processDataflow executes a dataflow
-func (jq *JobQueue) processDataflow(dataflowID string) error {
-	// Find the run record
+// processDataflow executes a dataflow run (job.ID is the DataflowRun ID)
+func (jq *JobQueue) processDataflow(job *Job) error {
+	// Find the run record (RUNNING when this is a retry)
 	var run models.DataflowRun
-	if err := database.DB.Where("dataflow_id = ? AND status = ?", dataflowID, "PENDING").
-		Order("started_at DESC").
+	if err := database.DB.Where("id = ? AND status IN ?", job.ID, []string{"PENDING", "RUNNING"}).
 		First(&run).Error; err != nil {
 		return fmt.Errorf("run not found: %w", err)
 	}
 
+	var dataflow models.Dataflow
+	if err := database.DB.First(&dataflow, "id = ?", job.EntityID).Error; err != nil {
+		return fmt.Errorf("dataflow not found: %w", err)
+	}
+
+	var steps []models.DataflowStep
+	if err := database.DB.Where(&models.DataflowStep{DataflowID: dataflow.ID}).Find(&steps).Error; err != nil {
+		return fmt.Errorf("failed to load dataflow steps: %w", err)
+	}
+
 	// Update status to RUNNING
 	run.Status = "RUNNING"
 	database.DB.Save(&run)
 
-	// NOTE: Actual dataflow execution logic is business-specific and depends on
-	// the dataflow configuration. This would typically:
-	// 1. Load dataflow nodes and edges from database
-	// 2. Execute nodes in topological order based on dependencies
-	// 3. Pass data between nodes according to edge configuration
-	// 4. Handle transformations, aggregations, and joins
-	// 5. Update run metadata with node-level execution details
-	//
-	// For now, this is a placeholder that simulates successful execution.
-	// Production implementation should integrate with DataflowExecutor service.
-	time.Sleep(2 * time.Second)
+	// Persist step status as it changes so clients can follow the run
+	onProgress := func(logs []DataflowStepLog) {
+		if b, err := json.Marshal(logs); err == nil {
+			database.DB.Model(&run).Update("logs", string(b))
+		}
+	}
+
+	if _, err := jq.dataflowRunner.Run(jq.ctx, &dataflow, steps, onProgress); err != nil {
+		errorMsg := err.Error()
+		run.Error = &errorMsg
+		database.DB.Model(&run).Update("error", errorMsg)
+		return err
+	}
 
 	// Update run record
 	now := time.Now()
-	run.Status = "COMPLETED"
-	run.CompletedAt = &now
-
-	if err := database.DB.Save(&run).Error; err != nil {
+	if err := database.DB.Model(&run).Updates(map[string]interface{}{
+		"status":       "COMPLETED",
+		"completed_at": now,
+		"error":        nil,
+	}).Error; err != nil {
 		return fmt.Errorf("failed to update run: %w", err)
 	}
 
@@ -227,8 +258,7 @@ func (jq *JobQueue) markJobFailed(job *Job, err error) {
 	switch job.Type {
 	case JobTypePipeline:
 		var execution models.JobExecution
-		if dbErr := database.DB.Where("pipeline_id = ? AND status IN ?", job.EntityID, []string{"PENDING", "PROCESSING"}).
-			Order("started_at DESC").
+		if dbErr := database.DB.Where("id = ? AND status IN ?", job.ID, []string{"PENDING", "PROCESSING"}).
 			First(&execution).Error; dbErr == nil {
 			now := time.Now()
 			execution.Status = "FAILED"
@@ -245,8 +275,7 @@ func (jq *JobQueue) markJobFailed(job *Job, err error) {
 
 	case JobTypeDataflow:
 		var run models.DataflowRun
-		if dbErr := database.DB.Where("dataflow_id = ? AND status IN ?", job.EntityID, []string{"PENDING", "RUNNING"}).
-			Order("started_at DESC").
+		if dbErr := database.DB.Where("id = ? AND status IN ?", job.ID, []string{"PENDING", "RUNNING"}).
 			First(&run).Error; dbErr == nil {
 			now := time.Now()
 			run.Status = "FAILED"
@@ -261,8 +290,8 @@ func (jq *JobQueue) markJobFailed(job *Job, err error) {
 var GlobalJobQueue *JobQueue
 
 // InitJobQueue initializes the global job queue
-func InitJobQueue(maxWorkers int) {
-	GlobalJobQueue = NewJobQueue(maxWorkers)
+func InitJobQueue(maxWorkers int, pipelineExecutor *PipelineExecutor, dataflowRunner *DataflowRunner) {
+	GlobalJobQueue = NewJobQueue(maxWorkers, pipelineExecutor, dataflowRunner)
 	GlobalJobQueue.Start()
 }
 
@@ -8,6 +8,7 @@ import (
 	"io"
 	"mime/multipart"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -385,6 +386,87 @@ func (imp *JSONImporter) ImportJSON(
 	return result, nil
 }
 
+// ReadJSONRows reads every record of a JSON document and converts values to their detected column types
+func (imp *JSONImporter) ReadJSONRows(
+	ctx context.Context,
+	r io.Reader,
+	options *JSONImportOptions,
+) ([]CSVColumn, [][]interface{}, error) {
+	if options == nil {
+		options = imp.GetDefaultOptions()
+	}
+
+	var data interface{}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	var err error
+	if options.RootPath != "" {
+		data, err = imp.navigateJSONPath(data, options.RootPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to navigate to root path: %w", err)
+		}
+	}
+
+	var records []map[string]interface{}
+	switch v := data.(type) {
+	case []interface{}:
+		records = imp.convertArrayToMaps(v, options.MaxRows)
+	case map[string]interface{}:
+		if arrayData, _ := imp.findArrayProperty(v); arrayData != nil {
+			records = imp.convertArrayToMaps(arrayData, options.MaxRows)
+		} else {
+			records = []map[string]interface{}{v}
+		}
+	default:
+		return nil, nil, errors.New("unsupported JSON structure: expected array or object")
+	}
+
+	if len(records) == 0 {
+		return nil, nil, errors.New("no data rows found in JSON")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if options.FlattenNested {
+		records = imp.flattenRows(records, options.MaxDepth, options.ArrayStrategy)
+	}
+
+	// Keep a stable column order across runs
+	columns := imp.extractColumns(records)
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	for i := range columns {
+		columns[i].Index = i
+	}
+
+	stringRows := imp.convertRowsToStrings(records, columns)
+
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.Name
+	}
+
+	sample := stringRows
+	if len(sample) > imp.sampleSize {
+		sample = sample[:imp.sampleSize]
+	}
+	csvOptions := &CSVImportOptions{
+		DetectTypes: options.DetectTypes,
+		NullValues:  options.NullValues,
+	}
+	detected := imp.csvImporter.detectColumnTypes(headers, sample, csvOptions)
+
+	rows := make([][]interface{}, len(stringRows))
+	for i, record := range stringRows {
+		rows[i] = imp.csvImporter.ConvertRecord(record, detected, csvOptions)
+	}
+
+	return detected, rows, nil
+}
+
 // ValidateJSONFile validates JSON file before import
 func (imp *JSONImporter) ValidateJSONFile(fileHeader *multipart.FileHeader) error {
 	// Check file size
@@ -0,0 +1,725 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Metric formulas are SQL expressions that may refer to other metrics by name, such as
+// "(revenue - cost) / revenue". They are parsed into an expression tree so that references
+// can be checked for cycles and expanded into SQL, and anything other than an expression,
+// such as a subquery or a second statement, is rejected.
+
+// metricNodeKind is the kind of a node of a parsed metric formula
+type metricNodeKind int
+
+const (
+	metricLiteral metricNodeKind = iota // Number, string, NULL, TRUE or FALSE
+	metricColumn                        // Column, or a metric when its name is one
+	metricCall                          // Function call
+	metricCast                          // CAST(expr AS type)
+	metricCase                          // CASE [operand] WHEN ... THEN ... [ELSE ...] END
+	metricUnary                         // -expr, NOT expr
+	metricBinary                        // Arithmetic, comparison, AND, OR
+	metricIsNull                        // expr IS [NOT] NULL
+	metricIn                            // expr [NOT] IN (list)
+	metricParen                         // Parenthesised expression, kept as written
+)
+
+// metricNode is a node of a parsed metric formula
+type metricNode struct {
+	kind     metricNodeKind
+	text     string   // Literal, operator, function name or type
+	parts    []string // Column name, split on dots
+	args     []*metricNode
+	distinct bool // COUNT(DISTINCT ...)
+	star     bool // COUNT(*)
+	negated  bool // IS NOT NULL, NOT IN
+	operand  bool // CASE with an operand, as args[0]
+	hasElse  bool // CASE with ELSE, as the last arg
+}
+
+// metricFunctions are the functions a metric formula may call
+var metricFunctions = map[string]bool{
+	"SUM": true, "AVG": true, "COUNT": true, "MIN": true, "MAX": true, "STDDEV": true, "VARIANCE": true,
+	"ROUND": true, "COALESCE": true, "NULLIF": true, "ABS": true, "CEIL": true, "CEILING": true, "FLOOR": true,
+	"GREATEST": true, "LEAST": true, "LOWER": true, "UPPER": true, "LENGTH": true,
+}
+
+// metricAggregates are the metricFunctions that aggregate rows
+var metricAggregates = map[string]bool{
+	"SUM": true, "AVG": true, "COUNT": true, "MIN": true, "MAX": true, "STDDEV": true, "VARIANCE": true,
+}
+
+// metricKeywords cannot be used as column or metric names
+var metricKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IS": true, "NULL": true, "IN": true, "TRUE": true, "FALSE": true,
+	"CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true, "AS": true, "DISTINCT": true,
+	"CAST": true, "SELECT": true, "FROM": true, "WHERE": true, "UNION": true,
+}
+
+// metricToken is a token of a metric formula
+type metricToken struct {
+	kind string // ident, number, string, symbol, end
+	text string
+}
+
+// tokenizeMetricFormula splits a formula into identifiers, numbers, strings and symbols
+func tokenizeMetricFormula(formula string) ([]metricToken, error) {
+	var tokens []metricToken
+	runes := []rune(formula)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, metricToken{kind: "ident", text: string(runes[start:i])})
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, metricToken{kind: "number", text: string(runes[start:i])})
+		case r == '\'':
+			// Quotes are escaped by doubling them
+			start := i
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string in formula")
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+			tokens = append(tokens, metricToken{kind: "string", text: string(runes[start:i])})
+		default:
+			rest := string(runes[i:])
+			if strings.HasPrefix(rest, "--") || strings.HasPrefix(rest, "/*") {
+				return nil, fmt.Errorf("comments are not allowed in formulas")
+			}
+			symbol := ""
+			for _, s := range []string{"<>", "!=", "<=", ">=", "(", ")", ",", ".", "+", "-", "*", "/", "%", "=", "<", ">"} {
+				if strings.HasPrefix(rest, s) {
+					symbol = s
+					break
+				}
+			}
+			if symbol == "" {
+				return nil, fmt.Errorf("unexpected character '%c' in formula", r)
+			}
+			i += len(symbol)
+			tokens = append(tokens, metricToken{kind: "symbol", text: symbol})
+		}
+	}
+	return append(tokens, metricToken{kind: "end"}), nil
+}
+
+// metricParser is a recursive descent parser of metric formulas
+type metricParser struct {
+	tokens []metricToken
+	pos    int
+}
+
+// parseMetricFormula parses a metric formula into an expression tree
+func parseMetricFormula(formula string) (*metricNode, error) {
+	if strings.TrimSpace(formula) == "" {
+		return nil, fmt.Errorf("formula cannot be empty")
+	}
+	tokens, err := tokenizeMetricFormula(formula)
+	if err != nil {
+		return nil, err
+	}
+	p := &metricParser{tokens: tokens}
+	node, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != "end" {
+		return nil, fmt.Errorf("unexpected '%s' in formula", p.peek().text)
+	}
+	return node, nil
+}
+
+func (p *metricParser) peek() metricToken {
+	return p.tokens[p.pos]
+}
+
+func (p *metricParser) next() metricToken {
+	token := p.tokens[p.pos]
+	if token.kind != "end" {
+		p.pos++
+	}
+	return token
+}
+
+// keyword consumes the next token if it is the given keyword
+func (p *metricParser) keyword(word string) bool {
+	if token := p.peek(); token.kind == "ident" && strings.EqualFold(token.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol consumes the next token if it is one of the given symbols, and returns it
+func (p *metricParser) symbol(symbols ...string) string {
+	if token := p.peek(); token.kind == "symbol" {
+		for _, s := range symbols {
+			if token.text == s {
+				p.pos++
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func (p *metricParser) expect(symbol string) error {
+	if p.symbol(symbol) == "" {
+		return fmt.Errorf("expected '%s' in formula", symbol)
+	}
+	return nil
+}
+
+func (p *metricParser) expression() (*metricNode, error) {
+	return p.or()
+}
+
+func (p *metricParser) or() (*metricNode, error) {
+	left, err := p.and()
+	for err == nil && p.keyword("OR") {
+		var right *metricNode
+		if right, err = p.and(); err == nil {
+			left = &metricNode{kind: metricBinary, text: "OR", args: []*metricNode{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *metricParser) and() (*metricNode, error) {
+	left, err := p.not()
+	for err == nil && p.keyword("AND") {
+		var right *metricNode
+		if right, err = p.not(); err == nil {
+			left = &metricNode{kind: metricBinary, text: "AND", args: []*metricNode{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *metricParser) not() (*metricNode, error) {
+	if p.keyword("NOT") {
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &metricNode{kind: metricUnary, text: "NOT ", args: []*metricNode{operand}}, nil
+	}
+	return p.comparison()
+}
+
+func (p *metricParser) comparison() (*metricNode, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	if op := p.symbol("=", "<>", "!=", "<=", ">=", "<", ">"); op != "" {
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return &metricNode{kind: metricBinary, text: op, args: []*metricNode{left, right}}, nil
+	}
+	if p.keyword("IS") {
+		negated := p.keyword("NOT")
+		if !p.keyword("NULL") {
+			return nil, fmt.Errorf("expected NULL after IS in formula")
+		}
+		return &metricNode{kind: metricIsNull, negated: negated, args: []*metricNode{left}}, nil
+	}
+	start := p.pos
+	negated := p.keyword("NOT")
+	if p.keyword("IN") {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		list, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		return &metricNode{kind: metricIn, negated: negated, args: append([]*metricNode{left}, list...)}, p.expect(")")
+	}
+	p.pos = start
+	return left, nil
+}
+
+func (p *metricParser) additive() (*metricNode, error) {
+	left, err := p.term()
+	for err == nil {
+		op := p.symbol("+", "-")
+		if op == "" {
+			break
+		}
+		var right *metricNode
+		if right, err = p.term(); err == nil {
+			left = &metricNode{kind: metricBinary, text: op, args: []*metricNode{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *metricParser) term() (*metricNode, error) {
+	left, err := p.unary()
+	for err == nil {
+		op := p.symbol("*", "/", "%")
+		if op == "" {
+			break
+		}
+		var right *metricNode
+		if right, err = p.unary(); err == nil {
+			left = &metricNode{kind: metricBinary, text: op, args: []*metricNode{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *metricParser) unary() (*metricNode, error) {
+	if p.symbol("-") != "" {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &metricNode{kind: metricUnary, text: "-", args: []*metricNode{operand}}, nil
+	}
+	return p.primary()
+}
+
+// list parses a comma-separated list of expressions
+func (p *metricParser) list() ([]*metricNode, error) {
+	var nodes []*metricNode
+	for {
+		node, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		if p.symbol(",") == "" {
+			return nodes, nil
+		}
+	}
+}
+
+func (p *metricParser) primary() (*metricNode, error) {
+	token := p.next()
+	switch token.kind {
+	case "number", "string":
+		return &metricNode{kind: metricLiteral, text: token.text}, nil
+	case "symbol":
+		if token.text == "(" {
+			inner, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			return &metricNode{kind: metricParen, args: []*metricNode{inner}}, p.expect(")")
+		}
+		return nil, fmt.Errorf("unexpected '%s' in formula", token.text)
+	case "end":
+		return nil, fmt.Errorf("formula ends unexpectedly")
+	}
+
+	word := strings.ToUpper(token.text)
+	switch word {
+	case "NULL", "TRUE", "FALSE":
+		return &metricNode{kind: metricLiteral, text: word}, nil
+	case "CASE":
+		return p.caseExpression()
+	case "CAST":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AS") {
+			return nil, fmt.Errorf("expected AS in CAST")
+		}
+		typeName := p.next()
+		if typeName.kind != "ident" || metricKeywords[strings.ToUpper(typeName.text)] {
+			return nil, fmt.Errorf("expected a type in CAST")
+		}
+		castType := strings.ToUpper(typeName.text)
+		if p.symbol("(") != "" {
+			// Precision and scale, such as DECIMAL(10, 2)
+			var sizes []string
+			for {
+				size := p.next()
+				if size.kind != "number" {
+					return nil, fmt.Errorf("expected a number in CAST type")
+				}
+				sizes = append(sizes, size.text)
+				if p.symbol(",") == "" {
+					break
+				}
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			castType += "(" + strings.Join(sizes, ", ") + ")"
+		}
+		return &metricNode{kind: metricCast, text: castType, args: []*metricNode{value}}, p.expect(")")
+	}
+	if metricKeywords[word] {
+		return nil, fmt.Errorf("unexpected '%s' in formula", token.text)
+	}
+
+	if p.symbol("(") != "" {
+		if !metricFunctions[word] {
+			return nil, fmt.Errorf("function not allowed in formula: %s", token.text)
+		}
+		call := &metricNode{kind: metricCall, text: word}
+		if p.symbol("*") != "" {
+			if word != "COUNT" {
+				return nil, fmt.Errorf("only COUNT accepts *")
+			}
+			call.star = true
+		} else if p.symbol(")") != "" {
+			return nil, fmt.Errorf("%s needs an argument", word)
+		} else {
+			call.distinct = p.keyword("DISTINCT")
+			args, err := p.list()
+			if err != nil {
+				return nil, err
+			}
+			call.args = args
+		}
+		return call, p.expect(")")
+	}
+
+	column := &metricNode{kind: metricColumn, parts: []string{token.text}}
+	for p.symbol(".") != "" {
+		part := p.next()
+		if part.kind != "ident" {
+			return nil, fmt.Errorf("expected a name after '.' in formula")
+		}
+		column.parts = append(column.parts, part.text)
+	}
+	return column, nil
+}
+
+func (p *metricParser) caseExpression() (*metricNode, error) {
+	node := &metricNode{kind: metricCase}
+	if !p.keyword("WHEN") {
+		operand, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		node.operand = true
+		node.args = append(node.args, operand)
+		if !p.keyword("WHEN") {
+			return nil, fmt.Errorf("expected WHEN in CASE")
+		}
+	}
+	for {
+		when, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("THEN") {
+			return nil, fmt.Errorf("expected THEN in CASE")
+		}
+		then, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		node.args = append(node.args, when, then)
+		if !p.keyword("WHEN") {
+			break
+		}
+	}
+	if p.keyword("ELSE") {
+		otherwise, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		node.hasElse = true
+		node.args = append(node.args, otherwise)
+	}
+	if !p.keyword("END") {
+		return nil, fmt.Errorf("expected END in CASE")
+	}
+	return node, nil
+}
+
+// walk calls visit for the node and all nodes below it
+func (n *metricNode) walk(visit func(*metricNode)) {
+	visit(n)
+	for _, arg := range n.args {
+		arg.walk(visit)
+	}
+}
+
+// render writes the node as SQL, replacing the columns that expand returns SQL for
+func (n *metricNode) render(expand func(*metricNode) (string, bool)) string {
+	args := make([]string, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.render(expand)
+	}
+	switch n.kind {
+	case metricColumn:
+		if sql, ok := expand(n); ok {
+			return sql
+		}
+		return strings.Join(n.parts, ".")
+	case metricCall:
+		if n.star {
+			return n.text + "(*)"
+		}
+		distinct := ""
+		if n.distinct {
+			distinct = "DISTINCT "
+		}
+		return n.text + "(" + distinct + strings.Join(args, ", ") + ")"
+	case metricCast:
+		return "CAST(" + args[0] + " AS " + n.text + ")"
+	case metricCase:
+		sql := "CASE"
+		if n.operand {
+			sql += " " + args[0]
+			args = args[1:]
+		}
+		otherwise := ""
+		if n.hasElse {
+			otherwise = " ELSE " + args[len(args)-1]
+			args = args[:len(args)-1]
+		}
+		for i := 0; i < len(args); i += 2 {
+			sql += " WHEN " + args[i] + " THEN " + args[i+1]
+		}
+		return sql + otherwise + " END"
+	case metricUnary:
+		if strings.HasPrefix(args[0], "-") {
+			return n.text + " " + args[0] // Not a comment
+		}
+		return n.text + args[0]
+	case metricBinary:
+		return args[0] + " " + n.text + " " + args[1]
+	case metricIsNull:
+		if n.negated {
+			return args[0] + " IS NOT NULL"
+		}
+		return args[0] + " IS NULL"
+	case metricIn:
+		keyword := " IN ("
+		if n.negated {
+			keyword = " NOT IN ("
+		}
+		return args[0] + keyword + strings.Join(args[1:], ", ") + ")"
+	case metricParen:
+		return "(" + args[0] + ")"
+	}
+	return n.text
+}
+
+// metricSet holds the parsed metrics of a model or workspace, which may refer to each other
+type metricSet struct {
+	nodes    map[string]*metricNode
+	refs     map[*metricNode]string // Column nodes that refer to metrics
+	expanded map[string]string
+}
+
+// metricClosure returns the formulas of the named metrics and of the metrics they refer to,
+// directly or indirectly, so that metrics can be checked and expanded without a problem with
+// an unrelated one failing them
+func metricClosure(formulas map[string]string, names []string) (map[string]string, error) {
+	closure := make(map[string]string)
+	var queue []string
+	for _, name := range names {
+		if formula, ok := formulas[name]; ok && closure[name] == "" {
+			closure[name] = formula
+			queue = append(queue, name)
+		}
+	}
+	for ; len(queue) > 0; queue = queue[1:] {
+		node, err := parseMetricFormula(closure[queue[0]])
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric '%s': %w", queue[0], err)
+		}
+		node.walk(func(n *metricNode) {
+			if n.kind != metricColumn || len(n.parts) != 1 {
+				return
+			}
+			name := n.parts[0]
+			if formula, ok := formulas[name]; ok && closure[name] == "" {
+				closure[name] = formula
+				queue = append(queue, name)
+			}
+		})
+	}
+	return closure, nil
+}
+
+// newMetricSet parses formulas by metric name and checks that their references to each other
+// resolve without cycles, that every metric aggregates, and that no aggregate is nested in
+// another. Outside aggregate functions, a name refers to a metric if there is one, so that
+// "SUM(revenue)" can define a metric named revenue. Columns qualified with a table must be of
+// table, when one is given, and columns must be in columns, when it is not nil.
+func newMetricSet(formulas map[string]string, table string, columns []string) (*metricSet, error) {
+	set := &metricSet{nodes: make(map[string]*metricNode), refs: make(map[*metricNode]string), expanded: make(map[string]string)}
+	names := make([]string, 0, len(formulas))
+	for name := range formulas {
+		if metricKeywords[strings.ToUpper(name)] {
+			return nil, fmt.Errorf("metric name is a reserved word: %s", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		node, err := parseMetricFormula(formulas[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric '%s': %w", name, err)
+		}
+		set.nodes[name] = node
+	}
+
+	for _, name := range names {
+		aggregates, err := set.resolve(set.nodes[name], false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric '%s': %w", name, err)
+		}
+		if !aggregates {
+			return nil, fmt.Errorf("invalid metric '%s': formula must aggregate, with SUM, AVG, COUNT, MIN or MAX, or refer to another metric", name)
+		}
+	}
+	if err := set.checkCycles(names); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, column := range columns {
+		known[strings.ToLower(column)] = true
+	}
+	for _, name := range names {
+		var err error
+		set.nodes[name].walk(func(n *metricNode) {
+			if n.kind != metricColumn || err != nil || set.refs[n] != "" {
+				return
+			}
+			if len(n.parts) > 1 && table != "" && !strings.EqualFold(strings.Join(n.parts[:len(n.parts)-1], "."), table) {
+				err = fmt.Errorf("invalid metric '%s': column %s is not in table %s", name, strings.Join(n.parts, "."), table)
+			} else if columns != nil && !known[strings.ToLower(n.parts[len(n.parts)-1])] {
+				err = fmt.Errorf("invalid metric '%s': unknown column %s", name, strings.Join(n.parts, "."))
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// resolve records the metrics a node refers to, and reports whether it aggregates rows,
+// directly or through those metrics. Aggregates within aggregates are an error.
+func (s *metricSet) resolve(n *metricNode, inAggregate bool) (bool, error) {
+	if n.kind == metricColumn && len(n.parts) == 1 && s.nodes[n.parts[0]] != nil && !inAggregate {
+		s.refs[n] = n.parts[0]
+		return true, nil
+	}
+	isAggregate := n.kind == metricCall && metricAggregates[n.text]
+	if isAggregate && inAggregate {
+		return false, fmt.Errorf("aggregate functions cannot be nested")
+	}
+	result := isAggregate
+	for _, arg := range n.args {
+		aggregates, err := s.resolve(arg, inAggregate || isAggregate)
+		if err != nil {
+			return false, err
+		}
+		result = result || aggregates
+	}
+	return result, nil
+}
+
+// dependencies returns the metrics a metric refers to, in order
+func (s *metricSet) dependencies(name string) []string {
+	var deps []string
+	s.nodes[name].walk(func(n *metricNode) {
+		if ref := s.refs[n]; ref != "" && !containsString(deps, ref) {
+			deps = append(deps, ref)
+		}
+	})
+	return deps
+}
+
+// checkCycles reports a metric that refers back to itself, directly or through others
+func (s *metricSet) checkCycles(names []string) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			start := 0
+			for path[start] != name {
+				start++
+			}
+			return fmt.Errorf("metric '%s' refers to itself: %s", name, strings.Join(append(path[start:], name), " -> "))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range s.dependencies(name) {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expand returns the SQL of a metric, with the metrics it refers to expanded in parentheses
+func (s *metricSet) expand(name string) (string, error) {
+	if sql, ok := s.expanded[name]; ok {
+		return sql, nil
+	}
+	node := s.nodes[name]
+	if node == nil {
+		return "", fmt.Errorf("metric not found: %s", name)
+	}
+	// References resolve without cycles, as checked by newMetricSet
+	sql := node.render(func(n *metricNode) (string, bool) {
+		ref := s.refs[n]
+		if ref == "" {
+			return "", false
+		}
+		expanded, _ := s.expand(ref)
+		return "(" + expanded + ")", true
+	})
+	s.expanded[name] = sql
+	return sql, nil
+}
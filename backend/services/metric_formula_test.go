@@ -0,0 +1,133 @@
+package services
+
+import (
+	"insight-engine-backend/models"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestDerivedMetrics tests parsing metric formulas, expanding metrics that refer to other
+// metrics and rejecting cycles
+func TestDerivedMetrics(t *testing.T) {
+	set, err := newMetricSet(map[string]string{
+		"revenue":      "SUM(revenue)", // A column with the metric's name
+		"cost":         "sum(orders.cost)",
+		"gross_margin": "(revenue - cost) / NULLIF(revenue, 0)",
+		"margin_pct":   "ROUND(gross_margin * 100, 1)",
+		"paid_share":   "COUNT(CASE WHEN status IN ('paid', 'shipped') THEN 1 END) * 1.0 / COUNT(*)",
+	}, "orders", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for name, expected := range map[string]string{
+		"revenue":    "SUM(revenue)",
+		"margin_pct": "ROUND((((SUM(revenue)) - (SUM(orders.cost))) / NULLIF((SUM(revenue)), 0)) * 100, 1)",
+		"paid_share": "COUNT(CASE WHEN status IN ('paid', 'shipped') THEN 1 END) * 1.0 / COUNT(*)",
+	} {
+		if sql, err := set.expand(name); err != nil || sql != expected {
+			t.Errorf("%s: expected %q, got %q (%v)", name, expected, sql, err)
+		}
+	}
+
+	for formulas, expected := range map[*map[string]string]string{
+		{"a": "b + 1", "b": "c * 2", "c": "a / SUM(x)"}: "a -> b -> c -> a",
+		{"a": "SUM(x) + a"}:                             "a -> a",
+		{"a": "SUM(MAX(x))"}:                            "cannot be nested",
+		{"a": "x + 1"}:                                  "must aggregate",
+		{"a": "SUM(x); DROP TABLE users"}:               "unexpected character",
+		{"a": "SUM(x) -- comment"}:                      "comments",
+		{"a": "SUM((SELECT x FROM users))"}:             "unexpected 'SELECT'",
+		{"a": "PG_SLEEP(10) + SUM(x)"}:                  "function not allowed",
+		{"a": "SUM(customers.x)"}:                       "not in table orders",
+	} {
+		if _, err := newMetricSet(*formulas, "orders", nil); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%v: expected an error containing %q, got %v", *formulas, expected, err)
+		}
+	}
+	if _, err := newMetricSet(map[string]string{"a": "SUM(amount)"}, "orders", []string{"id", "total"}); err == nil {
+		t.Error("Expected an unknown column to be rejected")
+	}
+
+	// Derived metrics run in semantic queries
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.AutoMigrate(&models.SemanticModel{}, &models.SemanticDimension{}, &models.SemanticMetric{}, &models.SemanticRelationship{},
+		&models.ModelDefinition{}, &models.MetricDefinition{})
+	db.Exec("CREATE TABLE orders (id INTEGER, region TEXT, amount INTEGER, cost INTEGER)")
+	db.Exec("INSERT INTO orders VALUES (1, 'EU', 100, 60), (2, 'EU', 100, 90), (3, 'US', 50, 10)")
+
+	service := NewSemanticLayerService(db)
+	model := &models.SemanticModel{ID: "m-orders", Name: "orders", DataSourceID: "conn-1", Table: "orders", WorkspaceID: "ws-1", CreatedBy: "user-1",
+		Dimensions: []models.SemanticDimension{{ID: "d1", Name: "region", ColumnName: "region"}},
+		Metrics: []models.SemanticMetric{
+			{ID: "x1", Name: "revenue", Formula: "SUM(amount)"},
+			{ID: "x2", Name: "cost", Formula: "SUM(cost)"},
+			{ID: "x3", Name: "gross_margin", Formula: "(revenue - cost) * 1.0 / revenue"},
+		},
+	}
+	if err := service.CreateModel(model); err != nil {
+		t.Fatalf("Failed to create model: %v", err)
+	}
+	sql, args, err := service.CompileSemanticQuery(model, SemanticQuery{
+		Dimensions: []string{"region"},
+		Metrics:    []string{"gross_margin"},
+		OrderBy:    []SemanticOrder{{Field: "region"}},
+	}, DialectSQLite)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	var rows []struct {
+		Region      string
+		GrossMargin float64
+	}
+	if err := db.Raw(sql, args...).Scan(&rows).Error; err != nil || len(rows) != 2 || rows[0].GrossMargin != 0.25 || rows[1].GrossMargin != 0.8 {
+		t.Errorf("Unexpected margins %+v (%v)\n%s", rows, err, sql)
+	}
+
+	// Metrics saved as raw SQL before formulas were parsed only fail the queries that use them
+	db.Create(&models.SemanticMetric{ID: "x4", ModelID: model.ID, Name: "median", Formula: "PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY amount)"})
+	model, _ = service.GetModelByID(model.ID)
+	if _, _, err := service.CompileSemanticQuery(model, SemanticQuery{Metrics: []string{"gross_margin"}}, DialectSQLite); err != nil {
+		t.Errorf("Expected a query of valid metrics to compile, got %v", err)
+	}
+	if _, _, err := service.TranslateSemanticQuery(model, []string{"region"}, []string{"revenue"}, nil, 10, DialectSQLite); err != nil {
+		t.Errorf("Expected a query of valid metrics to translate, got %v", err)
+	}
+	if _, _, err := service.CompileSemanticQuery(model, SemanticQuery{Metrics: []string{"median"}}, DialectSQLite); err == nil {
+		t.Error("Expected a query of the raw SQL metric to fail")
+	}
+	if invalid := service.InvalidMetrics(model); len(invalid) != 1 || invalid[0].Name != "median" {
+		t.Errorf("Expected median to be reported, got %+v", invalid)
+	}
+	if err := service.CreateMetric(&models.SemanticMetric{ID: "x5", ModelID: model.ID, Name: "orders", Formula: "COUNT(*)"}); err != nil {
+		t.Errorf("Expected a metric to be added next to the raw SQL metric, got %v", err)
+	}
+	if err := service.DeleteMetric("x4"); err != nil {
+		t.Errorf("Expected the raw SQL metric to be deleted, got %v", err)
+	}
+
+	// Workspace metric definitions refer to each other the same way
+	modeling := NewModelingService(db)
+	define := func(id, name, formula string) error {
+		return modeling.CreateMetricDefinition(&models.MetricDefinition{ID: id, Name: name, Formula: formula, DataType: "number", WorkspaceID: "ws-1", CreatedBy: "user-1"})
+	}
+	if err := define("md1", "signups", "COUNT(DISTINCT user_id)"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := define("md2", "conversion_rate", "COUNT(DISTINCT CASE WHEN converted THEN user_id END) * 1.0 / signups"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	signups, _ := modeling.GetMetricDefinition("md1")
+	signups.Formula = "conversion_rate * 2"
+	if err := modeling.UpdateMetricDefinition(signups); err == nil || !strings.Contains(err.Error(), "refers to itself") {
+		t.Errorf("Expected a cycle to be rejected, got %v", err)
+	}
+	if err := modeling.DeleteMetricDefinition("md1"); err == nil {
+		t.Error("Expected deleting a metric in use to be rejected")
+	}
+}
@@ -1,13 +1,19 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"insight-engine-backend/models"
-	"strings"
 
 	"gorm.io/gorm"
 )
 
+// ErrDefinitionMigrated is returned when changing a definition that has been migrated to the
+// semantic layer, where it must be changed instead
+var ErrDefinitionMigrated = errors.New("definition has been migrated to the semantic layer")
+
+// ModelingService manages the model and metric definitions of the modeling API. Semantic models
+// supersede them: MigrateModelDefinitions converts them, after which they are read-only here.
 type ModelingService struct {
 	db *gorm.DB
 }
@@ -60,6 +66,10 @@ func (s *ModelingService) CreateModelDefinition(model *models.ModelDefinition) e
 }
 
 func (s *ModelingService) UpdateModelDefinition(model *models.ModelDefinition) error {
+	if model.SemanticModelID != nil {
+		return fmt.Errorf("%w: update semantic model %s instead", ErrDefinitionMigrated, *model.SemanticModelID)
+	}
+
 	// Validate type
 	validTypes := map[string]bool{"table": true, "view": true, "query": true}
 	if !validTypes[model.Type] {
@@ -117,23 +127,30 @@ func (s *ModelingService) CreateMetricDefinition(metric *models.MetricDefinition
 		}
 	}
 
-	// Validate formula
-	if err := s.ValidateFormula(metric.Formula); err != nil {
-		return err
-	}
-
 	// Validate model_id if provided
 	if metric.ModelID != nil && *metric.ModelID != "" {
 		var model models.ModelDefinition
 		if err := s.db.Where("id = ?", *metric.ModelID).First(&model).Error; err != nil {
 			return fmt.Errorf("model not found: %s", *metric.ModelID)
 		}
+		if model.SemanticModelID != nil {
+			return fmt.Errorf("%w: add the metric to semantic model %s instead", ErrDefinitionMigrated, *model.SemanticModelID)
+		}
+	}
+
+	// Validate formula
+	if err := s.validateMetricFormula(metric); err != nil {
+		return err
 	}
 
 	return s.db.Create(metric).Error
 }
 
 func (s *ModelingService) UpdateMetricDefinition(metric *models.MetricDefinition) error {
+	if metric.SemanticMetricID != nil {
+		return fmt.Errorf("%w: update semantic metric %s instead", ErrDefinitionMigrated, *metric.SemanticMetricID)
+	}
+
 	// Validate data type
 	validDataTypes := map[string]bool{
 		"number": true, "currency": true, "percentage": true,
@@ -144,7 +161,7 @@ func (s *ModelingService) UpdateMetricDefinition(metric *models.MetricDefinition
 	}
 
 	// Validate formula
-	if err := s.ValidateFormula(metric.Formula); err != nil {
+	if err := s.validateMetricFormula(metric); err != nil {
 		return err
 	}
 
@@ -152,46 +169,55 @@ func (s *ModelingService) UpdateMetricDefinition(metric *models.MetricDefinition
 }
 
 func (s *ModelingService) DeleteMetricDefinition(id string) error {
-	return s.db.Delete(&models.MetricDefinition{}, "id = ?", id).Error
-}
-
-// ValidateFormula validates metric formula for security
-func (s *ModelingService) ValidateFormula(formula string) error {
-	if formula == "" {
-		return fmt.Errorf("formula cannot be empty")
+	metric, err := s.GetMetricDefinition(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
 	}
 
-	// Check for allowed aggregation functions
-	allowedFunctions := []string{
-		"SUM", "AVG", "COUNT", "MIN", "MAX",
-		"COUNT(DISTINCT", "ROUND", "CAST", "COALESCE",
+	// Metrics that refer to this one would no longer resolve
+	var workspaceMetrics []models.MetricDefinition
+	if err := s.db.Where("workspace_id = ? AND id <> ?", metric.WorkspaceID, metric.ID).Find(&workspaceMetrics).Error; err != nil {
+		return fmt.Errorf("failed to load metrics: %w", err)
 	}
-
-	upperFormula := strings.ToUpper(formula)
-	hasValidFunction := false
-	for _, fn := range allowedFunctions {
-		if strings.Contains(upperFormula, fn) {
-			hasValidFunction = true
-			break
+	for _, other := range workspaceMetrics {
+		set, err := newMetricSet(map[string]string{other.Name: other.Formula, metric.Name: metric.Formula}, "", nil)
+		if err == nil && containsString(set.dependencies(other.Name), metric.Name) {
+			return fmt.Errorf("%w: %s is used by %s", ErrMetricInUse, metric.Name, other.Name)
 		}
 	}
 
-	if !hasValidFunction {
-		return fmt.Errorf("formula must contain a valid aggregation function (SUM, AVG, COUNT, MIN, MAX)")
+	return s.db.Delete(&models.MetricDefinition{}, "id = ?", id).Error
+}
+
+// validateMetricFormula validates the formula of a metric definition, which may refer to other
+// metrics of the workspace by name, and checks that those references do not form a cycle
+func (s *ModelingService) validateMetricFormula(metric *models.MetricDefinition) error {
+	var workspaceMetrics []models.MetricDefinition
+	if err := s.db.Where("workspace_id = ? AND id <> ?", metric.WorkspaceID, metric.ID).Find(&workspaceMetrics).Error; err != nil {
+		return fmt.Errorf("failed to load metrics: %w", err)
+	}
+	byName := make(map[string]string, len(workspaceMetrics)+1)
+	for _, m := range workspaceMetrics {
+		byName[m.Name] = m.Formula
 	}
+	byName[metric.Name] = metric.Formula
 
-	// Check for dangerous keywords
-	dangerousKeywords := []string{
-		"DROP", "DELETE", "UPDATE", "INSERT", "ALTER",
-		"CREATE", "EXEC", "EXECUTE", "TRUNCATE", "GRANT",
-		"REVOKE", "MERGE", "REPLACE",
+	// Only the metrics this one refers to, directly or indirectly, are checked, so that a
+	// metric is not rejected for a problem with an unrelated one
+	formulas, err := metricClosure(byName, []string{metric.Name})
+	if err != nil {
+		return err
 	}
 
-	for _, keyword := range dangerousKeywords {
-		if strings.Contains(upperFormula, keyword) {
-			return fmt.Errorf("formula contains forbidden keyword: %s", keyword)
+	table := ""
+	if metric.ModelID != nil && *metric.ModelID != "" {
+		var model models.ModelDefinition
+		if err := s.db.Where("id = ?", *metric.ModelID).First(&model).Error; err == nil && model.Type != "query" {
+			table = model.SourceTable
 		}
 	}
-
-	return nil
+	_, err = newMetricSet(formulas, table, nil)
+	return err
 }
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// PDFDocument is a minimal PDF 1.4 writer for text, lines and filled rectangles.
+// It uses the standard Helvetica fonts, so no font files need to be embedded.
+// Coordinates are in points with the origin at the top-left corner of the page.
+type PDFDocument struct {
+	width, height float64
+	title         string
+	pages         []*bytes.Buffer
+	current       int
+}
+
+// pdfFont is one of the two standard fonts every PDF reader provides
+type pdfFont struct {
+	resource string
+	baseFont string
+	widths   [95]int // Glyph widths for ASCII 32-126 in 1/1000 em
+}
+
+var (
+	pdfHelvetica = pdfFont{
+		resource: "F1",
+		baseFont: "Helvetica",
+		widths: [95]int{
+			278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+			556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+			1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+			667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+			333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+			556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+		},
+	}
+	pdfHelveticaBold = pdfFont{
+		resource: "F2",
+		baseFont: "Helvetica-Bold",
+		widths: [95]int{
+			278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+			556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+			975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+			667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+			333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+			611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+		},
+	}
+)
+
+// NewPDFDocument creates an empty document whose pages are width x height points
+func NewPDFDocument(width, height float64, title string) *PDFDocument {
+	return &PDFDocument{width: width, height: height, title: title, current: -1}
+}
+
+// Width returns the page width in points
+func (d *PDFDocument) Width() float64 { return d.width }
+
+// Height returns the page height in points
+func (d *PDFDocument) Height() float64 { return d.height }
+
+// PageCount returns the number of pages
+func (d *PDFDocument) PageCount() int { return len(d.pages) }
+
+// AddPage appends a page and makes it current
+func (d *PDFDocument) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.current = len(d.pages) - 1
+}
+
+// SetPage makes an existing page current, e.g. to add footers once the page count is known
+func (d *PDFDocument) SetPage(index int) {
+	if index >= 0 && index < len(d.pages) {
+		d.current = index
+	}
+}
+
+func (d *PDFDocument) out(format string, args ...interface{}) {
+	if d.current < 0 {
+		d.AddPage()
+	}
+	fmt.Fprintf(d.pages[d.current], format+"\n", args...)
+}
+
+// SetFillColor sets the color of text and filled shapes (0-255 components)
+func (d *PDFDocument) SetFillColor(r, g, b int) {
+	d.out("%.3f %.3f %.3f rg", float64(r)/255, float64(g)/255, float64(b)/255)
+}
+
+// SetStrokeColor sets the color of lines and rectangle outlines (0-255 components)
+func (d *PDFDocument) SetStrokeColor(r, g, b int) {
+	d.out("%.3f %.3f %.3f RG", float64(r)/255, float64(g)/255, float64(b)/255)
+}
+
+// Text draws a single line of text whose baseline is at y
+func (d *PDFDocument) Text(x, y, size float64, bold bool, text string) {
+	font := pdfFontFor(bold)
+	d.out("BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET", font.resource, size, x, d.height-y, pdfEscape(text))
+}
+
+// RotatedText draws text rotated counter-clockwise by degrees around its starting point
+func (d *PDFDocument) RotatedText(x, y, size, degrees float64, bold bool, text string) {
+	font := pdfFontFor(bold)
+	cos, sin := pdfRotation(degrees)
+	d.out("BT /%s %.2f Tf %.4f %.4f %.4f %.4f %.2f %.2f Tm (%s) Tj ET",
+		font.resource, size, cos, sin, -sin, cos, x, d.height-y, pdfEscape(text))
+}
+
+// Line draws a straight line
+func (d *PDFDocument) Line(x1, y1, x2, y2, width float64) {
+	d.out("%.2f w %.2f %.2f m %.2f %.2f l S", width, x1, d.height-y1, x2, d.height-y2)
+}
+
+// Rect draws a rectangle whose top-left corner is at x, y
+func (d *PDFDocument) Rect(x, y, w, h float64, fill bool) {
+	op := "S"
+	if fill {
+		op = "f"
+	}
+	d.out("%.2f %.2f %.2f %.2f re %s", x, d.height-y-h, w, h, op)
+}
+
+// TextWidth returns the width of text in points
+func (d *PDFDocument) TextWidth(text string, size float64, bold bool) float64 {
+	font := pdfFontFor(bold)
+	total := 0
+	for _, b := range pdfEncode(text) {
+		if b >= 32 && b <= 126 {
+			total += font.widths[b-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Truncate shortens text with an ellipsis so that it fits in width points
+func (d *PDFDocument) Truncate(text string, size float64, bold bool, width float64) string {
+	if d.TextWidth(text, size, bold) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := string(runes) + "..."
+		if d.TextWidth(candidate, size, bold) <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// WrapText splits text into lines no wider than width points, breaking on spaces
+func (d *PDFDocument) WrapText(text string, size float64, bold bool, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := words[0]
+		for _, word := range words[1:] {
+			if d.TextWidth(line+" "+word, size, bold) <= width {
+				line += " " + word
+				continue
+			}
+			lines = append(lines, d.Truncate(line, size, bold, width))
+			line = word
+		}
+		lines = append(lines, d.Truncate(line, size, bold, width))
+	}
+	return lines
+}
+
+// WriteTo writes the document, compressing page content with the given zlib level
+func (d *PDFDocument) WriteTo(w io.Writer, compressionLevel int) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4: catalog, page tree, fonts. Pages start at object 5 as page/content pairs.
+	const firstPage = 5
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	for _, font := range []pdfFont{pdfHelvetica, pdfHelveticaBold} {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", font.baseFont))
+	}
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			d.width, d.height, firstPage+2*i+1))
+
+		content := page.Bytes()
+		filter := ""
+		if compressionLevel != zlib.NoCompression {
+			var compressed bytes.Buffer
+			zw, err := zlib.NewWriterLevel(&compressed, compressionLevel)
+			if err != nil {
+				return 0, err
+			}
+			if _, err := zw.Write(content); err != nil {
+				return 0, err
+			}
+			if err := zw.Close(); err != nil {
+				return 0, err
+			}
+			content = compressed.Bytes()
+			filter = " /Filter /FlateDecode"
+		}
+		object(fmt.Sprintf("<< /Length %d%s >>\nstream\n%s\nendstream", len(content), filter, content))
+	}
+
+	object(fmt.Sprintf("<< /Title (%s) /Producer (InsightEngine) /CreationDate (D:%s) >>",
+		pdfEscape(d.title), time.Now().UTC().Format("20060102150405Z")))
+	info := len(offsets)
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, info, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+func pdfFontFor(bold bool) pdfFont {
+	if bold {
+		return pdfHelveticaBold
+	}
+	return pdfHelvetica
+}
+
+func pdfRotation(degrees float64) (float64, float64) {
+	radians := degrees * math.Pi / 180
+	return math.Cos(radians), math.Sin(radians)
+}
+
+// pdfEncode maps text to WinAnsi bytes; characters outside Latin-1 become '?'
+func pdfEncode(text string) []byte {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			encoded = append(encoded, ' ')
+		case r >= 32 && r <= 126, r >= 0xA0 && r <= 0xFF:
+			encoded = append(encoded, byte(r))
+		case r < 32:
+			// Drop control characters
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+	return encoded
+}
+
+// pdfEscape encodes text as the contents of a PDF literal string
+func pdfEscape(text string) string {
+	var sb strings.Builder
+	for _, b := range pdfEncode(text) {
+		if b == '(' || b == ')' || b == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(b)
+	}
+	return sb.String()
+}
@@ -0,0 +1,807 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"insight-engine-backend/models"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Pipeline source kinds resolved from Pipeline.SourceType
+const (
+	pipelineSourceDatabase = "DATABASE"
+	pipelineSourceREST     = "REST"
+	pipelineSourceFile     = "FILE"
+)
+
+// validTableName restricts user supplied tables to [schema.]name identifiers
+var validTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// validWorkspaceTableName restricts internal database tables to plain names inside the workspace schema
+var validWorkspaceTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// PipelineSourceConfig is the decoded Pipeline.SourceConfig.
+// REST sources additionally accept every RESTConnectorConfig field.
+type PipelineSourceConfig struct {
+	ConnectionID string `json:"connectionId"` // DATABASE: saved connection (empty = workspace tables)
+	TableName    string `json:"tableName"`    // DATABASE: table to extract when no query is given
+	Query        string `json:"query"`        // DATABASE: custom extraction query (requires connectionId)
+	Endpoint     string `json:"endpoint"`     // REST: shorthand for baseUrl
+	MaxPages     int    `json:"maxPages"`     // REST: pages to follow when paginated (default 10)
+	FilePath     string `json:"filePath"`     // FILE: path relative to the workspace's upload directory
+	Format       string `json:"format"`       // FILE: csv, json or excel (default from extension)
+	Delimiter    string `json:"delimiter"`    // FILE: CSV delimiter
+	HasHeader    *bool  `json:"hasHeader"`    // FILE: CSV/Excel header row (default true)
+	SkipRows     int    `json:"skipRows"`     // FILE: CSV/Excel rows to skip before the header
+	SheetName    string `json:"sheetName"`    // FILE: Excel sheet (default active sheet)
+	RootPath     string `json:"rootPath"`     // FILE: JSON path to the record array
+
+	MaxRows int `json:"-"` // Rows to read at most (0 = all)
+}
+
+// PipelineDestinationConfig is the decoded Pipeline.DestinationConfig
+type PipelineDestinationConfig struct {
+	ConnectionID string `json:"connectionId"` // External destination (empty = workspace schema)
+	TableName    string `json:"tableName"`
+	WriteMode    string `json:"writeMode"` // APPEND (default), OVERWRITE
+}
+
+// PipelineRunLog collects the per-stage log lines stored on JobExecution.Logs
+type PipelineRunLog struct {
+	Lines []string
+}
+
+// Addf appends a "[STAGE] message" line
+func (l *PipelineRunLog) Addf(stage, format string, args ...interface{}) {
+	l.Lines = append(l.Lines, fmt.Sprintf("[%s] %s", stage, fmt.Sprintf(format, args...)))
+}
+
+// JSON encodes the log lines for the JobExecution.Logs jsonb column
+func (l *PipelineRunLog) JSON() *string {
+	b, err := json.Marshal(l.Lines)
+	if err != nil {
+		return nil
+	}
+	s := string(b)
+	return &s
+}
+
+// PipelineRunResult summarises a finished pipeline run
+type PipelineRunResult struct {
+	RowsExtracted    int    `json:"rowsExtracted"`
+	RowsLoaded       int    `json:"rowsLoaded"`
+	DestinationTable string `json:"destinationTable"`
+}
+
+// PipelineExecutor runs pipeline definitions end to end: extract, transform and load
+type PipelineExecutor struct {
+	db            *gorm.DB
+	queryExecutor *QueryExecutor
+	restConnector *RESTConnector
+	csvImporter   *CSVImporter
+	excelImporter *ExcelImporter
+	jsonImporter  *JSONImporter
+	uploadDir     string
+}
+
+// NewPipelineExecutor creates a new pipeline executor
+func NewPipelineExecutor(db *gorm.DB, queryExecutor *QueryExecutor) *PipelineExecutor {
+	return &PipelineExecutor{
+		db:            db,
+		queryExecutor: queryExecutor,
+		restConnector: NewRESTConnector(),
+		csvImporter:   NewCSVImporter(),
+		excelImporter: NewExcelImporter(),
+		jsonImporter:  NewJSONImporter(),
+		uploadDir:     getEnvOrDefault("UPLOAD_DIR", "./uploads"),
+	}
+}
+
+// Run executes a pipeline. ETL pipelines transform rows in memory before loading;
+// ELT pipelines load raw rows into a staging table and run the steps inside the destination.
+// Quality rules are checked against the rows about to be loaded.
+func (e *PipelineExecutor) Run(ctx context.Context, pipeline *models.Pipeline, executionID string, runLog *PipelineRunLog) (*PipelineRunResult, error) {
+	mode := strings.ToUpper(pipeline.Mode)
+	if mode == "" {
+		mode = "ELT"
+	}
+	runLog.Addf("INFO", "Pipeline %q running in %s mode", pipeline.Name, mode)
+
+	steps, err := ParseTransformationSteps(pipeline.TransformationSteps)
+	if err != nil {
+		return nil, err
+	}
+
+	var dest PipelineDestinationConfig
+	if pipeline.DestinationConfig != nil && *pipeline.DestinationConfig != "" {
+		if err := json.Unmarshal([]byte(*pipeline.DestinationConfig), &dest); err != nil {
+			return nil, fmt.Errorf("invalid destination config: %w", err)
+		}
+	}
+
+	// SQL steps are arbitrary statements, which must never run against the application's database
+	if mode == "ELT" && dest.ConnectionID == "" {
+		for i, step := range steps {
+			if step.Type == "SQL" {
+				return nil, fmt.Errorf("transformation step %d (SQL): SQL steps require a destination connection", i+1)
+			}
+		}
+	}
+
+	// 1. Extract
+	start := time.Now()
+	ds, err := e.Extract(ctx, pipeline.WorkspaceID, pipeline.SourceType, pipeline.SourceConfig, 0)
+	if err != nil {
+		runLog.Addf("EXTRACT", "Failed: %v", err)
+		return nil, err
+	}
+	result := &PipelineRunResult{RowsExtracted: len(ds.Rows)}
+	runLog.Addf("EXTRACT", "Extracted %d rows (%d columns) from %s source in %dms",
+		len(ds.Rows), len(ds.Columns), pipeline.SourceType, time.Since(start).Milliseconds())
+
+	// 2. Transform (ETL)
+	if mode == "ETL" {
+		if err := e.transformInMemory(ds, steps, runLog); err != nil {
+			return result, err
+		}
+	}
+
+	loader, table, err := e.destination(ctx, pipeline, &dest)
+	if err != nil {
+		runLog.Addf("LOAD", "Failed: %v", err)
+		return result, err
+	}
+	result.DestinationTable = table
+
+	// 3. Quality checks
+	if err := e.checkQuality(ctx, pipeline.ID, executionID, ds, loader, table, runLog); err != nil {
+		return result, err
+	}
+
+	// 4. Load (ELT steps run on a staging copy that replaces the plain load)
+	writeMode := strings.ToUpper(dest.WriteMode)
+	if writeMode == "" {
+		writeMode = LoadModeAppend
+	}
+
+	start = time.Now()
+	columns := InferColumnTypes(ds.Columns, ds.Rows)
+	var loaded int
+	if mode == "ELT" && len(steps) > 0 {
+		loaded, err = e.loadTransformed(ctx, loader, table, executionID, columns, ds.PositionalRows(), writeMode, steps, runLog)
+	} else {
+		loaded, err = loader.Load(ctx, table, columns, ds.PositionalRows(), writeMode)
+	}
+	if err != nil {
+		runLog.Addf("LOAD", "Failed: %v", err)
+		return result, err
+	}
+	result.RowsLoaded = loaded
+	runLog.Addf("LOAD", "Loaded %d rows into %s (%s) in %dms", loaded, table, writeMode, time.Since(start).Milliseconds())
+
+	return result, nil
+}
+
+// checkQuality evaluates the pipeline's quality rules and stores per-rule counts.
+// Rows breaking a FAIL-severity rule are moved to "<table>_quarantine" and the load is aborted.
+func (e *PipelineExecutor) checkQuality(
+	ctx context.Context,
+	pipelineID string,
+	executionID string,
+	ds *PipelineDataset,
+	loader *TableLoader,
+	table string,
+	runLog *PipelineRunLog,
+) error {
+	var rules []models.QualityRule
+	if err := e.db.Where(&models.QualityRule{PipelineID: pipelineID}).Find(&rules).Error; err != nil {
+		return fmt.Errorf("failed to load quality rules: %w", err)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	report, err := EvaluateQualityRules(ds, rules)
+	if err != nil {
+		runLog.Addf("QUALITY", "%v", err)
+		return err
+	}
+
+	for i := range report.Results {
+		result := &report.Results[i]
+		result.ExecutionID = executionID
+		runLog.Addf("QUALITY", "%s on %s (%s): %d passed, %d failed",
+			result.RuleType, result.Column, result.Severity, result.RowsPassed, result.RowsFailed)
+	}
+	if err := e.saveQualityResults(executionID, report.Results); err != nil {
+		LogWarn("pipeline_quality_save", "Failed to store quality check results", map[string]interface{}{
+			"execution_id": executionID,
+			"error":        err,
+		})
+	}
+
+	if !report.Failed() {
+		return nil
+	}
+
+	failedRows := report.FailedRows(len(ds.Rows))
+	quarantineTable := table + "_quarantine"
+	if err := e.quarantine(ctx, loader, quarantineTable, executionID, ds, failedRows, report.Violations); err != nil {
+		runLog.Addf("QUALITY", "Failed to quarantine %d rows: %v", len(failedRows), err)
+		return fmt.Errorf("%w: %d rows broke FAIL rules and could not be quarantined: %v",
+			ErrQualityCheckFailed, len(failedRows), err)
+	}
+
+	runLog.Addf("QUALITY", "Moved %d rows to %s, load aborted", len(failedRows), quarantineTable)
+	return fmt.Errorf("%w: %d rows broke FAIL rules and were moved to %s",
+		ErrQualityCheckFailed, len(failedRows), quarantineTable)
+}
+
+// saveQualityResults replaces the stored results of an execution (retries re-evaluate the rules)
+func (e *PipelineExecutor) saveQualityResults(executionID string, results []models.QualityCheckResult) error {
+	return e.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("execution_id = ?", executionID).Delete(&models.QualityCheckResult{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&results).Error
+	})
+}
+
+// quarantine appends the given rows to the quarantine table together with their violations
+func (e *PipelineExecutor) quarantine(
+	ctx context.Context,
+	loader *TableLoader,
+	table string,
+	executionID string,
+	ds *PipelineDataset,
+	rowIndexes []int,
+	violations map[int][]string,
+) error {
+	maps := make([]map[string]interface{}, len(rowIndexes))
+	for i, idx := range rowIndexes {
+		maps[i] = ds.Rows[idx]
+	}
+
+	columns := InferColumnTypes(ds.Columns, maps)
+	columns = append(columns,
+		TempTableColumn{Name: "_execution_id", DataType: "text", Nullable: false, Index: len(columns)},
+		TempTableColumn{Name: "_violations", DataType: "text", Nullable: true, Index: len(columns) + 1},
+		TempTableColumn{Name: "_quarantined_at", DataType: "timestamp", Nullable: false, Index: len(columns) + 2},
+	)
+
+	now := time.Now()
+	rows := make([][]interface{}, len(rowIndexes))
+	for i, idx := range rowIndexes {
+		values := make([]interface{}, 0, len(columns))
+		for _, col := range ds.Columns {
+			values = append(values, ds.Rows[idx][col])
+		}
+		values = append(values, executionID, strings.Join(violations[idx], "; "), now)
+		rows[i] = values
+	}
+
+	_, err := loader.Load(ctx, table, columns, rows, LoadModeAppend)
+	return err
+}
+
+// transformInMemory applies ETL steps to the extracted dataset
+func (e *PipelineExecutor) transformInMemory(ds *PipelineDataset, steps []TransformationStep, runLog *PipelineRunLog) error {
+	if len(steps) == 0 {
+		runLog.Addf("TRANSFORM", "No rules defined. Skipping.")
+		return nil
+	}
+
+	for i, step := range steps {
+		start := time.Now()
+		before := len(ds.Rows)
+		if err := ApplyTransformation(ds, step); err != nil {
+			runLog.Addf("TRANSFORM", "Step %d (%s) failed: %v", i+1, step.Type, err)
+			return fmt.Errorf("transformation step %d (%s): %w", i+1, step.Type, err)
+		}
+		runLog.Addf("TRANSFORM", "Step %d (%s): %d -> %d rows in %dms",
+			i+1, step.Type, before, len(ds.Rows), time.Since(start).Milliseconds())
+	}
+	return nil
+}
+
+// loadTransformed loads rows into a staging table, runs the ELT steps against it and copies
+// the result into the destination table, all in one transaction. The destination only changes
+// once every step has succeeded, so failed runs can be retried without loading rows twice, and
+// steps that drop columns never change the columns of the destination.
+func (e *PipelineExecutor) loadTransformed(
+	ctx context.Context,
+	loader *TableLoader,
+	table string,
+	executionID string,
+	columns []TempTableColumn,
+	rows [][]interface{},
+	writeMode string,
+	steps []TransformationStep,
+	runLog *PipelineRunLog,
+) (int, error) {
+	staging := stagingTable(table, executionID)
+	stagingExists := loader.TableExists(ctx, staging)
+	exists := loader.TableExists(ctx, table)
+
+	// Destinations without transactional DDL keep the staging table of a failed run
+	committed := false
+	defer func() {
+		if !committed && loader.TableExists(ctx, staging) {
+			loader.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", loader.QuoteIdent(staging)))
+		}
+	}()
+
+	tx, err := loader.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transformation transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Replace the staging table of a run that crashed before cleaning up
+	if _, err := loader.LoadTx(ctx, tx, staging, stagingExists, columns, rows, LoadModeOverwrite); err != nil {
+		return 0, err
+	}
+
+	names := make([]string, len(columns))
+	types := make(map[string]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+		types[col.Name] = col.DataType
+	}
+
+	for i, step := range steps {
+		start := time.Now()
+		stmts, args, err := CompileELTStep(loader, staging, names, step)
+		if err != nil {
+			runLog.Addf("TRANSFORM", "Step %d (%s) failed: %v", i+1, step.Type, err)
+			return 0, fmt.Errorf("transformation step %d (%s): %w", i+1, step.Type, err)
+		}
+
+		var affected int64
+		for j, stmt := range stmts {
+			res, err := tx.ExecContext(ctx, stmt, args[j]...)
+			if err != nil {
+				runLog.Addf("TRANSFORM", "Step %d (%s) failed: %v", i+1, step.Type, err)
+				return 0, fmt.Errorf("transformation step %d (%s): %w", i+1, step.Type, err)
+			}
+			if n, err := res.RowsAffected(); err == nil && n > 0 {
+				affected += n
+			}
+		}
+
+		switch step.Type {
+		case "DROP":
+			names = removeStrings(names, step.Fields)
+		case "KEEP":
+			names = keepStrings(names, step.Fields)
+		case "CAST":
+			types[step.Field] = castColumnTypes[step.TargetType]
+		}
+
+		runLog.Addf("TRANSFORM", "Step %d (%s): %d rows affected in %dms",
+			i+1, step.Type, affected, time.Since(start).Milliseconds())
+	}
+
+	// SQL steps may have added or removed columns, so the staging table has the final say
+	result, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", loader.QuoteIdent(staging)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read transformed columns: %w", err)
+	}
+	names, err = result.Columns()
+	result.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read transformed columns: %w", err)
+	}
+	final := make([]TempTableColumn, len(names))
+	for i, name := range names {
+		dataType := types[name]
+		if dataType == "" {
+			dataType = "text"
+		}
+		final[i] = TempTableColumn{Name: name, DataType: dataType, Nullable: true, Index: i}
+	}
+
+	loaded, err := loader.CopyTx(ctx, tx, staging, table, exists, final, writeMode)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", loader.QuoteIdent(staging))); err != nil {
+		return 0, fmt.Errorf("failed to drop staging table %s: %w", staging, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transformations: %w", err)
+	}
+	committed = true
+	return loaded, nil
+}
+
+// castColumnTypes maps CAST step target types to the column types of the destination table
+var castColumnTypes = map[string]string{"number": "float", "string": "text", "boolean": "boolean", "date": "timestamp"}
+
+// stagingTable names the table an ELT run transforms its rows in before they reach table
+func stagingTable(table, executionID string) string {
+	suffix := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.ToLower(executionID))
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+	return table + "_staging_" + suffix
+}
+
+// Extract reads the rows of a pipeline source, up to maxRows rows (0 = all)
+func (e *PipelineExecutor) Extract(ctx context.Context, workspaceID string, sourceType string, rawConfig string, maxRows int) (*PipelineDataset, error) {
+	var cfg PipelineSourceConfig
+	if rawConfig != "" {
+		if err := json.Unmarshal([]byte(rawConfig), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid source config: %w", err)
+		}
+	}
+	cfg.MaxRows = maxRows
+
+	switch classifyPipelineSource(sourceType) {
+	case pipelineSourceREST:
+		return e.extractREST(ctx, rawConfig, &cfg)
+	case pipelineSourceFile:
+		return e.extractFile(ctx, workspaceID, sourceType, &cfg)
+	default:
+		return e.extractDatabase(ctx, workspaceID, &cfg)
+	}
+}
+
+// classifyPipelineSource maps the free-form SourceType to a source kind
+func classifyPipelineSource(sourceType string) string {
+	switch strings.ToUpper(sourceType) {
+	case "REST", "REST_API", "API", "HTTP":
+		return pipelineSourceREST
+	case "CSV", "JSON", "EXCEL", "FILE", "UPLOAD":
+		return pipelineSourceFile
+	default:
+		return pipelineSourceDatabase
+	}
+}
+
+// extractDatabase reads rows from a saved connection or from the workspace's own tables
+func (e *PipelineExecutor) extractDatabase(ctx context.Context, workspaceID string, cfg *PipelineSourceConfig) (*PipelineDataset, error) {
+	if cfg.ConnectionID == "" {
+		// The internal database only exposes tables of the pipeline's workspace
+		if !validWorkspaceTableName.MatchString(cfg.TableName) {
+			return nil, fmt.Errorf("source without connectionId requires a valid tableName")
+		}
+		db, err := e.db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to access internal database: %w", err)
+		}
+		loader := NewTableLoader(db, "postgres")
+		query := fmt.Sprintf("SELECT * FROM %s", loader.QuoteIdent(WorkspaceSchema(workspaceID)+"."+cfg.TableName))
+		if cfg.MaxRows > 0 {
+			query = DialectPostgres.LimitSQL(query, &cfg.MaxRows, nil)
+		}
+		return queryDataset(ctx, db, query)
+	}
+
+	conn, err := e.workspaceConnection(workspaceID, cfg.ConnectionID)
+	if err != nil {
+		return nil, fmt.Errorf("source connection not found: %w", err)
+	}
+	query := cfg.Query
+	if query == "" {
+		if !validTableName.MatchString(cfg.TableName) {
+			return nil, fmt.Errorf("source requires a query or a valid tableName")
+		}
+		query = TableQuery(DialectFor(conn.Type), cfg.TableName)
+	}
+	if cfg.MaxRows > 0 {
+		query = DialectFor(conn.Type).LimitSQL(query, &cfg.MaxRows, nil)
+	}
+
+	return e.queryExecutor.Dataset(ctx, conn, query)
+}
+
+// workspaceConnection loads a saved connection owned by a member of the pipeline's workspace
+func (e *PipelineExecutor) workspaceConnection(workspaceID, connectionID string) (*models.Connection, error) {
+	members := e.db.Model(&models.WorkspaceMember{}).Select("user_id").Where("workspace_id = ?", workspaceID)
+
+	var conn models.Connection
+	if err := e.db.Where("id = ? AND user_id IN (?)", connectionID, members).First(&conn).Error; err != nil {
+		return nil, err
+	}
+	return &conn, nil
+}
+
+// extractREST reads rows from a REST API, following pagination up to MaxPages
+func (e *PipelineExecutor) extractREST(ctx context.Context, rawConfig string, cfg *PipelineSourceConfig) (*PipelineDataset, error) {
+	var restConfig RESTConnectorConfig
+	if err := json.Unmarshal([]byte(rawConfig), &restConfig); err != nil {
+		return nil, fmt.Errorf("invalid REST source config: %w", err)
+	}
+	if restConfig.BaseURL == "" {
+		restConfig.BaseURL = cfg.Endpoint
+	}
+	if restConfig.BaseURL == "" {
+		return nil, fmt.Errorf("REST source requires baseUrl or endpoint")
+	}
+	if restConfig.Method == "" {
+		restConfig.Method = "GET"
+	}
+	if restConfig.QueryParams == nil {
+		restConfig.QueryParams = map[string]string{}
+	}
+
+	maxPages := cfg.MaxPages
+	if maxPages <= 0 {
+		maxPages = 10
+	}
+
+	page, err := e.restConnector.FetchData(ctx, &restConfig, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &PipelineDataset{}
+	ds.appendMaps(page.Rows)
+	for i := 1; i < maxPages && page.HasMore && (cfg.MaxRows == 0 || len(ds.Rows) < cfg.MaxRows); i++ {
+		page, err = e.restConnector.GetNextPage(ctx, &restConfig, i, page.NextCursor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch page %d: %w", i+1, err)
+		}
+		ds.appendMaps(page.Rows)
+	}
+	if cfg.MaxRows > 0 && len(ds.Rows) > cfg.MaxRows {
+		ds.Rows = ds.Rows[:cfg.MaxRows]
+	}
+
+	return ds, nil
+}
+
+// extractFile reads a CSV, JSON or Excel file uploaded to the workspace
+func (e *PipelineExecutor) extractFile(ctx context.Context, workspaceID string, sourceType string, cfg *PipelineSourceConfig) (*PipelineDataset, error) {
+	file, err := e.openUpload(workspaceID, cfg.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	columns, rows, err := e.ReadFile(ctx, FileFormat(cfg.Format, sourceType, cfg.FilePath), file, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	return NewPipelineDataset(names, rows), nil
+}
+
+// openUpload opens a file inside the workspace's directory of the upload directory
+func (e *PipelineExecutor) openUpload(workspaceID, filePath string) (*os.File, error) {
+	if filePath == "" {
+		return nil, fmt.Errorf("file source requires filePath")
+	}
+	if workspaceID == "" {
+		return nil, fmt.Errorf("file source requires a workspace")
+	}
+
+	// Resolve inside the workspace's upload directory only, so that workspaces cannot read
+	// each other's files
+	path := filepath.Join(e.uploadDir, filepath.Clean("/"+workspaceID), filepath.Clean("/"+filePath))
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	return file, nil
+}
+
+// ReadFile reads a CSV, JSON or Excel stream into typed columns and rows
+func (e *PipelineExecutor) ReadFile(ctx context.Context, format string, r io.Reader, cfg *PipelineSourceConfig) ([]CSVColumn, [][]interface{}, error) {
+	switch format {
+	case "json":
+		options := e.jsonImporter.GetDefaultOptions()
+		options.RootPath = cfg.RootPath
+		options.MaxRows = cfg.MaxRows
+		return e.jsonImporter.ReadJSONRows(ctx, r, options)
+	case "excel":
+		options := e.excelImporter.GetDefaultOptions()
+		options.SheetName = cfg.SheetName
+		options.SkipRows = cfg.SkipRows
+		options.MaxRows = cfg.MaxRows
+		if cfg.HasHeader != nil {
+			options.HasHeader = *cfg.HasHeader
+		}
+		return e.excelImporter.ReadExcelRows(ctx, r, options)
+	case "csv":
+		options := e.csvImporter.GetDefaultOptions()
+		if cfg.Delimiter != "" {
+			options.Delimiter = []rune(cfg.Delimiter)[0]
+		}
+		if cfg.HasHeader != nil {
+			options.HasHeader = *cfg.HasHeader
+		}
+		options.SkipRows = cfg.SkipRows
+		options.MaxRows = cfg.MaxRows
+		return e.csvImporter.ReadCSVRows(ctx, r, options)
+	default:
+		return nil, nil, fmt.Errorf("unsupported file format: %s", format)
+	}
+}
+
+// FileFormat resolves the format (csv, json, excel) of a file source from an explicit
+// format, the source type or the file name
+func FileFormat(format string, sourceType string, fileName string) string {
+	switch strings.ToLower(format) {
+	case "csv", "json":
+		return strings.ToLower(format)
+	case "excel", "xlsx", "xls":
+		return "excel"
+	}
+
+	name := strings.ToLower(fileName)
+	switch {
+	case strings.EqualFold(sourceType, "excel"), strings.HasSuffix(name, ".xlsx"), strings.HasSuffix(name, ".xls"):
+		return "excel"
+	case strings.EqualFold(sourceType, "json"), strings.HasSuffix(name, ".json"):
+		return "json"
+	default:
+		return "csv"
+	}
+}
+
+// destination resolves the loader and table a pipeline writes to
+func (e *PipelineExecutor) destination(ctx context.Context, pipeline *models.Pipeline, dest *PipelineDestinationConfig) (*TableLoader, string, error) {
+	table := dest.TableName
+	if table == "" {
+		table = "pipeline_" + strings.ReplaceAll(pipeline.ID, "-", "_")
+	}
+
+	if dest.ConnectionID != "" {
+		if !validTableName.MatchString(table) {
+			return nil, "", fmt.Errorf("invalid destination table name: %s", table)
+		}
+		conn, err := e.workspaceConnection(pipeline.WorkspaceID, dest.ConnectionID)
+		if err != nil {
+			return nil, "", fmt.Errorf("destination connection not found: %w", err)
+		}
+		db, err := e.queryExecutor.getConnection(conn)
+		if err != nil {
+			return nil, "", err
+		}
+		return NewTableLoader(db, conn.Type), table, nil
+	}
+
+	if !validWorkspaceTableName.MatchString(table) {
+		return nil, "", fmt.Errorf("invalid destination table name: %s", table)
+	}
+	db, err := e.db.DB()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to access internal database: %w", err)
+	}
+	loader := NewTableLoader(db, "postgres")
+	schema := WorkspaceSchema(pipeline.WorkspaceID)
+	if err := loader.EnsureSchema(ctx, schema); err != nil {
+		return nil, "", err
+	}
+	return loader, schema + "." + table, nil
+}
+
+// queryDataset runs a query and collects every row keyed by column name
+func queryDataset(ctx context.Context, db *sql.DB, query string) (*PipelineDataset, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("source query failed: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &PipelineDataset{Columns: columns}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[col] = string(b)
+			} else {
+				row[col] = values[i]
+			}
+		}
+		ds.Rows = append(ds.Rows, row)
+	}
+
+	return ds, rows.Err()
+}
+
+// NewPipelineDataset builds a dataset from positional rows
+func NewPipelineDataset(columns []string, rows [][]interface{}) *PipelineDataset {
+	ds := &PipelineDataset{Columns: columns, Rows: make([]map[string]interface{}, len(rows))}
+	for i, values := range rows {
+		row := make(map[string]interface{}, len(columns))
+		for j, col := range columns {
+			if j < len(values) {
+				row[col] = values[j]
+			}
+		}
+		ds.Rows[i] = row
+	}
+	return ds
+}
+
+// PositionalRows returns the rows ordered by Columns
+func (ds *PipelineDataset) PositionalRows() [][]interface{} {
+	rows := make([][]interface{}, len(ds.Rows))
+	for i, row := range ds.Rows {
+		values := make([]interface{}, len(ds.Columns))
+		for j, col := range ds.Columns {
+			values[j] = row[col]
+		}
+		rows[i] = values
+	}
+	return rows
+}
+
+// appendMaps appends map rows, registering unseen keys as columns
+func (ds *PipelineDataset) appendMaps(rows []map[string]interface{}) {
+	seen := make(map[string]bool, len(ds.Columns))
+	for _, col := range ds.Columns {
+		seen[col] = true
+	}
+	for _, row := range rows {
+		var added []string
+		for key := range row {
+			if !seen[key] {
+				seen[key] = true
+				added = append(added, key)
+			}
+		}
+		sort.Strings(added)
+		ds.Columns = append(ds.Columns, added...)
+		ds.Rows = append(ds.Rows, row)
+	}
+}
+
+// removeStrings returns list without the given items
+func removeStrings(list, items []string) []string {
+	result := make([]string, 0, len(list))
+	for _, s := range list {
+		if !contains(items, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// keepStrings returns the entries of list that are also in items
+func keepStrings(list, items []string) []string {
+	result := make([]string, 0, len(items))
+	for _, s := range list {
+		if contains(items, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
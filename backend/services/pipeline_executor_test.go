@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"insight-engine-backend/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestCompileELTStep tests compiling declarative and SQL steps for the destination dialect
+func TestCompileELTStep(t *testing.T) {
+	postgres := NewTableLoader(nil, "postgres")
+	columns := []string{"id", "status", "amount"}
+
+	stmts, _, err := CompileELTStep(postgres, "ws_1.orders", columns, TransformationStep{Type: "KEEP", Fields: []string{"id"}})
+	if err != nil || len(stmts) != 2 || stmts[0] != `ALTER TABLE "ws_1"."orders" DROP COLUMN "status"` {
+		t.Errorf("Unexpected KEEP statements %v (%v)", stmts, err)
+	}
+
+	stmts, args, err := CompileELTStep(postgres, "ws_1.orders", columns, TransformationStep{Type: "FILTER", Field: "status", Operator: "contains", Value: "paid"})
+	if err != nil || stmts[0] != `DELETE FROM "ws_1"."orders" WHERE "status" IS NULL OR NOT ("status" LIKE $1)` || args[0][0] != "%paid%" {
+		t.Errorf("Unexpected FILTER statement %v %v (%v)", stmts, args, err)
+	}
+
+	stmts, _, err = CompileELTStep(postgres, "ws_1.orders", columns, TransformationStep{Type: "SQL", SQL: "UPDATE {{table}} SET amount = 0"})
+	if err != nil || stmts[0] != `UPDATE "ws_1"."orders" SET amount = 0` {
+		t.Errorf("Unexpected SQL statement %v (%v)", stmts, err)
+	}
+
+	mysql := NewTableLoader(nil, "mysql")
+	if _, _, err := CompileELTStep(mysql, "orders", columns, TransformationStep{Type: "CAST", Field: "amount", TargetType: "number"}); err == nil {
+		t.Error("Expected CAST to be rejected for MySQL destinations")
+	}
+	if _, _, err := CompileELTStep(postgres, "orders", columns, TransformationStep{Type: "FILTER", Field: "status", Operator: "matches"}); err == nil {
+		t.Error("Expected an unknown filter operator to be rejected")
+	}
+}
+
+// TestPipelineExecutor_ELT tests running ELT pipelines between connections of the workspace
+func TestPipelineExecutor_ELT(t *testing.T) {
+	root := t.TempDir()
+	SetFileStorageDir(root)
+	defer SetFileStorageDir("./data/files")
+
+	if err := os.MkdirAll(filepath.Join(root, "user-1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	warehouse, err := sql.Open("sqlite", filepath.Join(root, "user-1", "warehouse.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer warehouse.Close()
+	for _, statement := range []string{
+		"CREATE TABLE orders (id INTEGER, status TEXT, amount INTEGER)",
+		"INSERT INTO orders VALUES (1, 'paid', 10), (2, 'open', 20), (3, 'paid', 30)",
+	} {
+		if _, err := warehouse.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Connection{}, &models.WorkspaceMember{}, &models.QualityRule{}, &models.QualityCheckResult{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	db.Create(&models.WorkspaceMember{ID: "m1", WorkspaceID: "ws-1", UserID: "user-1", Role: models.RoleEditor})
+	db.Create(&models.Connection{ID: "wh", Name: "warehouse", Type: "sqlite", Database: "warehouse.db", UserID: "user-1"})
+	db.Create(&models.Connection{ID: "other", Name: "other", Type: "sqlite", Database: "warehouse.db", UserID: "user-2"})
+
+	qe := NewQueryExecutor()
+	defer qe.Close()
+	executor := NewPipelineExecutor(db, qe)
+	ctx := context.Background()
+
+	pipeline := func(source, destination, steps string) *models.Pipeline {
+		return &models.Pipeline{ID: "p1", Name: "paid orders", WorkspaceID: "ws-1", Mode: "ELT",
+			SourceType: "SQLITE", SourceConfig: source, DestinationConfig: &destination, TransformationSteps: &steps}
+	}
+	count := func() (int, []string) {
+		rows, err := warehouse.Query("SELECT * FROM paid_orders")
+		if err != nil {
+			t.Fatalf("Failed to read destination: %v", err)
+		}
+		defer rows.Close()
+		columns, _ := rows.Columns()
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		return n, columns
+	}
+
+	source := `{"connectionId":"wh","tableName":"orders"}`
+	destination := `{"connectionId":"wh","tableName":"paid_orders"}`
+	steps := `[{"type":"FILTER","field":"status","operator":"eq","value":"paid"},{"type":"DROP","fields":["status"]},
+		{"type":"SQL","sql":"UPDATE {{table}} SET amount = amount * 2"}]`
+
+	// Dropped columns stay out of the destination, so later appends still fit it
+	for run := 1; run <= 2; run++ {
+		result, err := executor.Run(ctx, pipeline(source, destination, steps), "exec-1", &PipelineRunLog{})
+		if err != nil {
+			t.Fatalf("Run %d failed: %v", run, err)
+		}
+		if result.RowsExtracted != 3 || result.RowsLoaded != 2 {
+			t.Errorf("Run %d: unexpected result %+v", run, result)
+		}
+	}
+	if n, columns := count(); n != 4 || strings.Join(columns, ",") != "id,amount" {
+		t.Errorf("Expected 4 rows of id and amount, got %d of %v", n, columns)
+	}
+	var total int
+	warehouse.QueryRow("SELECT SUM(amount) FROM paid_orders").Scan(&total)
+	if total != 160 {
+		t.Errorf("Expected the SQL step to double the amounts, got a total of %d", total)
+	}
+
+	// A failing step leaves the destination as it was, so the run can be retried
+	failing := `[{"type":"DROP","fields":["status"]},{"type":"SQL","sql":"UPDATE {{table}} SET missing = 1"}]`
+	if _, err := executor.Run(ctx, pipeline(source, destination, failing), "exec-2", &PipelineRunLog{}); err == nil {
+		t.Fatal("Expected the failing step to fail the run")
+	}
+	if n, _ := count(); n != 4 {
+		t.Errorf("Expected the failed run to load nothing, got %d rows", n)
+	}
+	var staging int
+	warehouse.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name LIKE '%staging%'").Scan(&staging)
+	if staging != 0 {
+		t.Errorf("Expected no staging table to be left behind, found %d", staging)
+	}
+
+	// Connections of users outside the workspace cannot be read or written
+	if _, err := executor.Run(ctx, pipeline(`{"connectionId":"other","tableName":"orders"}`, destination, "[]"), "exec-3", &PipelineRunLog{}); err == nil {
+		t.Error("Expected a source connection outside the workspace to be rejected")
+	}
+	if _, err := executor.Run(ctx, pipeline(source, `{"connectionId":"other","tableName":"copy"}`, "[]"), "exec-4", &PipelineRunLog{}); err == nil {
+		t.Error("Expected a destination connection outside the workspace to be rejected")
+	}
+
+	// SQL steps never run against the application's database
+	if _, err := executor.Run(ctx, pipeline(source, `{"tableName":"copy"}`, steps), "exec-5", &PipelineRunLog{}); err == nil ||
+		!strings.Contains(err.Error(), "require a destination connection") {
+		t.Errorf("Expected a SQL step without a destination connection to be rejected, got %v", err)
+	}
+}
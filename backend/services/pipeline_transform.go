@@ -0,0 +1,425 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TransformationStep is one entry of Pipeline.TransformationSteps.
+// Only the fields relevant to the step type are set.
+type TransformationStep struct {
+	Type        string      `json:"type"` // CAST, DROP, KEEP, FILTER, DEDUPLICATE, REPLACE, DEFAULT_VALUE, SQL
+	Field       string      `json:"field,omitempty"`
+	Fields      []string    `json:"fields,omitempty"`
+	TargetType  string      `json:"targetType,omitempty"` // number, string, boolean, date
+	Format      string      `json:"format,omitempty"`     // e.g. "YYYY-MM-DD"
+	Operator    string      `json:"operator,omitempty"`   // eq, neq, gt, lt, contains, not_contains
+	Value       interface{} `json:"value,omitempty"`
+	UniqueKeys  []string    `json:"uniqueKeys,omitempty"`
+	Pattern     string      `json:"pattern,omitempty"`
+	Replacement string      `json:"replacement,omitempty"`
+	SQL         string      `json:"sql,omitempty"` // ELT only; {{table}} is replaced by the destination table
+}
+
+// PipelineDataset is the tabular batch that flows through a pipeline run
+type PipelineDataset struct {
+	Columns []string
+	Rows    []map[string]interface{}
+}
+
+// ParseTransformationSteps decodes the JSON stored in Pipeline.TransformationSteps
+func ParseTransformationSteps(raw *string) ([]TransformationStep, error) {
+	if raw == nil || strings.TrimSpace(*raw) == "" || *raw == "null" {
+		return nil, nil
+	}
+
+	var steps []TransformationStep
+	if err := json.Unmarshal([]byte(*raw), &steps); err != nil {
+		return nil, fmt.Errorf("invalid transformation steps: %w", err)
+	}
+	for i := range steps {
+		steps[i].Type = strings.ToUpper(steps[i].Type)
+	}
+	return steps, nil
+}
+
+// ApplyTransformation applies a single ETL step to the dataset in memory
+func ApplyTransformation(ds *PipelineDataset, step TransformationStep) error {
+	switch step.Type {
+	case "CAST":
+		for _, row := range ds.Rows {
+			if value, ok := row[step.Field]; ok && value != nil {
+				row[step.Field] = castValue(value, step.TargetType, step.Format)
+			}
+		}
+
+	case "DROP":
+		drop := make(map[string]bool, len(step.Fields))
+		for _, f := range step.Fields {
+			drop[f] = true
+		}
+		kept := make([]string, 0, len(ds.Columns))
+		for _, col := range ds.Columns {
+			if !drop[col] {
+				kept = append(kept, col)
+			}
+		}
+		ds.Columns = kept
+		for _, row := range ds.Rows {
+			for _, f := range step.Fields {
+				delete(row, f)
+			}
+		}
+
+	case "KEEP":
+		keep := make(map[string]bool, len(step.Fields))
+		for _, f := range step.Fields {
+			keep[f] = true
+		}
+		kept := make([]string, 0, len(step.Fields))
+		for _, col := range ds.Columns {
+			if keep[col] {
+				kept = append(kept, col)
+			}
+		}
+		ds.Columns = kept
+		for _, row := range ds.Rows {
+			for key := range row {
+				if !keep[key] {
+					delete(row, key)
+				}
+			}
+		}
+
+	case "FILTER":
+		filtered := ds.Rows[:0]
+		for _, row := range ds.Rows {
+			if matchesFilter(row[step.Field], step.Operator, step.Value) {
+				filtered = append(filtered, row)
+			}
+		}
+		ds.Rows = filtered
+
+	case "DEDUPLICATE":
+		if len(step.UniqueKeys) == 0 {
+			return fmt.Errorf("DEDUPLICATE requires uniqueKeys")
+		}
+		seen := make(map[string]bool, len(ds.Rows))
+		deduped := ds.Rows[:0]
+		for _, row := range ds.Rows {
+			parts := make([]string, len(step.UniqueKeys))
+			for i, key := range step.UniqueKeys {
+				parts[i] = fmt.Sprintf("%v", row[key])
+			}
+			composite := strings.Join(parts, "||")
+			if seen[composite] {
+				continue
+			}
+			seen[composite] = true
+			deduped = append(deduped, row)
+		}
+		ds.Rows = deduped
+
+	case "REPLACE":
+		re, err := regexp.Compile(step.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid REPLACE pattern %q: %w", step.Pattern, err)
+		}
+		for _, row := range ds.Rows {
+			if s, ok := row[step.Field].(string); ok {
+				row[step.Field] = re.ReplaceAllString(s, step.Replacement)
+			}
+		}
+
+	case "DEFAULT_VALUE":
+		for _, row := range ds.Rows {
+			if value, ok := row[step.Field]; !ok || value == nil || value == "" {
+				row[step.Field] = step.Value
+			}
+		}
+		if !contains(ds.Columns, step.Field) {
+			ds.Columns = append(ds.Columns, step.Field)
+		}
+
+	case "SQL":
+		return fmt.Errorf("SQL steps run against the destination and require ELT mode")
+
+	default:
+		return fmt.Errorf("unknown transformation step type: %s", step.Type)
+	}
+
+	return nil
+}
+
+// CompileELTStep compiles a step into statements executed against the loaded
+// destination table. Declarative steps that need dialect-specific functions are
+// only compiled for Postgres; other destinations must use SQL steps.
+func CompileELTStep(loader *TableLoader, table string, columns []string, step TransformationStep) ([]string, [][]interface{}, error) {
+	quotedTable := loader.QuoteIdent(table)
+	isPostgres := loader.dialect == "postgres" || loader.dialect == "postgresql"
+
+	switch step.Type {
+	case "SQL":
+		if strings.TrimSpace(step.SQL) == "" {
+			return nil, nil, fmt.Errorf("SQL step has no statement")
+		}
+		return []string{strings.ReplaceAll(step.SQL, "{{table}}", quotedTable)}, [][]interface{}{nil}, nil
+
+	case "DROP", "KEEP":
+		drop := step.Fields
+		if step.Type == "KEEP" {
+			drop = nil
+			for _, col := range columns {
+				if !contains(step.Fields, col) {
+					drop = append(drop, col)
+				}
+			}
+		}
+		stmts := make([]string, len(drop))
+		args := make([][]interface{}, len(drop))
+		for i, col := range drop {
+			stmts[i] = fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", quotedTable, loader.QuoteIdent(col))
+		}
+		return stmts, args, nil
+
+	case "FILTER":
+		col := loader.QuoteIdent(step.Field)
+		var keep string
+		value := step.Value
+		switch step.Operator {
+		case "eq":
+			keep = fmt.Sprintf("%s = %s", col, loader.Placeholder(1))
+		case "neq":
+			keep = fmt.Sprintf("%s <> %s", col, loader.Placeholder(1))
+		case "gt":
+			keep = fmt.Sprintf("%s > %s", col, loader.Placeholder(1))
+		case "lt":
+			keep = fmt.Sprintf("%s < %s", col, loader.Placeholder(1))
+		case "contains":
+			keep = fmt.Sprintf("%s LIKE %s", col, loader.Placeholder(1))
+			value = "%" + fmt.Sprintf("%v", step.Value) + "%"
+		case "not_contains":
+			keep = fmt.Sprintf("%s NOT LIKE %s", col, loader.Placeholder(1))
+			value = "%" + fmt.Sprintf("%v", step.Value) + "%"
+		default:
+			return nil, nil, fmt.Errorf("unsupported filter operator: %s", step.Operator)
+		}
+		stmt := fmt.Sprintf("DELETE FROM %s WHERE %s IS NULL OR NOT (%s)", quotedTable, col, keep)
+		return []string{stmt}, [][]interface{}{{value}}, nil
+
+	case "DEFAULT_VALUE":
+		col := loader.QuoteIdent(step.Field)
+		stmt := fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL", quotedTable, col, loader.Placeholder(1), col)
+		return []string{stmt}, [][]interface{}{{step.Value}}, nil
+
+	case "CAST":
+		if !isPostgres {
+			break
+		}
+		pgType := map[string]string{"number": "DOUBLE PRECISION", "string": "TEXT", "boolean": "BOOLEAN", "date": "TIMESTAMP"}[step.TargetType]
+		if pgType == "" {
+			return nil, nil, fmt.Errorf("unsupported cast target type: %s", step.TargetType)
+		}
+		col := loader.QuoteIdent(step.Field)
+		stmt := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s", quotedTable, col, pgType, col, pgType)
+		return []string{stmt}, [][]interface{}{nil}, nil
+
+	case "REPLACE":
+		if !isPostgres {
+			break
+		}
+		col := loader.QuoteIdent(step.Field)
+		stmt := fmt.Sprintf("UPDATE %s SET %s = regexp_replace(%s, $1, $2, 'g') WHERE %s IS NOT NULL", quotedTable, col, col, col)
+		return []string{stmt}, [][]interface{}{{step.Pattern, step.Replacement}}, nil
+
+	case "DEDUPLICATE":
+		if !isPostgres {
+			break
+		}
+		if len(step.UniqueKeys) == 0 {
+			return nil, nil, fmt.Errorf("DEDUPLICATE requires uniqueKeys")
+		}
+		conds := make([]string, len(step.UniqueKeys))
+		for i, key := range step.UniqueKeys {
+			col := loader.QuoteIdent(key)
+			conds[i] = fmt.Sprintf("a.%s IS NOT DISTINCT FROM b.%s", col, col)
+		}
+		stmt := fmt.Sprintf("DELETE FROM %s a USING %s b WHERE a.ctid > b.ctid AND %s",
+			quotedTable, quotedTable, strings.Join(conds, " AND "))
+		return []string{stmt}, [][]interface{}{nil}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown transformation step type: %s", step.Type)
+	}
+
+	return nil, nil, fmt.Errorf("%s steps are not supported in ELT mode for %s destinations; use ETL mode or a SQL step", step.Type, loader.dialect)
+}
+
+// matchesFilter evaluates a FILTER step condition with loose equality semantics
+func matchesFilter(value interface{}, operator string, target interface{}) bool {
+	switch operator {
+	case "eq":
+		return looseEqual(value, target)
+	case "neq":
+		return !looseEqual(value, target)
+	case "gt", "lt":
+		if value == nil {
+			return false
+		}
+		cmp := compareValues(value, target)
+		if operator == "gt" {
+			return cmp > 0
+		}
+		return cmp < 0
+	case "contains":
+		return strings.Contains(fmt.Sprintf("%v", value), fmt.Sprintf("%v", target))
+	case "not_contains":
+		return !strings.Contains(fmt.Sprintf("%v", value), fmt.Sprintf("%v", target))
+	default:
+		return true
+	}
+}
+
+// looseEqual compares values numerically when both are numeric, otherwise as strings
+func looseEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			return af == bf
+		}
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// compareValues orders values numerically when possible, then chronologically, then lexically
+func compareValues(a, b interface{}) int {
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt)
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// toFloat converts numeric values and numeric strings to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	case []byte:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// castValue converts a value to the CAST step target type, keeping the original on failure
+func castValue(value interface{}, targetType, format string) interface{} {
+	switch targetType {
+	case "number":
+		if f, ok := toFloat(value); ok {
+			if f == float64(int64(f)) {
+				return int64(f)
+			}
+			return f
+		}
+	case "string":
+		if t, ok := value.(time.Time); ok {
+			return t.Format(time.RFC3339)
+		}
+		return fmt.Sprintf("%v", value)
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "", "false", "0", "no", "n", "f":
+				return false
+			default:
+				return true
+			}
+		default:
+			if f, ok := toFloat(v); ok {
+				return f != 0
+			}
+			return true
+		}
+	case "date":
+		if t, ok := value.(time.Time); ok {
+			return t
+		}
+		s := fmt.Sprintf("%v", value)
+		layouts := []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02", "2006/01/02"}
+		if format != "" {
+			layouts = append([]string{dateFormatToLayout(format)}, layouts...)
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t
+			}
+		}
+	}
+	return value
+}
+
+// dateFormatToLayout converts a YYYY-MM-DD style format to a Go time layout
+func dateFormatToLayout(format string) string {
+	replacer := strings.NewReplacer(
+		"YYYY", "2006",
+		"YY", "06",
+		"MM", "01",
+		"DD", "02",
+		"HH", "15",
+		"mm", "04",
+		"ss", "05",
+	)
+	return replacer.Replace(format)
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"insight-engine-backend/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Quality rule types and severities (see models.QualityRule)
+const (
+	QualityRuleNotNull = "NOT_NULL"
+	QualityRuleUnique  = "UNIQUE"
+	QualityRuleRange   = "RANGE"
+	QualityRuleRegex   = "REGEX"
+
+	QualitySeverityWarn = "WARN"
+	QualitySeverityFail = "FAIL"
+)
+
+// ErrQualityCheckFailed is returned when a FAIL-severity quality rule is broken.
+// Re-running the same data cannot succeed, so the job queue does not retry it.
+var ErrQualityCheckFailed = errors.New("quality check failed")
+
+// QualityReport is the outcome of evaluating every rule of a pipeline against a dataset
+type QualityReport struct {
+	Results    []models.QualityCheckResult
+	Violations map[int][]string // Row index -> messages of broken FAIL rules
+}
+
+// Failed reports whether any FAIL-severity rule was broken
+func (r *QualityReport) Failed() bool {
+	return len(r.Violations) > 0
+}
+
+// FailedRows returns the indexes of rows that broke a FAIL-severity rule, in dataset order
+func (r *QualityReport) FailedRows(total int) []int {
+	rows := make([]int, 0, len(r.Violations))
+	for i := 0; i < total; i++ {
+		if _, ok := r.Violations[i]; ok {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
+// ValidateQualityRule checks that a rule is well formed before it is stored or evaluated
+func ValidateQualityRule(rule models.QualityRule) error {
+	if strings.TrimSpace(rule.Column) == "" {
+		return fmt.Errorf("quality rule requires a column")
+	}
+	if rule.Severity != QualitySeverityWarn && rule.Severity != QualitySeverityFail {
+		return fmt.Errorf("invalid severity %q for column %s", rule.Severity, rule.Column)
+	}
+
+	switch rule.RuleType {
+	case QualityRuleNotNull, QualityRuleUnique:
+		return nil
+	case QualityRuleRange:
+		_, _, err := parseQualityRange(rule.Value)
+		return err
+	case QualityRuleRegex:
+		if rule.Value == nil || *rule.Value == "" {
+			return fmt.Errorf("REGEX rule on %s requires a pattern", rule.Column)
+		}
+		if _, err := regexp.Compile(*rule.Value); err != nil {
+			return fmt.Errorf("invalid pattern for REGEX rule on %s: %w", rule.Column, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported rule type %q", rule.RuleType)
+	}
+}
+
+// EvaluateQualityRules checks every rule against every row of the dataset.
+// Null values only break NOT_NULL rules; RANGE ignores non-numeric values.
+func EvaluateQualityRules(ds *PipelineDataset, rules []models.QualityRule) (*QualityReport, error) {
+	report := &QualityReport{Violations: make(map[int][]string)}
+	now := time.Now()
+
+	for _, rule := range rules {
+		if err := ValidateQualityRule(rule); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrQualityCheckFailed, err)
+		}
+
+		check, err := qualityCheckFunc(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrQualityCheckFailed, err)
+		}
+
+		result := models.QualityCheckResult{
+			ID:          uuid.New().String(),
+			RuleID:      rule.ID,
+			PipelineID:  rule.PipelineID,
+			Column:      rule.Column,
+			RuleType:    rule.RuleType,
+			Severity:    rule.Severity,
+			RowsChecked: len(ds.Rows),
+			CreatedAt:   now,
+		}
+
+		for i, row := range ds.Rows {
+			if msg := check(row[rule.Column]); msg != "" {
+				result.RowsFailed++
+				if rule.Severity == QualitySeverityFail {
+					report.Violations[i] = append(report.Violations[i], msg)
+				}
+			}
+		}
+
+		result.RowsPassed = result.RowsChecked - result.RowsFailed
+		result.Passed = result.RowsFailed == 0
+		report.Results = append(report.Results, result)
+	}
+
+	return report, nil
+}
+
+// qualityCheckFunc returns a check that yields a violation message, or "" when the value passes
+func qualityCheckFunc(rule models.QualityRule) (func(value interface{}) string, error) {
+	column := rule.Column
+
+	switch rule.RuleType {
+	case QualityRuleNotNull:
+		return func(value interface{}) string {
+			if value == nil {
+				return fmt.Sprintf("Column '%s' cannot be null/empty", column)
+			}
+			if s, ok := value.(string); ok && s == "" {
+				return fmt.Sprintf("Column '%s' cannot be null/empty", column)
+			}
+			return ""
+		}, nil
+
+	case QualityRuleUnique:
+		seen := make(map[string]bool)
+		return func(value interface{}) string {
+			if value == nil {
+				return ""
+			}
+			key := fmt.Sprintf("%v", value)
+			if seen[key] {
+				return fmt.Sprintf("Duplicate value '%v' in column '%s'", value, column)
+			}
+			seen[key] = true
+			return ""
+		}, nil
+
+	case QualityRuleRange:
+		min, max, err := parseQualityRange(rule.Value)
+		if err != nil {
+			return nil, err
+		}
+		return func(value interface{}) string {
+			f, ok := toFloat(value)
+			if !ok {
+				return ""
+			}
+			if min != nil && f < *min {
+				return fmt.Sprintf("Value %v in column '%s' is below minimum %v", value, column, *min)
+			}
+			if max != nil && f > *max {
+				return fmt.Sprintf("Value %v in column '%s' is above maximum %v", value, column, *max)
+			}
+			return ""
+		}, nil
+
+	case QualityRuleRegex:
+		re, err := regexp.Compile(*rule.Value)
+		if err != nil {
+			return nil, err
+		}
+		return func(value interface{}) string {
+			if value == nil {
+				return ""
+			}
+			if !re.MatchString(fmt.Sprintf("%v", value)) {
+				return fmt.Sprintf("Value '%v' in column '%s' does not match pattern %s", value, column, *rule.Value)
+			}
+			return ""
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported rule type %q", rule.RuleType)
+}
+
+// parseQualityRange parses a "min,max" RANGE parameter; either bound may be empty
+func parseQualityRange(value *string) (*float64, *float64, error) {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil, nil, fmt.Errorf("RANGE rule requires a \"min,max\" value")
+	}
+
+	parts := strings.Split(*value, ",")
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("invalid RANGE value %q, expected \"min,max\"", *value)
+	}
+
+	bounds := make([]*float64, 2)
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid RANGE bound %q: %w", part, err)
+		}
+		bounds[i] = &f
+	}
+
+	if bounds[0] == nil && bounds[1] == nil {
+		return nil, nil, fmt.Errorf("RANGE rule requires at least one bound")
+	}
+	return bounds[0], bounds[1], nil
+}
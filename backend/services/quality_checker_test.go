@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"insight-engine-backend/models"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// TestEvaluateQualityRules tests per-rule counts and FAIL-severity violations
+func TestEvaluateQualityRules(t *testing.T) {
+	ds := NewPipelineDataset([]string{"id", "email", "age"}, [][]interface{}{
+		{int64(1), "a@example.com", int64(30)},
+		{int64(2), nil, int64(150)},
+		{int64(2), "not-an-email", int64(25)},
+		{int64(3), "c@example.com", "unknown"},
+	})
+
+	rules := []models.QualityRule{
+		{ID: "r1", Column: "email", RuleType: QualityRuleNotNull, Severity: QualitySeverityWarn},
+		{ID: "r2", Column: "id", RuleType: QualityRuleUnique, Severity: QualitySeverityFail},
+		{ID: "r3", Column: "age", RuleType: QualityRuleRange, Value: strPtr("0,120"), Severity: QualitySeverityFail},
+		{ID: "r4", Column: "email", RuleType: QualityRuleRegex, Value: strPtr(`^[^@]+@[^@]+$`), Severity: QualitySeverityWarn},
+	}
+
+	report, err := EvaluateQualityRules(ds, rules)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedFailed := map[string]int{"r1": 1, "r2": 1, "r3": 1, "r4": 1}
+	for _, result := range report.Results {
+		if result.RowsChecked != 4 {
+			t.Errorf("Rule %s: expected 4 rows checked, got %d", result.RuleID, result.RowsChecked)
+		}
+		if result.RowsFailed != expectedFailed[result.RuleID] {
+			t.Errorf("Rule %s: expected %d failed rows, got %d", result.RuleID, expectedFailed[result.RuleID], result.RowsFailed)
+		}
+		if result.RowsPassed+result.RowsFailed != result.RowsChecked {
+			t.Errorf("Rule %s: passed and failed rows do not add up", result.RuleID)
+		}
+	}
+
+	// Only the duplicate id (row 2) and out of range age (row 1) break FAIL rules
+	failedRows := report.FailedRows(len(ds.Rows))
+	if len(failedRows) != 2 || failedRows[0] != 1 || failedRows[1] != 2 {
+		t.Errorf("Expected failed rows [1 2], got %v", failedRows)
+	}
+	if !report.Failed() {
+		t.Error("Expected report to fail")
+	}
+}
+
+// TestEvaluateQualityRules_InvalidRule tests that malformed rules stop the run
+func TestEvaluateQualityRules_InvalidRule(t *testing.T) {
+	ds := NewPipelineDataset([]string{"age"}, [][]interface{}{{int64(1)}})
+
+	tests := []models.QualityRule{
+		{Column: "age", RuleType: QualityRuleRange, Value: strPtr("ten"), Severity: QualitySeverityWarn},
+		{Column: "age", RuleType: QualityRuleRegex, Value: strPtr("("), Severity: QualitySeverityWarn},
+		{Column: "age", RuleType: "BETWEEN", Severity: QualitySeverityWarn},
+		{Column: "age", RuleType: QualityRuleNotNull, Severity: "ERROR"},
+	}
+
+	for _, rule := range tests {
+		if _, err := EvaluateQualityRules(ds, []models.QualityRule{rule}); !errors.Is(err, ErrQualityCheckFailed) {
+			t.Errorf("Rule %+v: expected ErrQualityCheckFailed, got %v", rule, err)
+		}
+	}
+}
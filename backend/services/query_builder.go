@@ -6,6 +6,7 @@ import (
 	"insight-engine-backend/models"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // QueryBuilder handles visual query configuration to SQL conversion
@@ -34,54 +35,60 @@ func (qb *QueryBuilder) BuildSQL(ctx context.Context, config *models.VisualQuery
 		return "", nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	// Quoting, placeholders and row limiting follow the connection's dialect
+	sql, params := qb.generateSQL(config, DialectFor(conn.Type))
+
+	// Note: RLS is now applied at query execution time via QueryExecutor
+	// to avoid tight coupling and provide cleaner separation of concerns
+
+	return sql, params, nil
+}
+
+// generateSQL generates the SQL of a validated visual configuration in a dialect
+func (qb *QueryBuilder) generateSQL(config *models.VisualQueryConfig, dialect SQLDialect) (string, []interface{}) {
 	var sqlParts []string
 	var params []interface{}
 
 	// Build SELECT clause
-	selectClause := qb.buildSelectClause(config)
+	selectClause := qb.buildSelectClause(config, dialect)
 	sqlParts = append(sqlParts, selectClause)
 
 	// Build FROM clause
-	fromClause := qb.buildFromClause(config)
+	fromClause := qb.buildFromClause(config, dialect)
 	sqlParts = append(sqlParts, fromClause)
 
 	// Build JOIN clauses
 	if len(config.Joins) > 0 {
-		joinClause := qb.buildJoinClause(config)
+		joinClause := qb.buildJoinClause(config, dialect)
 		sqlParts = append(sqlParts, joinClause)
 	}
 
 	// Build WHERE clause
 	if len(config.Filters) > 0 {
-		whereClause, whereParams := qb.buildWhereClause(config)
+		whereClause, whereParams := qb.buildWhereClause(config, dialect)
 		sqlParts = append(sqlParts, whereClause)
 		params = append(params, whereParams...)
 	}
 
 	// Build GROUP BY clause
-	if len(config.GroupBy) > 0 {
-		groupByClause := qb.buildGroupByClause(config)
+	if len(config.GroupBy) > 0 || (len(config.DateBuckets) > 0 && isAggregateQuery(config)) {
+		groupByClause := qb.buildGroupByClause(config, dialect)
 		sqlParts = append(sqlParts, groupByClause)
 	}
 
+	// Window functions run over the grouped rows, so the query so far becomes a subquery
+	if len(config.WindowColumns) > 0 {
+		sqlParts = []string{qb.buildWindowQuery(config, strings.Join(sqlParts, "\n"), dialect)}
+	}
+
 	// Build ORDER BY clause
 	if len(config.OrderBy) > 0 {
-		orderByClause := qb.buildOrderByClause(config)
+		orderByClause := qb.buildOrderByClause(config, dialect)
 		sqlParts = append(sqlParts, orderByClause)
 	}
 
-	// Build LIMIT clause
-	if config.Limit != nil {
-		limitClause := qb.buildLimitClause(config)
-		sqlParts = append(sqlParts, limitClause)
-	}
-
-	sql := strings.Join(sqlParts, "\n")
-
-	// Note: RLS is now applied at query execution time via QueryExecutor
-	// to avoid tight coupling and provide cleaner separation of concerns
-
-	return sql, params, nil
+	// Apply the limit in the dialect's syntax (LIMIT, TOP or FETCH FIRST)
+	return dialect.LimitSQL(strings.Join(sqlParts, "\n"), config.Limit, nil), params
 }
 
 // ValidateConfig validates visual configuration before SQL generation
@@ -154,31 +161,151 @@ func (qb *QueryBuilder) ValidateConfig(ctx context.Context, config *models.Visua
 	}
 
 	// Validate filters
-	if len(config.Filters) > 50 {
-		return fmt.Errorf("maximum 50 filters allowed")
+	filterCount := 0
+	if err := qb.validateFilters(config.Filters, 0, &filterCount); err != nil {
+		return err
+	}
+
+	// Validate date buckets, calculated columns and window functions
+	if err := qb.validateDerivedColumns(config, tableMap, DialectFor(conn.Type)); err != nil {
+		return err
 	}
 
-	for _, filter := range config.Filters {
-		// Validate operator
-		validOperators := map[string]bool{
-			"=": true, "!=": true, ">": true, "<": true, ">=": true, "<=": true,
-			"LIKE": true, "IN": true, "BETWEEN": true,
+	// Validate aggregations
+	for _, agg := range config.Aggregations {
+		validFunctions := map[string]bool{"SUM": true, "AVG": true, "COUNT": true, "MIN": true, "MAX": true}
+		if !validFunctions[strings.ToUpper(agg.Function)] {
+			return fmt.Errorf("invalid aggregation function '%s'", agg.Function)
 		}
-		if !validOperators[strings.ToUpper(filter.Operator)] {
-			return fmt.Errorf("invalid operator '%s'", filter.Operator)
+	}
+
+	return nil
+}
+
+var outputAlias = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateDerivedColumns validates date buckets, calculated columns and window functions
+func (qb *QueryBuilder) validateDerivedColumns(config *models.VisualQueryConfig, tableMap map[string]*TableInfo, dialect SQLDialect) error {
+	if len(config.DateBuckets) > 5 {
+		return fmt.Errorf("maximum 5 date buckets allowed")
+	}
+	for _, bucket := range config.DateBuckets {
+		tableInfo, exists := tableMap[bucket.Table]
+		if !exists {
+			return fmt.Errorf("table '%s' does not exist for date bucket '%s'", bucket.Table, bucket.Column)
+		}
+		columnExists := false
+		for _, tableCol := range tableInfo.Columns {
+			if tableCol.Name == bucket.Column {
+				columnExists = true
+				break
+			}
+		}
+		if !columnExists {
+			return fmt.Errorf("column '%s' does not exist in table '%s'", bucket.Column, bucket.Table)
+		}
+		if _, err := dialect.DateTrunc(bucket.Grain, "x"); err != nil {
+			return err
 		}
+		if bucket.Alias != "" && !outputAlias.MatchString(bucket.Alias) {
+			return fmt.Errorf("invalid date bucket alias '%s'", bucket.Alias)
+		}
+	}
 
+	if len(config.CalculatedColumns) > 20 {
+		return fmt.Errorf("maximum 20 calculated columns allowed")
+	}
+	for _, calc := range config.CalculatedColumns {
+		if !outputAlias.MatchString(calc.Alias) {
+			return fmt.Errorf("invalid calculated column alias '%s'", calc.Alias)
+		}
+		if err := validateCalculatedColumn(calc.Expression, config, tableMap); err != nil {
+			return fmt.Errorf("invalid calculated column '%s': %w", calc.Alias, err)
+		}
+	}
+
+	if len(config.WindowColumns) > 10 {
+		return fmt.Errorf("maximum 10 window columns allowed")
+	}
+	for _, window := range config.WindowColumns {
+		if !outputAlias.MatchString(window.Alias) {
+			return fmt.Errorf("invalid window column alias '%s'", window.Alias)
+		}
+		function := strings.ToUpper(window.Function)
+		switch function {
+		case "RANK", "DENSE_RANK", "ROW_NUMBER":
+			if window.Column == "" && len(window.OrderBy) == 0 {
+				return fmt.Errorf("%s needs a column or an order", function)
+			}
+		case "RUNNING_TOTAL", "LAG", "LEAD", "MOVING_AVERAGE":
+			if window.Column == "" || len(window.OrderBy) == 0 {
+				return fmt.Errorf("%s needs a column and an order", function)
+			}
+		case "PERCENT_OF_TOTAL":
+			if window.Column == "" {
+				return fmt.Errorf("%s needs a column", function)
+			}
+		default:
+			return fmt.Errorf("invalid window function '%s'", window.Function)
+		}
+		if window.Offset < 0 || window.Window < 0 || window.Window > 1000 {
+			return fmt.Errorf("invalid offset or window size for '%s'", window.Alias)
+		}
+	}
+
+	return nil
+}
+
+// validateFilters validates filters and nested filter groups
+func (qb *QueryBuilder) validateFilters(filters []models.FilterCondition, depth int, count *int) error {
+	if depth > 5 {
+		return fmt.Errorf("filter groups can be nested at most 5 levels deep")
+	}
+
+	validOperators := map[string]bool{
+		"=": true, "!=": true, ">": true, "<": true, ">=": true, "<=": true,
+		"LIKE": true, "NOT LIKE": true, "ILIKE": true, "IN": true, "NOT IN": true, "BETWEEN": true,
+		"IS NULL": true, "IS NOT NULL": true, "RELATIVE_DATE": true,
+	}
+	for _, filter := range filters {
 		// Validate logic
 		if filter.Logic != "" && filter.Logic != "AND" && filter.Logic != "OR" {
 			return fmt.Errorf("invalid logic '%s', must be AND or OR", filter.Logic)
 		}
-	}
 
-	// Validate aggregations
-	for _, agg := range config.Aggregations {
-		validFunctions := map[string]bool{"SUM": true, "AVG": true, "COUNT": true, "MIN": true, "MAX": true}
-		if !validFunctions[strings.ToUpper(agg.Function)] {
-			return fmt.Errorf("invalid aggregation function '%s'", agg.Function)
+		if filter.Group != nil {
+			if filter.Group.Logic != "" && filter.Group.Logic != "AND" && filter.Group.Logic != "OR" {
+				return fmt.Errorf("invalid group logic '%s', must be AND or OR", filter.Group.Logic)
+			}
+			if len(filter.Group.Filters) == 0 {
+				return fmt.Errorf("filter groups must contain at least one filter")
+			}
+			if err := qb.validateFilters(filter.Group.Filters, depth+1, count); err != nil {
+				return err
+			}
+			continue
+		}
+
+		*count++
+		if *count > 50 {
+			return fmt.Errorf("maximum 50 filters allowed")
+		}
+
+		// Validate operator and the shape of its value
+		operator := filterOperator(filter.Operator)
+		if !validOperators[operator] {
+			return fmt.Errorf("invalid operator '%s'", filter.Operator)
+		}
+		switch operator {
+		case "BETWEEN":
+			if arr, ok := filter.Value.([]interface{}); !ok || len(arr) != 2 {
+				return fmt.Errorf("BETWEEN on '%s' needs a list of two values", filter.Column)
+			}
+		case "RELATIVE_DATE":
+			value, _ := filter.Value.(string)
+			if _, _, err := relativeDateRange(value, time.Now()); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -186,16 +313,16 @@ func (qb *QueryBuilder) ValidateConfig(ctx context.Context, config *models.Visua
 }
 
 // buildSelectClause generates SELECT clause with columns and aggregations
-func (qb *QueryBuilder) buildSelectClause(config *models.VisualQueryConfig) string {
+func (qb *QueryBuilder) buildSelectClause(config *models.VisualQueryConfig, dialect SQLDialect) string {
 	var columns []string
 
 	// Add regular columns
 	for _, col := range config.Columns {
 		var colStr string
 		if col.Column == "*" {
-			colStr = fmt.Sprintf("%s.*", qb.sanitizeIdentifier(col.Table))
+			colStr = fmt.Sprintf("%s.*", qb.sanitizeIdentifier(col.Table, dialect))
 		} else {
-			colStr = fmt.Sprintf("%s.%s", qb.sanitizeIdentifier(col.Table), qb.sanitizeIdentifier(col.Column))
+			colStr = fmt.Sprintf("%s.%s", qb.sanitizeIdentifier(col.Table, dialect), qb.sanitizeIdentifier(col.Column, dialect))
 		}
 
 		// Add aggregation if specified
@@ -205,18 +332,28 @@ func (qb *QueryBuilder) buildSelectClause(config *models.VisualQueryConfig) stri
 
 		// Add alias if specified
 		if col.Alias != nil && *col.Alias != "" {
-			colStr = fmt.Sprintf("%s AS %s", colStr, qb.sanitizeIdentifier(*col.Alias))
+			colStr = fmt.Sprintf("%s AS %s", colStr, qb.sanitizeIdentifier(*col.Alias, dialect))
 		}
 
 		columns = append(columns, colStr)
 	}
 
+	// Add date buckets
+	for _, bucket := range config.DateBuckets {
+		columns = append(columns, fmt.Sprintf("%s AS %s", qb.dateBucketExpression(bucket, dialect), qb.sanitizeIdentifier(dateBucketAlias(bucket), dialect)))
+	}
+
+	// Add calculated columns
+	for _, calc := range config.CalculatedColumns {
+		columns = append(columns, fmt.Sprintf("(%s) AS %s", qb.calculatedColumnSQL(calc.Expression, dialect), qb.sanitizeIdentifier(calc.Alias, dialect)))
+	}
+
 	// Add aggregations
 	for _, agg := range config.Aggregations {
 		aggStr := fmt.Sprintf("%s(%s) AS %s",
 			strings.ToUpper(agg.Function),
-			qb.sanitizeIdentifier(agg.Column),
-			qb.sanitizeIdentifier(agg.Alias))
+			qb.sanitizeIdentifier(agg.Column, dialect),
+			qb.sanitizeIdentifier(agg.Alias, dialect))
 		columns = append(columns, aggStr)
 	}
 
@@ -227,127 +364,391 @@ func (qb *QueryBuilder) buildSelectClause(config *models.VisualQueryConfig) stri
 	return "SELECT\n    " + strings.Join(columns, ",\n    ")
 }
 
+// validateCalculatedColumn parses a calculated column's expression, which may use the
+// operators and functions of metric formulas but nothing else, and checks that its columns
+// are columns of the query's tables, named by their table or alias where qualified
+func validateCalculatedColumn(expression string, config *models.VisualQueryConfig, tableMap map[string]*TableInfo) error {
+	node, err := parseMetricFormula(expression)
+	if err != nil {
+		return err
+	}
+
+	tables := make(map[string]*TableInfo)
+	for _, table := range config.Tables {
+		tables[table.Name] = tableMap[table.Name]
+		if table.Alias != "" {
+			tables[table.Alias] = tableMap[table.Name]
+		}
+	}
+	for _, join := range config.Joins {
+		tables[join.LeftTable] = tableMap[join.LeftTable]
+		tables[join.RightTable] = tableMap[join.RightTable]
+	}
+	hasColumn := func(table *TableInfo, column string) bool {
+		if table == nil {
+			return false
+		}
+		for _, tableCol := range table.Columns {
+			if strings.EqualFold(tableCol.Name, column) {
+				return true
+			}
+		}
+		return false
+	}
+
+	node.walk(func(n *metricNode) {
+		if n.kind != metricColumn || err != nil {
+			return
+		}
+		name := strings.Join(n.parts, ".")
+		switch len(n.parts) {
+		case 1:
+			for _, table := range tables {
+				if hasColumn(table, n.parts[0]) {
+					return
+				}
+			}
+			err = fmt.Errorf("column '%s' does not exist in the query's tables", name)
+		case 2:
+			table, ok := tables[n.parts[0]]
+			if !ok {
+				err = fmt.Errorf("table '%s' is not in the query", n.parts[0])
+			} else if !hasColumn(table, n.parts[1]) {
+				err = fmt.Errorf("column '%s' does not exist in table '%s'", n.parts[1], n.parts[0])
+			}
+		default:
+			err = fmt.Errorf("column '%s' must be named by its table or alias", name)
+		}
+	})
+	return err
+}
+
+// calculatedColumnSQL renders a calculated column's expression, validated by
+// validateCalculatedColumn, with its column names quoted
+func (qb *QueryBuilder) calculatedColumnSQL(expression string, dialect SQLDialect) string {
+	node, err := parseMetricFormula(expression)
+	if err != nil {
+		return "NULL"
+	}
+	return node.render(func(n *metricNode) (string, bool) {
+		parts := make([]string, len(n.parts))
+		for i, part := range n.parts {
+			parts[i] = qb.sanitizeIdentifier(part, dialect)
+		}
+		return strings.Join(parts, "."), true
+	})
+}
+
 // buildFromClause generates FROM clause with tables
-func (qb *QueryBuilder) buildFromClause(config *models.VisualQueryConfig) string {
+func (qb *QueryBuilder) buildFromClause(config *models.VisualQueryConfig, dialect SQLDialect) string {
 	if len(config.Tables) == 0 {
 		return ""
 	}
 
 	table := config.Tables[0]
-	fromClause := fmt.Sprintf("FROM %s", qb.sanitizeIdentifier(table.Name))
+	fromClause := fmt.Sprintf("FROM %s", qb.sanitizeIdentifier(table.Name, dialect))
 	if table.Alias != "" {
-		fromClause = fmt.Sprintf("%s %s", fromClause, qb.sanitizeIdentifier(table.Alias))
+		fromClause = fmt.Sprintf("%s %s", fromClause, qb.sanitizeIdentifier(table.Alias, dialect))
 	}
 
 	return fromClause
 }
 
 // buildJoinClause generates JOIN clauses
-func (qb *QueryBuilder) buildJoinClause(config *models.VisualQueryConfig) string {
+func (qb *QueryBuilder) buildJoinClause(config *models.VisualQueryConfig, dialect SQLDialect) string {
 	var joins []string
 
 	for _, join := range config.Joins {
 		joinStr := fmt.Sprintf("%s JOIN %s ON %s.%s = %s.%s",
 			strings.ToUpper(join.Type),
-			qb.sanitizeIdentifier(join.RightTable),
-			qb.sanitizeIdentifier(join.LeftTable),
-			qb.sanitizeIdentifier(join.LeftColumn),
-			qb.sanitizeIdentifier(join.RightTable),
-			qb.sanitizeIdentifier(join.RightColumn))
+			qb.sanitizeIdentifier(join.RightTable, dialect),
+			qb.sanitizeIdentifier(join.LeftTable, dialect),
+			qb.sanitizeIdentifier(join.LeftColumn, dialect),
+			qb.sanitizeIdentifier(join.RightTable, dialect),
+			qb.sanitizeIdentifier(join.RightColumn, dialect))
 		joins = append(joins, joinStr)
 	}
 
 	return strings.Join(joins, "\n")
 }
 
-// buildWhereClause generates WHERE clause with filters (AND/OR logic)
-func (qb *QueryBuilder) buildWhereClause(config *models.VisualQueryConfig) (string, []interface{}) {
+// buildWhereClause generates WHERE clause with filters (AND/OR logic and nested groups)
+func (qb *QueryBuilder) buildWhereClause(config *models.VisualQueryConfig, dialect SQLDialect) (string, []interface{}) {
 	if len(config.Filters) == 0 {
 		return "", nil
 	}
 
-	var conditions []string
-	var params []interface{}
-	paramIndex := 1
+	params := &sqlParams{dialect: dialect}
+	conditions := qb.buildConditions(config.Filters, "", dialect, params, time.Now())
 
-	for i, filter := range config.Filters {
-		var condition string
+	whereClause := "WHERE " + strings.Join(conditions, "\n    ")
+	return whereClause, params.args
+}
 
-		// Handle different operators
-		switch strings.ToUpper(filter.Operator) {
-		case "IN":
-			// For IN operator, value should be an array
-			condition = fmt.Sprintf("%s IN ($%d)", qb.sanitizeIdentifier(filter.Column), paramIndex)
-			params = append(params, filter.Value)
-			paramIndex++
-		case "BETWEEN":
-			// For BETWEEN, value should be an array with 2 elements
-			condition = fmt.Sprintf("%s BETWEEN $%d AND $%d", qb.sanitizeIdentifier(filter.Column), paramIndex, paramIndex+1)
-			// Assuming filter.Value is []interface{}{start, end}
-			if arr, ok := filter.Value.([]interface{}); ok && len(arr) == 2 {
-				params = append(params, arr[0], arr[1])
-				paramIndex += 2
+// buildConditions generates the conditions of a list of filters, each but the first prefixed
+// with its logic operator. Inside a group every condition uses the group's logic.
+func (qb *QueryBuilder) buildConditions(filters []models.FilterCondition, groupLogic string, dialect SQLDialect, params *sqlParams, now time.Time) []string {
+	var conditions []string
+	for i, filter := range filters {
+		var condition string
+		if filter.Group != nil {
+			logic := strings.ToUpper(filter.Group.Logic)
+			if logic == "" {
+				logic = "AND"
 			}
-		default:
-			condition = fmt.Sprintf("%s %s $%d", qb.sanitizeIdentifier(filter.Column), filter.Operator, paramIndex)
-			params = append(params, filter.Value)
-			paramIndex++
+			nested := qb.buildConditions(filter.Group.Filters, logic, dialect, params, now)
+			condition = "(" + strings.Join(nested, " ") + ")"
+		} else {
+			condition = qb.buildCondition(filter, dialect, params, now)
 		}
 
 		// Add logic operator (AND/OR) except for first condition
 		if i > 0 {
-			logic := "AND"
-			if filter.Logic != "" {
-				logic = strings.ToUpper(filter.Logic)
+			logic := groupLogic
+			if logic == "" {
+				logic = "AND"
+				if filter.Logic != "" {
+					logic = strings.ToUpper(filter.Logic)
+				}
 			}
 			conditions = append(conditions, fmt.Sprintf("%s %s", logic, condition))
 		} else {
 			conditions = append(conditions, condition)
 		}
 	}
+	return conditions
+}
 
-	whereClause := "WHERE " + strings.Join(conditions, "\n    ")
-	return whereClause, params
+// buildCondition generates a single parameterised filter condition
+func (qb *QueryBuilder) buildCondition(filter models.FilterCondition, dialect SQLDialect, params *sqlParams, now time.Time) string {
+	column := qb.sanitizeIdentifier(filter.Column, dialect)
+
+	// Handle different operators
+	operator := filterOperator(filter.Operator)
+	switch operator {
+	case "IN", "NOT IN":
+		// For IN operators, value should be an array; each element gets its own placeholder
+		values, ok := filter.Value.([]interface{})
+		if !ok {
+			values = []interface{}{filter.Value}
+		}
+		if len(values) == 0 {
+			// Nothing is in an empty list
+			if operator == "IN" {
+				return "1 = 0"
+			}
+			return "1 = 1"
+		}
+		placeholders := make([]string, len(values))
+		for i, value := range values {
+			placeholders[i] = params.add(value)
+		}
+		return fmt.Sprintf("%s %s (%s)", column, operator, strings.Join(placeholders, ", "))
+	case "BETWEEN":
+		// For BETWEEN, value should be an array with 2 elements
+		if arr, ok := filter.Value.([]interface{}); ok && len(arr) == 2 {
+			return fmt.Sprintf("%s BETWEEN %s AND %s", column, params.add(arr[0]), params.add(arr[1]))
+		}
+		return "1 = 0"
+	case "IS NULL", "IS NOT NULL":
+		return fmt.Sprintf("%s %s", column, operator)
+	case "ILIKE":
+		return dialect.ILike(column, params.add(filter.Value))
+	case "RELATIVE_DATE":
+		// Resolved to a fixed range now, so the same SQL works in every dialect
+		value, _ := filter.Value.(string)
+		start, end, err := relativeDateRange(value, now)
+		if err != nil {
+			return "1 = 0"
+		}
+		return fmt.Sprintf("(%s >= %s AND %s < %s)", column, params.add(start), column, params.add(end))
+	default:
+		return fmt.Sprintf("%s %s %s", column, operator, params.add(filter.Value))
+	}
+}
+
+// filterOperator normalises the case and spacing of a filter operator ("not  in" is NOT IN)
+func filterOperator(operator string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(operator)), " ")
+}
+
+// usesRelativeDates reports whether any filter, including those in nested groups, is a
+// relative date whose SQL changes from day to day
+func usesRelativeDates(filters []models.FilterCondition) bool {
+	for _, filter := range filters {
+		if filter.Group != nil && usesRelativeDates(filter.Group.Filters) {
+			return true
+		}
+		if filter.Group == nil && filterOperator(filter.Operator) == "RELATIVE_DATE" {
+			return true
+		}
+	}
+	return false
 }
 
 // buildGroupByClause generates GROUP BY clause
-func (qb *QueryBuilder) buildGroupByClause(config *models.VisualQueryConfig) string {
+func (qb *QueryBuilder) buildGroupByClause(config *models.VisualQueryConfig, dialect SQLDialect) string {
 	var groupByCols []string
 	for _, col := range config.GroupBy {
-		groupByCols = append(groupByCols, qb.sanitizeIdentifier(col))
+		groupByCols = append(groupByCols, qb.sanitizeIdentifier(col, dialect))
+	}
+	// Group by the bucket expression itself, since not every dialect accepts aliases here
+	if isAggregateQuery(config) {
+		for _, bucket := range config.DateBuckets {
+			groupByCols = append(groupByCols, qb.dateBucketExpression(bucket, dialect))
+		}
 	}
 	return "GROUP BY " + strings.Join(groupByCols, ", ")
 }
 
+// dateBucketExpression truncates a date bucket's column to its grain
+func (qb *QueryBuilder) dateBucketExpression(bucket models.DateBucket, dialect SQLDialect) string {
+	column := fmt.Sprintf("%s.%s", qb.sanitizeIdentifier(bucket.Table, dialect), qb.sanitizeIdentifier(bucket.Column, dialect))
+	expr, err := dialect.DateTrunc(bucket.Grain, column)
+	if err != nil {
+		// Rejected by ValidateConfig
+		return column
+	}
+	return expr
+}
+
+// dateBucketAlias returns the output column name of a date bucket
+func dateBucketAlias(bucket models.DateBucket) string {
+	if bucket.Alias != "" {
+		return bucket.Alias
+	}
+	return bucket.Column + "_" + strings.ToLower(bucket.Grain)
+}
+
+var aggregateCall = regexp.MustCompile(`(?i)\b(SUM|AVG|COUNT|MIN|MAX)\s*\(`)
+
+// isAggregateQuery reports whether the query aggregates its rows
+func isAggregateQuery(config *models.VisualQueryConfig) bool {
+	if len(config.Aggregations) > 0 {
+		return true
+	}
+	for _, col := range config.Columns {
+		if col.Aggregation != nil && *col.Aggregation != "" {
+			return true
+		}
+	}
+	for _, calc := range config.CalculatedColumns {
+		if aggregateCall.MatchString(calc.Expression) {
+			return true
+		}
+	}
+	return false
+}
+
+// windowQueryAlias names the subquery that window functions are computed over
+const windowQueryAlias = "vq"
+
+// buildWindowQuery wraps a query to add its window columns
+func (qb *QueryBuilder) buildWindowQuery(config *models.VisualQueryConfig, query string, dialect SQLDialect) string {
+	columns := []string{windowQueryAlias + ".*"}
+	for _, window := range config.WindowColumns {
+		columns = append(columns, fmt.Sprintf("%s AS %s", qb.windowExpression(window, dialect), qb.sanitizeIdentifier(window.Alias, dialect)))
+	}
+	return "SELECT\n    " + strings.Join(columns, ",\n    ") + "\nFROM (\n" + query + "\n)" + dialect.subqueryAlias(windowQueryAlias)
+}
+
+// windowExpression generates the window function of a window column
+func (qb *QueryBuilder) windowExpression(window models.WindowColumn, dialect SQLDialect) string {
+	measure := qb.outerColumn(window.Column, dialect)
+
+	var partitionBy, orderBy []string
+	for _, col := range window.PartitionBy {
+		partitionBy = append(partitionBy, qb.outerColumn(col, dialect))
+	}
+	for _, order := range window.OrderBy {
+		direction := "ASC"
+		if strings.ToUpper(order.Direction) == "DESC" {
+			direction = "DESC"
+		}
+		orderBy = append(orderBy, fmt.Sprintf("%s %s", qb.outerColumn(order.Column, dialect), direction))
+	}
+
+	function := strings.ToUpper(window.Function)
+	switch function {
+	case "RANK", "DENSE_RANK", "ROW_NUMBER":
+		if len(orderBy) == 0 {
+			// Rank by the measure, highest first
+			orderBy = []string{measure + " DESC"}
+		}
+		return fmt.Sprintf("%s() OVER (%s)", function, windowSpec(partitionBy, orderBy, ""))
+	case "LAG", "LEAD":
+		offset := window.Offset
+		if offset == 0 {
+			offset = 1
+		}
+		return fmt.Sprintf("%s(%s, %d) OVER (%s)", function, measure, offset, windowSpec(partitionBy, orderBy, ""))
+	case "RUNNING_TOTAL":
+		return fmt.Sprintf("SUM(%s) OVER (%s)", measure, windowSpec(partitionBy, orderBy, "ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW"))
+	case "MOVING_AVERAGE":
+		size := window.Window
+		if size == 0 {
+			size = 3
+		}
+		if dialect == DialectSQLServer {
+			// SQL Server averages integers as integers
+			measure = "CAST(" + measure + " AS FLOAT)"
+		}
+		return fmt.Sprintf("AVG(%s) OVER (%s)", measure, windowSpec(partitionBy, orderBy, fmt.Sprintf("ROWS BETWEEN %d PRECEDING AND CURRENT ROW", size-1)))
+	case "PERCENT_OF_TOTAL":
+		return fmt.Sprintf("%s * 100.0 / NULLIF(SUM(%s) OVER (%s), 0)", measure, measure, windowSpec(partitionBy, nil, ""))
+	}
+	// Rejected by ValidateConfig
+	return "NULL"
+}
+
+// windowSpec generates the contents of an OVER clause
+func windowSpec(partitionBy, orderBy []string, frame string) string {
+	var parts []string
+	if len(partitionBy) > 0 {
+		parts = append(parts, "PARTITION BY "+strings.Join(partitionBy, ", "))
+	}
+	if len(orderBy) > 0 {
+		parts = append(parts, "ORDER BY "+strings.Join(orderBy, ", "))
+	}
+	if frame != "" {
+		parts = append(parts, frame)
+	}
+	return strings.Join(parts, " ")
+}
+
+// outerColumn references an output column of the query wrapped by buildWindowQuery;
+// a qualified name (table.column) refers to its column name
+func (qb *QueryBuilder) outerColumn(name string, dialect SQLDialect) string {
+	parts := strings.Split(strings.Trim(name, "."), ".")
+	return windowQueryAlias + "." + qb.sanitizeIdentifier(parts[len(parts)-1], dialect)
+}
+
 // buildOrderByClause generates ORDER BY clause
-func (qb *QueryBuilder) buildOrderByClause(config *models.VisualQueryConfig) string {
+func (qb *QueryBuilder) buildOrderByClause(config *models.VisualQueryConfig, dialect SQLDialect) string {
 	var orderByCols []string
 	for _, orderBy := range config.OrderBy {
 		direction := "ASC"
 		if strings.ToUpper(orderBy.Direction) == "DESC" {
 			direction = "DESC"
 		}
-		orderByCols = append(orderByCols, fmt.Sprintf("%s %s", qb.sanitizeIdentifier(orderBy.Column), direction))
+		column := qb.sanitizeIdentifier(orderBy.Column, dialect)
+		if len(config.WindowColumns) > 0 {
+			// Ordering applies to the wrapped query's output columns
+			column = qb.outerColumn(orderBy.Column, dialect)
+		}
+		orderByCols = append(orderByCols, fmt.Sprintf("%s %s", column, direction))
 	}
 	return "ORDER BY " + strings.Join(orderByCols, ", ")
 }
 
-// buildLimitClause generates LIMIT clause
-func (qb *QueryBuilder) buildLimitClause(config *models.VisualQueryConfig) string {
-	if config.Limit == nil {
-		return ""
-	}
-	return fmt.Sprintf("LIMIT %d", *config.Limit)
-}
+var unsafeIdentifierChars = regexp.MustCompile(`[^a-zA-Z0-9_.]`)
 
-// sanitizeIdentifier sanitizes SQL identifiers to prevent SQL injection
-func (qb *QueryBuilder) sanitizeIdentifier(identifier string) string {
+// sanitizeIdentifier sanitizes SQL identifiers to prevent SQL injection and quotes each
+// part of a qualified name (table.column) in the dialect's style
+func (qb *QueryBuilder) sanitizeIdentifier(identifier string, dialect SQLDialect) string {
 	// Remove any characters that are not alphanumeric, underscore, or dot
-	reg := regexp.MustCompile(`[^a-zA-Z0-9_.]`)
-	sanitized := reg.ReplaceAllString(identifier, "")
-
-	// Wrap in double quotes for PostgreSQL
-	return fmt.Sprintf("\"%s\"", sanitized)
+	sanitized := strings.Trim(unsafeIdentifierChars.ReplaceAllString(identifier, ""), ".")
+	return dialect.QuoteIdent(sanitized)
 }
 
 // ExecuteQuery executes a visual query configuration and returns results
@@ -374,18 +775,12 @@ func (qb *QueryBuilder) ExecuteQuery(ctx context.Context, config *models.VisualQ
 		return nil, err
 	}
 
-	// Execute query
-	limit := config.Limit
-	var offset *int
-
-	result, err := queryExecutor.Execute(ctx, conn, sql, limit, offset)
+	// Execute query; the generated SQL already carries the limit in the connection's dialect
+	result, err := queryExecutor.ExecuteWithArgs(ctx, conn, sql, nil, nil, params...)
 	if err != nil {
 		return nil, err
 	}
 
-	// Store params in result for debugging (if needed)
-	_ = params
-
 	// Store result in cache with tags for invalidation (if cache is available)
 	if qb.queryCache != nil {
 		tags := qb.queryCache.GenerateTags(visualQueryID, conn.ID, userID)
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"insight-engine-backend/models"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestDerivedColumns tests date buckets, calculated columns and window functions by running
+// the generated SQL against SQLite
+func TestDerivedColumns(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.Exec("CREATE TABLE orders (id INTEGER, amount INTEGER, created_at TEXT)")
+	db.Exec(`INSERT INTO orders VALUES (1, 100, '2026-01-05 10:00:00'), (2, 50, '2026-01-20 12:00:00'),
+		(3, 300, '2026-02-11 09:00:00'), (4, 150, '2026-03-02 16:30:00')`)
+
+	queryBuilder := NewQueryBuilder(NewQueryValidator([]string{}), nil, nil, nil)
+	config := &models.VisualQueryConfig{
+		Tables:            []models.TableSelection{{Name: "orders"}},
+		DateBuckets:       []models.DateBucket{{Table: "orders", Column: "created_at", Grain: "month", Alias: "month"}},
+		CalculatedColumns: []models.CalculatedColumn{{Expression: "SUM(orders.amount) / COUNT(*)", Alias: "average_order"}},
+		Aggregations:      []models.Aggregation{{Function: "SUM", Column: "orders.amount", Alias: "revenue"}},
+		WindowColumns: []models.WindowColumn{
+			{Function: "LAG", Column: "revenue", Alias: "previous_revenue", OrderBy: []models.OrderByClause{{Column: "month"}}},
+			{Function: "RUNNING_TOTAL", Column: "revenue", Alias: "cumulative_revenue", OrderBy: []models.OrderByClause{{Column: "month"}}},
+			{Function: "PERCENT_OF_TOTAL", Column: "revenue", Alias: "share"},
+			{Function: "RANK", Column: "revenue", Alias: "revenue_rank"},
+		},
+		OrderBy: []models.OrderByClause{{Column: "month"}},
+	}
+
+	sql, params := queryBuilder.generateSQL(config, DialectSQLite)
+	var rows []struct {
+		Month             string
+		Revenue           int
+		AverageOrder      int
+		PreviousRevenue   *int
+		CumulativeRevenue int
+		Share             float64
+		RevenueRank       int
+	}
+	if err := db.Raw(sql, params...).Scan(&rows).Error; err != nil {
+		t.Fatalf("Generated SQL failed: %v\n%s", err, sql)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 months, got %+v", rows)
+	}
+	january, march := rows[0], rows[2]
+	if january.Month != "2026-01-01" || january.Revenue != 150 || january.AverageOrder != 75 || january.PreviousRevenue != nil ||
+		january.Share != 25 || january.RevenueRank != 2 { // Tied with March
+		t.Errorf("Unexpected first month %+v", january)
+	}
+	if march.PreviousRevenue == nil || *march.PreviousRevenue != 300 || march.CumulativeRevenue != 600 || march.RevenueRank != 2 {
+		t.Errorf("Unexpected last month %+v", march)
+	}
+
+	sql, _ = queryBuilder.generateSQL(config, DialectSQLServer)
+	for _, expected := range []string{
+		"DATEADD(month, DATEDIFF(month, 0, [orders].[created_at]), 0) AS [month]",
+		"GROUP BY DATEADD(month, DATEDIFF(month, 0, [orders].[created_at]), 0)",
+		"(SUM([orders].[amount]) / COUNT(*)) AS [average_order]",
+		"LAG(vq.[revenue], 1) OVER (ORDER BY vq.[month] ASC) AS [previous_revenue]",
+		") AS vq\nORDER BY vq.[month] ASC",
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, sql)
+		}
+	}
+
+	tableMap := map[string]*TableInfo{"orders": {Name: "orders", Columns: []ColumnInfo{{Name: "amount"}, {Name: "created_at"}}}}
+	if err := queryBuilder.validateDerivedColumns(config, tableMap, DialectSQLite); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, invalid := range []*models.VisualQueryConfig{
+		{CalculatedColumns: []models.CalculatedColumn{{Expression: "(SELECT password FROM users)", Alias: "x"}}},
+		{CalculatedColumns: []models.CalculatedColumn{{Expression: "query_to_xml('sel' || 'ect * from users', true, true, '')", Alias: "x"}}},
+		{CalculatedColumns: []models.CalculatedColumn{{Expression: "query_to_xml('select 1', true, true, '')", Alias: "x"}}},
+		{CalculatedColumns: []models.CalculatedColumn{{Expression: "LENGTH(users.password)", Alias: "x"}}},
+		{Tables: []models.TableSelection{{Name: "orders"}}, CalculatedColumns: []models.CalculatedColumn{{Expression: "SUM(discount)", Alias: "x"}}},
+		{CalculatedColumns: []models.CalculatedColumn{{Expression: "amount * 2", Alias: "bad alias"}}},
+		{DateBuckets: []models.DateBucket{{Table: "orders", Column: "created_at", Grain: "fortnight"}}},
+		{WindowColumns: []models.WindowColumn{{Function: "LAG", Column: "revenue", Alias: "previous"}}},
+		{WindowColumns: []models.WindowColumn{{Function: "NTILE", Column: "revenue", Alias: "tile"}}},
+	} {
+		if err := queryBuilder.validateDerivedColumns(invalid, tableMap, DialectSQLite); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"insight-engine-backend/models"
+	"strings"
 	"testing"
 	"time"
 )
@@ -49,9 +50,8 @@ func TestBuildSQL_SimpleSelect(t *testing.T) {
 	ctx := context.Background()
 
 	// Build SQL (skip validation for unit test)
-	sql := queryBuilder.buildSelectClause(config) + "\n" +
-		queryBuilder.buildFromClause(config) + "\n" +
-		queryBuilder.buildLimitClause(config)
+	sql := DialectPostgres.LimitSQL(queryBuilder.buildSelectClause(config, DialectPostgres)+"\n"+
+		queryBuilder.buildFromClause(config, DialectPostgres), config.Limit, nil)
 
 	// Verify SQL structure
 	if sql == "" {
@@ -59,17 +59,17 @@ func TestBuildSQL_SimpleSelect(t *testing.T) {
 	}
 
 	// Check for SELECT clause
-	if !contains(sql, "SELECT") {
+	if !strings.Contains(sql, "SELECT") {
 		t.Error("Expected SELECT clause")
 	}
 
 	// Check for FROM clause
-	if !contains(sql, "FROM") {
+	if !strings.Contains(sql, "FROM") {
 		t.Error("Expected FROM clause")
 	}
 
 	// Check for LIMIT clause
-	if !contains(sql, "LIMIT 10") {
+	if !strings.Contains(sql, "LIMIT 10") {
 		t.Error("Expected LIMIT 10 clause")
 	}
 
@@ -107,9 +107,9 @@ func TestBuildSQL_WithJoins(t *testing.T) {
 				},
 			}
 
-			sql := queryBuilder.buildJoinClause(config)
+			sql := queryBuilder.buildJoinClause(config, DialectPostgres)
 
-			if !contains(sql, tt.expected) {
+			if !strings.Contains(sql, tt.expected) {
 				t.Errorf("Expected %s, got: %s", tt.expected, sql)
 			}
 		})
@@ -129,15 +129,15 @@ func TestBuildSQL_WithFilters(t *testing.T) {
 		},
 	}
 
-	whereClause, params := queryBuilder.buildWhereClause(config)
+	whereClause, params := queryBuilder.buildWhereClause(config, DialectPostgres)
 
 	// Check WHERE clause exists
-	if !contains(whereClause, "WHERE") {
+	if !strings.Contains(whereClause, "WHERE") {
 		t.Error("Expected WHERE clause")
 	}
 
 	// Check operators
-	if !contains(whereClause, "=") || !contains(whereClause, ">") {
+	if !strings.Contains(whereClause, "=") || !strings.Contains(whereClause, ">") {
 		t.Error("Expected operators in WHERE clause")
 	}
 
@@ -181,13 +181,13 @@ func TestBuildSQL_WithAggregations(t *testing.T) {
 				},
 			}
 
-			sql := queryBuilder.buildSelectClause(config)
+			sql := queryBuilder.buildSelectClause(config, DialectPostgres)
 
-			if !contains(sql, tt.expected) {
+			if !strings.Contains(sql, tt.expected) {
 				t.Errorf("Expected %s function, got: %s", tt.expected, sql)
 			}
 
-			if !contains(sql, "AS") {
+			if !strings.Contains(sql, "AS") {
 				t.Error("Expected AS keyword for alias")
 			}
 		})
@@ -203,13 +203,13 @@ func TestBuildSQL_WithGroupBy(t *testing.T) {
 		GroupBy: []string{"customer_id", "product_id"},
 	}
 
-	sql := queryBuilder.buildGroupByClause(config)
+	sql := queryBuilder.buildGroupByClause(config, DialectPostgres)
 
-	if !contains(sql, "GROUP BY") {
+	if !strings.Contains(sql, "GROUP BY") {
 		t.Error("Expected GROUP BY clause")
 	}
 
-	if !contains(sql, "customer_id") || !contains(sql, "product_id") {
+	if !strings.Contains(sql, "customer_id") || !strings.Contains(sql, "product_id") {
 		t.Error("Expected column names in GROUP BY")
 	}
 }
@@ -239,13 +239,13 @@ func TestBuildSQL_WithOrderBy(t *testing.T) {
 				},
 			}
 
-			sql := queryBuilder.buildOrderByClause(config)
+			sql := queryBuilder.buildOrderByClause(config, DialectPostgres)
 
-			if !contains(sql, "ORDER BY") {
+			if !strings.Contains(sql, "ORDER BY") {
 				t.Error("Expected ORDER BY clause")
 			}
 
-			if !contains(sql, tt.expected) {
+			if !strings.Contains(sql, tt.expected) {
 				t.Errorf("Expected %s direction, got: %s", tt.expected, sql)
 			}
 		})
@@ -254,17 +254,14 @@ func TestBuildSQL_WithOrderBy(t *testing.T) {
 
 // TestBuildSQL_WithLimit tests LIMIT clause
 func TestBuildSQL_WithLimit(t *testing.T) {
-	queryValidator := NewQueryValidator([]string{})
-	queryBuilder := NewQueryBuilder(queryValidator, nil, nil, nil)
-
 	limit := 50
 	config := &models.VisualQueryConfig{
 		Limit: &limit,
 	}
 
-	sql := queryBuilder.buildLimitClause(config)
+	sql := DialectPostgres.LimitSQL("SELECT 1", config.Limit, nil)
 
-	if !contains(sql, "LIMIT 50") {
+	if !strings.Contains(sql, "LIMIT 50") {
 		t.Errorf("Expected LIMIT 50, got: %s", sql)
 	}
 }
@@ -306,13 +303,13 @@ func TestBuildSQL_ComplexQuery(t *testing.T) {
 	}
 
 	// Build all clauses
-	selectClause := queryBuilder.buildSelectClause(config)
-	fromClause := queryBuilder.buildFromClause(config)
-	joinClause := queryBuilder.buildJoinClause(config)
-	whereClause, _ := queryBuilder.buildWhereClause(config)
-	groupByClause := queryBuilder.buildGroupByClause(config)
-	orderByClause := queryBuilder.buildOrderByClause(config)
-	limitClause := queryBuilder.buildLimitClause(config)
+	selectClause := queryBuilder.buildSelectClause(config, DialectPostgres)
+	fromClause := queryBuilder.buildFromClause(config, DialectPostgres)
+	joinClause := queryBuilder.buildJoinClause(config, DialectPostgres)
+	whereClause, _ := queryBuilder.buildWhereClause(config, DialectPostgres)
+	groupByClause := queryBuilder.buildGroupByClause(config, DialectPostgres)
+	orderByClause := queryBuilder.buildOrderByClause(config, DialectPostgres)
+	limitClause := DialectPostgres.LimitSQL("SELECT 1", config.Limit, nil)
 
 	// Verify all clauses are present
 	if selectClause == "" || fromClause == "" || joinClause == "" ||
@@ -321,22 +318,22 @@ func TestBuildSQL_ComplexQuery(t *testing.T) {
 	}
 
 	// Verify key components
-	if !contains(selectClause, "SELECT") {
+	if !strings.Contains(selectClause, "SELECT") {
 		t.Error("Expected SELECT in select clause")
 	}
-	if !contains(joinClause, "INNER JOIN") {
+	if !strings.Contains(joinClause, "INNER JOIN") {
 		t.Error("Expected INNER JOIN")
 	}
-	if !contains(whereClause, "WHERE") {
+	if !strings.Contains(whereClause, "WHERE") {
 		t.Error("Expected WHERE clause")
 	}
-	if !contains(groupByClause, "GROUP BY") {
+	if !strings.Contains(groupByClause, "GROUP BY") {
 		t.Error("Expected GROUP BY")
 	}
-	if !contains(orderByClause, "ORDER BY") {
+	if !strings.Contains(orderByClause, "ORDER BY") {
 		t.Error("Expected ORDER BY")
 	}
-	if !contains(limitClause, "LIMIT") {
+	if !strings.Contains(limitClause, "LIMIT") {
 		t.Error("Expected LIMIT")
 	}
 }
@@ -367,10 +364,10 @@ func TestBuildSQL_SQLInjectionPrevention(t *testing.T) {
 		},
 	}
 
-	sql := queryBuilder.buildFromClause(config)
+	sql := queryBuilder.buildFromClause(config, DialectPostgres)
 
 	// Sanitized identifier should not contain semicolon or --
-	if contains(sql, ";") || contains(sql, "--") {
+	if strings.Contains(sql, ";") || strings.Contains(sql, "--") {
 		t.Error("SQL injection attempt not prevented")
 	}
 
@@ -381,10 +378,10 @@ func TestBuildSQL_SQLInjectionPrevention(t *testing.T) {
 		},
 	}
 
-	sql2 := queryBuilder.buildSelectClause(config2)
+	sql2 := queryBuilder.buildSelectClause(config2, DialectPostgres)
 
 	// Sanitized identifier should not contain quotes
-	if contains(sql2, "'") {
+	if strings.Contains(sql2, "'") {
 		t.Error("SQL injection attempt not prevented in column")
 	}
 }
@@ -394,19 +391,3 @@ func TestBuildSQL_SQLInjectionPrevention(t *testing.T) {
 func intPtr(i int) *int {
 	return &i
 }
-
-func contains(s, substr string) bool {
-	return len(s) > 0 && len(substr) > 0 &&
-		(s == substr || len(s) >= len(substr) &&
-			(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr ||
-				findSubstring(s, substr)))
-}
-
-func findSubstring(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
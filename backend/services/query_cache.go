@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -32,11 +33,16 @@ func (qc *QueryCache) GenerateCacheKey(config *models.VisualQueryConfig, conn *m
 		ConnectionID string                    `json:"connectionId"`
 		UserID       string                    `json:"userId"`
 		Config       *models.VisualQueryConfig `json:"config"`
+		AsOf         string                    `json:"asOf,omitempty"`
 	}{
 		ConnectionID: conn.ID,
 		UserID:       userId,
 		Config:       config,
 	}
+	if usesRelativeDates(config.Filters) {
+		// "last_7_days" means different rows tomorrow
+		keyData.AsOf = time.Now().Format("2006-01-02")
+	}
 
 	// Marshal to JSON for consistent hashing
 	jsonData, err := json.Marshal(keyData)
@@ -65,9 +71,11 @@ func (qc *QueryCache) GetCachedResult(ctx context.Context, key string) (*models.
 		return nil, nil // Cache miss
 	}
 
-	// Unmarshal result
+	// Unmarshal result; numbers stay json.Number so decimals keep their precision
 	var result models.QueryResult
-	if err := json.Unmarshal(data, &result); err != nil {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
 		// Invalid cached data, delete it
 		_ = qc.redis.Delete(ctx, key)
 		return nil, fmt.Errorf("failed to unmarshal cached result: %w", err)
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"insight-engine-backend/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNestedFilters tests parenthesised filter groups and the null, negated and relative operators
+func TestNestedFilters(t *testing.T) {
+	queryBuilder := NewQueryBuilder(NewQueryValidator([]string{}), nil, nil, nil)
+	config := &models.VisualQueryConfig{
+		Filters: []models.FilterCondition{
+			{Group: &models.FilterGroup{Logic: "OR", Filters: []models.FilterCondition{
+				{Column: "status", Operator: "=", Value: "paid"},
+				{Group: &models.FilterGroup{Filters: []models.FilterCondition{
+					{Column: "status", Operator: "not in", Value: []interface{}{"void", "draft"}},
+					{Column: "refunded_at", Operator: "IS NULL"},
+				}}},
+			}}},
+			{Column: "customer", Operator: "ILIKE", Value: "%acme%", Logic: "AND"},
+			{Column: "created_at", Operator: "RELATIVE_DATE", Value: "last_7_days", Logic: "AND"},
+		},
+	}
+
+	whereClause, params := queryBuilder.buildWhereClause(config, DialectMySQL)
+	expected := "WHERE (`status` = ? OR (`status` NOT IN (?, ?) AND `refunded_at` IS NULL))\n" +
+		"    AND LOWER(`customer`) LIKE LOWER(?)\n" +
+		"    AND (`created_at` >= ? AND `created_at` < ?)"
+	if whereClause != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, whereClause)
+	}
+	if len(params) != 6 || params[0] != "paid" || params[2] != "draft" || params[3] != "%acme%" {
+		t.Errorf("Unexpected params %v", params)
+	}
+	if start, end := params[4].(time.Time), params[5].(time.Time); !end.AddDate(0, 0, -7).Equal(start) {
+		t.Errorf("Expected a 7 day range, got %v to %v", start, end)
+	}
+
+	whereClause, _ = queryBuilder.buildWhereClause(config, DialectPostgres)
+	if !strings.Contains(whereClause, `"status" = $1 OR ("status" NOT IN ($2, $3)`) || !strings.Contains(whereClause, `"customer" ILIKE $4`) {
+		t.Errorf("Unexpected Postgres WHERE clause %s", whereClause)
+	}
+
+	count := 0
+	if err := queryBuilder.validateFilters(config.Filters, 0, &count); err != nil || count != 5 {
+		t.Errorf("Expected 5 valid filters, got %d (%v)", count, err)
+	}
+	for _, invalid := range []models.FilterCondition{
+		{Group: &models.FilterGroup{Logic: "OR"}},
+		{Group: &models.FilterGroup{Logic: "XOR", Filters: config.Filters}},
+		{Column: "amount", Operator: "BETWEEN", Value: []interface{}{1}},
+		{Column: "created_at", Operator: "RELATIVE_DATE", Value: "next_week"},
+		{Column: "amount", Operator: "SOUNDS LIKE", Value: "x"},
+	} {
+		if err := queryBuilder.validateFilters([]models.FilterCondition{invalid}, 0, new(int)); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+	if !usesRelativeDates(config.Filters) || usesRelativeDates(config.Filters[:2]) {
+		t.Error("Expected only the relative date filter to be detected")
+	}
+}
+
+// TestRelativeDateRange tests resolving relative dates to date ranges
+func TestRelativeDateRange(t *testing.T) {
+	now := time.Date(2026, time.August, 16, 13, 45, 0, 0, time.UTC) // A Sunday
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+
+	cases := map[string][2]time.Time{
+		"today":         {day(time.August, 16), day(time.August, 17)},
+		"Yesterday":     {day(time.August, 15), day(time.August, 16)},
+		"this_week":     {day(time.August, 10), day(time.August, 17)},
+		"last week":     {day(time.August, 3), day(time.August, 10)},
+		"this_quarter":  {day(time.July, 1), day(time.October, 1)},
+		"last_quarter":  {day(time.April, 1), day(time.July, 1)},
+		"last_month":    {day(time.July, 1), day(time.August, 1)},
+		"last_7_days":   {day(time.August, 10), day(time.August, 17)},
+		"last 3 months": {day(time.June, 1), day(time.September, 1)},
+		"this_year":     {day(time.January, 1), time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for value, expected := range cases {
+		start, end, err := relativeDateRange(value, now)
+		if err != nil || !start.Equal(expected[0]) || !end.Equal(expected[1]) {
+			t.Errorf("%s: expected %v to %v, got %v to %v (%v)", value, expected[0], expected[1], start, end, err)
+		}
+	}
+
+	for _, value := range []string{"", "tomorrow", "last_0_days", "this_decade", "last_x_days"} {
+		if _, _, err := relativeDateRange(value, now); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"insight-engine-backend/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrQueryRejected is returned when governance limits turn a query away
+var ErrQueryRejected = errors.New("query rejected by governance policy")
+
+// ErrQueryNotFound is returned when cancelling a query that is not queued or running
+var ErrQueryNotFound = errors.New("query not found")
+
+// ErrExecutionIDInUse is returned when a query is started with the execution ID of one
+// that is still queued or running
+var ErrExecutionIDInUse = errors.New("execution ID is already in use")
+
+// States of a governed query
+const (
+	QueryStateQueued  = "queued"
+	QueryStateRunning = "running"
+)
+
+// policyCacheTTL is how long governance policies, and the user roles they are matched on,
+// are cached between reloads
+const policyCacheTTL = 30 * time.Second
+
+// maxCachedRoles is the number of cached user roles above which expired ones are dropped
+const maxCachedRoles = 10000
+
+type queryUserKey struct{}
+type queryExecutionIDKey struct{}
+type runningQueryKey struct{}
+
+// WithQueryUser marks the queries run with ctx as issued by a user, so that the policies of
+// the user's role and the per-user limits apply to them
+func WithQueryUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, queryUserKey{}, userID)
+}
+
+// queryUser returns the user set by WithQueryUser, or ""
+func queryUser(ctx context.Context) string {
+	userID, _ := ctx.Value(queryUserKey{}).(string)
+	return userID
+}
+
+// WithQueryExecutionID sets the ID under which the next query run with ctx is registered,
+// so that a client can cancel it before the query returns
+func WithQueryExecutionID(ctx context.Context, executionID string) context.Context {
+	return context.WithValue(ctx, queryExecutionIDKey{}, executionID)
+}
+
+// queryExecutionID returns the execution ID of the query run with ctx: the ID it was
+// registered under once admitted, otherwise the one requested with WithQueryExecutionID
+func queryExecutionID(ctx context.Context) string {
+	if query, ok := ctx.Value(runningQueryKey{}).(*registeredQuery); ok {
+		return query.ID
+	}
+	executionID, _ := ctx.Value(queryExecutionIDKey{}).(string)
+	return executionID
+}
+
+// onServerCancel registers how to stop the query running with ctx on the database server,
+// for dialects where cancelling the context only abandons the query client-side
+func onServerCancel(ctx context.Context, cancel func(context.Context) error) {
+	if query, ok := ctx.Value(runningQueryKey{}).(*registeredQuery); ok {
+		query.mu.Lock()
+		query.serverCancel = cancel
+		query.mu.Unlock()
+	}
+}
+
+// tracksServerCancel reports whether ctx belongs to a registered query, i.e. whether a
+// connector should register a server-side cancel for it
+func tracksServerCancel(ctx context.Context) bool {
+	_, ok := ctx.Value(runningQueryKey{}).(*registeredQuery)
+	return ok
+}
+
+// GovernedQuery is a queued or running query
+type GovernedQuery struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"userId"`
+	ConnectionID   string     `json:"connectionId"`
+	ConnectionName string     `json:"connectionName"`
+	SQL            string     `json:"sql"`
+	State          string     `json:"state"`
+	Policy         string     `json:"policy,omitempty"`
+	QueuedAt       time.Time  `json:"queuedAt"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+}
+
+// registeredQuery is a GovernedQuery with the means to cancel it
+type registeredQuery struct {
+	GovernedQuery
+
+	cancel       context.CancelFunc
+	mu           sync.Mutex
+	serverCancel func(context.Context) error
+}
+
+// QueryGovernor enforces QueryGovernancePolicy limits. Queries wait in a queue for a free
+// slot (or are rejected), run with the policy's maximum runtime, and are registered so
+// that they can be listed and cancelled while queued or running.
+type QueryGovernor struct {
+	db *gorm.DB
+
+	mu       sync.Mutex
+	policies []models.QueryGovernancePolicy
+	loadedAt time.Time
+	roles    map[string]cachedRole // By user ID
+	running  map[string]int        // Running queries per slot key
+	queued   map[string]int        // Queued queries per connection
+	queries  map[string]*registeredQuery
+	changed  chan struct{} // Closed and replaced whenever a slot frees up
+}
+
+// cachedRole is the role of a user as loaded at loadedAt
+type cachedRole struct {
+	role     string
+	loadedAt time.Time
+}
+
+// NewQueryGovernor creates a governor that reads its policies from db
+func NewQueryGovernor(db *gorm.DB) *QueryGovernor {
+	return &QueryGovernor{
+		db:      db,
+		roles:   make(map[string]cachedRole),
+		running: make(map[string]int),
+		queued:  make(map[string]int),
+		queries: make(map[string]*registeredQuery),
+		changed: make(chan struct{}),
+	}
+}
+
+// InvalidatePolicies makes the next query reload the policies, e.g. after one was edited
+func (g *QueryGovernor) InvalidatePolicies() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.loadedAt = time.Time{}
+}
+
+// Policy returns the policy that applies to a connection and role, or nil
+func (g *QueryGovernor) Policy(conn *models.Connection, role string) *models.QueryGovernancePolicy {
+	g.mu.Lock()
+	if time.Since(g.loadedAt) > policyCacheTTL && g.db != nil {
+		var policies []models.QueryGovernancePolicy
+		if err := g.db.Where("enabled = ?", true).Order("updated_at DESC").Find(&policies).Error; err != nil {
+			LogWarn("query_governor", "Failed to load governance policies", map[string]interface{}{"error": err})
+		} else {
+			g.policies = policies
+			g.loadedAt = time.Now()
+		}
+	}
+	policies := g.policies
+	g.mu.Unlock()
+
+	return matchPolicy(policies, conn.ID, role)
+}
+
+// matchPolicy picks the most specific enabled policy: connection and role, then connection,
+// then role, then global. Among equally specific ones the first wins.
+func matchPolicy(policies []models.QueryGovernancePolicy, connectionID, role string) *models.QueryGovernancePolicy {
+	var best *models.QueryGovernancePolicy
+	bestScore := -1
+	for i := range policies {
+		policy := &policies[i]
+		if !policy.Enabled {
+			continue
+		}
+		score := 0
+		if policy.ConnectionID != nil {
+			if *policy.ConnectionID != connectionID {
+				continue
+			}
+			score += 2
+		}
+		if policy.Role != nil {
+			if *policy.Role != role {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = policy, score
+		}
+	}
+	return best
+}
+
+// userRole looks up the role of a user. Roles are cached like policies, so that admitting a
+// query does not read the users table every time.
+func (g *QueryGovernor) userRole(userID string) string {
+	if userID == "" || g.db == nil {
+		return ""
+	}
+	g.mu.Lock()
+	cached, ok := g.roles[userID]
+	g.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) <= policyCacheTTL {
+		return cached.role
+	}
+
+	var user models.User
+	err := g.db.Select("id, role").Where("id = ?", userID).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return ""
+	}
+
+	g.mu.Lock()
+	if len(g.roles) >= maxCachedRoles {
+		for id, role := range g.roles {
+			if time.Since(role.loadedAt) > policyCacheTTL {
+				delete(g.roles, id)
+			}
+		}
+	}
+	g.roles[userID] = cachedRole{role: user.Role, loadedAt: time.Now()}
+	g.mu.Unlock()
+	return user.Role
+}
+
+// IsAdmin reports whether a user may see and cancel every user's queries
+func (g *QueryGovernor) IsAdmin(userID string) bool {
+	return g.userRole(userID) == "admin"
+}
+
+// admit waits until the policy of a query allows it to run and registers it. The returned
+// context ends when the policy's maximum runtime (or defaultRuntime) elapses or the query is
+// cancelled; release must be called when the query finishes. A nil governor only applies
+// defaultRuntime.
+func (g *QueryGovernor) admit(ctx context.Context, conn *models.Connection, sqlQuery string, defaultRuntime time.Duration) (context.Context, *models.QueryGovernancePolicy, func(), error) {
+	if g == nil {
+		runCtx, cancel := context.WithTimeout(ctx, defaultRuntime)
+		return runCtx, nil, cancel, nil
+	}
+
+	userID := queryUser(ctx)
+	policy := g.Policy(conn, g.userRole(userID))
+	runtime := defaultRuntime
+	queueTimeout := time.Duration(0)
+	policyName := ""
+	if policy != nil {
+		policyName = policy.Name
+		if policy.MaxRuntimeSeconds > 0 {
+			runtime = time.Duration(policy.MaxRuntimeSeconds) * time.Second
+		}
+		queueTimeout = time.Duration(policy.QueueTimeoutSeconds) * time.Second
+	}
+	if queueTimeout <= 0 {
+		queueTimeout = runtime
+	}
+
+	executionID := queryExecutionID(ctx)
+	if executionID == "" {
+		executionID = uuid.New().String()
+	}
+
+	queryCtx, cancel := context.WithCancel(ctx)
+	query := &registeredQuery{
+		GovernedQuery: GovernedQuery{
+			ID:             executionID,
+			UserID:         userID,
+			ConnectionID:   conn.ID,
+			ConnectionName: conn.Name,
+			SQL:            sqlQuery,
+			State:          QueryStateQueued,
+			Policy:         policyName,
+			QueuedAt:       time.Now(),
+		},
+		cancel: cancel,
+	}
+	connKey := "conn:" + conn.ID
+	userKey := ""
+	if userID != "" {
+		userKey = "user:" + conn.ID + ":" + userID
+	}
+
+	g.mu.Lock()
+	if _, exists := g.queries[query.ID]; exists {
+		g.mu.Unlock()
+		cancel()
+		return nil, policy, nil, fmt.Errorf("%w: %s", ErrExecutionIDInUse, query.ID)
+	}
+	g.queries[query.ID] = query
+	queuing := false
+	var timeout <-chan time.Time
+	for !g.hasSlotLocked(policy, connKey, userKey) {
+		if policy.QueueMode == models.QueueModeReject {
+			g.dropLocked(query, queuing, conn.ID)
+			g.mu.Unlock()
+			cancel()
+			return nil, policy, nil, fmt.Errorf("%w %q: concurrent query limit reached", ErrQueryRejected, policy.Name)
+		}
+		if !queuing {
+			if policy.MaxQueueLength > 0 && g.queued[conn.ID] >= policy.MaxQueueLength {
+				g.dropLocked(query, queuing, conn.ID)
+				g.mu.Unlock()
+				cancel()
+				return nil, policy, nil, fmt.Errorf("%w %q: query queue is full", ErrQueryRejected, policy.Name)
+			}
+			queuing = true
+			g.queued[conn.ID]++
+			timer := time.NewTimer(queueTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		changed := g.changed
+		g.mu.Unlock()
+
+		select {
+		case <-changed:
+			g.mu.Lock()
+		case <-queryCtx.Done():
+			g.mu.Lock()
+			g.dropLocked(query, queuing, conn.ID)
+			g.mu.Unlock()
+			cancel()
+			return nil, policy, nil, fmt.Errorf("query cancelled while queued: %w", queryCtx.Err())
+		case <-timeout:
+			g.mu.Lock()
+			g.dropLocked(query, queuing, conn.ID)
+			g.mu.Unlock()
+			cancel()
+			return nil, policy, nil, fmt.Errorf("%w %q: timed out waiting for a free slot", ErrQueryRejected, policy.Name)
+		}
+	}
+
+	if queuing {
+		g.queued[conn.ID]--
+	}
+	g.running[connKey]++
+	if userKey != "" {
+		g.running[userKey]++
+	}
+	now := time.Now()
+	query.State = QueryStateRunning
+	query.StartedAt = &now
+	g.mu.Unlock()
+
+	runCtx, cancelRun := context.WithTimeout(queryCtx, runtime)
+	runCtx = context.WithValue(runCtx, runningQueryKey{}, query)
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			cancelRun()
+			cancel()
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			g.running[connKey]--
+			if userKey != "" {
+				g.running[userKey]--
+			}
+			delete(g.queries, query.ID)
+			g.notifyLocked()
+		})
+	}
+	return runCtx, policy, release, nil
+}
+
+// hasSlotLocked reports whether the policy allows another running query
+func (g *QueryGovernor) hasSlotLocked(policy *models.QueryGovernancePolicy, connKey, userKey string) bool {
+	if policy == nil {
+		return true
+	}
+	if policy.MaxConcurrentQueries > 0 && g.running[connKey] >= policy.MaxConcurrentQueries {
+		return false
+	}
+	if userKey != "" && policy.MaxConcurrentPerUser > 0 && g.running[userKey] >= policy.MaxConcurrentPerUser {
+		return false
+	}
+	return true
+}
+
+// dropLocked unregisters a query that never ran
+func (g *QueryGovernor) dropLocked(query *registeredQuery, queued bool, connectionID string) {
+	delete(g.queries, query.ID)
+	if queued {
+		g.queued[connectionID]--
+	}
+}
+
+// notifyLocked wakes the queued queries so they can retry for a slot
+func (g *QueryGovernor) notifyLocked() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// Queries lists the queued and running queries of a user, or of every user when all is set,
+// oldest first
+func (g *QueryGovernor) Queries(userID string, all bool) []GovernedQuery {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	queries := make([]GovernedQuery, 0, len(g.queries))
+	for _, query := range g.queries {
+		if all || query.UserID == userID {
+			queries = append(queries, query.GovernedQuery)
+		}
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].QueuedAt.Before(queries[j].QueuedAt)
+	})
+	return queries
+}
+
+// serverCancelTimeout bounds the statement that cancels a query on the database server
+const serverCancelTimeout = 10 * time.Second
+
+// Cancel stops a queued or running query. Users may only cancel their own queries unless
+// all is set. A running query is first cancelled on the database server where the dialect
+// supports it, then its context is cancelled.
+func (g *QueryGovernor) Cancel(id, userID string, all bool) error {
+	g.mu.Lock()
+	query, exists := g.queries[id]
+	g.mu.Unlock()
+	if !exists || (!all && query.UserID != userID) {
+		return ErrQueryNotFound
+	}
+
+	query.mu.Lock()
+	serverCancel := query.serverCancel
+	query.mu.Unlock()
+	if serverCancel != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), serverCancelTimeout)
+		if err := serverCancel(ctx); err != nil {
+			LogWarn("query_governor", "Server-side cancel failed", map[string]interface{}{
+				"query_id": id,
+				"error":    err,
+			})
+		}
+		cancel()
+	}
+	query.cancel()
+
+	LogInfo("query_governor", "Query cancelled", map[string]interface{}{
+		"query_id":      id,
+		"connection_id": query.ConnectionID,
+		"cancelled_by":  userID,
+		"server_cancel": serverCancel != nil,
+	})
+	return nil
+}
+
+// ListPolicies returns every governance policy, enabled or not
+func (g *QueryGovernor) ListPolicies() ([]models.QueryGovernancePolicy, error) {
+	var policies []models.QueryGovernancePolicy
+	err := g.db.Order("name").Find(&policies).Error
+	return policies, err
+}
+
+// GetPolicy returns a governance policy by ID
+func (g *QueryGovernor) GetPolicy(id string) (*models.QueryGovernancePolicy, error) {
+	var policy models.QueryGovernancePolicy
+	if err := g.db.Where("id = ?", id).First(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SavePolicy validates and creates or updates a governance policy
+func (g *QueryGovernor) SavePolicy(policy *models.QueryGovernancePolicy) error {
+	if err := validatePolicy(policy); err != nil {
+		return err
+	}
+	if policy.ID == "" {
+		policy.ID = uuid.New().String()
+	}
+	if err := g.db.Save(policy).Error; err != nil {
+		return err
+	}
+	g.InvalidatePolicies()
+	return nil
+}
+
+// DeletePolicy removes a governance policy
+func (g *QueryGovernor) DeletePolicy(id string) error {
+	result := g.db.Where("id = ?", id).Delete(&models.QueryGovernancePolicy{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	g.InvalidatePolicies()
+	return nil
+}
+
+// validatePolicy checks the limits of a policy and fills in defaults
+func validatePolicy(policy *models.QueryGovernancePolicy) error {
+	if policy.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch policy.QueueMode {
+	case "":
+		policy.QueueMode = models.QueueModeQueue
+	case models.QueueModeQueue, models.QueueModeReject:
+	default:
+		return fmt.Errorf("invalid queueMode: %s", policy.QueueMode)
+	}
+	for name, value := range map[string]int{
+		"maxConcurrentQueries": policy.MaxConcurrentQueries,
+		"maxConcurrentPerUser": policy.MaxConcurrentPerUser,
+		"maxRuntimeSeconds":    policy.MaxRuntimeSeconds,
+		"maxRows":              policy.MaxRows,
+		"maxQueueLength":       policy.MaxQueueLength,
+		"queueTimeoutSeconds":  policy.QueueTimeoutSeconds,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s cannot be negative", name)
+		}
+	}
+	if policy.ConnectionID != nil && *policy.ConnectionID == "" {
+		policy.ConnectionID = nil
+	}
+	if policy.Role != nil && *policy.Role == "" {
+		policy.Role = nil
+	}
+	return nil
+}
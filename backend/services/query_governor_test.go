@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"insight-engine-backend/models"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestQueryGovernor tests policy matching, queueing, rejection, cancellation, execution IDs
+// and row limits
+func TestQueryGovernor(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.QueryGovernancePolicy{}, &models.User{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	db.Create(&models.User{ID: "analyst-1", Email: "a@example.com", Username: "analyst", Role: "analyst"})
+
+	governor := NewQueryGovernor(db)
+	connID, role := "conn-1", "analyst"
+	for _, policy := range []*models.QueryGovernancePolicy{
+		{Name: "global", Enabled: true, MaxRows: 1000},
+		{Name: "analysts", Role: &role, Enabled: true, MaxConcurrentPerUser: 1, MaxRows: 2, QueueTimeoutSeconds: 5},
+		{Name: "replica", ConnectionID: &connID, Enabled: true, MaxConcurrentQueries: 1, QueueMode: models.QueueModeReject},
+	} {
+		if err := governor.SavePolicy(policy); err != nil {
+			t.Fatalf("Failed to save policy: %v", err)
+		}
+	}
+	if err := governor.SavePolicy(&models.QueryGovernancePolicy{Name: "bad", MaxRows: -1}); err == nil {
+		t.Error("Expected negative limits to be rejected")
+	}
+
+	replica := &models.Connection{ID: "conn-1", Name: "replica"}
+	warehouse := &models.Connection{ID: "conn-2", Name: "warehouse"}
+	if p := governor.Policy(replica, "analyst"); p == nil || p.Name != "replica" {
+		t.Errorf("Expected the connection policy to win, got %+v", p)
+	}
+	if p := governor.Policy(warehouse, "analyst"); p == nil || p.Name != "analysts" {
+		t.Errorf("Expected the role policy, got %+v", p)
+	}
+	if p := governor.Policy(warehouse, "viewer"); p == nil || p.Name != "global" {
+		t.Errorf("Expected the global policy, got %+v", p)
+	}
+
+	// The replica rejects a second concurrent query
+	ctx := context.Background()
+	_, _, release, err := governor.admit(ctx, replica, "SELECT 1", time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, _, _, err := governor.admit(ctx, replica, "SELECT 2", time.Minute); !errors.Is(err, ErrQueryRejected) {
+		t.Errorf("Expected the second query to be rejected, got %v", err)
+	}
+	release()
+
+	// An analyst's second query waits until the first finishes
+	userCtx := WithQueryUser(ctx, "analyst-1")
+	_, _, release, err = governor.admit(userCtx, warehouse, "SELECT 1", time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	admitted := make(chan error, 1)
+	go func() {
+		_, _, release, err := governor.admit(userCtx, warehouse, "SELECT 2", time.Minute)
+		if err == nil {
+			release()
+		}
+		admitted <- err
+	}()
+	waitForQueries(t, governor, 2)
+	if queries := governor.Queries("analyst-1", false); queries[1].State != QueryStateQueued {
+		t.Errorf("Expected the second query to be queued, got %+v", queries)
+	}
+	release()
+	if err := <-admitted; err != nil {
+		t.Errorf("Expected the queued query to run, got %v", err)
+	}
+
+	// A queued query can be cancelled by its owner but not by another user
+	_, _, release, _ = governor.admit(userCtx, warehouse, "SELECT 1", time.Minute)
+	go func() {
+		_, _, _, err := governor.admit(userCtx, warehouse, "SELECT 2", time.Minute)
+		admitted <- err
+	}()
+	queued := waitForQueries(t, governor, 2)[1]
+	if err := governor.Cancel(queued.ID, "someone-else", false); err != ErrQueryNotFound {
+		t.Errorf("Expected another user's cancel to fail, got %v", err)
+	}
+	if err := governor.Cancel(queued.ID, "analyst-1", false); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := <-admitted; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the queued query to be cancelled, got %v", err)
+	}
+	release()
+
+	// A query registered under a client-chosen execution ID is cancelled on the server first
+	execCtx := WithQueryExecutionID(ctx, "exec-1")
+	runCtx, _, release, err := governor.admit(execCtx, warehouse, "SELECT pg_sleep(60)", time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, _, _, err := governor.admit(execCtx, warehouse, "SELECT 1", time.Minute); !errors.Is(err, ErrExecutionIDInUse) {
+		t.Errorf("Expected a duplicate execution ID to be refused, got %v", err)
+	}
+	serverCancelled := false
+	onServerCancel(runCtx, func(context.Context) error {
+		serverCancelled = runCtx.Err() == nil
+		return nil
+	})
+	if err := governor.Cancel("exec-1", "", false); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if !serverCancelled || runCtx.Err() == nil {
+		t.Errorf("Expected a server-side cancel before the context was cancelled")
+	}
+	release()
+
+	// Execute cuts results off at the policy's row limit
+	connects := 0
+	RegisterConnector("governed", func(conn *models.Connection) (Connector, error) {
+		return &memoryConnector{connects: &connects, rows: [][]interface{}{{int64(1), "a"}, {int64(2), "b"}, {int64(3), "c"}}}, nil
+	})
+	qe := NewQueryExecutor()
+	defer qe.Close()
+	qe.SetGovernor(governor)
+	result, err := qe.Execute(userCtx, &models.Connection{ID: "conn-3", Type: "governed"}, "SELECT * FROM orders", nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.RowCount != 2 || !result.Truncated {
+		t.Errorf("Expected 2 rows and a truncated flag, got %d rows (truncated %v)", result.RowCount, result.Truncated)
+	}
+	if len(governor.Queries("", true)) != 0 {
+		t.Error("Expected no queries to remain registered")
+	}
+
+	// Roles are cached like policies, so role changes apply once the cache expires
+	db.Model(&models.User{}).Where("id = ?", "analyst-1").Update("role", "admin")
+	if governor.IsAdmin("analyst-1") {
+		t.Error("Expected the cached role to be used")
+	}
+	governor.mu.Lock()
+	governor.roles["analyst-1"] = cachedRole{role: "analyst", loadedAt: time.Now().Add(-2 * policyCacheTTL)}
+	governor.mu.Unlock()
+	if !governor.IsAdmin("analyst-1") {
+		t.Error("Expected the role to be reloaded once the cache expired")
+	}
+}
+
+// waitForQueries waits until n queries are registered and returns them
+func waitForQueries(t *testing.T, governor *QueryGovernor, n int) []GovernedQuery {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if queries := governor.Queries("", true); len(queries) == n {
+			return queries
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d registered queries", n)
+	return nil
+}
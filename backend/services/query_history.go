@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"insight-engine-backend/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Sources of a logged query
+const (
+	QuerySourceAdHoc    = "adhoc"
+	QuerySourceSaved    = "saved"
+	QuerySourceVisual   = "visual"
+	QuerySourceSemantic = "semantic"
+	QuerySourceAI       = "ai"
+	QuerySourceExport   = "export"
+	QuerySourceSystem   = "system" // Alerts, exports and blends run in the background
+)
+
+type querySourceKey struct{}
+
+type querySource struct {
+	source  string
+	queryID string
+}
+
+// WithQuerySource labels the queries run with ctx in the query history. queryID names the
+// saved or visual query they came from, if any.
+func WithQuerySource(ctx context.Context, source, queryID string) context.Context {
+	return context.WithValue(ctx, querySourceKey{}, querySource{source: source, queryID: queryID})
+}
+
+// QueryHistoryFilter selects entries of the query history. Zero values do not filter.
+type QueryHistoryFilter struct {
+	UserID        string
+	ConnectionID  string
+	Status        string
+	Source        string
+	MinDurationMs int64
+	MaxDurationMs int64
+	Search        string // Case-insensitive match on the SQL text
+	From          *time.Time
+	To            *time.Time
+	Limit         int
+	Offset        int
+}
+
+// QueryHistory records every query run through the QueryExecutor in QueryExecutionLog
+type QueryHistory struct {
+	db *gorm.DB
+}
+
+// NewQueryHistory creates a query history stored in db
+func NewQueryHistory(db *gorm.DB) *QueryHistory {
+	return &QueryHistory{db: db}
+}
+
+// record logs a finished query. A nil history records nothing; failing to record is logged
+// but never fails the query.
+func (h *QueryHistory) record(ctx context.Context, conn *models.Connection, sqlQuery string, started time.Time, rowCount int, queryErr error) {
+	if h == nil {
+		return
+	}
+	source, _ := ctx.Value(querySourceKey{}).(querySource)
+	if source.source == "" {
+		source.source = QuerySourceAdHoc
+	}
+	entry := models.QueryExecutionLog{
+		ID:            uuid.New().String(),
+		SQL:           sqlQuery,
+		ConnectionID:  conn.ID,
+		UserID:        queryUser(ctx),
+		Source:        source.source,
+		Status:        queryStatus(queryErr),
+		RowCount:      rowCount,
+		ExecutionTime: time.Since(started).Milliseconds(),
+	}
+	if source.queryID != "" {
+		entry.QueryID = &source.queryID
+	}
+	if executionID := queryExecutionID(ctx); executionID != "" {
+		entry.ExecutionID = &executionID
+	}
+	if queryErr != nil {
+		message := queryErr.Error()
+		entry.ErrorMessage = &message
+	}
+	if err := h.db.Create(&entry).Error; err != nil {
+		LogWarn("query_history", "Failed to record query execution", map[string]interface{}{
+			"connection_id": conn.ID,
+			"error":         err,
+		})
+	}
+}
+
+// queryStatus classifies how a query ended
+func queryStatus(err error) string {
+	switch {
+	case err == nil:
+		return models.QueryStatusSuccess
+	case errors.Is(err, context.DeadlineExceeded):
+		return models.QueryStatusTimeout
+	case errors.Is(err, context.Canceled):
+		return models.QueryStatusCancelled
+	case errors.Is(err, ErrQueryRejected):
+		return models.QueryStatusRejected
+	default:
+		return models.QueryStatusError
+	}
+}
+
+// Search returns the entries matching filter, newest first, with the total number of matches
+func (h *QueryHistory) Search(filter QueryHistoryFilter) ([]models.QueryExecutionLog, int64, error) {
+	query := h.db.Model(&models.QueryExecutionLog{})
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.ConnectionID != "" {
+		query = query.Where("connection_id = ?", filter.ConnectionID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.MinDurationMs > 0 {
+		query = query.Where("execution_time >= ?", filter.MinDurationMs)
+	}
+	if filter.MaxDurationMs > 0 {
+		query = query.Where("execution_time <= ?", filter.MaxDurationMs)
+	}
+	if filter.Search != "" {
+		query = query.Where(`LOWER(sql) LIKE ? ESCAPE '\'`, "%"+strings.ToLower(escapeLike(filter.Search))+"%")
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	var entries []models.QueryExecutionLog
+	err := query.Order("created_at DESC").Limit(limit).Offset(filter.Offset).Find(&entries).Error
+	return entries, total, err
+}
+
+// Get returns a history entry
+func (h *QueryHistory) Get(id string) (*models.QueryExecutionLog, error) {
+	var entry models.QueryExecutionLog
+	if err := h.db.Where("id = ?", id).First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// escapeLike escapes the LIKE wildcards of a search term
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"insight-engine-backend/models"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestQueryHistory tests that executed, streamed and failed queries are recorded and searchable
+func TestQueryHistory(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.QueryExecutionLog{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	connects := 0
+	RegisterConnector("history", func(conn *models.Connection) (Connector, error) {
+		return &memoryConnector{connects: &connects, rows: [][]interface{}{{int64(1), "a"}, {int64(2), "b"}}}, nil
+	})
+	RegisterConnector("history-down", func(conn *models.Connection) (Connector, error) {
+		return nil, errors.New("connection refused")
+	})
+	qe := NewQueryExecutor()
+	defer qe.Close()
+	history := NewQueryHistory(db)
+	qe.SetHistory(history)
+
+	conn := &models.Connection{ID: "conn-1", Type: "history"}
+	ctx := WithQuerySource(WithQueryExecutionID(WithQueryUser(context.Background(), "user-1"), "exec-1"), QuerySourceSaved, "saved-1")
+	if _, err := qe.Execute(ctx, conn, "SELECT * FROM orders", nil, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stream, err := qe.Stream(WithQueryUser(context.Background(), "user-2"), conn, "SELECT id FROM customers")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for stream.Next() {
+	}
+	stream.Close()
+	stream.Close()
+
+	down := &models.Connection{ID: "conn-2", Type: "history-down"}
+	if _, err := qe.Execute(WithQueryUser(context.Background(), "user-1"), down, "SELECT 1", nil, nil); err == nil {
+		t.Fatal("Expected the query to fail")
+	}
+
+	entries, total, err := history.Search(QueryHistoryFilter{})
+	if err != nil || total != 3 {
+		t.Fatalf("Expected 3 recorded runs, got %d (%v)", total, err)
+	}
+
+	entries, _, _ = history.Search(QueryHistoryFilter{UserID: "user-1", Status: models.QueryStatusSuccess})
+	if len(entries) != 1 {
+		t.Fatalf("Expected one successful run by user-1, got %+v", entries)
+	}
+	saved := entries[0]
+	if saved.Source != QuerySourceSaved || saved.QueryID == nil || *saved.QueryID != "saved-1" ||
+		saved.ExecutionID == nil || *saved.ExecutionID != "exec-1" || saved.RowCount != 2 {
+		t.Errorf("Unexpected entry %+v", saved)
+	}
+
+	entries, _, _ = history.Search(QueryHistoryFilter{Search: "CUSTOMERS"})
+	if len(entries) != 1 || entries[0].UserID != "user-2" || entries[0].Source != QuerySourceAdHoc || entries[0].RowCount != 2 {
+		t.Errorf("Expected the streamed run to be found by its SQL, got %+v", entries)
+	}
+	if entries, _, _ := history.Search(QueryHistoryFilter{Search: "%"}); len(entries) != 0 {
+		t.Errorf("Expected LIKE wildcards in the search to be matched literally, got %d entries", len(entries))
+	}
+
+	entries, _, _ = history.Search(QueryHistoryFilter{ConnectionID: "conn-2"})
+	if len(entries) != 1 || entries[0].Status != models.QueryStatusError || entries[0].ErrorMessage == nil {
+		t.Errorf("Expected the failed run with its error, got %+v", entries)
+	}
+
+	if status := queryStatus(context.DeadlineExceeded); status != models.QueryStatusTimeout {
+		t.Errorf("Expected a timeout status, got %s", status)
+	}
+}
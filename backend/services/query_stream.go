@@ -0,0 +1,319 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"insight-engine-backend/models"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamQueryTimeout bounds how long a streamed result set may stay open
+const streamQueryTimeout = 30 * time.Minute
+
+// QueryStream is an open result set that is read one row at a time
+type QueryStream struct {
+	Columns []ResultColumn
+
+	rows   RowIterator
+	cancel context.CancelFunc
+	values []interface{}
+	err    error
+	done   bool
+
+	keyIndex int         // Column tracked for keyset pagination, or -1
+	lastKey  interface{} // Key of the last row read
+	pageSize int         // Rows requested by StreamPage, 0 when unlimited
+	read     int
+
+	maxRows   int // Row limit of the governance policy, 0 when unlimited
+	truncated bool
+}
+
+// QueryPage selects a window of a query's rows. With KeyColumn set, rows are paged by that
+// column (keyset pagination) starting after the Cursor of the previous page; otherwise
+// Offset skips rows. Limit of 0 streams every row.
+type QueryPage struct {
+	Limit     int           `json:"limit"`
+	Offset    int           `json:"offset"`
+	KeyColumn string        `json:"keyColumn"`
+	Cursor    string        `json:"cursor"`
+	Args      []interface{} `json:"-"`
+}
+
+// StreamPage streams one page of a query using the connection's dialect for limiting
+func (qe *QueryExecutor) StreamPage(ctx context.Context, conn *models.Connection, sqlQuery string, page QueryPage) (*QueryStream, error) {
+	dialect := DialectFor(conn.Type)
+	args := page.Args
+
+	if page.KeyColumn != "" {
+		if dialect == DialectMongoDB {
+			return nil, fmt.Errorf("keyset pagination is not supported for %s connections", conn.Type)
+		}
+		if page.Limit <= 0 {
+			return nil, fmt.Errorf("keyset pagination requires a limit")
+		}
+		after, err := decodeQueryCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		var keyArgs []interface{}
+		sqlQuery, keyArgs = dialect.KeysetSQL(sqlQuery, page.KeyColumn, after, page.Limit, len(args))
+		args = append(append([]interface{}{}, args...), keyArgs...)
+	} else {
+		var limit, offset *int
+		if page.Limit > 0 {
+			limit = &page.Limit
+		}
+		if page.Offset > 0 {
+			offset = &page.Offset
+		}
+		sqlQuery = dialect.LimitSQL(sqlQuery, limit, offset)
+	}
+
+	stream, err := qe.Stream(ctx, conn, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	stream.pageSize = page.Limit
+	if page.KeyColumn != "" {
+		for i, column := range stream.Columns {
+			if strings.EqualFold(column.Name, page.KeyColumn) {
+				stream.keyIndex = i
+				break
+			}
+		}
+	}
+	return stream, nil
+}
+
+// Stream runs a query and returns a cursor over its rows instead of collecting them.
+// The caller must Close the stream.
+func (qe *QueryExecutor) Stream(ctx context.Context, conn *models.Connection, sqlQuery string, args ...interface{}) (*QueryStream, error) {
+	startTime := time.Now()
+	historySQL := sqlQuery
+	connector, err := qe.Connector(ctx, conn)
+	if err != nil {
+		qe.history.record(ctx, conn, historySQL, startTime, 0, err)
+		return nil, err
+	}
+
+	queryCtx, policy, release, err := qe.governor.admit(ctx, conn, sqlQuery, streamQueryTimeout)
+	if err != nil {
+		qe.history.record(ctx, conn, historySQL, startTime, 0, err)
+		return nil, err
+	}
+	maxRows := 0
+	if policy != nil && policy.MaxRows > 0 {
+		maxRows = policy.MaxRows
+		capped := maxRows + 1
+		sqlQuery = connector.Dialect().LimitSQL(sqlQuery, &capped, nil)
+	}
+
+	rows, err := connector.Stream(queryCtx, sqlQuery, args...)
+	if err != nil {
+		qe.history.record(queryCtx, conn, historySQL, startTime, 0, err)
+		release()
+		return nil, err
+	}
+
+	stream := &QueryStream{
+		Columns:  typedColumns(rows.Columns()),
+		rows:     rows,
+		values:   make([]interface{}, len(rows.Columns())),
+		keyIndex: -1,
+		maxRows:  maxRows,
+	}
+	// The run is recorded when the stream is closed, once the rows read are known
+	var once sync.Once
+	stream.cancel = func() {
+		once.Do(func() {
+			qe.history.record(queryCtx, conn, historySQL, startTime, stream.read, stream.err)
+			release()
+		})
+	}
+	return stream, nil
+}
+
+// Next advances to the next row, returning false at the end of the result or on error
+func (s *QueryStream) Next() bool {
+	if s.done {
+		return false
+	}
+	if s.maxRows > 0 && s.read == s.maxRows {
+		// Stop at the policy's row limit; the query fetched one more row if there were more
+		s.truncated = s.rows.Next(s.values) == nil
+		s.done = true
+		return false
+	}
+	if err := s.rows.Next(s.values); err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		s.done = true
+		return false
+	}
+	s.read++
+	if s.keyIndex >= 0 {
+		s.lastKey = s.values[s.keyIndex]
+		if b, ok := s.lastKey.([]byte); ok {
+			s.lastKey = string(b)
+		}
+	}
+	return true
+}
+
+// NextChunk reads up to size rows, returning an empty chunk at the end of the result.
+// Unlike Values, the returned rows are not reused.
+func (s *QueryStream) NextChunk(size int) ([][]interface{}, error) {
+	chunk := make([][]interface{}, 0, size)
+	for len(chunk) < size && s.Next() {
+		chunk = append(chunk, append([]interface{}(nil), s.Values()...))
+	}
+	return chunk, s.Err()
+}
+
+// RowsRead returns how many rows have been read so far
+func (s *QueryStream) RowsRead() int {
+	return s.read
+}
+
+// NextCursor returns the cursor of the page after this one when the stream is keyset paged
+// and returned a full page, or "" when there are no more rows to page through
+func (s *QueryStream) NextCursor() string {
+	if s.keyIndex < 0 || s.lastKey == nil || s.pageSize <= 0 || s.read < s.pageSize {
+		return ""
+	}
+	return encodeQueryCursor(s.lastKey)
+}
+
+// Truncated reports whether rows were left out because of the governance policy's row limit
+func (s *QueryStream) Truncated() bool {
+	return s.truncated
+}
+
+// Values returns the current row, with decimals read as text as json.Number. The slice is
+// reused by the next call to Next.
+func (s *QueryStream) Values() []interface{} {
+	for i, v := range s.values {
+		v = decimalResultValue(v, s.Columns[i])
+		if b, ok := v.([]byte); ok && s.Columns[i].Kind != ColumnKindBytes {
+			v = string(b)
+		}
+		s.values[i] = v
+	}
+	return s.values
+}
+
+// Err returns the error that stopped iteration, if any
+func (s *QueryStream) Err() error {
+	return s.err
+}
+
+// Close releases the result set
+func (s *QueryStream) Close() error {
+	defer s.cancel()
+	return s.rows.Close()
+}
+
+// encodeQueryCursor encodes the last key of a page as an opaque cursor
+func encodeQueryCursor(key interface{}) string {
+	if t, ok := key.(time.Time); ok {
+		key = t.Format(time.RFC3339Nano)
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeQueryCursor returns the key encoded in a cursor, or nil for the first page
+func decodeQueryCursor(cursor string) (interface{}, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var key interface{}
+	if err := decoder.Decode(&key); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if n, ok := key.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		f, _ := n.Float64()
+		return f, nil
+	}
+	return key, nil
+}
+
+// Chunk sizes for streamed query results
+const (
+	DefaultStreamChunkSize = 500
+	MaxStreamChunkSize     = 10000
+)
+
+// QueryStreamEvent is one message of a streamed result: the columns first, then chunks of
+// rows, and finally "done" with the totals or "error"
+type QueryStreamEvent struct {
+	Type          string          `json:"type"` // columns, rows, done, error
+	RequestID     string          `json:"requestId,omitempty"`
+	ExecutionID   string          `json:"executionId,omitempty"`
+	Columns       []ResultColumn  `json:"columns,omitempty"`
+	Rows          [][]interface{} `json:"rows,omitempty"`
+	RowCount      *int            `json:"rowCount,omitempty"`
+	ExecutionTime int64           `json:"executionTime,omitempty"` // milliseconds
+	NextCursor    string          `json:"nextCursor,omitempty"`
+	Truncated     bool            `json:"truncated,omitempty"` // Cut off at the governance policy's row limit
+	Error         string          `json:"error,omitempty"`
+}
+
+// PumpQueryStream reads the stream in chunks of chunkSize rows and passes each to emit as it
+// arrives. It stops early when emit fails, e.g. because the client has gone away.
+func PumpQueryStream(stream *QueryStream, chunkSize int, emit func(*QueryStreamEvent) error) error {
+	start := time.Now()
+	if chunkSize <= 0 {
+		chunkSize = DefaultStreamChunkSize
+	}
+	chunkSize = min(chunkSize, MaxStreamChunkSize)
+
+	if err := emit(&QueryStreamEvent{Type: "columns", Columns: stream.Columns}); err != nil {
+		return err
+	}
+
+	for {
+		rows, err := stream.NextChunk(chunkSize)
+		if len(rows) > 0 {
+			if emitErr := emit(&QueryStreamEvent{Type: "rows", Rows: rows}); emitErr != nil {
+				return emitErr
+			}
+		}
+		if err != nil {
+			emit(&QueryStreamEvent{Type: "error", Error: err.Error()})
+			return err
+		}
+		if len(rows) < chunkSize {
+			break
+		}
+	}
+
+	rowCount := stream.RowsRead()
+	return emit(&QueryStreamEvent{
+		Type:          "done",
+		RowCount:      &rowCount,
+		ExecutionTime: time.Since(start).Milliseconds(),
+		NextCursor:    stream.NextCursor(),
+		Truncated:     stream.Truncated(),
+	})
+}
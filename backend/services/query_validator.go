@@ -143,6 +143,15 @@ func (v *QueryValidator) ValidateFormula(formula string) (bool, error) {
 	if v.hasComments(formula) {
 		return false, errors.New("comments are not allowed in formulas")
 	}
+	if strings.Contains(formula, ";") {
+		return false, errors.New("semicolons are not allowed in formulas")
+	}
+	if keyword := formulaKeywords.FindString(formula); keyword != "" {
+		return false, fmt.Errorf("%s is not allowed in formulas", strings.ToUpper(keyword))
+	}
+	if !isBalancedFormula(formula) {
+		return false, errors.New("formula has unbalanced parentheses or quotes")
+	}
 
 	// Basic validation - formula should contain operators or functions
 	hasOperator := strings.ContainsAny(formula, "+-*/()[]")
@@ -154,3 +163,30 @@ func (v *QueryValidator) ValidateFormula(formula string) (bool, error) {
 
 	return true, nil
 }
+
+// formulaKeywords matches statements, subqueries and system objects, which expressions must not contain
+var formulaKeywords = regexp.MustCompile(`(?i)\b(SELECT|DROP|DELETE|UPDATE|INSERT|TRUNCATE|ALTER|CREATE|GRANT|REVOKE|EXEC|EXECUTE|CALL|INFORMATION_SCHEMA|PG_\w+|SYS)\b`)
+
+// isBalancedFormula checks that parentheses are balanced and string literals are closed
+func isBalancedFormula(formula string) bool {
+	depth := 0
+	var quote rune
+	for _, ch := range formula {
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0 && quote == 0
+}
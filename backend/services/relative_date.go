@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var lastNPeriods = regexp.MustCompile(`^last_(\d+)_(day|week|month|quarter|year)s?$`)
+
+// relativeDateRange resolves a relative date such as "today", "this_quarter", "last_month"
+// or "last_7_days" to the half-open range [start, end) around now. "last_<period>" is the
+// previous complete period; "last_N_<periods>" is the N periods up to and including the
+// current one. Weeks start on Monday.
+func relativeDateRange(value string, now time.Time) (time.Time, time.Time, error) {
+	name := strings.Join(strings.Fields(strings.ToLower(value)), "_")
+
+	grain, offset, count := "", 0, 1
+	switch {
+	case name == "today":
+		grain = GrainDay
+	case name == "yesterday":
+		grain, offset = GrainDay, -1
+	case strings.HasPrefix(name, "this_"):
+		grain = strings.TrimPrefix(name, "this_")
+	case lastNPeriods.MatchString(name):
+		match := lastNPeriods.FindStringSubmatch(name)
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || n > 3660 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid relative date %q", value)
+		}
+		grain, offset, count = match[2], 1-n, n
+	case strings.HasPrefix(name, "last_"):
+		grain, offset = strings.TrimPrefix(name, "last_"), -1
+	}
+
+	switch grain {
+	case GrainDay, GrainWeek, GrainMonth, GrainQuarter, GrainYear:
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid relative date %q", value)
+	}
+
+	start := addPeriods(startOfPeriod(now, grain), grain, offset)
+	return start, addPeriods(start, grain, count), nil
+}
+
+// startOfPeriod truncates t to the start of its day, week, month, quarter or year
+func startOfPeriod(t time.Time, grain string) time.Time {
+	year, month, day := t.Date()
+	switch grain {
+	case GrainWeek:
+		day -= (int(t.Weekday()) + 6) % 7
+	case GrainMonth:
+		day = 1
+	case GrainQuarter:
+		month, day = month-(month-1)%3, 1
+	case GrainYear:
+		month, day = time.January, 1
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// addPeriods moves t by n days, weeks, months, quarters or years
+func addPeriods(t time.Time, grain string, n int) time.Time {
+	switch grain {
+	case GrainWeek:
+		return t.AddDate(0, 0, 7*n)
+	case GrainMonth:
+		return t.AddDate(0, n, 0)
+	case GrainQuarter:
+		return t.AddDate(0, 3*n, 0)
+	case GrainYear:
+		return t.AddDate(n, 0, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
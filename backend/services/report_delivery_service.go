@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"insight-engine-backend/models"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// Report delivery statuses
+const (
+	ReportDeliverySent   = "SENT"
+	ReportDeliveryFailed = "FAILED"
+)
+
+const (
+	reportMaxAttempts  = 3               // Attempts per scheduled run before giving up until the next run
+	reportRetryBackoff = 5 * time.Minute // Multiplied by the attempt number
+)
+
+var reportFileNameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// ReportDeliveryService renders due dashboard report schedules and emails them as attachments
+type ReportDeliveryService struct {
+	db     *gorm.DB
+	export *ExportService
+	email  *EmailService
+	cron   *cron.Cron
+
+	mu      sync.Mutex
+	running map[string]bool // Schedules currently being delivered
+}
+
+// NewReportDeliveryService creates a new report delivery service
+func NewReportDeliveryService(db *gorm.DB, export *ExportService, email *EmailService) *ReportDeliveryService {
+	return &ReportDeliveryService{
+		db:      db,
+		export:  export,
+		email:   email,
+		cron:    cron.New(),
+		running: make(map[string]bool),
+	}
+}
+
+// Start checks every minute for schedules whose NextRunAt has passed
+func (s *ReportDeliveryService) Start() error {
+	if _, err := s.cron.AddFunc("@every 1m", s.tick); err != nil {
+		return fmt.Errorf("failed to schedule report delivery: %w", err)
+	}
+	s.cron.Start()
+	LogInfo("report_delivery_start", "Report delivery worker started", nil)
+	return nil
+}
+
+// Stop stops the report delivery worker
+func (s *ReportDeliveryService) Stop() {
+	s.cron.Stop()
+	LogInfo("report_delivery_stop", "Report delivery worker stopped", nil)
+}
+
+// tick delivers every active schedule that is due
+func (s *ReportDeliveryService) tick() {
+	var schedules []models.ReportSchedule
+	if err := s.db.Where("is_active = ? AND next_run_at <= ?", true, time.Now()).Find(&schedules).Error; err != nil {
+		LogError("report_delivery_load", "Failed to load due report schedules", map[string]interface{}{"error": err})
+		return
+	}
+
+	for i := range schedules {
+		schedule := schedules[i]
+		if !s.acquire(schedule.ID) {
+			continue
+		}
+		go func() {
+			defer s.release(schedule.ID)
+			s.Deliver(context.Background(), &schedule)
+		}()
+	}
+}
+
+// acquire marks a schedule as running, returning false if a previous delivery is still in progress
+func (s *ReportDeliveryService) acquire(scheduleID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[scheduleID] {
+		return false
+	}
+	s.running[scheduleID] = true
+	return true
+}
+
+func (s *ReportDeliveryService) release(scheduleID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, scheduleID)
+}
+
+// Deliver renders and emails one scheduled report, records the attempt and advances the schedule.
+// Failed deliveries are retried with backoff up to reportMaxAttempts before moving on to the next run.
+func (s *ReportDeliveryService) Deliver(ctx context.Context, schedule *models.ReportSchedule) *models.ReportDelivery {
+	now := time.Now()
+	recipients := splitRecipients(schedule.Email)
+
+	delivery := &models.ReportDelivery{
+		ID:          uuid.New().String(),
+		ScheduleID:  schedule.ID,
+		DashboardID: schedule.DashboardID,
+		Status:      ReportDeliverySent,
+		Attempt:     schedule.Attempts + 1,
+		Recipients:  strings.Join(recipients, ", "),
+		Format:      schedule.Format,
+		CreatedAt:   now,
+	}
+
+	size, err := s.send(ctx, schedule, recipients, now)
+	delivery.FileSize = size
+	if err != nil {
+		delivery.Status = ReportDeliveryFailed
+		message := err.Error()
+		delivery.Error = &message
+	}
+
+	nextRunAt, attempts := nextReportRun(schedule.Frequency, delivery.Attempt, err != nil, now)
+	if err != nil {
+		LogError("report_delivery_failed", "Scheduled report delivery failed", map[string]interface{}{
+			"schedule_id": schedule.ID,
+			"attempt":     delivery.Attempt,
+			"retrying":    attempts > 0,
+			"error":       err,
+		})
+	} else {
+		LogInfo("report_delivered", "Scheduled report delivered", map[string]interface{}{
+			"schedule_id": schedule.ID,
+			"recipients":  len(recipients),
+			"size":        size,
+		})
+	}
+
+	if err := s.db.Create(delivery).Error; err != nil {
+		LogError("report_delivery_save", "Failed to save report delivery", map[string]interface{}{"schedule_id": schedule.ID, "error": err})
+	}
+	if err := s.db.Model(&models.ReportSchedule{}).Where("id = ?", schedule.ID).Updates(map[string]interface{}{
+		"last_run_at": now,
+		"last_status": delivery.Status,
+		"attempts":    attempts,
+		"next_run_at": nextRunAt,
+	}).Error; err != nil {
+		LogError("report_schedule_update", "Failed to advance report schedule", map[string]interface{}{"schedule_id": schedule.ID, "error": err})
+	}
+
+	return delivery
+}
+
+// nextReportRun returns when a schedule runs next and its failed attempt count after an attempt.
+// A failed attempt is retried after a backoff; success or the last failed attempt moves to the next run.
+func nextReportRun(frequency string, attempt int, failed bool, now time.Time) (time.Time, int) {
+	if failed && attempt < reportMaxAttempts {
+		return now.Add(time.Duration(attempt) * reportRetryBackoff), attempt
+	}
+	return models.CalculateNextRun(frequency), 0
+}
+
+// send renders the dashboard and emails it to the recipients, returning the attachment size
+func (s *ReportDeliveryService) send(ctx context.Context, schedule *models.ReportSchedule, recipients []string, now time.Time) (int64, error) {
+	if len(recipients) == 0 {
+		return 0, fmt.Errorf("schedule has no recipients")
+	}
+
+	var dashboard models.Dashboard
+	if err := s.db.Where("id = ?", schedule.DashboardID).First(&dashboard).Error; err != nil {
+		return 0, fmt.Errorf("dashboard not found")
+	}
+
+	format, contentType := ExportFormatPDF, "application/pdf"
+	if strings.EqualFold(schedule.Format, "PNG") {
+		format, contentType = ExportFormatPNG, "image/png"
+	}
+
+	title := dashboard.Name
+	data, err := s.export.RenderDashboard(ctx, dashboard.ID, &ExportOptions{
+		Format:           format,
+		Orientation:      OrientationLandscape,
+		PageSize:         PageSizeA4,
+		Quality:          QualityHigh,
+		Resolution:       150,
+		IncludeTimestamp: true,
+		Title:            &title,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to render dashboard: %w", err)
+	}
+
+	attachment := EmailAttachment{
+		Filename:    reportFileName(dashboard.Name, format, now),
+		ContentType: contentType,
+		Data:        data,
+	}
+	if err := s.email.SendReportEmail(recipients, dashboard.Name, "/dashboards/"+dashboard.ID, attachment); err != nil {
+		return int64(len(data)), fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return int64(len(data)), nil
+}
+
+// reportFileName builds an attachment name such as "sales-overview-2026-10-16.pdf"
+func reportFileName(dashboardName string, format ExportFormat, now time.Time) string {
+	name := strings.Trim(strings.ToLower(reportFileNameUnsafe.ReplaceAllString(dashboardName, "-")), "-")
+	if name == "" {
+		name = "dashboard"
+	}
+	return fmt.Sprintf("%s-%s.%s", name, now.Format("2006-01-02"), format)
+}
+
+// splitRecipients splits a comma separated recipient list
+func splitRecipients(emails string) []string {
+	var recipients []string
+	for _, email := range strings.Split(emails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			recipients = append(recipients, email)
+		}
+	}
+	return recipients
+}
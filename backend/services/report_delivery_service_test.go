@@ -0,0 +1,85 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+	"time"
+)
+
+// TestNextReportRun tests retry backoff and advancing to the next regular run
+func TestNextReportRun(t *testing.T) {
+	now := time.Now()
+
+	next, attempts := nextReportRun("DAILY", 1, true, now)
+	if attempts != 1 || !next.Equal(now.Add(reportRetryBackoff)) {
+		t.Errorf("Expected first failure to retry after %v, got %v (attempts %d)", reportRetryBackoff, next.Sub(now), attempts)
+	}
+
+	next, attempts = nextReportRun("DAILY", reportMaxAttempts, true, now)
+	if attempts != 0 || next.Sub(now) < time.Hour {
+		t.Errorf("Expected last failure to move to the next daily run, got %v (attempts %d)", next, attempts)
+	}
+
+	_, attempts = nextReportRun("WEEKLY", 2, false, now)
+	if attempts != 0 {
+		t.Errorf("Expected success to reset attempts, got %d", attempts)
+	}
+}
+
+// TestReportFileName tests attachment naming
+func TestReportFileName(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	if got := reportFileName("Sales / Overview (EU)", ExportFormatPDF, now); got != "sales-overview-eu-2026-10-16.pdf" {
+		t.Errorf("Unexpected file name %q", got)
+	}
+	if got := reportFileName("!!!", ExportFormatPNG, now); got != "dashboard-2026-10-16.png" {
+		t.Errorf("Unexpected file name %q", got)
+	}
+}
+
+// TestBuildMultipartEmail tests that attachments survive MIME encoding
+func TestBuildMultipartEmail(t *testing.T) {
+	data := bytes.Repeat([]byte("%PDF-1.4 report "), 20)
+	raw, err := buildMultipartEmail("InsightEngine <noreply@example.com>", []string{"a@example.com", "b@example.com"},
+		"Scheduled Report", "<p>Attached</p>", []EmailAttachment{{Filename: "report.pdf", ContentType: "application/pdf", Data: data}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if to := msg.Header.Get("To"); to != "a@example.com, b@example.com" {
+		t.Errorf("Unexpected To header %q", to)
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("Invalid Content-Type: %v", err)
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+
+	if _, err := reader.NextPart(); err != nil {
+		t.Fatalf("Missing body part: %v", err)
+	}
+	part, err := reader.NextPart()
+	if err != nil {
+		t.Fatalf("Missing attachment part: %v", err)
+	}
+	if part.FileName() != "report.pdf" {
+		t.Errorf("Unexpected attachment name %q", part.FileName())
+	}
+	decoded, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+	if err != nil {
+		t.Fatalf("Failed to decode attachment: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Error("Attachment content does not round-trip")
+	}
+}
@@ -0,0 +1,580 @@
+package services
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/xuri/excelize/v2"
+)
+
+// ResultFormat is a tabular download format for query results
+type ResultFormat string
+
+const (
+	ResultFormatCSV     ResultFormat = "csv"
+	ResultFormatXLSX    ResultFormat = "xlsx"
+	ResultFormatParquet ResultFormat = "parquet"
+	ResultFormatNDJSON  ResultFormat = "ndjson"
+)
+
+const (
+	xlsxMaxRows         = 1048576 // Worksheet row limit of Excel, including the header row
+	parquetRowGroupSize = 50000   // Rows buffered in memory before a Parquet row group is written
+)
+
+// ParseResultFormat parses a format name, accepting "jsonl" for NDJSON
+func ParseResultFormat(format string) (ResultFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "csv":
+		return ResultFormatCSV, nil
+	case "xlsx", "excel":
+		return ResultFormatXLSX, nil
+	case "parquet":
+		return ResultFormatParquet, nil
+	case "ndjson", "jsonl":
+		return ResultFormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q (use csv, xlsx, parquet or ndjson)", format)
+	}
+}
+
+// ContentType returns the MIME type of the format
+func (f ResultFormat) ContentType() string {
+	switch f {
+	case ResultFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ResultFormatParquet:
+		return "application/vnd.apache.parquet"
+	case ResultFormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// ResultFileName builds a download name such as "monthly-revenue.xlsx"
+func ResultFileName(name string, format ResultFormat) string {
+	base := strings.Trim(strings.ToLower(reportFileNameUnsafe.ReplaceAllString(name, "-")), "-")
+	if base == "" {
+		base = "query-results"
+	}
+	return base + "." + string(format)
+}
+
+// ResultWriter writes the rows of a result set in order and finishes the file on Close
+type ResultWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// NewResultWriter creates a writer that encodes rows with the given columns in format
+func NewResultWriter(format ResultFormat, w io.Writer, columns []ResultColumn) (ResultWriter, error) {
+	switch format {
+	case ResultFormatCSV:
+		return newCSVResultWriter(w, columns)
+	case ResultFormatXLSX:
+		return newXLSXResultWriter(w, columns)
+	case ResultFormatParquet:
+		return newParquetResultWriter(w, columns)
+	case ResultFormatNDJSON:
+		return &ndjsonResultWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// ExportQueryStream copies every row of stream to w in format and returns the number of rows written
+func ExportQueryStream(stream *QueryStream, format ResultFormat, w io.Writer) (int64, error) {
+	writer, err := NewResultWriter(format, w, stream.Columns)
+	if err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	for stream.Next() {
+		if err := writer.WriteRow(stream.Values()); err != nil {
+			discardResultWriter(writer)
+			return rows, err
+		}
+		rows++
+	}
+	if err := stream.Err(); err != nil {
+		discardResultWriter(writer)
+		return rows, err
+	}
+	return rows, writer.Close()
+}
+
+// discardResultWriter releases an unfinished writer's resources without completing the file
+func discardResultWriter(writer ResultWriter) {
+	switch x := writer.(type) {
+	case *xlsxResultWriter:
+		x.file.Close()
+	case *parquetResultWriter:
+		x.builder.Release()
+	}
+}
+
+// csvResultWriter writes a header row followed by one line per row
+type csvResultWriter struct {
+	w       *csv.Writer
+	columns []ResultColumn
+	record  []string
+}
+
+func newCSVResultWriter(w io.Writer, columns []ResultColumn) (*csvResultWriter, error) {
+	writer := &csvResultWriter{w: csv.NewWriter(w), columns: columns, record: make([]string, len(columns))}
+	for i, column := range columns {
+		writer.record[i] = column.Name
+	}
+	if err := writer.w.Write(writer.record); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (c *csvResultWriter) WriteRow(values []interface{}) error {
+	for i := range c.record {
+		c.record[i] = ""
+		if i < len(values) && values[i] != nil {
+			c.record[i] = formatResultValue(values[i])
+		}
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvResultWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonResultWriter writes one JSON object per line, keeping the column order
+type ndjsonResultWriter struct {
+	w       *bufio.Writer
+	columns []ResultColumn
+}
+
+func (n *ndjsonResultWriter) WriteRow(values []interface{}) error {
+	n.w.WriteByte('{')
+	for i, column := range n.columns {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(column.Name)
+		n.w.Write(key)
+		n.w.WriteByte(':')
+
+		var value interface{}
+		if i < len(values) {
+			value = typedResultValue(values[i], column.Kind)
+		}
+		if t, ok := value.(time.Time); ok {
+			value = t.Format(time.RFC3339Nano)
+		}
+		if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			value = nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", column.Name, err)
+		}
+		n.w.Write(encoded)
+	}
+	n.w.WriteByte('}')
+	return n.w.WriteByte('\n')
+}
+
+func (n *ndjsonResultWriter) Close() error {
+	return n.w.Flush()
+}
+
+// xlsxResultWriter streams rows into a single worksheet with a bold header row
+type xlsxResultWriter struct {
+	w         io.Writer
+	file      *excelize.File
+	stream    *excelize.StreamWriter
+	columns   []ResultColumn
+	row       int
+	dateStyle int
+	cells     []interface{}
+}
+
+func newXLSXResultWriter(w io.Writer, columns []ResultColumn) (*xlsxResultWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	headerStyle, err := file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	dateFormat := "yyyy-mm-dd hh:mm:ss"
+	dateStyle, err := file.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: column.Name}
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &xlsxResultWriter{
+		w:         w,
+		file:      file,
+		stream:    stream,
+		columns:   columns,
+		row:       1,
+		dateStyle: dateStyle,
+		cells:     make([]interface{}, len(columns)),
+	}, nil
+}
+
+func (x *xlsxResultWriter) WriteRow(values []interface{}) error {
+	if x.row >= xlsxMaxRows {
+		return fmt.Errorf("result exceeds the XLSX limit of %d rows", xlsxMaxRows-1)
+	}
+	x.row++
+
+	for i, column := range x.columns {
+		var value interface{}
+		if i < len(values) {
+			value = typedResultValue(values[i], column.Kind)
+		}
+		switch v := value.(type) {
+		case time.Time:
+			value = excelize.Cell{StyleID: x.dateStyle, Value: v}
+		case []byte:
+			value = base64.StdEncoding.EncodeToString(v)
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				value = nil
+			}
+		}
+		x.cells[i] = value
+	}
+
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	return x.stream.SetRow(cell, x.cells)
+}
+
+func (x *xlsxResultWriter) Close() error {
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.w)
+}
+
+// parquetResultWriter buffers rows into Arrow record batches and writes each batch as a row group,
+// so at most parquetRowGroupSize rows are held in memory
+type parquetResultWriter struct {
+	writer  *pqarrow.FileWriter
+	builder *array.RecordBuilder
+	columns []ResultColumn
+	rows    int
+}
+
+func newParquetResultWriter(w io.Writer, columns []ResultColumn) (*parquetResultWriter, error) {
+	names := uniqueColumnNames(columns)
+	fields := make([]arrow.Field, len(columns))
+	for i, column := range columns {
+		fields[i] = arrow.Field{Name: names[i], Type: arrowColumnType(column.Kind), Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(compress.Codecs.Snappy),
+		parquet.WithCreatedBy("InsightEngine"),
+	)
+	writer, err := pqarrow.NewFileWriter(schema, w, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, err
+	}
+
+	return &parquetResultWriter{
+		writer:  writer,
+		builder: array.NewRecordBuilder(memory.DefaultAllocator, schema),
+		columns: columns,
+	}, nil
+}
+
+func (p *parquetResultWriter) WriteRow(values []interface{}) error {
+	for i, column := range p.columns {
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+		if err := appendArrowValue(p.builder.Field(i), column.Kind, value); err != nil {
+			return fmt.Errorf("column %q: %w", column.Name, err)
+		}
+	}
+
+	if p.rows++; p.rows >= parquetRowGroupSize {
+		return p.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows as a row group
+func (p *parquetResultWriter) flush() error {
+	record := p.builder.NewRecord()
+	defer record.Release()
+	p.rows = 0
+	return p.writer.Write(record)
+}
+
+func (p *parquetResultWriter) Close() error {
+	defer p.builder.Release()
+	if p.rows > 0 {
+		if err := p.flush(); err != nil {
+			return err
+		}
+	}
+	return p.writer.Close()
+}
+
+// arrowColumnType maps a column kind to the Arrow type stored in Parquet
+func arrowColumnType(kind string) arrow.DataType {
+	switch kind {
+	case ColumnKindInteger:
+		return arrow.PrimitiveTypes.Int64
+	case ColumnKindFloat:
+		return arrow.PrimitiveTypes.Float64
+	case ColumnKindBoolean:
+		return arrow.FixedWidthTypes.Boolean
+	case ColumnKindTimestamp:
+		return arrow.FixedWidthTypes.Timestamp_us
+	case ColumnKindBytes:
+		return arrow.BinaryTypes.Binary
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// appendArrowValue appends a scanned value to the builder of its column kind
+func appendArrowValue(builder array.Builder, kind string, value interface{}) error {
+	if value == nil {
+		builder.AppendNull()
+		return nil
+	}
+
+	switch b := builder.(type) {
+	case *array.Int64Builder:
+		if v, ok := toInt64(value); ok {
+			b.Append(v)
+			return nil
+		}
+	case *array.Float64Builder:
+		if v, ok := toFloat(value); ok {
+			b.Append(v)
+			return nil
+		}
+	case *array.BooleanBuilder:
+		if v, ok := toBool(value); ok {
+			b.Append(v)
+			return nil
+		}
+	case *array.TimestampBuilder:
+		if v, ok := toTime(value); ok {
+			b.Append(arrow.Timestamp(v.UnixMicro()))
+			return nil
+		}
+	case *array.BinaryBuilder:
+		if v, ok := value.([]byte); ok {
+			b.Append(v)
+		} else {
+			b.AppendString(fmt.Sprint(value))
+		}
+		return nil
+	case *array.StringBuilder:
+		b.Append(formatResultValue(value))
+		return nil
+	}
+	return fmt.Errorf("cannot convert %v to %s", value, kind)
+}
+
+// typedResultValue converts a scanned value to the Go type of its column kind,
+// keeping the original value when it does not convert
+func typedResultValue(value interface{}, kind string) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch kind {
+	case ColumnKindInteger:
+		if v, ok := toInt64(value); ok {
+			return v
+		}
+	case ColumnKindFloat:
+		if v, ok := toFloat(value); ok {
+			return v
+		}
+	case ColumnKindBoolean:
+		if v, ok := toBool(value); ok {
+			return v
+		}
+	case ColumnKindTimestamp:
+		if v, ok := toTime(value); ok {
+			return v
+		}
+	}
+	return value
+}
+
+// formatResultValue formats a non-null value as text for CSV and string columns
+func formatResultValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// uniqueColumnNames returns the column names with duplicates suffixed, e.g. "count", "count_2"
+func uniqueColumnNames(columns []ResultColumn) []string {
+	names := make([]string, len(columns))
+	seen := make(map[string]int, len(columns))
+	for i, column := range columns {
+		name := column.Name
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name = fmt.Sprintf("%s_%d", name, n)
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// toInt64 converts integers, integral floats and numeric strings to int64
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return i, err == nil
+	case []byte:
+		i, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+		return i, err == nil
+	}
+	if f, ok := toFloat(value); ok && f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+		return int64(f), true
+	}
+	return 0, false
+}
+
+// toBool converts booleans, numbers and "true"/"false" style strings to bool
+func toBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	case []byte:
+		if len(v) == 1 && v[0] <= 1 {
+			return v[0] == 1, true // MySQL BIT(1)
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(string(v)))
+		return b, err == nil
+	}
+	if i, ok := toInt64(value); ok {
+		return i != 0, true
+	}
+	return false, false
+}
+
+// resultTimeLayouts are the text layouts drivers use for dates and timestamps
+var resultTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// toTime converts time values and timestamp strings to time.Time
+func toTime(value interface{}) (time.Time, bool) {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return time.Time{}, false
+	}
+	text = strings.TrimSpace(text)
+	for _, layout := range resultTimeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/xuri/excelize/v2"
+)
+
+var exportTestColumns = []ResultColumn{
+	{Name: "region", Kind: ColumnKindString},
+	{Name: "orders", Kind: ColumnKindInteger},
+	{Name: "revenue", Kind: ColumnKindFloat},
+	{Name: "active", Kind: ColumnKindBoolean},
+	{Name: "updated_at", Kind: ColumnKindTimestamp},
+}
+
+func writeExportTestRows(t *testing.T, format ResultFormat) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer, err := NewResultWriter(format, &buf, exportTestColumns)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	updated := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{"EU, West", int64(3), "120.50", true, updated},
+		{"US", "5", 80.0, int64(0), nil},
+	}
+	for _, row := range rows {
+		if err := writer.WriteRow(row); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestResultWriter_CSV tests quoting, nulls and timestamp formatting
+func TestResultWriter_CSV(t *testing.T) {
+	expected := "region,orders,revenue,active,updated_at\n" +
+		"\"EU, West\",3,120.50,true,2026-10-16T09:30:00Z\n" +
+		"US,5,80,0,\n"
+	if got := string(writeExportTestRows(t, ResultFormatCSV)); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+// TestResultWriter_NDJSON tests that values are written with their column types
+func TestResultWriter_NDJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(writeExportTestRows(t, ResultFormatNDJSON))), "\n")
+	expected := []string{
+		`{"region":"EU, West","orders":3,"revenue":120.5,"active":true,"updated_at":"2026-10-16T09:30:00Z"}`,
+		`{"region":"US","orders":5,"revenue":80,"active":false,"updated_at":null}`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d", len(expected), len(lines))
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Line %d: expected %s, got %s", i, expected[i], lines[i])
+		}
+	}
+}
+
+// TestResultWriter_Parquet tests that the file reads back with typed columns
+func TestResultWriter_Parquet(t *testing.T) {
+	data := writeExportTestRows(t, ResultFormatParquet)
+
+	reader, err := file.NewParquetReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to open parquet file: %v", err)
+	}
+	defer reader.Close()
+	arrowReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	table, err := arrowReader.ReadTable(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer table.Release()
+
+	if table.NumRows() != 2 || table.NumCols() != 5 {
+		t.Fatalf("Expected 2 rows and 5 columns, got %d and %d", table.NumRows(), table.NumCols())
+	}
+	orders := table.Column(1).Data().Chunk(0).(*array.Int64)
+	if orders.Value(0) != 3 || orders.Value(1) != 5 {
+		t.Errorf("Expected orders 3 and 5, got %v", orders)
+	}
+	revenue := table.Column(2).Data().Chunk(0).(*array.Float64)
+	if revenue.Value(0) != 120.5 {
+		t.Errorf("Expected revenue 120.5, got %v", revenue.Value(0))
+	}
+	if updated := table.Column(4).Data().Chunk(0); !updated.IsNull(1) {
+		t.Error("Expected a null timestamp in the second row")
+	}
+}
+
+// TestResultWriter_XLSX tests the header row and typed cells
+func TestResultWriter_XLSX(t *testing.T) {
+	xlsx, err := excelize.OpenReader(bytes.NewReader(writeExportTestRows(t, ResultFormatXLSX)))
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %v", err)
+	}
+	defer xlsx.Close()
+
+	rows, err := xlsx.GetRows("Sheet1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "region" || rows[1][1] != "3" || rows[1][2] != "120.5" {
+		t.Errorf("Unexpected rows: %v", rows)
+	}
+	if value, _ := xlsx.GetCellValue("Sheet1", "E2"); value != "2026-10-16 09:30:00" {
+		t.Errorf("Expected a formatted timestamp, got %q", value)
+	}
+}
+
+// TestColumnKind tests classification of database type names
+func TestColumnKind(t *testing.T) {
+	cases := map[string]string{
+		"INT4":        ColumnKindInteger,
+		"BIGINT":      ColumnKindInteger,
+		"NUMERIC":     ColumnKindFloat,
+		"FLOAT8":      ColumnKindFloat,
+		"BOOL":        ColumnKindBoolean,
+		"TIMESTAMPTZ": ColumnKindTimestamp,
+		"DATETIME2":   ColumnKindTimestamp,
+		"INTERVAL":    ColumnKindString,
+		"VARCHAR":     ColumnKindString,
+		"BYTEA":       ColumnKindBytes,
+	}
+	for databaseType, expected := range cases {
+		if got := columnKind(databaseType, nil); got != expected {
+			t.Errorf("%s: expected %s, got %s", databaseType, expected, got)
+		}
+	}
+}
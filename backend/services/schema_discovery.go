@@ -23,6 +23,7 @@ func NewSchemaDiscovery(executor *QueryExecutor) *SchemaDiscovery {
 type TableInfo struct {
 	Name     string       `json:"name"`
 	Schema   string       `json:"schema"`
+	Type     string       `json:"type,omitempty"` // BASE TABLE, VIEW, COLLECTION, ...
 	Columns  []ColumnInfo `json:"columns"`
 	RowCount *int64       `json:"rowCount,omitempty"`
 }
@@ -58,10 +59,32 @@ func (sd *SchemaDiscovery) DiscoverSchema(ctx context.Context, conn *models.Conn
 	case "mysql":
 		return sd.discoverMySQLSchema(ctx, conn)
 	default:
-		return nil, fmt.Errorf("schema discovery not supported for database type: %s", conn.Type)
+		return sd.discoverConnectorSchema(ctx, conn)
 	}
 }
 
+// discoverConnectorSchema discovers the default schema of any other connection type
+// through its Connector
+func (sd *SchemaDiscovery) discoverConnectorSchema(ctx context.Context, conn *models.Connection) ([]TableInfo, error) {
+	connector, err := sd.executor.Connector(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := connector.ListTables(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+	for i := range tables {
+		columns, err := connector.ListColumns(ctx, tables[i].Schema, tables[i].Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query columns for table %s: %w", tables[i].Name, err)
+		}
+		tables[i].Columns = columns
+	}
+	return tables, nil
+}
+
 // discoverPostgresSchema discovers PostgreSQL schema
 func (sd *SchemaDiscovery) discoverPostgresSchema(ctx context.Context, conn *models.Connection) ([]TableInfo, error) {
 	// Get database connection
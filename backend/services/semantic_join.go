@@ -0,0 +1,357 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"insight-engine-backend/models"
+)
+
+// Relationship types of a SemanticRelationship, read from its from model to its to model
+const (
+	RelationshipOneToOne   = "one_to_one"
+	RelationshipOneToMany  = "one_to_many"
+	RelationshipManyToOne  = "many_to_one"
+	RelationshipManyToMany = "many_to_many"
+)
+
+// semanticField is a requested dimension or metric resolved to the model it belongs to
+type semanticField struct {
+	name  string // As requested; also the output column name
+	model *models.SemanticModel
+	expr  string // Column for dimensions, formula for metrics
+	// Filters only
+	operator string
+	value    interface{}
+}
+
+// semanticEdge is a relationship followed from one model to another
+type semanticEdge struct {
+	from, to             *models.SemanticModel
+	fromColumn, toColumn string
+	toMany               bool // A row of from can match many rows of to
+}
+
+// semanticGraph holds a model and the models related to it on the same data source
+type semanticGraph struct {
+	models map[string]*models.SemanticModel // By ID
+	byName map[string]*models.SemanticModel
+	edges  map[string][]semanticEdge // By the ID of the model they start from
+	// Parsed metrics with the metrics they refer to, by model ID and metric name
+	metricSets map[string]*metricSet
+}
+
+// loadSemanticGraph loads the models reachable from base through relationships. Models of
+// other workspaces or data sources cannot be joined and are left out.
+func (s *SemanticLayerService) loadSemanticGraph(base *models.SemanticModel) (*semanticGraph, error) {
+	graph := &semanticGraph{
+		models:     map[string]*models.SemanticModel{base.ID: base},
+		byName:     map[string]*models.SemanticModel{base.Name: base},
+		edges:      make(map[string][]semanticEdge),
+		metricSets: make(map[string]*metricSet),
+	}
+	relationships := make(map[string]models.SemanticRelationship)
+	skipped := make(map[string]bool)
+
+	for frontier := []string{base.ID}; len(frontier) > 0; {
+		var found []models.SemanticRelationship
+		if err := s.db.Where("from_model_id IN ? OR to_model_id IN ?", frontier, frontier).Find(&found).Error; err != nil {
+			return nil, fmt.Errorf("failed to load relationships: %w", err)
+		}
+		frontier = nil
+		for _, rel := range found {
+			relationships[rel.ID] = rel
+			for _, id := range []string{rel.FromModelID, rel.ToModelID} {
+				if graph.models[id] != nil || skipped[id] {
+					continue
+				}
+				model, err := s.GetModelByID(id)
+				if err != nil || model.DataSourceID != base.DataSourceID || model.WorkspaceID != base.WorkspaceID {
+					skipped[id] = true
+					continue
+				}
+				graph.models[id] = model
+				graph.byName[model.Name] = model
+				frontier = append(frontier, id)
+			}
+		}
+	}
+
+	// Follow relationships in a stable order so that equally short join paths are chosen consistently
+	ids := make([]string, 0, len(relationships))
+	for id := range relationships {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		rel := relationships[id]
+		from, to := graph.models[rel.FromModelID], graph.models[rel.ToModelID]
+		if from == nil || to == nil {
+			continue
+		}
+		graph.edges[from.ID] = append(graph.edges[from.ID], semanticEdge{
+			from: from, to: to, fromColumn: rel.FromColumn, toColumn: rel.ToColumn,
+			toMany: rel.RelationshipType == RelationshipOneToMany || rel.RelationshipType == RelationshipManyToMany,
+		})
+		graph.edges[to.ID] = append(graph.edges[to.ID], semanticEdge{
+			from: to, to: from, fromColumn: rel.ToColumn, toColumn: rel.FromColumn,
+			toMany: rel.RelationshipType == RelationshipManyToOne || rel.RelationshipType == RelationshipManyToMany,
+		})
+	}
+	return graph, nil
+}
+
+// field splits a "<model>.<field>" name; other names belong to base
+func (g *semanticGraph) field(base *models.SemanticModel, name string) (*models.SemanticModel, string) {
+	if i := strings.Index(name, "."); i > 0 {
+		if model := g.byName[name[:i]]; model != nil {
+			return model, name[i+1:]
+		}
+	}
+	return base, name
+}
+
+// dimension resolves a dimension name
+func (g *semanticGraph) dimension(base *models.SemanticModel, name string) (semanticField, error) {
+	model, fieldName := g.field(base, name)
+	for _, dim := range model.Dimensions {
+		if dim.Name == fieldName {
+			return semanticField{name: name, model: model, expr: qualifyColumn(model, dim.ColumnName)}, nil
+		}
+	}
+	return semanticField{}, fmt.Errorf("dimension not found: %s", name)
+}
+
+// metric resolves a metric name, expanding the metrics its formula refers to
+func (g *semanticGraph) metric(base *models.SemanticModel, name string) (semanticField, error) {
+	model, fieldName := g.field(base, name)
+	key := model.ID + "." + fieldName
+	set := g.metricSets[key]
+	if set == nil {
+		var err error
+		if set, err = modelMetricSubset(model, []string{fieldName}); err != nil {
+			return semanticField{}, err
+		}
+		g.metricSets[key] = set
+	}
+	if set.nodes[fieldName] == nil {
+		return semanticField{}, fmt.Errorf("metric not found: %s", name)
+	}
+	expr, err := set.expand(fieldName)
+	return semanticField{name: name, model: model, expr: expr}, err
+}
+
+var plainColumnName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// qualifyColumn prefixes a plain column name with its model's table, so that columns with the
+// same name in joined tables are not ambiguous. Expressions are used as written.
+func qualifyColumn(model *models.SemanticModel, column string) string {
+	if plainColumnName.MatchString(column) {
+		return model.Table + "." + column
+	}
+	return column
+}
+
+// semanticRelation returns what a model's rows are selected from: its table, or its source
+// query named by its table
+func semanticRelation(model *models.SemanticModel, dialect SQLDialect) string {
+	if model.SourceQuery == "" {
+		return model.Table
+	}
+	return "(" + trimStatement(model.SourceQuery) + ")" + dialect.subqueryAlias(model.Table)
+}
+
+// joinPaths finds the shortest join path from root to every reachable model, as the edge
+// each model is reached by. With toOneOnly, only edges that match at most one row are used.
+func (g *semanticGraph) joinPaths(root *models.SemanticModel, toOneOnly bool) map[string]semanticEdge {
+	parents := make(map[string]semanticEdge)
+	queue := []string{root.ID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, edge := range g.edges[id] {
+			if _, seen := parents[edge.to.ID]; seen || edge.to.ID == root.ID || (toOneOnly && edge.toMany) {
+				continue
+			}
+			parents[edge.to.ID] = edge
+			queue = append(queue, edge.to.ID)
+		}
+	}
+	return parents
+}
+
+// rootedQuery generates a query over root joined to the models of dims, metrics and filters.
+// Metrics must belong to root and are grouped by dims, and having filters them; with
+// toOneOnly, a model that can only be reached through a one-to-many join is an error, as it
+// would multiply root's rows.
+func (g *semanticGraph) rootedQuery(root *models.SemanticModel, dims, metrics, filters, having []semanticField, toOneOnly bool, dialect SQLDialect, params *sqlParams, now time.Time) (string, error) {
+	parents := g.joinPaths(root, toOneOnly)
+	joined := map[string]bool{root.ID: true}
+	var joins []string
+	for _, field := range append(append(append([]semanticField{}, dims...), metrics...), filters...) {
+		var path []semanticEdge
+		for id := field.model.ID; !joined[id]; {
+			edge, ok := parents[id]
+			if !ok {
+				if _, reachable := g.joinPaths(root, false)[id]; reachable {
+					return "", fmt.Errorf("%s cannot be combined with metrics of %s: %s has many rows per %s row and would be counted more than once",
+						field.name, root.Name, field.model.Name, root.Name)
+				}
+				return "", fmt.Errorf("no relationship connects %s to %s", field.model.Name, root.Name)
+			}
+			path = append([]semanticEdge{edge}, path...)
+			id = edge.from.ID
+		}
+		for _, edge := range path {
+			joined[edge.to.ID] = true
+			joins = append(joins, fmt.Sprintf("LEFT JOIN %s ON %s = %s",
+				semanticRelation(edge.to, dialect), qualifyColumn(edge.from, edge.fromColumn), qualifyColumn(edge.to, edge.toColumn)))
+		}
+	}
+
+	var selectParts, groupByParts []string
+	for _, dim := range dims {
+		selectParts = append(selectParts, fmt.Sprintf("%s AS %s", dim.expr, dialect.QuoteAlias(dim.name)))
+		groupByParts = append(groupByParts, dim.expr)
+	}
+	for _, metric := range metrics {
+		selectParts = append(selectParts, fmt.Sprintf("%s AS %s", metric.expr, dialect.QuoteAlias(metric.name)))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selectParts, ", "), semanticRelation(root, dialect))
+	if len(joins) > 0 {
+		query += " " + strings.Join(joins, " ")
+	}
+	if len(filters) > 0 {
+		var whereParts []string
+		for _, filter := range filters {
+			condition, err := semanticCondition(filter.expr, filter, dialect, params, now)
+			if err != nil {
+				return "", err
+			}
+			whereParts = append(whereParts, "("+condition+")")
+		}
+		query += " WHERE " + strings.Join(whereParts, " AND ")
+	}
+	// Dimensions alone are grouped too, as joins may repeat their values
+	if len(groupByParts) > 0 && (len(metrics) > 0 || len(joins) > 0) {
+		query += " GROUP BY " + strings.Join(groupByParts, ", ")
+	}
+	if len(having) > 0 {
+		var havingParts []string
+		for _, filter := range having {
+			condition, err := semanticCondition(filter.expr, filter, dialect, params, now)
+			if err != nil {
+				return "", err
+			}
+			havingParts = append(havingParts, "("+condition+")")
+		}
+		query += " HAVING " + strings.Join(havingParts, " AND ")
+	}
+	return query, nil
+}
+
+// combinedQuery aggregates the metrics of each root model in its own subquery and joins the
+// results on their dimension values, so that no model's metrics are multiplied by another's
+// rows. Metric filters in having apply to the combined rows.
+func (g *semanticGraph) combinedQuery(roots []*models.SemanticModel, dims, metrics, filters, having []semanticField, dialect SQLDialect, params *sqlParams, now time.Time) (string, error) {
+	// Subqueries are generated in the order they appear in the SQL, to number placeholders in order
+	aggregate := func(i int) (string, error) {
+		var rootMetrics []semanticField
+		for _, metric := range metrics {
+			if metric.model == roots[i] {
+				rootMetrics = append(rootMetrics, metric)
+			}
+		}
+		query, err := g.rootedQuery(roots[i], dims, rootMetrics, filters, nil, true, dialect, params, now)
+		return "(\n" + query + "\n)" + dialect.subqueryAlias(fmt.Sprintf("m%d", i)), err
+	}
+
+	var selectParts []string
+	var from string
+	if len(dims) == 0 {
+		// Every aggregate is a single row
+		var subqueries []string
+		for i := range roots {
+			subquery, err := aggregate(i)
+			if err != nil {
+				return "", err
+			}
+			subqueries = append(subqueries, subquery)
+		}
+		from = strings.Join(subqueries, "\nCROSS JOIN ")
+	} else {
+		// Every combination of dimension values found by any aggregate, once
+		var dimColumns []string
+		for _, dim := range dims {
+			dimColumns = append(dimColumns, dialect.QuoteAlias(dim.name))
+			selectParts = append(selectParts, "dims."+dialect.QuoteAlias(dim.name)+" AS "+dialect.QuoteAlias(dim.name))
+		}
+		union := "\nUNION\n"
+		if dialect == DialectBigQuery {
+			union = "\nUNION DISTINCT\n"
+		}
+		var spine []string
+		for i := range roots {
+			subquery, err := aggregate(i)
+			if err != nil {
+				return "", err
+			}
+			spine = append(spine, fmt.Sprintf("SELECT %s FROM %s", strings.Join(dimColumns, ", "), subquery))
+		}
+		from = "(\n" + strings.Join(spine, union) + "\n)" + dialect.subqueryAlias("dims")
+
+		for i := range roots {
+			subquery, err := aggregate(i)
+			if err != nil {
+				return "", err
+			}
+			var on []string
+			for _, column := range dimColumns {
+				// NULL dimension values match each other
+				on = append(on, fmt.Sprintf("(dims.%s = m%d.%s OR (dims.%s IS NULL AND m%d.%s IS NULL))", column, i, column, column, i, column))
+			}
+			from += "\nLEFT JOIN " + subquery + " ON " + strings.Join(on, " AND ")
+		}
+	}
+
+	// Metrics are referred to by the column of the subquery that aggregated them
+	column := func(metric semanticField) string {
+		for i, root := range roots {
+			if metric.model == root {
+				return fmt.Sprintf("m%d.%s", i, dialect.QuoteAlias(metric.name))
+			}
+		}
+		return ""
+	}
+	for _, metric := range metrics {
+		selectParts = append(selectParts, column(metric)+" AS "+dialect.QuoteAlias(metric.name))
+	}
+	query := "SELECT " + strings.Join(selectParts, ", ") + "\nFROM " + from
+
+	if len(having) > 0 {
+		var whereParts []string
+		for _, filter := range having {
+			condition, err := semanticCondition(column(filter), filter, dialect, params, now)
+			if err != nil {
+				return "", err
+			}
+			whereParts = append(whereParts, "("+condition+")")
+		}
+		query += "\nWHERE " + strings.Join(whereParts, " AND ")
+	}
+	return query, nil
+}
+
+// containsModel reports whether list contains model
+func containsModel(list []*models.SemanticModel, model *models.SemanticModel) bool {
+	for _, m := range list {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"insight-engine-backend/models"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestJoinedSemanticQuery tests join path finding and fan-out protection by running the
+// translated SQL against SQLite
+func TestJoinedSemanticQuery(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.SemanticModel{}, &models.SemanticDimension{}, &models.SemanticMetric{}, &models.SemanticRelationship{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	for _, statement := range []string{
+		"CREATE TABLE customers (id INTEGER, region TEXT)",
+		"CREATE TABLE orders (id INTEGER, customer_id INTEGER, status TEXT, amount INTEGER)",
+		"CREATE TABLE tickets (id INTEGER, customer_id INTEGER)",
+		"INSERT INTO customers VALUES (1, 'EU'), (2, 'EU'), (3, 'US')",
+		"INSERT INTO orders VALUES (1, 1, 'paid', 100), (2, 1, 'paid', 50), (3, 2, 'open', 30), (4, 3, 'paid', 70)",
+		"INSERT INTO tickets VALUES (1, 1), (2, 1), (3, 1), (4, 3)",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("Failed to set up data: %v", err)
+		}
+	}
+
+	service := NewSemanticLayerService(db)
+	model := func(id, table string, dims []models.SemanticDimension, metrics []models.SemanticMetric) {
+		if err := service.CreateModel(&models.SemanticModel{ID: id, Name: table, DataSourceID: "conn-1", Table: table,
+			WorkspaceID: "ws-1", CreatedBy: "user-1", Dimensions: dims, Metrics: metrics}); err != nil {
+			t.Fatalf("Failed to create model: %v", err)
+		}
+	}
+	model("m-customers", "customers",
+		[]models.SemanticDimension{{ID: "d1", Name: "region", ColumnName: "region", DataType: "string"}},
+		[]models.SemanticMetric{{ID: "x1", Name: "customer_count", Formula: "COUNT(DISTINCT customers.id)"}})
+	model("m-orders", "orders",
+		[]models.SemanticDimension{{ID: "d2", Name: "status", ColumnName: "status", DataType: "string"}},
+		[]models.SemanticMetric{{ID: "x2", Name: "revenue", Formula: "SUM(orders.amount)"}})
+	model("m-tickets", "tickets", nil,
+		[]models.SemanticMetric{{ID: "x3", Name: "ticket_count", Formula: "COUNT(*)"}})
+	for _, rel := range []models.SemanticRelationship{
+		{ID: "r1", FromModelID: "m-customers", ToModelID: "m-orders", FromColumn: "id", ToColumn: "customer_id", RelationshipType: RelationshipOneToMany},
+		{ID: "r2", FromModelID: "m-tickets", ToModelID: "m-customers", FromColumn: "customer_id", ToColumn: "id", RelationshipType: RelationshipManyToOne},
+	} {
+		if err := service.CreateRelationship(&rel); err != nil {
+			t.Fatalf("Failed to create relationship: %v", err)
+		}
+	}
+	if err := service.CreateRelationship(&models.SemanticRelationship{ID: "r3", FromModelID: "m-orders", ToModelID: "m-orders",
+		FromColumn: "id", ToColumn: "id", RelationshipType: RelationshipOneToOne}); err == nil {
+		t.Error("Expected a relationship of a model with itself to be rejected")
+	}
+
+	run := func(base string, dims, metrics []string, filters []SemanticFilter) ([]map[string]interface{}, string, error) {
+		model, _ := service.GetModelByID(base)
+		query := SemanticQuery{Dimensions: dims, Metrics: metrics, Filters: filters, Limit: 100}
+		sql, args, err := service.CompileSemanticQuery(model, query, DialectSQLite)
+		if err != nil {
+			return nil, sql, err
+		}
+		var rows []map[string]interface{}
+		err = db.Raw(sql, args...).Scan(&rows).Error
+		return rows, sql, err
+	}
+
+	// Order revenue by customer region joins orders to customers, which cannot fan out
+	rows, sql, err := run("m-orders", []string{"customers.region"}, []string{"revenue"}, []SemanticFilter{{Field: "status", Value: "paid"}})
+	if err != nil || len(rows) != 2 || rows[0]["customers.region"] != "EU" || rows[0]["revenue"] != int64(150) {
+		t.Errorf("Unexpected revenue by region %v (%v)\n%s", rows, err, sql)
+	}
+
+	// Revenue and ticket counts per region are aggregated separately: customer 1 has two orders
+	// and three tickets, which a plain join would multiply
+	rows, sql, err = run("m-customers", []string{"region"}, []string{"orders.revenue", "tickets.ticket_count", "customer_count"}, nil)
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("Unexpected per-region metrics %v (%v)\n%s", rows, err, sql)
+	}
+	eu := rows[0]
+	if eu["region"] != "EU" || eu["orders.revenue"] != int64(180) || eu["tickets.ticket_count"] != int64(3) || eu["customer_count"] != int64(2) {
+		t.Errorf("Expected EU revenue 180, 3 tickets and 2 customers, got %v\n%s", eu, sql)
+	}
+	if !strings.Contains(sql, "UNION") || !strings.Contains(sql, "FROM orders LEFT JOIN customers ON") {
+		t.Errorf("Expected pre-aggregated subqueries, got\n%s", sql)
+	}
+
+	// Counting customers by order status would count customers with several orders repeatedly
+	if _, _, err := run("m-customers", []string{"orders.status"}, []string{"customer_count"}, nil); err == nil ||
+		!strings.Contains(err.Error(), "more than once") {
+		t.Errorf("Expected a fan-out error, got %v", err)
+	}
+}
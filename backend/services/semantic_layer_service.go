@@ -1,7 +1,10 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 
 	"insight-engine-backend/models"
@@ -9,6 +12,12 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrMetricInUse is returned when deleting or renaming a metric that other metrics refer to
+var ErrMetricInUse = errors.New("metric is used by other metrics")
+
+// metricDataTypes are the data types a metric's values can be displayed as
+var metricDataTypes = map[string]bool{"number": true, "currency": true, "percentage": true, "count": true, "decimal": true}
+
 type SemanticLayerService struct {
 	db *gorm.DB
 }
@@ -27,13 +36,14 @@ func (s *SemanticLayerService) GetModelByID(modelID string) (*models.SemanticMod
 	return &model, nil
 }
 
-// TranslateSemanticQuery translates a semantic query to SQL
+// TranslateSemanticQuery translates a semantic query to SQL in the given dialect
 func (s *SemanticLayerService) TranslateSemanticQuery(
 	model *models.SemanticModel,
 	dimensions []string,
 	metrics []string,
 	filters map[string]interface{},
 	limit int,
+	dialect SQLDialect,
 ) (string, []interface{}, error) {
 	// Build dimension mapping
 	dimMap := make(map[string]string)
@@ -41,10 +51,10 @@ func (s *SemanticLayerService) TranslateSemanticQuery(
 		dimMap[dim.Name] = dim.ColumnName
 	}
 
-	// Build metric mapping
-	metricMap := make(map[string]string)
-	for _, metric := range model.Metrics {
-		metricMap[metric.Name] = metric.Formula
+	// Metrics may refer to each other, and are expanded to SQL
+	metricSet, err := modelMetricSubset(model, metrics)
+	if err != nil {
+		return "", nil, err
 	}
 
 	// Build SELECT clause
@@ -56,16 +66,16 @@ func (s *SemanticLayerService) TranslateSemanticQuery(
 		if !ok {
 			return "", nil, fmt.Errorf("dimension not found: %s", dimName)
 		}
-		selectParts = append(selectParts, fmt.Sprintf("%s AS \"%s\"", colName, dimName))
+		selectParts = append(selectParts, fmt.Sprintf("%s AS %s", colName, dialect.QuoteIdent(dimName)))
 	}
 
 	// Add metrics
 	for _, metricName := range metrics {
-		formula, ok := metricMap[metricName]
-		if !ok {
-			return "", nil, fmt.Errorf("metric not found: %s", metricName)
+		formula, err := metricSet.expand(metricName)
+		if err != nil {
+			return "", nil, err
 		}
-		selectParts = append(selectParts, fmt.Sprintf("%s AS \"%s\"", formula, metricName))
+		selectParts = append(selectParts, fmt.Sprintf("%s AS %s", formula, dialect.QuoteIdent(metricName)))
 	}
 
 	if len(selectParts) == 0 {
@@ -73,18 +83,23 @@ func (s *SemanticLayerService) TranslateSemanticQuery(
 	}
 
 	// Build query
-	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selectParts, ", "), model.Table)
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selectParts, ", "), semanticRelation(model, dialect))
+
+	// Build WHERE clause; filters are applied in name order so the placeholders are stable
+	filterNames := make([]string, 0, len(filters))
+	for dimName := range filters {
+		filterNames = append(filterNames, dimName)
+	}
+	sort.Strings(filterNames)
 
-	// Build WHERE clause
 	var whereParts []string
-	var args []interface{}
-	for dimName, value := range filters {
+	params := &sqlParams{dialect: dialect}
+	for _, dimName := range filterNames {
 		colName, ok := dimMap[dimName]
 		if !ok {
 			return "", nil, fmt.Errorf("filter dimension not found: %s", dimName)
 		}
-		whereParts = append(whereParts, fmt.Sprintf("%s = ?", colName))
-		args = append(args, value)
+		whereParts = append(whereParts, fmt.Sprintf("%s = %s", colName, params.add(filters[dimName])))
 	}
 
 	if len(whereParts) > 0 {
@@ -101,62 +116,177 @@ func (s *SemanticLayerService) TranslateSemanticQuery(
 		query += " GROUP BY " + strings.Join(groupByParts, ", ")
 	}
 
-	// Add LIMIT
+	// Add the limit in the dialect's syntax
 	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
+		query = dialect.LimitSQL(query, &limit, nil)
 	}
 
-	return query, args, nil
+	return query, params.args, nil
 }
 
-// ValidateMetricFormula validates a metric formula
-func (s *SemanticLayerService) ValidateMetricFormula(formula string) error {
-	// Basic validation: check for allowed aggregation functions
-	allowedFunctions := []string{"SUM", "AVG", "COUNT", "MIN", "MAX", "COUNT(DISTINCT"}
+// ListModelsByWorkspace retrieves all models for a workspace
+func (s *SemanticLayerService) ListModelsByWorkspace(workspaceID string) ([]models.SemanticModel, error) {
+	var models []models.SemanticModel
+	err := s.db.Preload("Dimensions").Preload("Metrics").
+		Where("workspace_id = ?", workspaceID).
+		Find(&models).Error
+	return models, err
+}
 
-	upperFormula := strings.ToUpper(formula)
-	hasValidFunction := false
-	for _, fn := range allowedFunctions {
-		if strings.Contains(upperFormula, fn) {
-			hasValidFunction = true
-			break
-		}
+// CreateModel creates a new semantic model
+func (s *SemanticLayerService) CreateModel(model *models.SemanticModel) error {
+	if err := validateModelSource(model); err != nil {
+		return err
 	}
 
-	if !hasValidFunction {
-		return fmt.Errorf("formula must contain a valid aggregation function (SUM, AVG, COUNT, MIN, MAX)")
+	// Validate metric formulas and their references to each other
+	if err := s.ValidateModelMetrics(model, nil); err != nil {
+		return err
 	}
 
-	// Check for dangerous SQL keywords
-	dangerousKeywords := []string{"DROP", "DELETE", "UPDATE", "INSERT", "ALTER", "CREATE", "EXEC", "EXECUTE"}
-	for _, keyword := range dangerousKeywords {
-		if strings.Contains(upperFormula, keyword) {
-			return fmt.Errorf("formula contains forbidden keyword: %s", keyword)
-		}
+	return s.db.Create(model).Error
+}
+
+// UpdateModel saves a model's name, description and source and, unless dimensions is nil,
+// replaces its dimensions. The workspace and data source of a model cannot change.
+func (s *SemanticLayerService) UpdateModel(model *models.SemanticModel, dimensions []models.SemanticDimension) error {
+	if err := validateModelSource(model); err != nil {
+		return err
 	}
+	// Metrics are checked against the model's table, which may have changed
+	if err := s.ValidateModelMetrics(model, nil); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(model).Select("Name", "Description", "Table", "SourceQuery").Updates(model).Error; err != nil {
+			return err
+		}
+		if dimensions == nil {
+			return nil
+		}
+		if err := tx.Where("model_id = ?", model.ID).Delete(&models.SemanticDimension{}).Error; err != nil {
+			return err
+		}
+		for i := range dimensions {
+			dimensions[i].ModelID = model.ID
+		}
+		if len(dimensions) > 0 {
+			if err := tx.Create(&dimensions).Error; err != nil {
+				return err
+			}
+		}
+		model.Dimensions = dimensions
+		return nil
+	})
+}
+
+// DeleteModel deletes a model with its dimensions, metrics and relationships
+func (s *SemanticLayerService) DeleteModel(modelID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("from_model_id = ? OR to_model_id = ?", modelID, modelID).Delete(&models.SemanticRelationship{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("model_id = ?", modelID).Delete(&models.SemanticMetric{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("model_id = ?", modelID).Delete(&models.SemanticDimension{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.SemanticModel{}, "id = ?", modelID).Error
+	})
+}
 
+// validateModelSource checks that a model reads from a table, or from a read-only query whose
+// results its table names
+func validateModelSource(model *models.SemanticModel) error {
+	if model.Table == "" {
+		return fmt.Errorf("table name is required")
+	}
+	if model.SourceQuery == "" {
+		return nil
+	}
+	if !plainColumnName.MatchString(model.Table) {
+		return fmt.Errorf("the table name of a query model must be a plain name: %s", model.Table)
+	}
+	query := trimStatement(model.SourceQuery)
+	upper := strings.ToUpper(query)
+	switch {
+	case !strings.HasPrefix(upper, "SELECT") && !strings.HasPrefix(upper, "WITH"):
+		return fmt.Errorf("source query must be a SELECT statement")
+	case strings.Contains(query, ";"):
+		return fmt.Errorf("source query must be a single statement")
+	case strings.Contains(query, "--") || strings.Contains(query, "/*"):
+		return fmt.Errorf("comments are not allowed in source queries")
+	}
+	if keyword := sourceQueryKeywords.FindString(query); keyword != "" {
+		return fmt.Errorf("source query contains forbidden keyword: %s", strings.ToUpper(keyword))
+	}
 	return nil
 }
 
-// ListModelsByWorkspace retrieves all models for a workspace
-func (s *SemanticLayerService) ListModelsByWorkspace(workspaceID string) ([]models.SemanticModel, error) {
-	var models []models.SemanticModel
-	err := s.db.Preload("Dimensions").Preload("Metrics").
-		Where("workspace_id = ?", workspaceID).
-		Find(&models).Error
-	return models, err
+// sourceQueryKeywords matches keywords that change data, which a model's query must not contain
+var sourceQueryKeywords = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|MERGE|DROP|CREATE|ALTER|TRUNCATE|GRANT|REVOKE|EXEC|EXECUTE|CALL|INTO)\b`)
+
+// ValidateModelMetrics validates the metric formulas of a model, which may refer to the model's
+// other metrics by name. Columns are checked against the table's columns when they are known.
+func (s *SemanticLayerService) ValidateModelMetrics(model *models.SemanticModel, columns []string) error {
+	_, err := modelMetricSet(model, columns)
+	return err
 }
 
-// CreateModel creates a new semantic model
-func (s *SemanticLayerService) CreateModel(model *models.SemanticModel) error {
-	// Validate metric formulas
+// modelMetricSet parses the metrics of a model
+func modelMetricSet(model *models.SemanticModel, columns []string) (*metricSet, error) {
+	formulas, err := modelMetricFormulas(model)
+	if err != nil {
+		return nil, err
+	}
+	return newMetricSet(formulas, model.Table, columns)
+}
+
+// modelMetricSubset parses the named metrics of a model and the metrics they refer to. The
+// model's other metrics are left out, so that one that does not parse, such as a raw SQL
+// formula from before metrics were parsed, only fails the queries that use it.
+func modelMetricSubset(model *models.SemanticModel, names []string) (*metricSet, error) {
+	formulas, err := modelMetricFormulas(model)
+	if err != nil {
+		return nil, err
+	}
+	if formulas, err = metricClosure(formulas, names); err != nil {
+		return nil, err
+	}
+	return newMetricSet(formulas, model.Table, nil)
+}
+
+// modelMetricFormulas returns the formulas of a model's metrics by name
+func modelMetricFormulas(model *models.SemanticModel) (map[string]string, error) {
+	formulas := make(map[string]string, len(model.Metrics))
 	for _, metric := range model.Metrics {
-		if err := s.ValidateMetricFormula(metric.Formula); err != nil {
-			return fmt.Errorf("invalid metric '%s': %w", metric.Name, err)
+		if _, ok := formulas[metric.Name]; ok {
+			return nil, fmt.Errorf("duplicate metric: %s", metric.Name)
 		}
+		formulas[metric.Name] = metric.Formula
 	}
+	return formulas, nil
+}
 
-	return s.db.Create(model).Error
+// InvalidMetric is a metric of a model whose formula cannot be used, with the reason
+type InvalidMetric struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// InvalidMetrics reports the metrics of a model that cannot be queried, such as raw SQL
+// formulas saved before metrics were parsed, so that they can be rewritten or deleted
+func (s *SemanticLayerService) InvalidMetrics(model *models.SemanticModel) []InvalidMetric {
+	invalid := []InvalidMetric{}
+	for _, metric := range model.Metrics {
+		if _, err := modelMetricSubset(model, []string{metric.Name}); err != nil {
+			invalid = append(invalid, InvalidMetric{ID: metric.ID, Name: metric.Name, Error: err.Error()})
+		}
+	}
+	return invalid
 }
 
 // ListMetricsByModel retrieves all metrics for a model
@@ -166,6 +296,104 @@ func (s *SemanticLayerService) ListMetricsByModel(modelID string) ([]models.Sema
 	return metrics, err
 }
 
+// GetMetricByID retrieves a metric
+func (s *SemanticLayerService) GetMetricByID(metricID string) (*models.SemanticMetric, error) {
+	var metric models.SemanticMetric
+	if err := s.db.First(&metric, "id = ?", metricID).Error; err != nil {
+		return nil, err
+	}
+	return &metric, nil
+}
+
+// CreateMetric adds a metric to its model
+func (s *SemanticLayerService) CreateMetric(metric *models.SemanticMetric) error {
+	model, err := s.GetModelByID(metric.ModelID)
+	if err != nil {
+		return fmt.Errorf("model not found: %s", metric.ModelID)
+	}
+	if metric.DataType != "" && !metricDataTypes[metric.DataType] {
+		return fmt.Errorf("invalid data type: %s", metric.DataType)
+	}
+	model.Metrics = append(model.Metrics, *metric)
+	if _, err := modelMetricSubset(model, []string{metric.Name}); err != nil {
+		return err
+	}
+
+	return s.db.Create(metric).Error
+}
+
+// UpdateMetric saves changes to a metric. A metric that other metrics refer to cannot be renamed.
+func (s *SemanticLayerService) UpdateMetric(metric *models.SemanticMetric) error {
+	model, err := s.GetModelByID(metric.ModelID)
+	if err != nil {
+		return fmt.Errorf("model not found: %s", metric.ModelID)
+	}
+	if metric.DataType != "" && !metricDataTypes[metric.DataType] {
+		return fmt.Errorf("invalid data type: %s", metric.DataType)
+	}
+
+	for i, existing := range model.Metrics {
+		if existing.ID != metric.ID {
+			continue
+		}
+		if existing.Name != metric.Name {
+			if err := s.checkMetricUnused(model, existing.Name); err != nil {
+				return err
+			}
+		}
+		model.Metrics[i] = *metric
+	}
+	if _, err := modelMetricSubset(model, []string{metric.Name}); err != nil {
+		return err
+	}
+
+	return s.db.Model(metric).Select("Name", "Formula", "Description", "Format", "DataType").Updates(metric).Error
+}
+
+// DeleteMetric deletes a metric that no other metric refers to
+func (s *SemanticLayerService) DeleteMetric(metricID string) error {
+	metric, err := s.GetMetricByID(metricID)
+	if err != nil {
+		return err
+	}
+	model, err := s.GetModelByID(metric.ModelID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkMetricUnused(model, metric.Name); err != nil {
+		return err
+	}
+
+	return s.db.Delete(&models.SemanticMetric{}, "id = ?", metricID).Error
+}
+
+// checkMetricUnused returns ErrMetricInUse if another metric of the model refers to name
+func (s *SemanticLayerService) checkMetricUnused(model *models.SemanticModel, name string) error {
+	formulas, err := modelMetricFormulas(model)
+	if err != nil {
+		return err
+	}
+	for _, metric := range model.Metrics {
+		if metric.Name == name {
+			continue
+		}
+		// Metrics that cannot be parsed refer to none, so that they do not keep the metrics
+		// they name from being fixed or deleted
+		closure, err := metricClosure(formulas, []string{metric.Name})
+		if err != nil {
+			continue
+		}
+		set, err := newMetricSet(closure, model.Table, nil)
+		if err != nil {
+			continue
+		}
+		if containsString(set.dependencies(metric.Name), name) {
+			return fmt.Errorf("%w: %s is used by %s", ErrMetricInUse, name, metric.Name)
+		}
+	}
+	return nil
+}
+
 // ListAllMetrics retrieves all metrics across all models in a workspace
 func (s *SemanticLayerService) ListAllMetrics(workspaceID string) ([]models.SemanticMetric, error) {
 	var metrics []models.SemanticMetric
@@ -174,3 +402,40 @@ func (s *SemanticLayerService) ListAllMetrics(workspaceID string) ([]models.Sema
 		Find(&metrics).Error
 	return metrics, err
 }
+
+// CreateRelationship creates a relationship between two models of the same workspace and data source
+func (s *SemanticLayerService) CreateRelationship(rel *models.SemanticRelationship) error {
+	switch rel.RelationshipType {
+	case RelationshipOneToOne, RelationshipOneToMany, RelationshipManyToOne, RelationshipManyToMany:
+	default:
+		return fmt.Errorf("invalid relationship type '%s'", rel.RelationshipType)
+	}
+	if rel.FromColumn == "" || rel.ToColumn == "" {
+		return fmt.Errorf("both join columns are required")
+	}
+
+	var from, to models.SemanticModel
+	if err := s.db.First(&from, "id = ?", rel.FromModelID).Error; err != nil {
+		return fmt.Errorf("model not found: %s", rel.FromModelID)
+	}
+	if err := s.db.First(&to, "id = ?", rel.ToModelID).Error; err != nil {
+		return fmt.Errorf("model not found: %s", rel.ToModelID)
+	}
+	if from.ID == to.ID || from.WorkspaceID != to.WorkspaceID || from.DataSourceID != to.DataSourceID {
+		return fmt.Errorf("relationships must join two different models of the same workspace and data source")
+	}
+
+	return s.db.Create(rel).Error
+}
+
+// DeleteRelationship deletes a relationship
+func (s *SemanticLayerService) DeleteRelationship(relationshipID string) error {
+	return s.db.Delete(&models.SemanticRelationship{}, "id = ?", relationshipID).Error
+}
+
+// ListRelationships retrieves the relationships a model takes part in
+func (s *SemanticLayerService) ListRelationships(modelID string) ([]models.SemanticRelationship, error) {
+	var relationships []models.SemanticRelationship
+	err := s.db.Where("from_model_id = ? OR to_model_id = ?", modelID, modelID).Find(&relationships).Error
+	return relationships, err
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"insight-engine-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ModelMigrationResult reports what MigrateModelDefinitions converted and what it left alone
+type ModelMigrationResult struct {
+	Models  []MigratedModel     `json:"models"`
+	Skipped []SkippedDefinition `json:"skipped"`
+}
+
+// MigratedModel is a model definition converted to a semantic model
+type MigratedModel struct {
+	DefinitionID    string `json:"definitionId"`
+	SemanticModelID string `json:"semanticModelId"`
+	Name            string `json:"name"`
+	Metrics         int    `json:"metrics"`
+}
+
+// SkippedDefinition is a model or metric definition that could not be converted
+type SkippedDefinition struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Kind   string `json:"kind"` // model, metric
+	Reason string `json:"reason"`
+}
+
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// MigrateModelDefinitions converts the model definitions of a workspace, with their metric
+// definitions, into semantic models on a data source, which model definitions do not record.
+// With definitionIDs, only those definitions are converted, so that definitions of different
+// data sources can be migrated separately. Converted definitions are linked to their semantic
+// model and metrics, which own them from then on, and are not converted again.
+func (s *SemanticLayerService) MigrateModelDefinitions(workspaceID, dataSourceID string, definitionIDs []string) (*ModelMigrationResult, error) {
+	query := s.db.Preload("Metrics").Where("workspace_id = ? AND semantic_model_id IS NULL", workspaceID)
+	if len(definitionIDs) > 0 {
+		query = query.Where("id IN ?", definitionIDs)
+	}
+	var definitions []models.ModelDefinition
+	if err := query.Order("created_at").Find(&definitions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load model definitions: %w", err)
+	}
+
+	result := &ModelMigrationResult{Models: []MigratedModel{}, Skipped: []SkippedDefinition{}}
+	for _, definition := range definitions {
+		model, err := s.migrateModelDefinition(definition, dataSourceID)
+		if err != nil {
+			result.Skipped = append(result.Skipped, SkippedDefinition{ID: definition.ID, Name: definition.Name, Kind: "model", Reason: err.Error()})
+			continue
+		}
+		result.Models = append(result.Models, MigratedModel{
+			DefinitionID: definition.ID, SemanticModelID: model.ID, Name: model.Name, Metrics: len(model.Metrics),
+		})
+	}
+
+	// Metrics of no model have no table to be computed from
+	if len(definitionIDs) == 0 {
+		var unattached []models.MetricDefinition
+		if err := s.db.Where("workspace_id = ? AND semantic_metric_id IS NULL AND (model_id IS NULL OR model_id = '')", workspaceID).
+			Find(&unattached).Error; err != nil {
+			return nil, fmt.Errorf("failed to load metric definitions: %w", err)
+		}
+		for _, metric := range unattached {
+			result.Skipped = append(result.Skipped, SkippedDefinition{ID: metric.ID, Name: metric.Name, Kind: "metric",
+				Reason: "metric is not attached to a model; attach it to one and migrate that model"})
+		}
+	}
+	return result, nil
+}
+
+// migrateModelDefinition converts a model definition and its metrics to a semantic model
+func (s *SemanticLayerService) migrateModelDefinition(definition models.ModelDefinition, dataSourceID string) (*models.SemanticModel, error) {
+	model := &models.SemanticModel{
+		ID:           uuid.New().String(),
+		Name:         definition.Name,
+		Description:  definition.Description,
+		DataSourceID: dataSourceID,
+		Table:        definition.SourceTable,
+		WorkspaceID:  definition.WorkspaceID,
+		CreatedBy:    definition.CreatedBy,
+	}
+	if definition.Type == "query" {
+		// The query's results are named after the model
+		model.SourceQuery = definition.SourceQuery
+		model.Table = strings.Trim(nonIdentifierChars.ReplaceAllString(strings.ToLower(definition.Name), "_"), "_")
+		if model.Table == "" || !plainColumnName.MatchString(model.Table) {
+			model.Table = "model_" + strings.ReplaceAll(definition.ID, "-", "")
+		}
+	}
+	for _, metric := range definition.Metrics {
+		format := metric.Format
+		if format == "" {
+			format = metric.DataType
+		}
+		model.Metrics = append(model.Metrics, models.SemanticMetric{
+			ID:          uuid.New().String(),
+			ModelID:     model.ID,
+			Name:        metric.Name,
+			Formula:     metric.Formula,
+			Description: metric.Description,
+			Format:      format,
+			DataType:    metric.DataType,
+		})
+	}
+
+	var existing int64
+	s.db.Model(&models.SemanticModel{}).Where("workspace_id = ? AND name = ?", model.WorkspaceID, model.Name).Count(&existing)
+	if existing > 0 {
+		return nil, fmt.Errorf("a semantic model named %s already exists", model.Name)
+	}
+	if err := validateModelSource(model); err != nil {
+		return nil, err
+	}
+	if err := s.ValidateModelMetrics(model, nil); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(model).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ModelDefinition{}).Where("id = ?", definition.ID).Update("semantic_model_id", model.ID).Error; err != nil {
+			return err
+		}
+		for i, metric := range definition.Metrics {
+			if err := tx.Model(&models.MetricDefinition{}).Where("id = ?", metric.ID).Update("semantic_metric_id", model.Metrics[i].ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save semantic model: %w", err)
+	}
+	return model, nil
+}
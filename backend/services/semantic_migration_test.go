@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"insight-engine-backend/models"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestMigrateModelDefinitions tests converting modeling API definitions into semantic models that
+// can be queried, and managing the semantic models' metrics afterwards
+func TestMigrateModelDefinitions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.SemanticModel{}, &models.SemanticDimension{}, &models.SemanticMetric{}, &models.SemanticRelationship{},
+		&models.ModelDefinition{}, &models.MetricDefinition{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	db.Exec("CREATE TABLE orders (id INTEGER, status TEXT, amount INTEGER, created_at TEXT)")
+	db.Exec("INSERT INTO orders VALUES (1, 'paid', 100, '2026-01-05'), (2, 'paid', 50, '2026-01-20'), (3, 'open', 30, '2026-02-11')")
+
+	modeling := NewModelingService(db)
+	for _, definition := range []models.ModelDefinition{
+		{ID: "def-orders", Name: "orders", Type: "table", SourceTable: "orders", WorkspaceID: "ws-1", CreatedBy: "user-1"},
+		{ID: "def-paid", Name: "Paid Orders", Type: "query", SourceQuery: "SELECT id, amount, created_at FROM orders WHERE status = 'paid';", WorkspaceID: "ws-1", CreatedBy: "user-1"},
+	} {
+		if err := modeling.CreateModelDefinition(&definition); err != nil {
+			t.Fatalf("Failed to create model definition: %v", err)
+		}
+	}
+	ordersID, paidID := "def-orders", "def-paid"
+	for _, metric := range []models.MetricDefinition{
+		{ID: "md1", Name: "revenue", Formula: "SUM(amount)", ModelID: &ordersID, DataType: "currency"},
+		{ID: "md2", Name: "order_count", Formula: "COUNT(*)", ModelID: &ordersID, DataType: "count"},
+		{ID: "md3", Name: "average_order", Formula: "revenue / order_count", ModelID: &ordersID, DataType: "currency"},
+		{ID: "md4", Name: "paid_revenue", Formula: "SUM(paid_orders.amount)", ModelID: &paidID, DataType: "currency"},
+		{ID: "md5", Name: "signups", Formula: "COUNT(*)", DataType: "count"},
+	} {
+		metric.WorkspaceID, metric.CreatedBy = "ws-1", "user-1"
+		if err := modeling.CreateMetricDefinition(&metric); err != nil {
+			t.Fatalf("Failed to create metric definition %s: %v", metric.Name, err)
+		}
+	}
+
+	service := NewSemanticLayerService(db)
+	result, err := service.MigrateModelDefinitions("ws-1", "conn-1", nil)
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+	if len(result.Models) != 2 || result.Models[0].Metrics != 3 || len(result.Skipped) != 1 || result.Skipped[0].Name != "signups" {
+		t.Fatalf("Unexpected migration result %+v", result)
+	}
+
+	// The query model reads from its query, named after the model
+	paid, _ := service.GetModelByID(result.Models[1].SemanticModelID)
+	sql, args, err := service.CompileSemanticQuery(paid, SemanticQuery{Metrics: []string{"paid_revenue"}}, DialectSQLite)
+	if err != nil || paid.Table != "paid_orders" {
+		t.Fatalf("Failed to compile a query model query: %v (table %s)", err, paid.Table)
+	}
+	var paidRevenue int
+	if err := db.Raw(sql, args...).Scan(&paidRevenue).Error; err != nil || paidRevenue != 150 {
+		t.Errorf("Expected paid revenue 150, got %d (%v)\n%s", paidRevenue, err, sql)
+	}
+
+	// Migrated definitions are owned by the semantic layer and not migrated twice
+	definition, _ := modeling.GetModelDefinition("def-orders")
+	if definition.SemanticModelID == nil || *definition.SemanticModelID != result.Models[0].SemanticModelID {
+		t.Errorf("Expected the definition to be linked to its semantic model, got %v", definition.SemanticModelID)
+	}
+	legacyMetric, _ := modeling.GetMetricDefinition("md1")
+	legacyMetric.Formula = "SUM(amount) * 2"
+	if err := modeling.UpdateMetricDefinition(legacyMetric); !errors.Is(err, ErrDefinitionMigrated) {
+		t.Errorf("Expected updating a migrated metric to be rejected, got %v", err)
+	}
+	if again, err := service.MigrateModelDefinitions("ws-1", "conn-1", nil); err != nil || len(again.Models) != 0 {
+		t.Errorf("Expected nothing left to migrate, got %+v (%v)", again, err)
+	}
+
+	// Metrics other metrics refer to cannot be renamed or deleted
+	orders, _ := service.GetModelByID(result.Models[0].SemanticModelID)
+	var revenue models.SemanticMetric
+	for _, metric := range orders.Metrics {
+		if metric.Name == "revenue" {
+			revenue = metric
+		}
+	}
+	revenue.Name = "gross_revenue"
+	if err := service.UpdateMetric(&revenue); !errors.Is(err, ErrMetricInUse) {
+		t.Errorf("Expected renaming a metric in use to be rejected, got %v", err)
+	}
+	if err := service.DeleteMetric(revenue.ID); !errors.Is(err, ErrMetricInUse) {
+		t.Errorf("Expected deleting a metric in use to be rejected, got %v", err)
+	}
+	if err := service.CreateMetric(&models.SemanticMetric{ID: "x9", ModelID: orders.ID, Name: "loop", Formula: "loop + revenue"}); err == nil {
+		t.Error("Expected a metric that refers to itself to be rejected")
+	}
+
+	orders.Description = "All orders"
+	if err := service.UpdateModel(orders, []models.SemanticDimension{{ID: "d9", Name: "status", ColumnName: "status", DataType: "string"}}); err != nil {
+		t.Fatalf("Failed to update model: %v", err)
+	}
+	orders, _ = service.GetModelByID(orders.ID)
+	if orders.Description != "All orders" || len(orders.Dimensions) != 1 || len(orders.Metrics) != 3 {
+		t.Errorf("Unexpected updated model %+v", orders)
+	}
+	if err := service.DeleteModel(orders.ID); err != nil {
+		t.Fatalf("Failed to delete model: %v", err)
+	}
+	var remaining int64
+	db.Model(&models.SemanticMetric{}).Where("model_id = ?", orders.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Errorf("Expected the model's metrics to be deleted, %d remain", remaining)
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"insight-engine-backend/models"
+)
+
+// SemanticQuery asks for metrics broken down by dimensions. Fields of models related to the
+// queried model are named "<model>.<field>".
+type SemanticQuery struct {
+	Dimensions    []string               `json:"dimensions"`
+	Metrics       []string               `json:"metrics"`
+	Filters       []SemanticFilter       `json:"filters"` // On dimensions (WHERE) or requested metrics (HAVING)
+	TimeDimension *SemanticTimeDimension `json:"timeDimension,omitempty"`
+	OrderBy       []SemanticOrder        `json:"orderBy"`
+	Limit         int                    `json:"limit"`
+	Offset        int                    `json:"offset"`
+}
+
+// SemanticFilter restricts a dimension or a metric
+type SemanticFilter struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"` // equals, not_equals, gt, gte, lt, lte, in, not_in, between, contains, not_contains, starts_with, is_null, is_not_null, relative_date
+	Value    interface{} `json:"value"`
+}
+
+// SemanticTimeDimension breaks the query down by a date dimension truncated to a grain, and
+// optionally restricts it to a relative date range such as "last_12_months"
+type SemanticTimeDimension struct {
+	Dimension string `json:"dimension"`
+	Grain     string `json:"grain"` // day, week, month, quarter, year
+	DateRange string `json:"dateRange,omitempty"`
+}
+
+// SemanticOrder orders the results by a requested dimension, metric or time dimension
+type SemanticOrder struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction"` // asc, desc
+}
+
+// Name returns the output column of a time dimension, "<dimension>_<grain>"
+func (td SemanticTimeDimension) Name() string {
+	return td.Dimension + "_" + strings.ToLower(td.Grain)
+}
+
+// CompileSemanticQuery compiles a semantic query on base to parameterised SQL in a dialect.
+// Metrics are only ever joined to rows that match at most once, so one-to-many joins cannot
+// double count them; metrics of different models are aggregated separately and lined up by
+// their dimension values.
+func (s *SemanticLayerService) CompileSemanticQuery(base *models.SemanticModel, query SemanticQuery, dialect SQLDialect) (string, []interface{}, error) {
+	graph, err := s.loadSemanticGraph(base)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+
+	var dims, measures, filters, having []semanticField
+	for _, name := range query.Dimensions {
+		field, err := graph.dimension(base, name)
+		if err != nil {
+			return "", nil, err
+		}
+		dims = append(dims, field)
+	}
+	if td := query.TimeDimension; td != nil {
+		field, err := graph.dimension(base, td.Dimension)
+		if err != nil {
+			return "", nil, fmt.Errorf("time %w", err)
+		}
+		if td.DateRange != "" {
+			filters = append(filters, semanticField{name: field.name, model: field.model, expr: field.expr, operator: "relative_date", value: td.DateRange})
+		}
+		if field.expr, err = dialect.DateTrunc(td.Grain, field.expr); err != nil {
+			return "", nil, err
+		}
+		field.name = td.Name()
+		dims = append(dims, field)
+	}
+	for _, name := range query.Metrics {
+		field, err := graph.metric(base, name)
+		if err != nil {
+			return "", nil, err
+		}
+		measures = append(measures, field)
+	}
+	if len(dims) == 0 && len(measures) == 0 {
+		return "", nil, fmt.Errorf("no dimensions or metrics specified")
+	}
+
+	// Filters on dimensions restrict rows; filters on metrics restrict the aggregated results
+	for _, filter := range query.Filters {
+		field, err := graph.dimension(base, filter.Field)
+		if err == nil {
+			field.operator, field.value = filter.Operator, filter.Value
+			filters = append(filters, field)
+			continue
+		}
+		if !containsString(query.Metrics, filter.Field) {
+			return "", nil, fmt.Errorf("filter field must be a dimension or a requested metric: %s", filter.Field)
+		}
+		field, _ = graph.metric(base, filter.Field)
+		field.operator, field.value = filter.Operator, filter.Value
+		having = append(having, field)
+	}
+
+	// Metrics are aggregated per model
+	var roots []*models.SemanticModel
+	for _, metric := range measures {
+		if !containsModel(roots, metric.model) {
+			roots = append(roots, metric.model)
+		}
+	}
+
+	params := &sqlParams{dialect: dialect}
+	var sql string
+	switch len(roots) {
+	case 0:
+		sql, err = graph.rootedQuery(base, dims, nil, filters, nil, false, dialect, params, now)
+	case 1:
+		sql, err = graph.rootedQuery(roots[0], dims, measures, filters, having, true, dialect, params, now)
+	default:
+		sql, err = graph.combinedQuery(roots, dims, measures, filters, having, dialect, params, now)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Order by output columns; a time series runs forward unless asked otherwise
+	orderBy := query.OrderBy
+	if len(orderBy) == 0 && query.TimeDimension != nil {
+		orderBy = []SemanticOrder{{Field: query.TimeDimension.Name()}}
+	}
+	var orderParts []string
+	for _, order := range orderBy {
+		if !containsString(query.Dimensions, order.Field) && !containsString(query.Metrics, order.Field) &&
+			(query.TimeDimension == nil || order.Field != query.TimeDimension.Name()) {
+			return "", nil, fmt.Errorf("order field must be a requested dimension or metric: %s", order.Field)
+		}
+		direction := "ASC"
+		if strings.EqualFold(order.Direction, "desc") {
+			direction = "DESC"
+		}
+		orderParts = append(orderParts, dialect.QuoteAlias(order.Field)+" "+direction)
+	}
+	if len(orderParts) > 0 {
+		sql += "\nORDER BY " + strings.Join(orderParts, ", ")
+	}
+
+	var limit, offset *int
+	if query.Limit > 0 {
+		limit = &query.Limit
+	}
+	if query.Offset > 0 {
+		offset = &query.Offset
+	}
+	return dialect.LimitSQL(sql, limit, offset), params.args, nil
+}
+
+// semanticCondition compiles a filter on an expression to a parameterised condition
+func semanticCondition(expr string, filter semanticField, dialect SQLDialect, params *sqlParams, now time.Time) (string, error) {
+	comparisons := map[string]string{"equals": "=", "not_equals": "<>", "gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+	operator := strings.ToLower(filter.operator)
+	if operator == "" {
+		operator = "equals"
+	}
+
+	if comparison, ok := comparisons[operator]; ok {
+		if filter.value == nil {
+			return "", fmt.Errorf("filter on %s needs a value", filter.name)
+		}
+		return fmt.Sprintf("%s %s %s", expr, comparison, params.add(filter.value)), nil
+	}
+
+	switch operator {
+	case "in", "not_in":
+		values, ok := filter.value.([]interface{})
+		if !ok || len(values) == 0 {
+			return "", fmt.Errorf("%s filter on %s needs a list of values", operator, filter.name)
+		}
+		placeholders := make([]string, len(values))
+		for i, value := range values {
+			placeholders[i] = params.add(value)
+		}
+		keyword := "IN"
+		if operator == "not_in" {
+			keyword = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s (%s)", expr, keyword, strings.Join(placeholders, ", ")), nil
+	case "between":
+		values, ok := filter.value.([]interface{})
+		if !ok || len(values) != 2 {
+			return "", fmt.Errorf("between filter on %s needs two values", filter.name)
+		}
+		return fmt.Sprintf("%s BETWEEN %s AND %s", expr, params.add(values[0]), params.add(values[1])), nil
+	case "contains", "not_contains", "starts_with":
+		// Case-insensitive, with LIKE wildcards in the value matched literally
+		text, ok := filter.value.(string)
+		if !ok {
+			return "", fmt.Errorf("%s filter on %s needs a text value", operator, filter.name)
+		}
+		pattern := escapeLike(text) + "%"
+		if operator != "starts_with" {
+			pattern = "%" + pattern
+		}
+		condition := dialect.ILike(expr, params.add(pattern)) + dialect.likeEscape()
+		if operator == "not_contains" {
+			condition = "NOT (" + condition + ")"
+		}
+		return condition, nil
+	case "is_null":
+		return expr + " IS NULL", nil
+	case "is_not_null":
+		return expr + " IS NOT NULL", nil
+	case "relative_date":
+		value, _ := filter.value.(string)
+		start, end, err := relativeDateRange(value, now)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s >= %s AND %s < %s", expr, params.add(start), expr, params.add(end)), nil
+	}
+	return "", fmt.Errorf("invalid filter operator '%s'", filter.operator)
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
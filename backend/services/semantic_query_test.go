@@ -0,0 +1,140 @@
+package services
+
+import (
+	"insight-engine-backend/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestCompileSemanticQuery tests filter operators, time grains, metric filters, ordering and
+// pagination by running the compiled SQL against SQLite
+func TestCompileSemanticQuery(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.SemanticModel{}, &models.SemanticDimension{}, &models.SemanticMetric{}, &models.SemanticRelationship{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	for _, statement := range []string{
+		"CREATE TABLE orders (id INTEGER, customer TEXT, status TEXT, amount INTEGER, created_at TEXT)",
+		`INSERT INTO orders VALUES (1, 'Acme_EU', 'paid', 100, '2026-01-05 10:00:00'), (2, 'AcmeXEU', 'paid', 50, '2026-01-20 12:00:00'),
+			(3, 'acme_eu', 'open', 300, '2026-02-11 09:00:00'), (4, 'Globex', 'paid', 150, '2026-03-02 16:30:00'),
+			(5, 'Globex', 'void', 20, '2026-03-09 08:00:00')`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("Failed to set up data: %v", err)
+		}
+	}
+
+	service := NewSemanticLayerService(db)
+	model := &models.SemanticModel{ID: "m-orders", Name: "orders", DataSourceID: "conn-1", Table: "orders", WorkspaceID: "ws-1", CreatedBy: "user-1",
+		Dimensions: []models.SemanticDimension{
+			{ID: "d1", Name: "customer", ColumnName: "customer", DataType: "string"},
+			{ID: "d2", Name: "status", ColumnName: "status", DataType: "string"},
+			{ID: "d3", Name: "created_at", ColumnName: "created_at", DataType: "date"},
+		},
+		Metrics: []models.SemanticMetric{
+			{ID: "x1", Name: "revenue", Formula: "SUM(orders.amount)"},
+			{ID: "x2", Name: "order_count", Formula: "COUNT(*)"},
+		},
+	}
+	if err := service.CreateModel(model); err != nil {
+		t.Fatalf("Failed to create model: %v", err)
+	}
+
+	run := func(query SemanticQuery) ([]map[string]interface{}, string) {
+		sql, args, err := service.CompileSemanticQuery(model, query, DialectSQLite)
+		if err != nil {
+			t.Fatalf("Failed to compile %+v: %v", query, err)
+		}
+		var rows []map[string]interface{}
+		if err := db.Raw(sql, args...).Scan(&rows).Error; err != nil {
+			t.Fatalf("Compiled SQL failed: %v\n%s", err, sql)
+		}
+		return rows, sql
+	}
+
+	// Monthly revenue of non-void orders, most recent first, skipping the first month
+	rows, sql := run(SemanticQuery{
+		Metrics:       []string{"revenue"},
+		Filters:       []SemanticFilter{{Field: "status", Operator: "not_in", Value: []interface{}{"void"}}},
+		TimeDimension: &SemanticTimeDimension{Dimension: "created_at", Grain: "month"},
+		OrderBy:       []SemanticOrder{{Field: "created_at_month", Direction: "desc"}},
+		Limit:         2,
+		Offset:        1,
+	})
+	if len(rows) != 2 || rows[0]["created_at_month"] != "2026-02-01" || rows[0]["revenue"] != int64(300) || rows[1]["revenue"] != int64(150) {
+		t.Errorf("Unexpected monthly revenue %v\n%s", rows, sql)
+	}
+
+	// Wildcards in contains values are matched literally, and case is ignored
+	rows, sql = run(SemanticQuery{
+		Dimensions: []string{"customer"},
+		Metrics:    []string{"order_count"},
+		Filters:    []SemanticFilter{{Field: "customer", Operator: "contains", Value: "ME_e"}},
+		OrderBy:    []SemanticOrder{{Field: "customer"}},
+	})
+	if len(rows) != 2 || rows[0]["customer"] != "Acme_EU" || rows[1]["customer"] != "acme_eu" {
+		t.Errorf("Unexpected customers %v\n%s", rows, sql)
+	}
+
+	// Metric thresholds filter the aggregated rows
+	rows, sql = run(SemanticQuery{
+		Dimensions: []string{"status"},
+		Metrics:    []string{"revenue"},
+		Filters: []SemanticFilter{
+			{Field: "revenue", Operator: "between", Value: []interface{}{100, 400}},
+			{Field: "created_at", Operator: "gte", Value: "2026-01-10"},
+		},
+		OrderBy: []SemanticOrder{{Field: "revenue", Direction: "desc"}},
+	})
+	if len(rows) != 2 || rows[0]["status"] != "open" || rows[1]["status"] != "paid" || rows[1]["revenue"] != int64(200) {
+		t.Errorf("Unexpected revenue by status %v\n%s", rows, sql)
+	}
+	if !strings.Contains(sql, "HAVING (SUM(orders.amount) BETWEEN ? AND ?)") {
+		t.Errorf("Expected a HAVING clause in\n%s", sql)
+	}
+
+	sql, args, err := service.CompileSemanticQuery(model, SemanticQuery{
+		Dimensions:    []string{"customer"},
+		Metrics:       []string{"revenue"},
+		Filters:       []SemanticFilter{{Field: "customer", Operator: "starts_with", Value: "acme"}, {Field: "revenue", Operator: "gt", Value: 10}},
+		TimeDimension: &SemanticTimeDimension{Dimension: "created_at", Grain: "quarter", DateRange: "last_12_months"},
+		Limit:         10,
+	}, DialectPostgres)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	for _, expected := range []string{
+		`DATE_TRUNC('quarter', orders.created_at) AS "created_at_quarter"`,
+		`WHERE (orders.created_at >= $1 AND orders.created_at < $2) AND (orders.customer ILIKE $3 ESCAPE '\')`,
+		`HAVING (SUM(orders.amount) > $4)`,
+		`ORDER BY "created_at_quarter" ASC`,
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, sql)
+		}
+	}
+	if len(args) != 4 || args[2] != "acme%" {
+		t.Errorf("Unexpected args %v", args)
+	} else if start, end := args[0].(time.Time), args[1].(time.Time); !end.AddDate(0, -12, 0).Equal(start) {
+		t.Errorf("Expected a 12 month range, got %v to %v", start, end)
+	}
+
+	for _, invalid := range []SemanticQuery{
+		{Metrics: []string{"revenue"}, Filters: []SemanticFilter{{Field: "status", Operator: "matches", Value: "paid"}}},
+		{Metrics: []string{"revenue"}, Filters: []SemanticFilter{{Field: "status", Operator: "in", Value: "paid"}}},
+		{Metrics: []string{"revenue"}, Filters: []SemanticFilter{{Field: "order_count", Operator: "gt", Value: 1}}},
+		{Metrics: []string{"revenue"}, TimeDimension: &SemanticTimeDimension{Dimension: "created_at", Grain: "fortnight"}},
+		{Metrics: []string{"revenue"}, OrderBy: []SemanticOrder{{Field: "status"}}},
+	} {
+		if _, _, err := service.CompileSemanticQuery(model, invalid, DialectSQLite); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SQLDialect identifies the SQL syntax of a connection type
+type SQLDialect string
+
+const (
+	DialectPostgres  SQLDialect = "postgres"
+	DialectMySQL     SQLDialect = "mysql"
+	DialectSQLServer SQLDialect = "sqlserver"
+	DialectOracle    SQLDialect = "oracle"
+	DialectSnowflake SQLDialect = "snowflake"
+	DialectSQLite    SQLDialect = "sqlite"
+	DialectBigQuery  SQLDialect = "bigquery"
+	DialectMongoDB   SQLDialect = "mongodb"
+)
+
+// DialectFor returns the dialect of a Connection.Type, defaulting to PostgreSQL
+func DialectFor(connectionType string) SQLDialect {
+	switch strings.ToLower(connectionType) {
+	case "mysql", "mariadb":
+		return DialectMySQL
+	case "sqlserver", "mssql":
+		return DialectSQLServer
+	case "oracle":
+		return DialectOracle
+	case "snowflake":
+		return DialectSnowflake
+	case "sqlite", "sqlite3", "file":
+		return DialectSQLite
+	case "bigquery":
+		return DialectBigQuery
+	case "mongodb", "mongo":
+		return DialectMongoDB
+	default:
+		return DialectPostgres
+	}
+}
+
+// QuoteIdent quotes a (possibly schema-qualified) identifier
+func (d SQLDialect) QuoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = d.QuoteAlias(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// QuoteAlias quotes a single name, such as a column alias, which may itself contain dots
+func (d SQLDialect) QuoteAlias(name string) string {
+	switch d {
+	case DialectMySQL:
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	case DialectBigQuery:
+		return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+	case DialectSQLServer:
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+	default:
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+}
+
+// Placeholder returns the n-th (1-based) bind parameter
+func (d SQLDialect) Placeholder(n int) string {
+	switch d {
+	case DialectPostgres:
+		return fmt.Sprintf("$%d", n)
+	case DialectOracle:
+		return fmt.Sprintf(":%d", n)
+	case DialectSQLServer:
+		return fmt.Sprintf("@p%d", n)
+	default:
+		return "?"
+	}
+}
+
+// sqlParams collects the bind parameters of a generated query and returns the dialect's
+// placeholder for each one
+type sqlParams struct {
+	dialect SQLDialect
+	offset  int // Parameters bound before this set, e.g. by an enclosing query
+	args    []interface{}
+}
+
+// add binds a value and returns its placeholder
+func (p *sqlParams) add(value interface{}) string {
+	p.args = append(p.args, value)
+	return p.dialect.Placeholder(p.offset + len(p.args))
+}
+
+// Date truncation grains
+const (
+	GrainSecond  = "second"
+	GrainMinute  = "minute"
+	GrainHour    = "hour"
+	GrainDay     = "day"
+	GrainWeek    = "week" // Weeks start on Monday
+	GrainMonth   = "month"
+	GrainQuarter = "quarter"
+	GrainYear    = "year"
+)
+
+// DateTrunc truncates a date or timestamp expression to the start of its grain
+func (d SQLDialect) DateTrunc(grain, expr string) (string, error) {
+	grain = strings.ToLower(grain)
+	switch grain {
+	case GrainSecond, GrainMinute, GrainHour, GrainDay, GrainWeek, GrainMonth, GrainQuarter, GrainYear:
+	default:
+		return "", fmt.Errorf("unsupported date grain %q", grain)
+	}
+
+	switch d {
+	case DialectPostgres, DialectSnowflake:
+		return fmt.Sprintf("DATE_TRUNC('%s', %s)", grain, expr), nil
+
+	case DialectBigQuery:
+		unit := strings.ToUpper(grain)
+		if grain == GrainWeek {
+			unit = "ISOWEEK"
+		}
+		return fmt.Sprintf("TIMESTAMP_TRUNC(CAST(%s AS TIMESTAMP), %s)", expr, unit), nil
+
+	case DialectMySQL:
+		switch grain {
+		case GrainSecond:
+			return fmt.Sprintf("CAST(DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:%%i:%%s') AS DATETIME)", expr), nil
+		case GrainMinute:
+			return fmt.Sprintf("CAST(DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:%%i:00') AS DATETIME)", expr), nil
+		case GrainHour:
+			return fmt.Sprintf("CAST(DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:00:00') AS DATETIME)", expr), nil
+		case GrainDay:
+			return fmt.Sprintf("DATE(%s)", expr), nil
+		case GrainWeek:
+			return fmt.Sprintf("DATE_SUB(DATE(%s), INTERVAL WEEKDAY(%s) DAY)", expr, expr), nil
+		case GrainMonth:
+			return fmt.Sprintf("CAST(DATE_FORMAT(%s, '%%Y-%%m-01') AS DATE)", expr), nil
+		case GrainQuarter:
+			return fmt.Sprintf("MAKEDATE(YEAR(%s), 1) + INTERVAL (QUARTER(%s) - 1) QUARTER", expr, expr), nil
+		default:
+			return fmt.Sprintf("MAKEDATE(YEAR(%s), 1)", expr), nil
+		}
+
+	case DialectSQLServer:
+		switch grain {
+		case GrainSecond, GrainMinute:
+			// Counting seconds from 1900 overflows DATEDIFF's int, so count from 2000
+			return fmt.Sprintf("DATEADD(%s, DATEDIFF(%s, '2000-01-01', %s), CAST('2000-01-01' AS DATETIME2))", grain, grain, expr), nil
+		case GrainWeek:
+			// Day 0 (1900-01-01) is a Monday; DATEDIFF counts week boundaries on Sundays
+			return fmt.Sprintf("DATEADD(week, DATEDIFF(week, 0, DATEADD(day, -1, %s)), 0)", expr), nil
+		default:
+			return fmt.Sprintf("DATEADD(%s, DATEDIFF(%s, 0, %s), 0)", grain, grain, expr), nil
+		}
+
+	case DialectOracle:
+		formats := map[string]string{
+			GrainMinute: "MI", GrainHour: "HH24", GrainDay: "DD", GrainWeek: "IW",
+			GrainMonth: "MM", GrainQuarter: "Q", GrainYear: "YYYY",
+		}
+		if grain == GrainSecond {
+			return fmt.Sprintf("CAST(%s AS DATE)", expr), nil
+		}
+		return fmt.Sprintf("TRUNC(%s, '%s')", expr, formats[grain]), nil
+
+	case DialectSQLite:
+		switch grain {
+		case GrainSecond:
+			return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:%%M:%%S', %s)", expr), nil
+		case GrainMinute:
+			return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:%%M:00', %s)", expr), nil
+		case GrainHour:
+			return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:00:00', %s)", expr), nil
+		case GrainDay:
+			return fmt.Sprintf("date(%s)", expr), nil
+		case GrainWeek:
+			return fmt.Sprintf("date(%s, '-6 days', 'weekday 1')", expr), nil
+		case GrainMonth:
+			return fmt.Sprintf("date(%s, 'start of month')", expr), nil
+		case GrainQuarter:
+			return fmt.Sprintf("date(%s, 'start of month', printf('-%%d months', (CAST(strftime('%%m', %s) AS INTEGER) - 1) %% 3))", expr, expr), nil
+		default:
+			return fmt.Sprintf("date(%s, 'start of year')", expr), nil
+		}
+	}
+	return "", fmt.Errorf("date truncation is not supported for %s", d)
+}
+
+// Concat concatenates string expressions
+func (d SQLDialect) Concat(exprs ...string) string {
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	switch d {
+	case DialectMySQL, DialectSQLServer, DialectBigQuery:
+		// || is logical OR in MySQL and unsupported in SQL Server
+		return "CONCAT(" + strings.Join(exprs, ", ") + ")"
+	default:
+		return "(" + strings.Join(exprs, " || ") + ")"
+	}
+}
+
+// ILike matches an expression against a LIKE pattern case-insensitively
+func (d SQLDialect) ILike(expr, pattern string) string {
+	switch d {
+	case DialectPostgres, DialectSnowflake:
+		return expr + " ILIKE " + pattern
+	default:
+		return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s)", expr, pattern)
+	}
+}
+
+// likeEscape returns the clause that makes backslash the escape character of a LIKE pattern,
+// as written by escapeLike. MySQL and BigQuery escape with backslashes already.
+func (d SQLDialect) likeEscape() string {
+	switch d {
+	case DialectMySQL, DialectBigQuery:
+		return ""
+	case DialectSnowflake:
+		return ` ESCAPE '\\'` // Backslashes escape string literals too
+	default:
+		return ` ESCAPE '\'`
+	}
+}
+
+// sessionIDQuery returns the query that reads the server session ID of a connection, for
+// dialects whose running queries can be cancelled from another session, or ""
+func (d SQLDialect) sessionIDQuery() string {
+	switch d {
+	case DialectPostgres:
+		return "SELECT pg_backend_pid()"
+	case DialectMySQL:
+		return "SELECT CONNECTION_ID()"
+	default:
+		return ""
+	}
+}
+
+// cancelSQL returns the statement that cancels the running query of a server session
+func (d SQLDialect) cancelSQL(sessionID int64) string {
+	switch d {
+	case DialectPostgres:
+		return fmt.Sprintf("SELECT pg_cancel_backend(%d)", sessionID)
+	case DialectMySQL:
+		return fmt.Sprintf("KILL QUERY %d", sessionID)
+	default:
+		return ""
+	}
+}
+
+// subqueryAlias returns the alias clause for a derived table; Oracle does not accept AS
+func (d SQLDialect) subqueryAlias(alias string) string {
+	if d == DialectOracle {
+		return " " + alias
+	}
+	return " AS " + alias
+}
+
+// LimitSQL restricts a query to limit rows after skipping offset rows, using the dialect's
+// syntax: LIMIT/OFFSET, TOP or OFFSET ... FETCH. Queries that already limit their rows are
+// wrapped in a subquery so that the outer limit applies to their result.
+func (d SQLDialect) LimitSQL(query string, limit, offset *int) string {
+	query = trimStatement(query)
+	if limit == nil && (offset == nil || *offset <= 0) {
+		return query
+	}
+	if d == DialectMongoDB {
+		return mongoLimit(query, limit, offset)
+	}
+	keywords := topLevelKeywords(query)
+
+	switch d {
+	case DialectSQLServer:
+		if keywords["TOP"] || keywords["OFFSET"] {
+			query = "SELECT * FROM (\n" + query + "\n)" + d.subqueryAlias("limited_query")
+			keywords = map[string]bool{}
+		}
+		if limit != nil && (offset == nil || *offset <= 0) && !keywords["ORDER"] {
+			if top, ok := injectTop(query, *limit); ok {
+				return top
+			}
+		}
+		if !keywords["ORDER"] {
+			query += "\nORDER BY (SELECT NULL)"
+		}
+		return query + "\n" + offsetFetch(limit, offset)
+
+	case DialectOracle:
+		if keywords["FETCH"] || keywords["OFFSET"] || keywords["ROWNUM"] {
+			query = "SELECT * FROM (\n" + query + "\n)" + d.subqueryAlias("limited_query")
+		}
+		if offset == nil || *offset <= 0 {
+			return fmt.Sprintf("%s\nFETCH FIRST %d ROWS ONLY", query, *limit)
+		}
+		return query + "\n" + offsetFetch(limit, offset)
+
+	default:
+		if keywords["LIMIT"] || keywords["FETCH"] || keywords["OFFSET"] {
+			query = "SELECT * FROM (\n" + query + "\n)" + d.subqueryAlias("limited_query")
+		}
+		clause := ""
+		if limit != nil {
+			clause = fmt.Sprintf("LIMIT %d", *limit)
+		} else {
+			// OFFSET without LIMIT is not valid everywhere, so use the dialect's "no limit"
+			switch d {
+			case DialectMySQL:
+				clause = "LIMIT 18446744073709551615"
+			case DialectSQLite:
+				clause = "LIMIT -1"
+			case DialectSnowflake:
+				clause = "LIMIT NULL"
+			}
+		}
+		if offset != nil && *offset > 0 {
+			clause = strings.TrimSpace(fmt.Sprintf("%s OFFSET %d", clause, *offset))
+		}
+		return query + "\n" + clause
+	}
+}
+
+// KeysetSQL pages through a query by a key column: it returns the rows whose key is greater
+// than after (or all rows when after is nil), ordered by the key and limited to limit rows.
+// Unlike OFFSET, each page costs the same however deep the client has paged.
+func (d SQLDialect) KeysetSQL(query, keyColumn string, after interface{}, limit int, argOffset int) (string, []interface{}) {
+	key := "keyset_query." + d.QuoteIdent(keyColumn)
+	sql := "SELECT * FROM (\n" + trimStatement(query) + "\n)" + d.subqueryAlias("keyset_query")
+
+	var args []interface{}
+	if after != nil {
+		sql += fmt.Sprintf("\nWHERE %s > %s", key, d.Placeholder(argOffset+1))
+		args = append(args, after)
+	}
+	sql += "\nORDER BY " + key
+	return d.LimitSQL(sql, &limit, nil), args
+}
+
+// offsetFetch builds the standard OFFSET ... FETCH clause used by SQL Server and Oracle
+func offsetFetch(limit, offset *int) string {
+	skip := 0
+	if offset != nil && *offset > 0 {
+		skip = *offset
+	}
+	clause := fmt.Sprintf("OFFSET %d ROWS", skip)
+	if limit != nil {
+		clause += fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", *limit)
+	}
+	return clause
+}
+
+var selectPrefix = regexp.MustCompile(`(?is)^\s*SELECT(\s+(?:DISTINCT|ALL))?\s`)
+
+// injectTop rewrites "SELECT [DISTINCT] ..." as "SELECT [DISTINCT] TOP n ..."
+func injectTop(query string, limit int) (string, bool) {
+	loc := selectPrefix.FindStringIndex(query)
+	if loc == nil {
+		return query, false
+	}
+	return fmt.Sprintf("%s TOP %d %s", strings.TrimSpace(query[:loc[1]]), limit, query[loc[1]:]), true
+}
+
+// trimStatement removes surrounding whitespace and trailing semicolons
+func trimStatement(query string) string {
+	return strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+}
+
+// topLevelKeywords returns the upper-cased words of a query that are outside parentheses,
+// string literals, quoted identifiers and comments
+func topLevelKeywords(query string) map[string]bool {
+	return queryKeywords(query, false)
+}
+
+// queryKeywords returns the upper-cased words of a query that are outside string literals,
+// quoted identifiers and comments, including the words inside parentheses when nested is set
+func queryKeywords(query string, nested bool) map[string]bool {
+	keywords := make(map[string]bool)
+	depth := 0
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`' || ch == '[':
+			closing := ch
+			if ch == '[' {
+				closing = ']'
+			}
+			for i++; i < len(query); i++ {
+				if query[i] == closing {
+					if i+1 < len(query) && query[i+1] == closing && closing != ']' {
+						i++ // Escaped quote
+						continue
+					}
+					break
+				}
+			}
+		case ch == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case ch == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return keywords
+			}
+			i += end + 3
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case isIdentChar(ch):
+			start := i
+			for i+1 < len(query) && isIdentChar(query[i+1]) {
+				i++
+			}
+			if depth == 0 || nested {
+				keywords[strings.ToUpper(query[start:i+1])] = true
+			}
+		}
+	}
+	return keywords
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
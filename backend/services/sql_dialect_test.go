@@ -0,0 +1,168 @@
+package services
+
+import (
+	"insight-engine-backend/models"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestLimitSQL tests dialect-specific row limiting
+func TestLimitSQL(t *testing.T) {
+	limit, offset := 10, 20
+	cases := []struct {
+		dialect  SQLDialect
+		query    string
+		limit    *int
+		offset   *int
+		expected string
+	}{
+		{DialectPostgres, "SELECT * FROM orders;", &limit, nil, "SELECT * FROM orders\nLIMIT 10"},
+		{DialectMySQL, "SELECT * FROM orders", &limit, &offset, "SELECT * FROM orders\nLIMIT 10 OFFSET 20"},
+		{DialectMySQL, "SELECT * FROM orders", nil, &offset, "SELECT * FROM orders\nLIMIT 18446744073709551615 OFFSET 20"},
+		{DialectPostgres, "SELECT * FROM orders LIMIT 100", &limit, nil, "SELECT * FROM (\nSELECT * FROM orders LIMIT 100\n) AS limited_query\nLIMIT 10"},
+		{DialectPostgres, "SELECT * FROM (SELECT id FROM t LIMIT 5) s", &limit, nil, "SELECT * FROM (SELECT id FROM t LIMIT 5) s\nLIMIT 10"},
+		{DialectSQLServer, "SELECT DISTINCT region FROM orders", &limit, nil, "SELECT DISTINCT TOP 10 region FROM orders"},
+		{DialectSQLServer, "SELECT * FROM orders ORDER BY id", &limit, &offset, "SELECT * FROM orders ORDER BY id\nOFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"},
+		{DialectSQLServer, "SELECT * FROM orders", &limit, &offset, "SELECT * FROM orders\nORDER BY (SELECT NULL)\nOFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"},
+		{DialectOracle, "SELECT * FROM orders", &limit, nil, "SELECT * FROM orders\nFETCH FIRST 10 ROWS ONLY"},
+		{DialectOracle, "SELECT * FROM orders", &limit, &offset, "SELECT * FROM orders\nOFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"},
+		{DialectPostgres, "SELECT * FROM orders", nil, nil, "SELECT * FROM orders"},
+	}
+
+	for _, tc := range cases {
+		if got := tc.dialect.LimitSQL(tc.query, tc.limit, tc.offset); got != tc.expected {
+			t.Errorf("%s %q: expected %q, got %q", tc.dialect, tc.query, tc.expected, got)
+		}
+	}
+}
+
+// TestTopLevelKeywords tests that literals, comments and subqueries are skipped
+func TestTopLevelKeywords(t *testing.T) {
+	keywords := topLevelKeywords("SELECT 'limit' AS \"order\" -- limit\nFROM (SELECT * FROM t LIMIT 1) s /* offset */ WHERE x = 1")
+	for _, word := range []string{"LIMIT", "ORDER", "OFFSET"} {
+		if keywords[word] {
+			t.Errorf("Did not expect %s at the top level", word)
+		}
+	}
+	if !keywords["SELECT"] || !keywords["WHERE"] {
+		t.Errorf("Expected SELECT and WHERE, got %v", keywords)
+	}
+}
+
+// TestKeysetSQL tests keyset pagination queries and cursor round trips
+func TestKeysetSQL(t *testing.T) {
+	sql, args := DialectPostgres.KeysetSQL("SELECT * FROM orders WHERE region = $1", "id", int64(42), 100, 1)
+	expected := "SELECT * FROM (\nSELECT * FROM orders WHERE region = $1\n) AS keyset_query\nWHERE keyset_query.\"id\" > $2\nORDER BY keyset_query.\"id\"\nLIMIT 100"
+	if sql != expected {
+		t.Errorf("Expected %q, got %q", expected, sql)
+	}
+	if len(args) != 1 || args[0] != int64(42) {
+		t.Errorf("Expected the key as the only argument, got %v", args)
+	}
+
+	for _, key := range []interface{}{int64(42), "order-17", 1.5} {
+		decoded, err := decodeQueryCursor(encodeQueryCursor(key))
+		if err != nil || decoded != key {
+			t.Errorf("Expected cursor for %v to round trip, got %v (%v)", key, decoded, err)
+		}
+	}
+	if _, err := decodeQueryCursor("not a cursor!"); err == nil {
+		t.Error("Expected an error for an invalid cursor")
+	}
+}
+
+// TestDialectSyntax tests quoting, placeholders, concatenation and date truncation per dialect
+func TestDialectSyntax(t *testing.T) {
+	cases := []struct {
+		dialect     SQLDialect
+		quoted      string
+		placeholder string
+		concat      string
+		month       string
+	}{
+		{DialectPostgres, `"sales"."order"`, "$2", "(a || b)", "DATE_TRUNC('month', created_at)"},
+		{DialectMySQL, "`sales`.`order`", "?", "CONCAT(a, b)", "CAST(DATE_FORMAT(created_at, '%Y-%m-01') AS DATE)"},
+		{DialectSQLServer, "[sales].[order]", "@p2", "CONCAT(a, b)", "DATEADD(month, DATEDIFF(month, 0, created_at), 0)"},
+		{DialectOracle, `"sales"."order"`, ":2", "(a || b)", "TRUNC(created_at, 'MM')"},
+		{DialectBigQuery, "`sales`.`order`", "?", "CONCAT(a, b)", "TIMESTAMP_TRUNC(CAST(created_at AS TIMESTAMP), MONTH)"},
+	}
+	for _, tc := range cases {
+		if got := tc.dialect.QuoteIdent("sales.order"); got != tc.quoted {
+			t.Errorf("%s: expected %s, got %s", tc.dialect, tc.quoted, got)
+		}
+		params := &sqlParams{dialect: tc.dialect}
+		params.add(1)
+		if got := params.add(2); got != tc.placeholder || len(params.args) != 2 {
+			t.Errorf("%s: expected placeholder %s, got %s", tc.dialect, tc.placeholder, got)
+		}
+		if got := tc.dialect.Concat("a", "b"); got != tc.concat {
+			t.Errorf("%s: expected %s, got %s", tc.dialect, tc.concat, got)
+		}
+		if got, err := tc.dialect.DateTrunc(GrainMonth, "created_at"); err != nil || got != tc.month {
+			t.Errorf("%s: expected %s, got %s (%v)", tc.dialect, tc.month, got, err)
+		}
+	}
+	if _, err := DialectPostgres.DateTrunc("fortnight", "created_at"); err == nil {
+		t.Error("Expected an unsupported grain to be rejected")
+	}
+
+	// The SQLite truncations are checked against a real database
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for grain, expected := range map[string]string{
+		GrainHour:    "2026-08-16 13:00:00",
+		GrainDay:     "2026-08-16",
+		GrainWeek:    "2026-08-10", // 2026-08-16 is a Sunday
+		GrainMonth:   "2026-08-01",
+		GrainQuarter: "2026-07-01",
+		GrainYear:    "2026-01-01",
+	} {
+		expr, _ := DialectSQLite.DateTrunc(grain, "'2026-08-16 13:45:10'")
+		var got string
+		if err := db.Raw("SELECT " + expr).Scan(&got).Error; err != nil || got != expected {
+			t.Errorf("sqlite %s: expected %s, got %s (%v)", grain, expected, got, err)
+		}
+	}
+}
+
+// TestDialectSQLGeneration tests that the semantic layer and the engine generate SQL in the
+// connection's dialect
+func TestDialectSQLGeneration(t *testing.T) {
+	model := &models.SemanticModel{
+		Table:      "orders",
+		Dimensions: []models.SemanticDimension{{Name: "region", ColumnName: "region"}, {Name: "status", ColumnName: "status"}},
+		Metrics:    []models.SemanticMetric{{Name: "revenue", Formula: "SUM(amount)"}},
+	}
+	filters := map[string]interface{}{"status": "paid", "region": "EU"}
+
+	sql, args, err := (&SemanticLayerService{}).TranslateSemanticQuery(model, []string{"region"}, []string{"revenue"}, filters, 10, DialectSQLServer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "SELECT TOP 10 region AS [region], SUM(amount) AS [revenue] FROM orders WHERE region = @p1 AND status = @p2 GROUP BY region"
+	if sql != expected || len(args) != 2 || args[0] != "EU" || args[1] != "paid" {
+		t.Errorf("Expected %q with [EU paid], got %q with %v", expected, sql, args)
+	}
+
+	sql, _, _ = (&SemanticLayerService{}).TranslateSemanticQuery(model, []string{"region"}, nil, nil, 10, DialectOracle)
+	if sql != "SELECT region AS \"region\" FROM orders\nFETCH FIRST 10 ROWS ONLY" {
+		t.Errorf("Unexpected Oracle SQL %q", sql)
+	}
+
+	sql, err = (&EngineService{}).buildAggregationSQL(AggregateRequest{
+		SQL:          "SELECT * FROM orders;",
+		GroupBy:      []string{"region"},
+		Aggregations: []Aggregation{{Column: "amount", Function: "sum"}, {Column: "*", Function: "count"}},
+	}, DialectMySQL)
+	expected = "SELECT `region`, SUM(`amount`) AS `sum_amount`, COUNT(*) AS `count` FROM (\nSELECT * FROM orders\n) AS subquery GROUP BY `region`"
+	if err != nil || sql != expected {
+		t.Errorf("Expected %q, got %q (%v)", expected, sql, err)
+	}
+	if _, err := (&EngineService{}).buildAggregationSQL(AggregateRequest{SQL: "SELECT 1", Aggregations: []Aggregation{{Column: "x", Function: "median"}}}, DialectMySQL); err == nil {
+		t.Error("Expected an invalid aggregation function to be rejected")
+	}
+}
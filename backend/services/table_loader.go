@@ -0,0 +1,405 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Table write modes
+const (
+	LoadModeAppend    = "APPEND"
+	LoadModeOverwrite = "OVERWRITE"
+)
+
+// TableLoader writes tabular batches into a physical table, creating it on demand
+type TableLoader struct {
+	db        *sql.DB
+	dialect   string // Connection.Type of the target database
+	batchSize int    // Rows per multi-row INSERT
+	maxParams int    // Bind parameter limit per statement
+}
+
+// NewTableLoader creates a loader for the given database and dialect
+func NewTableLoader(db *sql.DB, dialect string) *TableLoader {
+	return &TableLoader{
+		db:        db,
+		dialect:   strings.ToLower(dialect),
+		batchSize: 500,
+		maxParams: 2000, // SQL Server caps a statement at 2100 parameters
+	}
+}
+
+// Load writes rows into table using the given mode (APPEND or OVERWRITE) in one transaction,
+// so that a failed load leaves the table as it was. Rows are positional and must follow the
+// order of columns.
+func (l *TableLoader) Load(
+	ctx context.Context,
+	table string,
+	columns []TempTableColumn,
+	rows [][]interface{},
+	mode string,
+) (int, error) {
+	exists := l.TableExists(ctx, table)
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin load transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	loaded, err := l.LoadTx(ctx, tx, table, exists, columns, rows, mode)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit load: %w", err)
+	}
+
+	return loaded, nil
+}
+
+// LoadTx writes rows into table within a transaction the caller commits.
+// exists reports whether the table existed before the transaction began.
+func (l *TableLoader) LoadTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	table string,
+	exists bool,
+	columns []TempTableColumn,
+	rows [][]interface{},
+	mode string,
+) (int, error) {
+	if err := l.prepareTx(ctx, tx, table, exists, columns, mode); err != nil {
+		return 0, err
+	}
+
+	batchSize := l.batchSize
+	if perStatement := l.maxParams / len(columns); perStatement < batchSize {
+		batchSize = perStatement
+	}
+	if batchSize < 1 || l.dialect == "oracle" {
+		// Oracle has no multi-row VALUES clause
+		batchSize = 1
+	}
+
+	loaded := 0
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		query, args := l.buildInsert(table, columns, rows[start:end])
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return loaded, fmt.Errorf("failed to insert rows %d-%d into %s: %w", start, end, table, err)
+		}
+		loaded += end - start
+	}
+
+	return loaded, nil
+}
+
+// CopyTx copies the given columns of every row of source into table within a transaction
+// the caller commits. exists reports whether table existed before the transaction began.
+func (l *TableLoader) CopyTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	source string,
+	table string,
+	exists bool,
+	columns []TempTableColumn,
+	mode string,
+) (int, error) {
+	if err := l.prepareTx(ctx, tx, table, exists, columns, mode); err != nil {
+		return 0, err
+	}
+
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = l.QuoteIdent(col.Name)
+	}
+	list := strings.Join(names, ", ")
+	res, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s",
+		l.QuoteIdent(table), list, list, l.QuoteIdent(source)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy rows from %s into %s: %w", source, table, err)
+	}
+	copied, err := res.RowsAffected()
+	if err != nil {
+		// The driver cannot count the copied rows
+		return 0, nil
+	}
+	return int(copied), nil
+}
+
+// prepareTx drops the table when it is overwritten and creates it when it does not exist
+func (l *TableLoader) prepareTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	table string,
+	exists bool,
+	columns []TempTableColumn,
+	mode string,
+) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("cannot load table %s without columns", table)
+	}
+
+	if strings.ToUpper(mode) == LoadModeOverwrite && exists {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", l.QuoteIdent(table))); err != nil {
+			return fmt.Errorf("failed to drop table %s: %w", table, err)
+		}
+		exists = false
+	}
+
+	if !exists {
+		if _, err := tx.ExecContext(ctx, l.createTableDDL(table, columns)); err != nil {
+			return fmt.Errorf("failed to create table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// EnsureSchema creates a schema if the dialect supports it and it does not exist yet
+func (l *TableLoader) EnsureSchema(ctx context.Context, schema string) error {
+	var ddl string
+	switch l.dialect {
+	case "postgres", "postgresql":
+		ddl = fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", l.QuoteIdent(schema))
+	case "mysql":
+		ddl = fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", l.QuoteIdent(schema))
+	default:
+		return nil
+	}
+	if _, err := l.db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("failed to create schema %s: %w", schema, err)
+	}
+	return nil
+}
+
+// TableExists reports whether the table can be selected from
+func (l *TableLoader) TableExists(ctx context.Context, table string) bool {
+	rows, err := l.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", l.QuoteIdent(table)))
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+// QuoteIdent quotes a (possibly schema-qualified) identifier for the loader's dialect
+func (l *TableLoader) QuoteIdent(name string) string {
+	return DialectFor(l.dialect).QuoteIdent(name)
+}
+
+// Placeholder returns the n-th (1-based) bind parameter for the loader's dialect
+func (l *TableLoader) Placeholder(n int) string {
+	return DialectFor(l.dialect).Placeholder(n)
+}
+
+// createTableDDL generates CREATE TABLE DDL for the loader's dialect
+func (l *TableLoader) createTableDDL(table string, columns []TempTableColumn) string {
+	defs := make([]string, len(columns))
+	for i, col := range columns {
+		defs[i] = fmt.Sprintf("%s %s", l.QuoteIdent(col.Name), l.columnType(col.DataType))
+		if !col.Nullable {
+			defs[i] += " NOT NULL"
+		}
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", l.QuoteIdent(table), strings.Join(defs, ",\n  "))
+}
+
+// columnType maps an internal data type to a column type of the loader's dialect
+func (l *TableLoader) columnType(dataType string) string {
+	switch l.dialect {
+	case "sqlserver", "mssql":
+		switch dataType {
+		case "integer":
+			return "BIGINT"
+		case "float":
+			return "FLOAT"
+		case "boolean":
+			return "BIT"
+		case "date", "timestamp":
+			return "DATETIME2"
+		default:
+			return "NVARCHAR(MAX)"
+		}
+	case "oracle":
+		switch dataType {
+		case "integer":
+			return "NUMBER(19)"
+		case "float":
+			return "BINARY_DOUBLE"
+		case "boolean":
+			return "NUMBER(1)"
+		case "date", "timestamp":
+			return "TIMESTAMP"
+		default:
+			return "CLOB"
+		}
+	case "mysql":
+		switch dataType {
+		case "integer":
+			return "BIGINT"
+		case "float":
+			return "DOUBLE"
+		case "boolean":
+			return "BOOLEAN"
+		case "date", "timestamp":
+			return "DATETIME"
+		default:
+			return "TEXT"
+		}
+	default:
+		switch dataType {
+		case "integer":
+			return "BIGINT"
+		case "float":
+			return "DOUBLE PRECISION"
+		case "boolean":
+			return "BOOLEAN"
+		case "date", "timestamp":
+			return "TIMESTAMP"
+		case "json":
+			if l.dialect == "postgres" || l.dialect == "postgresql" {
+				return "JSONB"
+			}
+			return "TEXT"
+		default:
+			return "TEXT"
+		}
+	}
+}
+
+// buildInsert builds a multi-row INSERT statement with bind parameters
+func (l *TableLoader) buildInsert(table string, columns []TempTableColumn, rows [][]interface{}) (string, []interface{}) {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = l.QuoteIdent(col.Name)
+	}
+
+	tuples := make([]string, len(rows))
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	n := 1
+	for i, row := range rows {
+		placeholders := make([]string, len(columns))
+		for j, col := range columns {
+			placeholders[j] = l.Placeholder(n)
+			n++
+
+			var value interface{}
+			if j < len(row) {
+				value = row[j]
+			}
+			args = append(args, l.normalizeValue(value, col.DataType))
+		}
+		tuples[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		l.QuoteIdent(table), strings.Join(names, ", "), strings.Join(tuples, ", "))
+	return query, args
+}
+
+// normalizeValue converts values the database drivers cannot bind directly
+func (l *TableLoader) normalizeValue(value interface{}, dataType string) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	case bool:
+		if l.dialect == "oracle" {
+			if v {
+				return 1
+			}
+			return 0
+		}
+		return v
+	case time.Time:
+		return v
+	}
+
+	if dataType == "text" || dataType == "json" {
+		if _, ok := value.(string); !ok {
+			return fmt.Sprintf("%v", value)
+		}
+	}
+	return value
+}
+
+// WorkspaceSchema returns the internal database schema that holds a workspace's tables.
+// Ingested and pipeline tables never live next to the application's own tables.
+func WorkspaceSchema(workspaceID string) string {
+	id := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.ToLower(workspaceID))
+	return "ws_" + id
+}
+
+// InferColumnTypes derives column definitions from the Go values held by map rows
+func InferColumnTypes(columns []string, rows []map[string]interface{}) []TempTableColumn {
+	result := make([]TempTableColumn, len(columns))
+	for i, name := range columns {
+		dataType := ""
+		for _, row := range rows {
+			value, ok := row[name]
+			if !ok || value == nil {
+				continue
+			}
+			valueType := goValueType(value)
+			switch {
+			case dataType == "":
+				dataType = valueType
+			case dataType == valueType:
+			case (dataType == "integer" && valueType == "float") || (dataType == "float" && valueType == "integer"):
+				dataType = "float"
+			default:
+				dataType = "text"
+			}
+			if dataType == "text" {
+				break
+			}
+		}
+		if dataType == "" {
+			dataType = "text"
+		}
+		result[i] = TempTableColumn{Name: name, DataType: dataType, Nullable: true, Index: i}
+	}
+	return result
+}
+
+// goValueType maps a scanned or decoded Go value to an internal data type
+func goValueType(value interface{}) string {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	case float32:
+		return "float"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "float"
+	case bool:
+		return "boolean"
+	case time.Time:
+		return "timestamp"
+	case map[string]interface{}, []interface{}:
+		return "json"
+	default:
+		return "text"
+	}
+}
@@ -332,6 +332,35 @@ func (s *TempTableService) CleanupExpiredTables(ctx context.Context) (int, error
 	return droppedCount, nil
 }
 
+// FindTempTables returns a user's temp tables with the given display name and source
+func (s *TempTableService) FindTempTables(ctx context.Context, userID uuid.UUID, displayName string, source string) ([]TempTableMetadata, error) {
+	var tables []TempTableMetadata
+	err := s.db.Where("user_id = ? AND display_name = ? AND source = ?", userID, displayName, source).
+		Order("created_at DESC").
+		Find(&tables).Error
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// AppendRows inserts rows into an existing temp table and restarts its TTL
+func (s *TempTableService) AppendRows(ctx context.Context, metadata *TempTableMetadata, columns []TempTableColumn, rows [][]interface{}) error {
+	if err := s.insertData(metadata.TempTableName, columns, rows); err != nil {
+		return fmt.Errorf("failed to append data: %w", err)
+	}
+
+	metadata.RowCount += len(rows)
+	metadata.ExpiresAt = time.Now().Add(time.Duration(metadata.TTL) * time.Hour)
+
+	return s.db.Model(&TempTableMetadata{}).
+		Where("id = ?", metadata.ID).
+		Updates(map[string]interface{}{
+			"row_count":  metadata.RowCount,
+			"expires_at": metadata.ExpiresAt,
+		}).Error
+}
+
 // ExtendTTL extends the TTL of a temp table
 func (s *TempTableService) ExtendTTL(ctx context.Context, tableID uuid.UUID, userID uuid.UUID, additionalHours int) error {
 	metadata, err := s.GetTempTable(ctx, tableID, userID)
@@ -1,8 +1,11 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"sync"
+	"time"
 
 	"github.com/gofiber/websocket/v2"
 )
@@ -133,6 +136,41 @@ func (h *WebSocketHub) BroadcastToUser(userID string, messageType string, payloa
 	h.broadcast <- message
 }
 
+// ErrWebSocketClientGone is returned when sending to a client that has disconnected
+var ErrWebSocketClientGone = errors.New("websocket client disconnected")
+
+// SendToClient delivers a message to one connection, waiting while its send buffer is full
+// instead of dropping the message. Use it for streams such as query results, where every
+// message matters and the sender should slow down to the client's pace.
+func (h *WebSocketHub) SendToClient(ctx context.Context, client *WebSocketClient, messageType string, payload interface{}) error {
+	messageBytes, err := json.Marshal(&WebSocketMessage{Type: messageType, UserID: client.UserID, Payload: payload})
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Hold the read lock while sending so the client cannot be unregistered (closing Send) meanwhile
+		h.mu.RLock()
+		if !h.clients[client.UserID][client] {
+			h.mu.RUnlock()
+			return ErrWebSocketClientGone
+		}
+		select {
+		case client.Send <- messageBytes:
+			h.mu.RUnlock()
+			return nil
+		default:
+		}
+		h.mu.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
 // BroadcastToWorkspace sends a message to all users in a workspace
 func (h *WebSocketHub) BroadcastToWorkspace(workspaceID string, userIDs []string, messageType string, payload interface{}) {
 	for _, userID := range userIDs {
@@ -1,5 +1,6 @@
 import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
 import { semanticLayerApi } from '../lib/api/semantic-layer';
+import { useWorkspace } from '../contexts/workspace-context';
 import type {
     SemanticModel,
     SemanticMetric,
@@ -10,36 +11,48 @@ import type {
 
 // Query keys
 const QUERY_KEYS = {
-    models: ['semantic-models'] as const,
-    metrics: (modelId?: string) => ['semantic-metrics', modelId] as const,
+    models: (workspaceId?: string) => ['semantic-models', workspaceId] as const,
+    metrics: (workspaceId?: string, modelId?: string) => ['semantic-metrics', workspaceId, modelId] as const,
     query: (request: SemanticQueryRequest) => ['semantic-query', request] as const,
 };
 
 // Hooks
 
 export function useSemanticModels() {
+    const { workspace } = useWorkspace();
+
     return useQuery({
-        queryKey: QUERY_KEYS.models,
-        queryFn: semanticLayerApi.listModels,
+        queryKey: QUERY_KEYS.models(workspace?.id),
+        queryFn: () => semanticLayerApi.listModels(workspace!.id),
+        enabled: !!workspace,
     });
 }
 
 export function useCreateSemanticModel() {
     const queryClient = useQueryClient();
+    const { workspace } = useWorkspace();
 
     return useMutation({
-        mutationFn: (data: CreateSemanticModelRequest) => semanticLayerApi.createModel(data),
+        mutationFn: (data: CreateSemanticModelRequest) => {
+            if (!workspace) {
+                throw new Error('No workspace selected');
+            }
+            return semanticLayerApi.createModel(workspace.id, data);
+        },
         onSuccess: () => {
             // Invalidate models list to refetch
-            queryClient.invalidateQueries({ queryKey: QUERY_KEYS.models });
+            queryClient.invalidateQueries({ queryKey: QUERY_KEYS.models(workspace?.id) });
         },
     });
 }
 
 export function useSemanticMetrics(modelId?: string) {
+    const { workspace } = useWorkspace();
+
     return useQuery({
-        queryKey: QUERY_KEYS.metrics(modelId),
-        queryFn: () => semanticLayerApi.listMetrics(modelId),
+        queryKey: QUERY_KEYS.metrics(workspace?.id, modelId),
+        queryFn: () => semanticLayerApi.listMetrics(workspace?.id ?? '', modelId),
+        enabled: !!modelId || !!workspace,
     });
 }
 
@@ -10,8 +10,8 @@ const API_BASE = '/api/v1';
 
 export const semanticLayerApi = {
     // Models
-    listModels: async (): Promise<SemanticModel[]> => {
-        const res = await fetch(`${API_BASE}/semantic/models`, {
+    listModels: async (workspaceId: string): Promise<SemanticModel[]> => {
+        const res = await fetch(`${API_BASE}/semantic/models?workspaceId=${encodeURIComponent(workspaceId)}`, {
             headers: {
                 'Content-Type': 'application/json',
             },
@@ -26,14 +26,14 @@ export const semanticLayerApi = {
         return res.json();
     },
 
-    createModel: async (data: CreateSemanticModelRequest): Promise<SemanticModel> => {
+    createModel: async (workspaceId: string, data: CreateSemanticModelRequest): Promise<SemanticModel> => {
         const res = await fetch(`${API_BASE}/semantic/models`, {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
             },
             credentials: 'include',
-            body: JSON.stringify(data),
+            body: JSON.stringify({ ...data, workspaceId }),
         });
 
         if (!res.ok) {
@@ -45,10 +45,10 @@ export const semanticLayerApi = {
     },
 
     // Metrics
-    listMetrics: async (modelId?: string): Promise<SemanticMetric[]> => {
+    listMetrics: async (workspaceId: string, modelId?: string): Promise<SemanticMetric[]> => {
         const url = modelId
             ? `${API_BASE}/semantic/metrics?modelId=${encodeURIComponent(modelId)}`
-            : `${API_BASE}/semantic/metrics`;
+            : `${API_BASE}/semantic/metrics?workspaceId=${encodeURIComponent(workspaceId)}`;
 
         const res = await fetch(url, {
             headers: {
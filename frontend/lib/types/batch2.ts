@@ -46,8 +46,24 @@ export interface QualityRule {
     createdAt: Date;
 }
 
+export interface QualityCheckResult {
+    id: string;
+    pipelineId: string;
+    executionId: string;
+    ruleId: string;
+    column: string;
+    ruleType: string;
+    severity: 'WARN' | 'FAIL';
+    rowsChecked: number;
+    rowsPassed: number;
+    rowsFailed: number;
+    passed: boolean;
+    createdAt: Date;
+}
+
 export interface PipelineWithRules extends Pipeline {
     qualityRules: QualityRule[];
+    latestQualityResults?: QualityCheckResult[]; // Latest run's per-rule results
 }
 
 export interface PipelineStats {